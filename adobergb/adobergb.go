@@ -14,6 +14,21 @@ var PrimaryGreen = ciexyy.Color{X: 0.21, Y: 0.71, YY: 1}
 var PrimaryBlue = ciexyy.Color{X: 0.15, Y: 0.06, YY: 1}
 var StandardWhitePoint = ciexyy.D65
 
+// ConvertImageAlphaAssociation converts an image with Adobe RGB encoded colour
+// from one alpha association convention to another.
+//
+// src is the encoded image to be converted.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func ConvertImageAlphaAssociation(dst draw.Image, src image.Image, from, to linear.AlphaAssociation, parallelism int) {
+	linear.ConvertImageAlphaAssociation(dst, src, from, to, parallelism, From16Bit, To16Bit)
+}
+
 // EncodeColor converts a linear colour value to an Adobe RGB encoded one.
 func EncodeColor(c color.Color) color.RGBA64 {
 	col, alpha := ColorFromLinearColor(c)
@@ -35,6 +50,43 @@ func EncodeImage(dst draw.Image, src image.Image, parallelism int) {
 	linear.TransformImageColor(dst, src, parallelism, EncodeColor)
 }
 
+// EncodeImageAssociated converts an image with linear colour into an Adobe RGB
+// encoded one, with the encoded colour values associated with alpha using the
+// specified convention.
+//
+// src is the linearised image to be encoded.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func EncodeImageAssociated(dst draw.Image, src image.Image, assoc linear.AlphaAssociation, parallelism int) {
+	linear.TransformImageColor(dst, src, parallelism, func(c color.Color) color.RGBA64 {
+		col, alpha := ColorFromLinearColor(c)
+		return col.ToRGBA64Associated(alpha, assoc)
+	})
+}
+
+// EncodeImageDithered converts an image with linear colour into an Adobe RGB
+// encoded one, dithering the encoded values when quantising them for an 8-bit
+// destination image (ie an *image.NRGBA or *image.RGBA). This avoids the
+// banding that can otherwise be visible in smooth gradients.
+//
+// src is the linearised image to be encoded.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads. Error diffusion
+// dithering is not parallelised.
+func EncodeImageDithered(dst draw.Image, src image.Image, dithering linear.Dithering, parallelism int) {
+	linear.EncodeImageDithered(dst, src, dithering, parallelism, To16Bit)
+}
+
 func encodedToLinear(v float32) float32 {
 	return float32(math.Pow(float64(v), 563.0/256))
 }
@@ -60,6 +112,25 @@ func LineariseImage(dst draw.Image, src image.Image, parallelism int) {
 	linear.TransformImageColor(dst, src, parallelism, LineariseColor)
 }
 
+// LineariseImageAssociated converts an image with Adobe RGB encoded colour,
+// whose colour values are associated with alpha using the specified convention,
+// to linear colour.
+//
+// src is the encoded image to be linearised.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func LineariseImageAssociated(dst draw.Image, src image.Image, assoc linear.AlphaAssociation, parallelism int) {
+	linear.TransformImageColor(dst, src, parallelism, func(c color.Color) color.RGBA64 {
+		col, alpha := ColorFromEncodedColorAssociated(c, assoc)
+		return col.ToLinearRGBA64(alpha)
+	})
+}
+
 func linearToEncoded(v float32) float32 {
 	return float32(math.Pow(float64(v), 256.0/563))
 }
@@ -0,0 +1,7 @@
+// Package cmyk provides support for converting CMYK colour to and from RGB
+// colour spaces using the lookup tables of ICC output profiles, such as those
+// for SWOP or FOGRA printing conditions.
+//
+// CMYK values are represented as color.CMYK, where 0 indicates no ink and 255
+// full coverage.
+package cmyk
@@ -0,0 +1,68 @@
+package cmyk
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/colorprofile"
+	"github.com/mandykoh/prism/colorspace"
+	"image"
+	"image/draw"
+)
+
+// ConvertImageFromRGB converts an image encoded in the specified RGB colour
+// space to CMYK using this profile and its rendering intent. Alpha is
+// discarded.
+//
+// src is the encoded RGB image to be converted.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func (p *Profile) ConvertImageFromRGB(dst *image.CMYK, src image.Image, space colorspace.RGB, parallelism int) {
+	bounds := src.Bounds()
+	dstOffsetX := dst.Rect.Min.X - bounds.Min.X
+	dstOffsetY := dst.Rect.Min.Y - bounds.Min.Y
+
+	read := space.LinearPixelReader(src)
+	toXYZ := colorprofile.TransformRGBToXYZ(space, p.Intent)
+	bpc := p.fromRGBCompensation(space)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				c, _ := read(j, i)
+				dst.SetCMYK(j+dstOffsetX, i+dstOffsetY, p.FromXYZ(bpc.Apply(ciexyz.ColorFromV(toXYZ.MulV(rgbToV(c))))))
+			}
+		}
+	})
+}
+
+// ConvertImageToRGB converts a CMYK image to the specified RGB colour space
+// using this profile and its rendering intent. Colours outside the gamut of
+// the RGB colour space are clipped.
+//
+// src is the CMYK image to be converted.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func (p *Profile) ConvertImageToRGB(dst draw.Image, src *image.CMYK, space colorspace.RGB, parallelism int) {
+	bounds := src.Rect
+	dstOffsetX := dst.Bounds().Min.X - bounds.Min.X
+	dstOffsetY := dst.Bounds().Min.Y - bounds.Min.Y
+
+	write := space.EncodedPixelWriter(dst)
+	fromXYZ := colorprofile.TransformXYZToRGB(space, p.Intent)
+	bpc := p.toRGBCompensation(space)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				xyz := bpc.Apply(p.ToXYZ(src.CMYKAt(j, i)))
+				write(j+dstOffsetX, i+dstOffsetY, rgbFromV(fromXYZ.MulV(xyz.ToV())), 1)
+			}
+		}
+	})
+}
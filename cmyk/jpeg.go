@@ -0,0 +1,45 @@
+package cmyk
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/meta/jpegmeta"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// DecodeJPEG decodes a 4-component (CMYK or YCCK) JPEG image, along with the
+// CMYK profile embedded in it. If the image has no embedded profile, profile
+// is nil.
+//
+// Adobe applications write CMYK JPEG data with inverted values, as indicated
+// by an Adobe APP14 segment. This inversion is undone when decoding, so that
+// the returned image represents no ink as 0 like any other image.CMYK.
+func DecodeJPEG(r io.Reader) (img *image.CMYK, profile *Profile, err error) {
+	md, imgStream, err := jpegmeta.Load(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoded, err := jpeg.Decode(imgStream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, ok := decoded.(*image.CMYK)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected CMYK image but got %v", md.ColorModel)
+	}
+
+	iccProfile, err := md.ICCProfile()
+	if err != nil {
+		return nil, nil, err
+	}
+	if iccProfile != nil {
+		if profile, err = NewProfile(iccProfile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return img, profile, nil
+}
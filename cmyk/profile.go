@@ -0,0 +1,135 @@
+package cmyk
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/colorprofile"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+	"github.com/mandykoh/prism/matrix"
+	"github.com/mandykoh/prism/meta/icc"
+	"image/color"
+)
+
+// Profile converts colours between a CMYK colour space described by an ICC
+// profile and CIE XYZ, via the profile's 'A2Bx' and 'B2Ax' lookup tables.
+//
+// A Profile is safe for concurrent use, provided that Intent and
+// BlackPointCompensation aren't modified while conversions are in progress.
+type Profile struct {
+	// Intent is the rendering intent used for conversions, which selects the
+	// lookup tables used. The zero value is the perceptual intent.
+	Intent icc.RenderingIntent
+
+	// BlackPointCompensation enables mapping of black between the CMYK and
+	// RGB colour spaces in RGB conversions, preserving shadow detail (see
+	// colorprofile.BlackPointCompensation). This has no effect for the
+	// absolute colorimetric intent.
+	BlackPointCompensation bool
+
+	profile *colorprofile.Profile
+}
+
+// NewProfile creates a Profile from an ICC profile with a CMYK data colour
+// space, such as an output profile for a printing condition.
+//
+// An error is returned if the profile doesn't describe CMYK colour, or lacks
+// the lookup tables needed to convert in both directions.
+func NewProfile(profile *icc.Profile) (*Profile, error) {
+	if cs := profile.Header.DataColorSpace; cs != icc.ColorSpaceCMYK {
+		return nil, fmt.Errorf("expected CMYK profile but got %v", cs)
+	}
+
+	p, err := colorprofile.NewProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if p.Channels() != 4 {
+		return nil, fmt.Errorf("expected profile with 4 channels but got %d", p.Channels())
+	}
+	if !p.SupportsFromXYZ() {
+		return nil, fmt.Errorf("profile has no %v tag", icc.BToA0Signature)
+	}
+
+	return &Profile{profile: p}, nil
+}
+
+// ColorProfile returns the underlying colour profile, for use in conversions
+// with other profiles (see colorprofile.NewTransform).
+func (p *Profile) ColorProfile() *colorprofile.Profile {
+	return p.profile
+}
+
+// FromRGB converts a linear colour in the specified RGB colour space to CMYK.
+//
+// For converting many colours, ConvertImageFromRGB is more efficient.
+func (p *Profile) FromRGB(c linear.RGB, space colorspace.RGB) color.CMYK {
+	bpc := p.fromRGBCompensation(space)
+	return p.FromXYZ(bpc.Apply(ciexyz.ColorFromV(colorprofile.TransformRGBToXYZ(space, p.Intent).MulV(rgbToV(c)))))
+}
+
+// FromXYZ converts a CIE XYZ colour to CMYK. The colour is interpreted
+// according to the rendering intent, as for ToXYZ.
+func (p *Profile) FromXYZ(c ciexyz.Color) color.CMYK {
+	var cmyk [4]float64
+	p.profile.FromXYZ(cmyk[:], c, p.Intent)
+
+	return color.CMYK{
+		C: linear.NormalisedTo8Bit(float32(cmyk[0])),
+		M: linear.NormalisedTo8Bit(float32(cmyk[1])),
+		Y: linear.NormalisedTo8Bit(float32(cmyk[2])),
+		K: linear.NormalisedTo8Bit(float32(cmyk[3])),
+	}
+}
+
+// ToRGB converts a CMYK colour to a linear colour in the specified RGB colour
+// space. The result may lie outside the gamut of the RGB colour space, in
+// which case components may be negative or greater than 1.0.
+//
+// For converting many colours, ConvertImageToRGB is more efficient.
+func (p *Profile) ToRGB(c color.CMYK, space colorspace.RGB) linear.RGB {
+	bpc := p.toRGBCompensation(space)
+	return rgbFromV(colorprofile.TransformXYZToRGB(space, p.Intent).MulV(bpc.Apply(p.ToXYZ(c)).ToV()))
+}
+
+// ToXYZ converts a CMYK colour to CIE XYZ. For the absolute colorimetric
+// intent, the result is the colour as printed on the paper. For other intents,
+// it is relative to the D50 illuminant of the profile connection space, such
+// that unprinted paper corresponds to D50.
+func (p *Profile) ToXYZ(c color.CMYK) ciexyz.Color {
+	cmyk := [4]float64{
+		float64(c.C) / 255,
+		float64(c.M) / 255,
+		float64(c.Y) / 255,
+		float64(c.K) / 255,
+	}
+	return p.profile.ToXYZ(cmyk[:], p.Intent)
+}
+
+// fromRGBCompensation returns the black point compensation for conversions
+// from the specified RGB colour space, which leaves colours unchanged if black
+// point compensation is disabled.
+func (p *Profile) fromRGBCompensation(space colorspace.RGB) colorprofile.BlackPointCompensation {
+	if !p.BlackPointCompensation {
+		return colorprofile.BlackPointCompensation{}
+	}
+	return colorprofile.NewBlackPointCompensation(colorprofile.NewRGBProfile(space), p.profile, p.Intent)
+}
+
+// toRGBCompensation returns the black point compensation for conversions to
+// the specified RGB colour space, which leaves colours unchanged if black
+// point compensation is disabled.
+func (p *Profile) toRGBCompensation(space colorspace.RGB) colorprofile.BlackPointCompensation {
+	if !p.BlackPointCompensation {
+		return colorprofile.BlackPointCompensation{}
+	}
+	return colorprofile.NewBlackPointCompensation(p.profile, colorprofile.NewRGBProfile(space), p.Intent)
+}
+
+func rgbFromV(v matrix.Vector3) linear.RGB {
+	return linear.RGB{R: float32(v[0]), G: float32(v[1]), B: float32(v[2])}
+}
+
+func rgbToV(c linear.RGB) matrix.Vector3 {
+	return matrix.Vector3{float64(c.R), float64(c.G), float64(c.B)}
+}
@@ -0,0 +1,186 @@
+package cmyk
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+	"github.com/mandykoh/prism/meta/icc"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"testing"
+)
+
+func loadTestImage(t *testing.T) (*image.CMYK, *Profile) {
+	t.Helper()
+
+	f, err := os.Open("../test-images/pizza-cmyk8-usswop.jpg")
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	defer f.Close()
+
+	img, profile, err := DecodeJPEG(f)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	if profile == nil {
+		t.Fatalf("Expected embedded profile but got none")
+	}
+	return img, profile
+}
+
+func TestProfile(t *testing.T) {
+	_, profile := loadTestImage(t)
+
+	t.Run("NewProfile()", func(t *testing.T) {
+
+		t.Run("rejects profiles which aren't CMYK", func(t *testing.T) {
+			data, err := os.ReadFile("../test-profiles/display-p3-v4-with-v2-desc.icc")
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+			rgbProfile, err := icc.NewProfileReader(bytes.NewReader(data)).ReadProfile()
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			if _, err := NewProfile(rgbProfile); err == nil {
+				t.Errorf("Expected error for RGB profile but got none")
+			}
+		})
+	})
+
+	t.Run("ToRGB()", func(t *testing.T) {
+
+		t.Run("maps unprinted paper to white", func(t *testing.T) {
+			c := profile.ToRGB(color.CMYK{}, colorspace.SRGB)
+
+			if c.R < 0.9 || c.G < 0.9 || c.B < 0.9 {
+				t.Errorf("Expected near white but got %+v", c)
+			}
+		})
+
+		t.Run("maps full ink coverage to black", func(t *testing.T) {
+			c := profile.ToRGB(color.CMYK{C: 255, M: 255, Y: 255, K: 255}, colorspace.SRGB)
+
+			if c.R > 0.02 || c.G > 0.02 || c.B > 0.02 {
+				t.Errorf("Expected near black but got %+v", c)
+			}
+		})
+
+		t.Run("maps process cyan to a blue-green", func(t *testing.T) {
+			c := profile.ToRGB(color.CMYK{C: 255}, colorspace.SRGB)
+
+			if c.R > 0.05 || c.G < 0.1 || c.B < 0.4 {
+				t.Errorf("Expected cyan but got %+v", c)
+			}
+		})
+	})
+
+	t.Run("Intent", func(t *testing.T) {
+
+		t.Run("simulates paper white for absolute colorimetric intent", func(t *testing.T) {
+			_, absolute := loadTestImage(t)
+			absolute.Intent = icc.AbsoluteColorimetricRenderingIntent
+
+			c := absolute.ToRGB(color.CMYK{}, colorspace.SRGB)
+
+			if c.G > 0.9 || c.B >= c.R {
+				t.Errorf("Expected darker, yellowish paper but got %+v", c)
+			}
+		})
+	})
+
+	t.Run("BlackPointCompensation", func(t *testing.T) {
+
+		t.Run("maps the black of the paper to the black of the RGB colour space", func(t *testing.T) {
+			_, relative := loadTestImage(t)
+			relative.Intent = icc.RelativeColorimetricRenderingIntent
+			_, compensated := loadTestImage(t)
+			compensated.Intent = icc.RelativeColorimetricRenderingIntent
+			compensated.BlackPointCompensation = true
+
+			black := color.CMYK{C: 255, M: 255, Y: 255, K: 255}
+			uncompensatedBlack := relative.ToRGB(black, colorspace.SRGB)
+			compensatedBlack := compensated.ToRGB(black, colorspace.SRGB)
+
+			if compensatedBlack.G > 0.002 || compensatedBlack.G >= uncompensatedBlack.G {
+				t.Errorf("Expected black darker than %+v but got %+v", uncompensatedBlack, compensatedBlack)
+			}
+		})
+
+		t.Run("maps white to no ink", func(t *testing.T) {
+			_, compensated := loadTestImage(t)
+			compensated.Intent = icc.RelativeColorimetricRenderingIntent
+			compensated.BlackPointCompensation = true
+
+			c := compensated.FromRGB(linear.RGB{R: 1, G: 1, B: 1}, colorspace.SRGB)
+
+			if c.C > 2 || c.M > 2 || c.Y > 2 || c.K > 2 {
+				t.Errorf("Expected no ink but got %+v", c)
+			}
+		})
+	})
+
+	t.Run("FromRGB()", func(t *testing.T) {
+
+		t.Run("round trips in-gamut colours", func(t *testing.T) {
+			for _, expected := range []linear.RGB{
+				{R: 0.5, G: 0.2, B: 0.1},
+				{R: 0.1, G: 0.3, B: 0.2},
+				{R: 0.2, G: 0.2, B: 0.2},
+			} {
+				actual := profile.ToRGB(profile.FromRGB(expected, colorspace.SRGB), colorspace.SRGB)
+
+				if math.Abs(float64(expected.R-actual.R)) > 0.03 ||
+					math.Abs(float64(expected.G-actual.G)) > 0.03 ||
+					math.Abs(float64(expected.B-actual.B)) > 0.03 {
+					t.Errorf("Expected %+v but got %+v", expected, actual)
+				}
+			}
+		})
+
+		t.Run("maps white to no ink", func(t *testing.T) {
+			c := profile.FromRGB(linear.RGB{R: 1, G: 1, B: 1}, colorspace.SRGB)
+
+			if c.C > 5 || c.M > 5 || c.Y > 5 || c.K > 5 {
+				t.Errorf("Expected no ink but got %+v", c)
+			}
+		})
+	})
+
+	t.Run("ConvertImageToRGB()", func(t *testing.T) {
+		img, _ := loadTestImage(t)
+
+		result := image.NewNRGBA(img.Rect)
+		profile.ConvertImageToRGB(result, img, colorspace.SRGB, 4)
+
+		for _, p := range []image.Point{{0, 0}, {img.Rect.Dx() / 2, img.Rect.Dy() / 2}, {img.Rect.Dx() - 1, img.Rect.Dy() - 1}} {
+			expected := profile.ToRGB(img.CMYKAt(p.X, p.Y), colorspace.SRGB).ToEncodedNRGBA(1, colorspace.SRGB.To8Bit)
+
+			if actual := result.NRGBAAt(p.X, p.Y); expected != actual {
+				t.Errorf("Expected %+v at %v but got %+v", expected, p, actual)
+			}
+		}
+	})
+
+	t.Run("ConvertImageFromRGB()", func(t *testing.T) {
+		src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+		src.SetNRGBA(1, 1, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+
+		result := image.NewCMYK(image.Rect(10, 10, 12, 12))
+		profile.ConvertImageFromRGB(result, src, colorspace.SRGB, 2)
+
+		expected := profile.FromRGB(linear.RGB{
+			R: colorspace.SRGB.From8Bit(200),
+			G: colorspace.SRGB.From8Bit(100),
+			B: colorspace.SRGB.From8Bit(50),
+		}, colorspace.SRGB)
+
+		if actual := result.CMYKAt(11, 11); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package colorlut
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Cube is the contents of an Adobe/Resolve .cube file, consisting of a 1D LUT,
+// a 3D LUT, or both. When both are present, the 1D LUT is applied first as a
+// shaper for the 3D LUT.
+type Cube struct {
+	Title string
+	LUT1D *LUT1D
+	LUT3D *LUT3D
+}
+
+// Apply returns the result of looking up the specified colour in the LUTs of
+// this cube.
+func (c *Cube) Apply(col [3]float32) [3]float32 {
+	if c.LUT1D != nil {
+		col = c.LUT1D.Apply(col)
+	}
+	if c.LUT3D != nil {
+		col = c.LUT3D.Apply(col)
+	}
+	return col
+}
+
+// ReadCube parses a .cube file from the specified reader.
+//
+// Both the Adobe Cube LUT Specification (with DOMAIN_MIN and DOMAIN_MAX) and
+// the Resolve variant (with LUT_1D_INPUT_RANGE and LUT_3D_INPUT_RANGE, and
+// optionally both a 1D and a 3D table) are supported.
+func ReadCube(r io.Reader) (*Cube, error) {
+	cube := &Cube{}
+
+	size1D := 0
+	size3D := 0
+	domainMin := [3]float32{0, 0, 0}
+	domainMax := [3]float32{1, 1, 1}
+	var range1D, range3D *[2]float32
+	var entries [][3]float32
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if isNumeric(line[0]) {
+			values, err := parseFloats(line, 3)
+			if err != nil {
+				return nil, fmt.Errorf("invalid table entry on line %d: %v", lineNum, err)
+			}
+			entries = append(entries, [3]float32{values[0], values[1], values[2]})
+			continue
+		}
+
+		if len(entries) > 0 {
+			return nil, fmt.Errorf("unexpected keyword after table data on line %d", lineNum)
+		}
+
+		fields := strings.Fields(line)
+		keyword := fields[0]
+		args := strings.TrimSpace(line[len(keyword):])
+
+		var err error
+
+		switch keyword {
+
+		case "TITLE":
+			cube.Title, err = parseTitle(args)
+
+		case "LUT_1D_SIZE":
+			size1D, err = parseSize(args, 2, 65536)
+
+		case "LUT_3D_SIZE":
+			size3D, err = parseSize(args, 2, 256)
+
+		case "DOMAIN_MIN":
+			err = parseTriple(args, &domainMin)
+
+		case "DOMAIN_MAX":
+			err = parseTriple(args, &domainMax)
+
+		case "LUT_1D_INPUT_RANGE":
+			range1D, err = parseRange(args)
+
+		case "LUT_3D_INPUT_RANGE":
+			range3D, err = parseRange(args)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s on line %d: %v", keyword, lineNum, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if size1D == 0 && size3D == 0 {
+		return nil, fmt.Errorf("missing LUT_1D_SIZE or LUT_3D_SIZE")
+	}
+
+	if expected := size1D + size3D*size3D*size3D; len(entries) != expected {
+		return nil, fmt.Errorf("expected %d table entries but found %d", expected, len(entries))
+	}
+
+	for ch := 0; ch < 3; ch++ {
+		if domainMin[ch] >= domainMax[ch] {
+			return nil, fmt.Errorf("DOMAIN_MIN must be less than DOMAIN_MAX")
+		}
+	}
+
+	if size1D > 0 {
+		cube.LUT1D = &LUT1D{
+			Title:     cube.Title,
+			DomainMin: domainMin,
+			DomainMax: domainMax,
+			Table:     entries[:size1D],
+		}
+		if range1D != nil {
+			cube.LUT1D.DomainMin = [3]float32{range1D[0], range1D[0], range1D[0]}
+			cube.LUT1D.DomainMax = [3]float32{range1D[1], range1D[1], range1D[1]}
+		}
+	}
+
+	if size3D > 0 {
+		cube.LUT3D = &LUT3D{
+			Title:     cube.Title,
+			Size:      size3D,
+			DomainMin: domainMin,
+			DomainMax: domainMax,
+			Table:     entries[size1D:],
+		}
+		if range3D != nil {
+			cube.LUT3D.DomainMin = [3]float32{range3D[0], range3D[0], range3D[0]}
+			cube.LUT3D.DomainMax = [3]float32{range3D[1], range3D[1], range3D[1]}
+		}
+	}
+
+	return cube, nil
+}
+
+// WriteCube writes a cube to the specified writer in .cube format.
+//
+// If the cube contains only one LUT, its domain is written using DOMAIN_MIN and
+// DOMAIN_MAX. If it contains both a 1D and 3D LUT, the Resolve format is used
+// and the domain of each LUT must be the same for all components.
+func WriteCube(w io.Writer, c *Cube) error {
+	if c.LUT1D == nil && c.LUT3D == nil {
+		return fmt.Errorf("cube contains no LUTs")
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if c.Title != "" {
+		fmt.Fprintf(bw, "TITLE \"%s\"\n", strings.ReplaceAll(c.Title, "\"", "'"))
+	}
+
+	if c.LUT1D != nil {
+		fmt.Fprintf(bw, "LUT_1D_SIZE %d\n", len(c.LUT1D.Table))
+	}
+	if c.LUT3D != nil {
+		if len(c.LUT3D.Table) != c.LUT3D.Size*c.LUT3D.Size*c.LUT3D.Size {
+			return fmt.Errorf("3D LUT of size %d has %d table entries", c.LUT3D.Size, len(c.LUT3D.Table))
+		}
+		fmt.Fprintf(bw, "LUT_3D_SIZE %d\n", c.LUT3D.Size)
+	}
+
+	if c.LUT1D != nil && c.LUT3D != nil {
+		for _, d := range []struct {
+			keyword  string
+			min, max [3]float32
+		}{
+			{"LUT_1D_INPUT_RANGE", c.LUT1D.DomainMin, c.LUT1D.DomainMax},
+			{"LUT_3D_INPUT_RANGE", c.LUT3D.DomainMin, c.LUT3D.DomainMax},
+		} {
+			if d.min[0] != d.min[1] || d.min[0] != d.min[2] || d.max[0] != d.max[1] || d.max[0] != d.max[2] {
+				return fmt.Errorf("domain must be the same for all components when writing both 1D and 3D LUTs")
+			}
+			fmt.Fprintf(bw, "%s %s %s\n", d.keyword, formatFloat(d.min[0]), formatFloat(d.max[0]))
+		}
+
+	} else {
+		domainMin, domainMax := [3]float32{}, [3]float32{}
+		if c.LUT1D != nil {
+			domainMin, domainMax = c.LUT1D.DomainMin, c.LUT1D.DomainMax
+		} else {
+			domainMin, domainMax = c.LUT3D.DomainMin, c.LUT3D.DomainMax
+		}
+		fmt.Fprintf(bw, "DOMAIN_MIN %s\n", formatTriple(domainMin))
+		fmt.Fprintf(bw, "DOMAIN_MAX %s\n", formatTriple(domainMax))
+	}
+
+	bw.WriteString("\n")
+
+	if c.LUT1D != nil {
+		for _, v := range c.LUT1D.Table {
+			fmt.Fprintf(bw, "%s\n", formatTriple(v))
+		}
+	}
+	if c.LUT3D != nil {
+		for _, v := range c.LUT3D.Table {
+			fmt.Fprintf(bw, "%s\n", formatTriple(v))
+		}
+	}
+
+	return bw.Flush()
+}
+
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+func formatTriple(v [3]float32) string {
+	return formatFloat(v[0]) + " " + formatFloat(v[1]) + " " + formatFloat(v[2])
+}
+
+func isNumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.'
+}
+
+func parseFloats(s string, count int) ([]float32, error) {
+	fields := strings.Fields(s)
+	if len(fields) != count {
+		return nil, fmt.Errorf("expected %d values but found %d", count, len(fields))
+	}
+
+	values := make([]float32, count)
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+func parseRange(s string) (*[2]float32, error) {
+	values, err := parseFloats(s, 2)
+	if err != nil {
+		return nil, err
+	}
+	if values[0] >= values[1] {
+		return nil, fmt.Errorf("minimum must be less than maximum")
+	}
+	return &[2]float32{values[0], values[1]}, nil
+}
+
+func parseSize(s string, min, max int) (int, error) {
+	size, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if size < min || size > max {
+		return 0, fmt.Errorf("size %d is outside the range %d–%d", size, min, max)
+	}
+	return size, nil
+}
+
+func parseTitle(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("title must be enclosed in double quotes")
+	}
+	return s[1 : len(s)-1], nil
+}
+
+func parseTriple(s string, v *[3]float32) error {
+	values, err := parseFloats(s, 3)
+	if err != nil {
+		return err
+	}
+	copy(v[:], values)
+	return nil
+}
@@ -0,0 +1,127 @@
+package colorlut
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCube(t *testing.T) {
+
+	t.Run("reads a 3D LUT", func(t *testing.T) {
+		cube, err := ReadCube(strings.NewReader(`# Comment
+TITLE "Test LUT"
+LUT_3D_SIZE 2
+DOMAIN_MIN 0 0 0
+DOMAIN_MAX 1 2 4
+
+0 0 0
+1 0 0
+0 1 0
+1 1 0
+0 0 1
+1 0 1
+0 1 1
+1 1 1
+`))
+		if err != nil {
+			t.Fatalf("Expected success but got %v", err)
+		}
+
+		if cube.LUT1D != nil {
+			t.Errorf("Expected no 1D LUT but got one")
+		}
+		if expected, actual := "Test LUT", cube.Title; expected != actual {
+			t.Errorf("Expected title '%s' but got '%s'", expected, actual)
+		}
+		if expected, actual := ([3]float32{1, 2, 4}), cube.LUT3D.DomainMax; expected != actual {
+			t.Errorf("Expected domain max %v but got %v", expected, actual)
+		}
+		if expected, actual := ([3]float32{1, 0.5, 0.25}), cube.Apply([3]float32{1, 1, 1}); expected != actual {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("reads combined 1D and 3D LUTs", func(t *testing.T) {
+		cube, err := ReadCube(strings.NewReader(`LUT_1D_SIZE 2
+LUT_3D_SIZE 2
+LUT_1D_INPUT_RANGE 0 2
+LUT_3D_INPUT_RANGE 0 1
+0 0 0
+1 1 1
+0 0 0
+1 0 0
+0 1 0
+1 1 0
+0 0 1
+1 0 1
+0 1 1
+1 1 1
+`))
+		if err != nil {
+			t.Fatalf("Expected success but got %v", err)
+		}
+
+		if expected, actual := ([3]float32{2, 2, 2}), cube.LUT1D.DomainMax; expected != actual {
+			t.Errorf("Expected 1D domain max %v but got %v", expected, actual)
+		}
+		if expected, actual := ([3]float32{0.5, 0.25, 0}), cube.Apply([3]float32{1, 0.5, 0}); expected != actual {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("returns an error when the number of entries is wrong", func(t *testing.T) {
+		_, err := ReadCube(strings.NewReader("LUT_1D_SIZE 3\n0 0 0\n1 1 1\n"))
+
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("returns an error for invalid entries", func(t *testing.T) {
+		_, err := ReadCube(strings.NewReader("LUT_1D_SIZE 2\n0 0\n1 1 1\n"))
+
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
+
+func TestWriteCube(t *testing.T) {
+
+	t.Run("round trips LUTs", func(t *testing.T) {
+		shaper := NewLUT1D(4)
+		shaper.DomainMax = [3]float32{2, 2, 2}
+
+		for _, cube := range []*Cube{
+			{Title: "3D only", LUT3D: Bake(3, func(c [3]float32) [3]float32 { return [3]float32{c[2], c[0] * 0.3, c[1]} })},
+			{Title: "1D only", LUT1D: shaper},
+			{Title: "Both", LUT1D: shaper, LUT3D: NewLUT3D(5)},
+		} {
+			buf := &bytes.Buffer{}
+			err := WriteCube(buf, cube)
+			if err != nil {
+				t.Fatalf("Expected success but got %v", err)
+			}
+
+			result, err := ReadCube(buf)
+			if err != nil {
+				t.Fatalf("Expected success but got %v", err)
+			}
+
+			if expected, actual := cube.Title, result.Title; expected != actual {
+				t.Errorf("Expected title '%s' but got '%s'", expected, actual)
+			}
+			if cube.LUT1D != nil && !reflect.DeepEqual(cube.LUT1D.Table, result.LUT1D.Table) {
+				t.Errorf("Expected 1D table %v but got %v", cube.LUT1D.Table, result.LUT1D.Table)
+			}
+			if cube.LUT1D != nil && cube.LUT1D.DomainMax != result.LUT1D.DomainMax {
+				t.Errorf("Expected 1D domain max %v but got %v", cube.LUT1D.DomainMax, result.LUT1D.DomainMax)
+			}
+			if cube.LUT3D != nil && !reflect.DeepEqual(cube.LUT3D.Table, result.LUT3D.Table) {
+				t.Errorf("Expected 3D table %v but got %v", cube.LUT3D.Table, result.LUT3D.Table)
+			}
+		}
+	})
+}
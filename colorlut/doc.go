@@ -0,0 +1,8 @@
+// Package colorlut provides support for colour lookup tables (LUTs), including
+// reading and writing them as Adobe/Resolve .cube files and baking them from
+// colour conversions.
+//
+// LUTs operate on normalised colour values, which are usually (but not
+// necessarily) encoded rather than linear. Colour values are represented as
+// [3]float32 arrays of red, green, and blue components.
+package colorlut
@@ -0,0 +1,29 @@
+package colorlut
+
+import "fmt"
+
+// Interpolation specifies the method used to interpolate between the entries
+// of a 3D LUT.
+type Interpolation int
+
+const (
+	// TrilinearInterpolation interpolates between the eight entries
+	// surrounding a colour.
+	TrilinearInterpolation Interpolation = iota
+
+	// TetrahedralInterpolation interpolates between the four entries of the
+	// tetrahedron containing a colour. This is faster than trilinear
+	// interpolation and better preserves neutral colours.
+	TetrahedralInterpolation
+)
+
+func (i Interpolation) String() string {
+	switch i {
+	case TrilinearInterpolation:
+		return "Trilinear"
+	case TetrahedralInterpolation:
+		return "Tetrahedral"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(i))
+	}
+}
@@ -0,0 +1,86 @@
+package colorlut
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism/linear"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// LUT is a colour lookup table which maps normalised colour values to new
+// ones.
+type LUT interface {
+
+	// Apply returns the result of looking up the specified colour.
+	Apply(c [3]float32) [3]float32
+}
+
+// ApplyImage applies a LUT to the colour values of an image. Colour values are
+// normalised to 0.0–1.0 directly as encoded, and alpha is preserved.
+//
+// src is the image whose colour values are to be mapped.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func ApplyImage(dst draw.Image, src image.Image, lut LUT, parallelism int) {
+	bounds := src.Bounds()
+	dstOffsetX := dst.Bounds().Min.X - bounds.Min.X
+	dstOffsetY := dst.Bounds().Min.Y - bounds.Min.Y
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				c := color.NRGBA64Model.Convert(src.At(j, i)).(color.NRGBA64)
+
+				result := lut.Apply([3]float32{
+					float32(c.R) / 65535,
+					float32(c.G) / 65535,
+					float32(c.B) / 65535,
+				})
+
+				dst.Set(j+dstOffsetX, i+dstOffsetY, color.NRGBA64{
+					R: linear.NormalisedTo16Bit(result[0]),
+					G: linear.NormalisedTo16Bit(result[1]),
+					B: linear.NormalisedTo16Bit(result[2]),
+					A: c.A,
+				})
+			}
+		}
+	})
+}
+
+// domainPosition maps a value within the specified domain to a (fractional)
+// position in a table of the specified size, clamping to the bounds of the
+// table.
+func domainPosition(v, min, max float32, size int) float32 {
+	if max == min {
+		return 0
+	}
+
+	p := (v - min) / (max - min) * float32(size-1)
+	if p <= 0 {
+		return 0
+	}
+	if p >= float32(size-1) {
+		return float32(size - 1)
+	}
+	return p
+}
+
+// split separates a table position into the index of the entry preceding it,
+// and the fractional distance from it to the next entry.
+func split(p float32, size int) (index int, fraction float32) {
+	index = int(p)
+	if index >= size-1 {
+		index = size - 2
+	}
+	if index < 0 {
+		return 0, 0
+	}
+	return index, p - float32(index)
+}
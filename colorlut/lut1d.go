@@ -0,0 +1,48 @@
+package colorlut
+
+// LUT1D is a one dimensional lookup table, which maps each colour component
+// independently.
+type LUT1D struct {
+	Title string
+
+	// DomainMin and DomainMax are the input values which map to the first and
+	// last entries of the table, respectively.
+	DomainMin [3]float32
+	DomainMax [3]float32
+
+	// Table contains the output values of the LUT, evenly spaced across the
+	// domain.
+	Table [][3]float32
+}
+
+// NewLUT1D returns an identity 1D LUT of the specified size, over the domain
+// 0.0–1.0.
+func NewLUT1D(size int) *LUT1D {
+	l := &LUT1D{
+		DomainMax: [3]float32{1, 1, 1},
+		Table:     make([][3]float32, size),
+	}
+
+	for i := range l.Table {
+		v := float32(i) / float32(size-1)
+		l.Table[i] = [3]float32{v, v, v}
+	}
+
+	return l
+}
+
+// Apply returns the result of looking up the specified colour, linearly
+// interpolating between entries of the table.
+func (l *LUT1D) Apply(c [3]float32) [3]float32 {
+	size := len(l.Table)
+	if size < 2 {
+		return c
+	}
+
+	var result [3]float32
+	for ch := 0; ch < 3; ch++ {
+		i, f := split(domainPosition(c[ch], l.DomainMin[ch], l.DomainMax[ch], size), size)
+		result[ch] = l.Table[i][ch] + (l.Table[i+1][ch]-l.Table[i][ch])*f
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package colorlut
+
+import (
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+)
+
+// LUT3D is a three dimensional lookup table, which maps each colour to a new
+// one by interpolating within a lattice of output colours.
+type LUT3D struct {
+	Title string
+
+	// Size is the number of entries along each dimension of the lattice.
+	Size int
+
+	// DomainMin and DomainMax are the input values which map to the first and
+	// last entries of the lattice, respectively.
+	DomainMin [3]float32
+	DomainMax [3]float32
+
+	// Table contains the Size×Size×Size output values of the lattice, with the
+	// red index changing fastest and the blue index slowest.
+	Table [][3]float32
+
+	// Interpolation is the method used to interpolate between entries.
+	Interpolation Interpolation
+}
+
+// NewLUT3D returns an identity 3D LUT of the specified size, over the domain
+// 0.0–1.0.
+func NewLUT3D(size int) *LUT3D {
+	return Bake(size, func(c [3]float32) [3]float32 {
+		return c
+	})
+}
+
+// Bake returns a 3D LUT of the specified size, over the domain 0.0–1.0,
+// approximating an arbitrary colour conversion function.
+func Bake(size int, convert func(c [3]float32) [3]float32) *LUT3D {
+	l := &LUT3D{
+		Size:      size,
+		DomainMax: [3]float32{1, 1, 1},
+		Table:     make([][3]float32, size*size*size),
+	}
+
+	scale := float32(size - 1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				l.Table[l.index(r, g, b)] = convert([3]float32{float32(r) / scale, float32(g) / scale, float32(b) / scale})
+			}
+		}
+	}
+
+	return l
+}
+
+// BakeConversion returns a 3D LUT of the specified size which converts colours
+// encoded in one colour space to the equivalent colours encoded in another.
+// Colours outside the gamut of the destination colour space are clipped.
+func BakeConversion(size int, from, to colorspace.RGB) *LUT3D {
+	transform := from.TransformTo(to)
+
+	l := Bake(size, func(c [3]float32) [3]float32 {
+		v := transform.MulV([3]float64{
+			float64(from.From16Bit(linear.NormalisedTo16Bit(c[0]))),
+			float64(from.From16Bit(linear.NormalisedTo16Bit(c[1]))),
+			float64(from.From16Bit(linear.NormalisedTo16Bit(c[2]))),
+		})
+
+		return [3]float32{
+			float32(to.To16Bit(float32(v[0]))) / 65535,
+			float32(to.To16Bit(float32(v[1]))) / 65535,
+			float32(to.To16Bit(float32(v[2]))) / 65535,
+		}
+	})
+	l.Title = from.Name + " to " + to.Name
+
+	return l
+}
+
+// Apply returns the result of looking up the specified colour, interpolating
+// between entries using the LUT’s interpolation method.
+func (l *LUT3D) Apply(c [3]float32) [3]float32 {
+	if l.Size < 2 {
+		return c
+	}
+
+	r, fr := split(domainPosition(c[0], l.DomainMin[0], l.DomainMax[0], l.Size), l.Size)
+	g, fg := split(domainPosition(c[1], l.DomainMin[1], l.DomainMax[1], l.Size), l.Size)
+	b, fb := split(domainPosition(c[2], l.DomainMin[2], l.DomainMax[2], l.Size), l.Size)
+
+	if l.Interpolation == TetrahedralInterpolation {
+		return l.tetrahedral(r, g, b, fr, fg, fb)
+	}
+	return l.trilinear(r, g, b, fr, fg, fb)
+}
+
+func (l *LUT3D) at(r, g, b int) [3]float32 {
+	return l.Table[l.index(r, g, b)]
+}
+
+func (l *LUT3D) index(r, g, b int) int {
+	return (b*l.Size+g)*l.Size + r
+}
+
+func (l *LUT3D) tetrahedral(r, g, b int, fr, fg, fb float32) [3]float32 {
+	c000 := l.at(r, g, b)
+	c111 := l.at(r+1, g+1, b+1)
+
+	// Select the tetrahedron containing the colour by ordering the fractions,
+	// and the two intermediate vertices along the path from c000 to c111
+	var c1, c2 [3]float32
+	var w0, w1, w2, w3 float32
+
+	switch {
+	case fr >= fg && fg >= fb:
+		c1, c2 = l.at(r+1, g, b), l.at(r+1, g+1, b)
+		w0, w1, w2, w3 = 1-fr, fr-fg, fg-fb, fb
+	case fr >= fb && fb >= fg:
+		c1, c2 = l.at(r+1, g, b), l.at(r+1, g, b+1)
+		w0, w1, w2, w3 = 1-fr, fr-fb, fb-fg, fg
+	case fb >= fr && fr >= fg:
+		c1, c2 = l.at(r, g, b+1), l.at(r+1, g, b+1)
+		w0, w1, w2, w3 = 1-fb, fb-fr, fr-fg, fg
+	case fg >= fr && fr >= fb:
+		c1, c2 = l.at(r, g+1, b), l.at(r+1, g+1, b)
+		w0, w1, w2, w3 = 1-fg, fg-fr, fr-fb, fb
+	case fg >= fb && fb >= fr:
+		c1, c2 = l.at(r, g+1, b), l.at(r, g+1, b+1)
+		w0, w1, w2, w3 = 1-fg, fg-fb, fb-fr, fr
+	default:
+		c1, c2 = l.at(r, g, b+1), l.at(r, g+1, b+1)
+		w0, w1, w2, w3 = 1-fb, fb-fg, fg-fr, fr
+	}
+
+	var result [3]float32
+	for ch := 0; ch < 3; ch++ {
+		result[ch] = w0*c000[ch] + w1*c1[ch] + w2*c2[ch] + w3*c111[ch]
+	}
+	return result
+}
+
+func (l *LUT3D) trilinear(r, g, b int, fr, fg, fb float32) [3]float32 {
+	lerp := func(a, b [3]float32, f float32) [3]float32 {
+		return [3]float32{
+			a[0] + (b[0]-a[0])*f,
+			a[1] + (b[1]-a[1])*f,
+			a[2] + (b[2]-a[2])*f,
+		}
+	}
+
+	c00 := lerp(l.at(r, g, b), l.at(r+1, g, b), fr)
+	c10 := lerp(l.at(r, g+1, b), l.at(r+1, g+1, b), fr)
+	c01 := lerp(l.at(r, g, b+1), l.at(r+1, g, b+1), fr)
+	c11 := lerp(l.at(r, g+1, b+1), l.at(r+1, g+1, b+1), fr)
+
+	return lerp(lerp(c00, c10, fg), lerp(c01, c11, fg), fb)
+}
@@ -0,0 +1,79 @@
+package colorlut
+
+import (
+	"github.com/mandykoh/prism/colorspace"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLUT3D(t *testing.T) {
+
+	near := func(a, b [3]float32, tolerance float64) bool {
+		for ch := 0; ch < 3; ch++ {
+			if math.Abs(float64(a[ch]-b[ch])) > tolerance {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("Apply()", func(t *testing.T) {
+
+		t.Run("reproduces affine conversions exactly with both interpolation methods", func(t *testing.T) {
+			convert := func(c [3]float32) [3]float32 {
+				return [3]float32{0.5*c[0] + 0.25*c[1], c[1]*0.8 + 0.1, 1 - c[2]}
+			}
+
+			random := rand.New(rand.NewSource(1))
+
+			for _, interpolation := range []Interpolation{TrilinearInterpolation, TetrahedralInterpolation} {
+				lut := Bake(9, convert)
+				lut.Interpolation = interpolation
+
+				for i := 0; i < 1000; i++ {
+					c := [3]float32{random.Float32(), random.Float32(), random.Float32()}
+					if expected, actual := convert(c), lut.Apply(c); !near(expected, actual, 0.00001) {
+						t.Fatalf("Expected %v interpolation of %v to produce %v but got %v", interpolation, c, expected, actual)
+					}
+				}
+			}
+		})
+
+		t.Run("clamps colours outside the domain", func(t *testing.T) {
+			lut := NewLUT3D(2)
+
+			if expected, actual := ([3]float32{0, 1, 0.5}), lut.Apply([3]float32{-1, 2, 0.5}); !near(expected, actual, 0.00001) {
+				t.Errorf("Expected %v but got %v", expected, actual)
+			}
+		})
+
+		t.Run("maps colours within a custom domain", func(t *testing.T) {
+			lut := NewLUT3D(3)
+			lut.DomainMin = [3]float32{-1, -1, -1}
+			lut.DomainMax = [3]float32{3, 3, 3}
+
+			if expected, actual := ([3]float32{0.25, 0.5, 1}), lut.Apply([3]float32{0, 1, 3}); !near(expected, actual, 0.00001) {
+				t.Errorf("Expected %v but got %v", expected, actual)
+			}
+		})
+	})
+
+	t.Run("BakeConversion()", func(t *testing.T) {
+
+		t.Run("converts between colour spaces", func(t *testing.T) {
+			lut := BakeConversion(33, colorspace.SRGB, colorspace.DisplayP3)
+			lut.Interpolation = TetrahedralInterpolation
+
+			red := lut.Apply([3]float32{1, 0, 0})
+			if expected, actual := ([3]float32{234.0 / 255, 51.0 / 255, 35.0 / 255}), red; !near(expected, actual, 0.005) {
+				t.Errorf("Expected %v but got %v", expected, actual)
+			}
+
+			grey := [3]float32{0.5, 0.5, 0.5}
+			if expected, actual := grey, lut.Apply(grey); !near(expected, actual, 0.001) {
+				t.Errorf("Expected %v but got %v", expected, actual)
+			}
+		})
+	})
+}
@@ -0,0 +1,26 @@
+package colorlut
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyImage(t *testing.T) {
+
+	t.Run("maps colour values and preserves alpha", func(t *testing.T) {
+		invert := Bake(2, func(c [3]float32) [3]float32 {
+			return [3]float32{1 - c[0], 1 - c[1], 1 - c[2]}
+		})
+
+		src := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		src.SetNRGBA(1, 1, color.NRGBA{R: 255, G: 51, B: 0, A: 128})
+
+		dst := image.NewNRGBA(src.Rect)
+		ApplyImage(dst, src, invert, 2)
+
+		if expected, actual := (color.NRGBA{R: 0, G: 204, B: 255, A: 128}), dst.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package colorprofile
+
+import (
+	"github.com/mandykoh/prism/cielab"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/matrix"
+	"github.com/mandykoh/prism/meta/icc"
+	"math"
+)
+
+// The black point of the perceptual reference medium, to which version 4
+// profiles map black for the perceptual and saturation intents
+var perceptualBlack = ciexyz.Color{X: 0.00336, Y: 0.0034731, Z: 0.00287}
+
+// The lightness above which detected black points are clipped
+const maxBlackPointL = 50
+
+// BlackPointCompensation maps colours in the profile connection space such
+// that the black point of a source profile maps to that of a destination
+// profile, while white is preserved. Without this, shadow detail is lost when
+// converting to a profile whose black is lighter than the source's (such as a
+// printing condition), and contrast is lost in the converse case.
+//
+// This is Adobe's black point compensation, which scales each component of
+// CIE XYZ linearly. The zero value leaves colours unchanged.
+type BlackPointCompensation struct {
+	// Source is the black point of the source profile.
+	Source ciexyz.Color
+
+	// Destination is the black point of the destination profile.
+	Destination ciexyz.Color
+}
+
+// NewBlackPointCompensation returns a BlackPointCompensation for converting
+// from the src profile to the dst profile with the specified rendering intent,
+// detecting the black point of each profile.
+//
+// Black point compensation doesn't apply to the absolute colorimetric intent,
+// for which the returned compensation leaves colours unchanged.
+func NewBlackPointCompensation(src, dst *Profile, intent icc.RenderingIntent) BlackPointCompensation {
+	if intent == icc.AbsoluteColorimetricRenderingIntent {
+		return BlackPointCompensation{}
+	}
+
+	return BlackPointCompensation{
+		Source:      src.BlackPoint(intent),
+		Destination: dst.DestinationBlackPoint(intent),
+	}
+}
+
+// Apply compensates the specified colour, which is relative to the D50
+// illuminant of the profile connection space.
+func (bpc BlackPointCompensation) Apply(c ciexyz.Color) ciexyz.Color {
+	v := c.ToV()
+	src := bpc.Source.ToV()
+	dst := bpc.Destination.ToV()
+	white := ciexyz.D50.ToV()
+
+	for i := range v {
+		if src[i] != white[i] {
+			v[i] = (v[i]-src[i])*(white[i]-dst[i])/(white[i]-src[i]) + dst[i]
+		}
+	}
+	return ciexyz.ColorFromV(v)
+}
+
+// BlackPoint returns the black point of this profile when it is the source of
+// a conversion with the specified rendering intent, relative to the D50
+// illuminant of the profile connection space.
+//
+// The black point is the darkest neutral colour reproduced by the profile's
+// colour space. It is found by converting the darkest device colour (eg zero
+// for RGB, or full coverage of all inks for CMYK) to CIE Lab, or for CMYK
+// output profiles with the relative colorimetric intent, by converting Lab
+// black to the device and back to discount any ink limit. Version 4 profiles
+// use the black of the perceptual reference medium for the perceptual and
+// saturation intents.
+func (p *Profile) BlackPoint(intent icc.RenderingIntent) ciexyz.Color {
+	if intent == icc.AbsoluteColorimetricRenderingIntent {
+		intent = icc.RelativeColorimetricRenderingIntent
+	}
+
+	if p.hasPerceptualBlack(intent) {
+		return perceptualBlack
+	}
+	if intent == icc.RelativeColorimetricRenderingIntent && p.lut && p.colorSpace == icc.ColorSpaceCMYK && p.SupportsFromXYZ() {
+		return neutralBlack(p.roundTrip(cielab.Color{}, icc.PerceptualRenderingIntent))
+	}
+
+	device := make([]float64, p.channels)
+	switch p.colorSpace {
+	case icc.ColorSpaceCMYK, icc.ColorSpaceCMY:
+		for i := range device {
+			device[i] = 1
+		}
+	case icc.ColorSpaceRGB, icc.ColorSpaceGray:
+		// Zero is already the darkest device colour
+	default:
+		return ciexyz.Color{}
+	}
+	return neutralBlack(p.ToXYZ(device, intent).ToLAB(ciexyz.D50))
+}
+
+// DestinationBlackPoint returns the black point of this profile when it is the
+// destination of a conversion with the specified rendering intent, relative to
+// the D50 illuminant of the profile connection space.
+//
+// This is generally the same as BlackPoint. However, for the relative
+// colorimetric intent, lookup tables which clip shadows are detected by
+// converting a ramp of neutral colours to the device and back. The black
+// point is then estimated by fitting a curve to the part of the ramp above
+// where it is clipped.
+func (p *Profile) DestinationBlackPoint(intent icc.RenderingIntent) ciexyz.Color {
+	if p.hasPerceptualBlack(intent) {
+		return perceptualBlack
+	}
+
+	initial := p.BlackPoint(intent)
+	if !p.lut || !p.SupportsFromXYZ() || intent != icc.RelativeColorimetricRenderingIntent {
+		return initial
+	}
+
+	initialLab := initial.ToLAB(ciexyz.D50)
+	minL := float64(initialLab.L)
+	if minL >= maxBlackPointL {
+		return initial
+	}
+
+	var rampL, roundTripL [256]float64
+	straight := true
+	for i := range rampL {
+		rampL[i] = float64(i) * 100 / float64(len(rampL)-1)
+		roundTripL[i] = float64(p.roundTrip(cielab.Color{L: float32(rampL[i])}, intent).L)
+
+		if rampL[i] > minL && rampL[i] <= maxBlackPointL && math.Abs(roundTripL[i]-rampL[i]) > 4 {
+			straight = false
+		}
+	}
+	if straight {
+		return initial
+	}
+
+	// Fit a curve to the part of the ramp just above the clipped shadows,
+	// with the round trip lightness normalised such that the initial black
+	// point is zero
+	var x, y []float64
+	for i := range rampL {
+		normalised := (roundTripL[i] - minL) / (maxBlackPointL - minL)
+		if normalised >= 0.1 && normalised < 0.5 {
+			x = append(x, rampL[i])
+			y = append(y, normalised)
+		}
+	}
+	if len(x) < 3 {
+		return initial
+	}
+
+	return ciexyz.ColorFromLAB(cielab.Color{L: float32(quadraticFitRoot(x, y)), A: initialLab.A, B: initialLab.B}, ciexyz.D50)
+}
+
+func (p *Profile) hasPerceptualBlack(intent icc.RenderingIntent) bool {
+	return p.lut && p.v4 && (intent == icc.PerceptualRenderingIntent || intent == icc.SaturationRenderingIntent)
+}
+
+// roundTrip converts a Lab colour to the device with the specified intent, and
+// back with the relative colorimetric intent.
+func (p *Profile) roundTrip(c cielab.Color, intent icc.RenderingIntent) cielab.Color {
+	device := make([]float64, p.channels)
+	p.FromXYZ(device, ciexyz.ColorFromLAB(c, ciexyz.D50), intent)
+	return p.ToXYZ(device, icc.RelativeColorimetricRenderingIntent).ToLAB(ciexyz.D50)
+}
+
+// neutralBlack returns the neutral colour with the lightness of the specified
+// black, clipped to a plausible range.
+func neutralBlack(c cielab.Color) ciexyz.Color {
+	l := math.Max(0, math.Min(maxBlackPointL, float64(c.L)))
+	return ciexyz.ColorFromLAB(cielab.Color{L: float32(l)}, ciexyz.D50)
+}
+
+// quadraticFitRoot fits a quadratic curve to the specified points by least
+// squares, returning its root within the range of black point lightnesses.
+func quadraticFitRoot(x, y []float64) float64 {
+	var sx, sx2, sx3, sx4, sy, sxy, sx2y float64
+	for i := range x {
+		x2 := x[i] * x[i]
+		sx += x[i]
+		sx2 += x2
+		sx3 += x2 * x[i]
+		sx4 += x2 * x2
+		sy += y[i]
+		sxy += x[i] * y[i]
+		sx2y += x2 * y[i]
+	}
+
+	// Solve the normal equations for y = ax^2 + bx + c by Cramer's rule
+	m := matrix.Matrix3{
+		{float64(len(x)), sx, sx2},
+		{sx, sx2, sx3},
+		{sx2, sx3, sx4},
+	}
+	det := determinant(m)
+	if det == 0 {
+		return 0
+	}
+	rhs := matrix.Vector3{sy, sxy, sx2y}
+	var coeffs [3]float64
+	for i := range coeffs {
+		mi := m
+		mi[i] = rhs
+		coeffs[i] = determinant(mi) / det
+	}
+	c, b, a := coeffs[0], coeffs[1], coeffs[2]
+
+	var root float64
+	if math.Abs(a) < 1e-10 {
+		if b == 0 {
+			return 0
+		}
+		root = -c / b
+	} else {
+		d := b*b - 4*a*c
+		if d <= 0 {
+			return 0
+		}
+		root = (-b + math.Sqrt(d)) / (2 * a)
+	}
+	return math.Max(0, math.Min(maxBlackPointL, root))
+}
@@ -0,0 +1,129 @@
+package colorprofile
+
+import (
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/meta/icc"
+	"math"
+	"testing"
+)
+
+func TestBlackPoint(t *testing.T) {
+
+	t.Run("BlackPoint()", func(t *testing.T) {
+
+		t.Run("is zero for built-in spaces", func(t *testing.T) {
+			profile := NewRGBProfile(colorspace.SRGB)
+
+			assertXYZ(t, ciexyz.Color{}, profile.BlackPoint(icc.PerceptualRenderingIntent), 0.0001)
+			assertXYZ(t, ciexyz.Color{}, profile.DestinationBlackPoint(icc.RelativeColorimetricRenderingIntent), 0.0001)
+		})
+
+		t.Run("is the neutral black of CMYK output profiles", func(t *testing.T) {
+			profile, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			lab := profile.BlackPoint(icc.RelativeColorimetricRenderingIntent).ToLAB(ciexyz.D50)
+
+			if lab.L < 5 || lab.L > 30 || math.Abs(float64(lab.A)) > 0.01 || math.Abs(float64(lab.B)) > 0.01 {
+				t.Errorf("Expected dark neutral black point but got %+v", lab)
+			}
+		})
+
+		t.Run("is the darkest colorant black of version 2 CMYK profiles for other intents", func(t *testing.T) {
+			profile, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+			if profile.v4 {
+				t.Fatalf("Expected a version 2 profile")
+			}
+
+			expected := neutralBlack(profile.ToXYZ([]float64{1, 1, 1, 1}, icc.PerceptualRenderingIntent).ToLAB(ciexyz.D50))
+			actual := profile.BlackPoint(icc.PerceptualRenderingIntent)
+
+			assertXYZ(t, expected, actual, 0.0001)
+			if relative := profile.BlackPoint(icc.RelativeColorimetricRenderingIntent); math.Abs(float64(relative.Y-actual.Y)) < 0.0001 {
+				t.Errorf("Expected perceptual black point %+v to differ from the relative colorimetric one %+v", actual, relative)
+			}
+		})
+
+		t.Run("is the perceptual reference medium black for version 4 lookup tables", func(t *testing.T) {
+			profile := &Profile{lut: true, v4: true}
+
+			if expected, actual := perceptualBlack, profile.BlackPoint(icc.PerceptualRenderingIntent); expected != actual {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+			if expected, actual := perceptualBlack, profile.DestinationBlackPoint(icc.SaturationRenderingIntent); expected != actual {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+		})
+	})
+
+	t.Run("BlackPointCompensation", func(t *testing.T) {
+
+		t.Run("maps source black to destination black and preserves white", func(t *testing.T) {
+			bpc := BlackPointCompensation{
+				Source:      ciexyz.Color{},
+				Destination: ciexyz.Color{X: 0.02, Y: 0.021, Z: 0.017},
+			}
+
+			assertXYZ(t, bpc.Destination, bpc.Apply(bpc.Source), 0.0001)
+			assertXYZ(t, ciexyz.D50, bpc.Apply(ciexyz.D50), 0.0001)
+		})
+
+		t.Run("leaves colours unchanged as the zero value", func(t *testing.T) {
+			c := ciexyz.Color{X: 0.3, Y: 0.2, Z: 0.1}
+
+			assertXYZ(t, c, BlackPointCompensation{}.Apply(c), 0.0001)
+		})
+
+		t.Run("doesn't apply to the absolute colorimetric intent", func(t *testing.T) {
+			cmyk, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			bpc := NewBlackPointCompensation(NewRGBProfile(colorspace.SRGB), cmyk, icc.AbsoluteColorimetricRenderingIntent)
+
+			if expected, actual := (BlackPointCompensation{}), bpc; expected != actual {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+		})
+	})
+
+	t.Run("quadraticFitRoot()", func(t *testing.T) {
+
+		t.Run("finds the root of a line", func(t *testing.T) {
+			x := []float64{20, 25, 30, 35}
+			y := []float64{0.5, 0.75, 1, 1.25}
+
+			if expected, actual := 10.0, quadraticFitRoot(x, y); math.Abs(expected-actual) > 0.0001 {
+				t.Errorf("Expected %f but got %f", expected, actual)
+			}
+		})
+
+		t.Run("finds the root of a quadratic", func(t *testing.T) {
+			var x, y []float64
+			for v := 10.0; v <= 30; v += 2 {
+				x = append(x, v)
+				y = append(y, (v-6)*(v+4)/1000)
+			}
+
+			if expected, actual := 6.0, quadraticFitRoot(x, y); math.Abs(expected-actual) > 0.0001 {
+				t.Errorf("Expected %f but got %f", expected, actual)
+			}
+		})
+
+		t.Run("clips the root to plausible black points", func(t *testing.T) {
+			x := []float64{20, 25, 30}
+			y := []float64{2, 2.5, 3}
+
+			if expected, actual := 0.0, quadraticFitRoot(x, y); expected != actual {
+				t.Errorf("Expected %f but got %f", expected, actual)
+			}
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package colorprofile
+
+import (
+	"github.com/mandykoh/prism/meta/icc"
+	"sort"
+)
+
+const inverseCurveSamples = 4096
+
+// inverseCurve approximates the inverse of a monotonically increasing tone
+// curve, by searching a table of samples of the curve and refining the result
+// by bisection.
+type inverseCurve struct {
+	curve   icc.ToneCurve
+	samples []float64
+}
+
+func newInverseCurve(curve icc.ToneCurve) *inverseCurve {
+	samples := make([]float64, inverseCurveSamples)
+	for i := range samples {
+		samples[i] = curve.Apply(float64(i) / (inverseCurveSamples - 1))
+	}
+	return &inverseCurve{curve: curve, samples: samples}
+}
+
+// Apply returns the input value at which the curve reaches y, clipped to the
+// range [0, 1].
+func (ic *inverseCurve) Apply(y float64) float64 {
+	last := len(ic.samples) - 1
+	if y <= ic.samples[0] {
+		return 0
+	}
+	if y >= ic.samples[last] {
+		return 1
+	}
+
+	i := sort.SearchFloat64s(ic.samples, y)
+	lo := float64(i-1) / float64(last)
+	hi := float64(i) / float64(last)
+
+	for n := 0; n < 16; n++ {
+		mid := (lo + hi) / 2
+		if ic.curve.Apply(mid) < y {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
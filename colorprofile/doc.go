@@ -0,0 +1,12 @@
+// Package colorprofile provides conversions between colour spaces described by
+// ICC profiles and built-in colour spaces, via the profile connection space
+// (PCS) of CIE XYZ relative to a D50 illuminant.
+//
+// Conversions honour rendering intents. Profiles with lookup tables (such as
+// CMYK output profiles) provide a separate table for each intent, while
+// matrix/TRC profiles and built-in colour spaces are colorimetric and use the
+// same conversion for all intents. For the absolute colorimetric intent,
+// colours are additionally scaled by the media white point, so that the white
+// of the source medium (eg the paper of a printing condition) is reproduced
+// rather than being mapped to the white of the destination.
+package colorprofile
@@ -0,0 +1,80 @@
+package colorprofile
+
+import (
+	"github.com/mandykoh/prism/cielab"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/meta/icc"
+)
+
+// Scale factors for the 16-bit Lab encoding used by 'mft2' tags, where L* of
+// 100 is 0xFF00 and a* and b* of 0 are 0x8000
+const legacyLabLScale = 65535.0 / 65280.0 * 100
+const legacyLabABScale = 65535.0 / 256.0
+
+// Scale factor for the XYZ encoding, where 1.0 is 0x8000
+const xyzScale = 65535.0 / 32768.0
+
+// pcsEncoding describes how colours in the profile connection space are
+// normalised for the input or output of a transform.
+type pcsEncoding struct {
+	space  icc.ColorSpace
+	legacy bool
+}
+
+func pcsEncodingForTag(profile *icc.Profile, sig icc.Signature) pcsEncoding {
+	typeSig, _ := profile.TagTable.Type(sig)
+	return pcsEncoding{
+		space:  profile.Header.ProfileConnectionSpace,
+		legacy: typeSig == icc.Lut16Signature,
+	}
+}
+
+// decode converts normalised PCS values to a CIE XYZ colour relative to the
+// D50 illuminant.
+func (e pcsEncoding) decode(v []float64) ciexyz.Color {
+	if e.space == icc.ColorSpaceXYZ {
+		return ciexyz.Color{
+			X: float32(v[0] * xyzScale),
+			Y: float32(v[1] * xyzScale),
+			Z: float32(v[2] * xyzScale),
+		}
+	}
+
+	var lab cielab.Color
+	if e.legacy {
+		lab = cielab.Color{
+			L: float32(v[0] * legacyLabLScale),
+			A: float32(v[1]*legacyLabABScale - 128),
+			B: float32(v[2]*legacyLabABScale - 128),
+		}
+	} else {
+		lab = cielab.Color{
+			L: float32(v[0] * 100),
+			A: float32(v[1]*255 - 128),
+			B: float32(v[2]*255 - 128),
+		}
+	}
+	return ciexyz.ColorFromLAB(lab, ciexyz.D50)
+}
+
+// encode converts a CIE XYZ colour relative to the D50 illuminant to
+// normalised PCS values, writing them to dst.
+func (e pcsEncoding) encode(dst []float64, c ciexyz.Color) {
+	if e.space == icc.ColorSpaceXYZ {
+		dst[0] = float64(c.X) / xyzScale
+		dst[1] = float64(c.Y) / xyzScale
+		dst[2] = float64(c.Z) / xyzScale
+		return
+	}
+
+	lab := c.ToLAB(ciexyz.D50)
+	if e.legacy {
+		dst[0] = float64(lab.L) / legacyLabLScale
+		dst[1] = (float64(lab.A) + 128) / legacyLabABScale
+		dst[2] = (float64(lab.B) + 128) / legacyLabABScale
+	} else {
+		dst[0] = float64(lab.L) / 100
+		dst[1] = (float64(lab.A) + 128) / 255
+		dst[2] = (float64(lab.B) + 128) / 255
+	}
+}
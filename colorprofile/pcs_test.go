@@ -0,0 +1,42 @@
+package colorprofile
+
+import (
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/meta/icc"
+	"math"
+	"testing"
+)
+
+func TestPCSEncoding(t *testing.T) {
+
+	t.Run("round trips colours", func(t *testing.T) {
+		c := ciexyz.Color{X: 0.4, Y: 0.3, Z: 0.2}
+
+		for _, e := range []pcsEncoding{
+			{space: icc.ColorSpaceLab},
+			{space: icc.ColorSpaceLab, legacy: true},
+			{space: icc.ColorSpaceXYZ},
+		} {
+			var v [3]float64
+			e.encode(v[:], c)
+			actual := e.decode(v[:])
+
+			if math.Abs(float64(c.X-actual.X)) > 0.0001 ||
+				math.Abs(float64(c.Y-actual.Y)) > 0.0001 ||
+				math.Abs(float64(c.Z-actual.Z)) > 0.0001 {
+				t.Errorf("Expected %+v to round trip via %+v but got %+v", c, e, actual)
+			}
+		}
+	})
+
+	t.Run("decodes legacy Lab white", func(t *testing.T) {
+		e := pcsEncoding{space: icc.ColorSpaceLab, legacy: true}
+		actual := e.decode([]float64{65280.0 / 65535, 32768.0 / 65535, 32768.0 / 65535})
+
+		if math.Abs(float64(actual.X-ciexyz.D50.X)) > 0.0001 ||
+			math.Abs(float64(actual.Y-ciexyz.D50.Y)) > 0.0001 ||
+			math.Abs(float64(actual.Z-ciexyz.D50.Z)) > 0.0001 {
+			t.Errorf("Expected %+v but got %+v", ciexyz.D50, actual)
+		}
+	})
+}
@@ -0,0 +1,395 @@
+package colorprofile
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+	"github.com/mandykoh/prism/matrix"
+	"github.com/mandykoh/prism/meta/icc"
+)
+
+var aToBSignatures = [3]icc.Signature{icc.AToB0Signature, icc.AToB1Signature, icc.AToB2Signature}
+var bToASignatures = [3]icc.Signature{icc.BToA0Signature, icc.BToA1Signature, icc.BToA2Signature}
+
+type toPCSFunc func(src []float64) ciexyz.Color
+type fromPCSFunc func(dst []float64, c ciexyz.Color)
+
+// Profile describes a colour space in terms of conversions between its colours
+// and the profile connection space, for each rendering intent.
+//
+// Colours in the colour space are represented as normalised device values,
+// such that the encoded range of each channel maps to [0, 1]. For example, an
+// RGB colour is represented by its encoded red, green and blue values, and a
+// CMYK colour by its ink coverages.
+//
+// A Profile is safe for concurrent use.
+type Profile struct {
+	colorSpace icc.ColorSpace
+	channels   int
+	mediaWhite ciexyz.Color
+
+	// lut indicates that conversions use lookup tables, and v4 that they come
+	// from a version 4 profile (for which the perceptual intent has a
+	// well-defined black point)
+	lut bool
+	v4  bool
+
+	// Conversions indexed by the perceptual, relative colorimetric and
+	// saturation intents
+	toPCS   [3]toPCSFunc
+	fromPCS [3]fromPCSFunc
+}
+
+// NewProfile creates a Profile from an ICC profile.
+//
+// The profile's 'A2Bx' and 'B2Ax' lookup tables are used if present. Where
+// there is no table for a particular rendering intent, the perceptual table
+// ('A2B0' or 'B2A0') is used instead. Otherwise, RGB profiles may instead
+// provide colorant and tone reproduction curve tags (ie a matrix/TRC
+// profile).
+//
+// An error is returned if the profile has neither lookup tables nor matrix/TRC
+// tags, or if they are malformed.
+func NewProfile(profile *icc.Profile) (*Profile, error) {
+	if pcs := profile.Header.ProfileConnectionSpace; pcs != icc.ColorSpaceLab && pcs != icc.ColorSpaceXYZ {
+		return nil, fmt.Errorf("unsupported profile connection space %v", pcs)
+	}
+
+	p := &Profile{
+		colorSpace: profile.Header.DataColorSpace,
+		mediaWhite: ciexyz.D50,
+		v4:         profile.Header.Version.Major >= 4,
+	}
+
+	mediaWhite, ok, err := decodeXYZ(profile, icc.MediaWhitePointSignature)
+	if err != nil {
+		return nil, err
+	} else if ok {
+		p.mediaWhite = mediaWhite
+	}
+
+	if ok, err := p.loadLUTs(profile); err != nil {
+		return nil, err
+	} else if ok {
+		return p, nil
+	}
+
+	if ok, err := p.loadMatrixTRC(profile); err != nil {
+		return nil, err
+	} else if ok {
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("profile has neither lookup table nor matrix/TRC tags")
+}
+
+// NewRGBProfile creates a Profile for a built-in RGB colour space, such as
+// colorspace.SRGB. Built-in colour spaces are colorimetric, so the same
+// conversion is used for all rendering intents, and their media white point is
+// their reference white.
+func NewRGBProfile(space colorspace.RGB) *Profile {
+	toXYZ := TransformRGBToXYZ(space, icc.RelativeColorimetricRenderingIntent)
+	fromXYZ := TransformXYZToRGB(space, icc.RelativeColorimetricRenderingIntent)
+
+	toPCS := func(src []float64) ciexyz.Color {
+		v := matrix.Vector3{
+			float64(space.From16Bit(linear.NormalisedTo16Bit(float32(src[0])))),
+			float64(space.From16Bit(linear.NormalisedTo16Bit(float32(src[1])))),
+			float64(space.From16Bit(linear.NormalisedTo16Bit(float32(src[2])))),
+		}
+		return ciexyz.ColorFromV(toXYZ.MulV(v))
+	}
+	fromPCS := func(dst []float64, c ciexyz.Color) {
+		v := fromXYZ.MulV(c.ToV())
+		for i := range v {
+			dst[i] = float64(space.To16Bit(float32(v[i]))) / 65535
+		}
+	}
+
+	p := &Profile{
+		colorSpace: icc.ColorSpaceRGB,
+		channels:   3,
+		mediaWhite: ciexyz.ColorFromXYY(space.WhitePoint),
+	}
+	for i := range p.toPCS {
+		p.toPCS[i] = toPCS
+		p.fromPCS[i] = fromPCS
+	}
+	return p
+}
+
+// Channels returns the number of channels of colours in this profile's colour
+// space.
+func (p *Profile) Channels() int {
+	return p.channels
+}
+
+// ColorSpace returns the colour space described by this profile.
+func (p *Profile) ColorSpace() icc.ColorSpace {
+	return p.colorSpace
+}
+
+// FromXYZ converts a CIE XYZ colour to a colour in this profile's colour
+// space using the specified rendering intent, writing the normalised device
+// values to dst. The colour is interpreted as for ToXYZ.
+//
+// This must only be called if SupportsFromXYZ returns true.
+func (p *Profile) FromXYZ(dst []float64, c ciexyz.Color, intent icc.RenderingIntent) {
+	if intent == icc.AbsoluteColorimetricRenderingIntent {
+		c = absoluteToRelative(c, p.mediaWhite)
+	}
+	p.fromPCS[intentIndex(intent)](dst, c)
+}
+
+// MediaWhitePoint returns the CIE XYZ colour of the white of the medium
+// described by this profile, as used for the absolute colorimetric intent.
+func (p *Profile) MediaWhitePoint() ciexyz.Color {
+	return p.mediaWhite
+}
+
+// SupportsFromXYZ reports whether colours can be converted to this profile's
+// colour space, which isn't the case for profiles only having 'A2Bx' tables
+// (such as many input device profiles).
+func (p *Profile) SupportsFromXYZ() bool {
+	return p.fromPCS[0] != nil
+}
+
+// ToXYZ converts a colour in this profile's colour space, given as normalised
+// device values, to CIE XYZ using the specified rendering intent.
+//
+// For the absolute colorimetric intent, the result is the actual colour
+// reproduced by the medium. For other intents, the result is relative to the
+// D50 illuminant of the profile connection space, such that the media white
+// point corresponds to D50.
+func (p *Profile) ToXYZ(src []float64, intent icc.RenderingIntent) ciexyz.Color {
+	c := p.toPCS[intentIndex(intent)](src)
+	if intent == icc.AbsoluteColorimetricRenderingIntent {
+		c = relativeToAbsolute(c, p.mediaWhite)
+	}
+	return c
+}
+
+func (p *Profile) loadLUTs(profile *icc.Profile) (bool, error) {
+	toPCS, toPCSSignatures, ok, err := loadLUTIntents(profile, aToBSignatures)
+	if err != nil || !ok {
+		return false, err
+	}
+	fromPCS, fromPCSSignatures, hasFromPCS, err := loadLUTIntents(profile, bToASignatures)
+	if err != nil {
+		return false, err
+	}
+
+	for i, transform := range toPCS {
+		sig := toPCSSignatures[i]
+		if err := p.checkChannels(sig, transform.InputChannels(), transform.OutputChannels()); err != nil {
+			return false, err
+		}
+		p.toPCS[i] = lutToPCS(transform, pcsEncodingForTag(profile, sig))
+	}
+
+	if hasFromPCS {
+		for i, transform := range fromPCS {
+			sig := fromPCSSignatures[i]
+			if err := p.checkChannels(sig, transform.OutputChannels(), transform.InputChannels()); err != nil {
+				return false, err
+			}
+			p.fromPCS[i] = lutFromPCS(transform, pcsEncodingForTag(profile, sig))
+		}
+	}
+
+	p.lut = true
+	return true, nil
+}
+
+func (p *Profile) loadMatrixTRC(profile *icc.Profile) (bool, error) {
+	if p.colorSpace != icc.ColorSpaceRGB {
+		return false, nil
+	}
+
+	var columns matrix.Matrix3
+	var curves [3]icc.ToneCurve
+	var inverseCurves [3]*inverseCurve
+
+	colorantSignatures := [3]icc.Signature{icc.RedMatrixColumnSignature, icc.GreenMatrixColumnSignature, icc.BlueMatrixColumnSignature}
+	curveSignatures := [3]icc.Signature{icc.RedTRCSignature, icc.GreenTRCSignature, icc.BlueTRCSignature}
+
+	for i := range columns {
+		colorant, ok, err := decodeXYZ(profile, colorantSignatures[i])
+		if err != nil || !ok {
+			return false, err
+		}
+		columns[i] = colorant.ToV()
+
+		value, err := profile.TagTable.Decode(curveSignatures[i])
+		if err != nil || value == nil {
+			return false, err
+		}
+		if curves[i], ok = value.(icc.ToneCurve); !ok {
+			typeSig, _ := profile.TagTable.Type(curveSignatures[i])
+			return false, fmt.Errorf("tag %v of type %v is not a curve", curveSignatures[i], typeSig)
+		}
+		inverseCurves[i] = newInverseCurve(curves[i])
+	}
+
+	if determinant(columns) == 0 {
+		return false, fmt.Errorf("colorant matrix is not invertible")
+	}
+	inverse := columns.Inverse()
+
+	toPCS := func(src []float64) ciexyz.Color {
+		v := matrix.Vector3{curves[0].Apply(src[0]), curves[1].Apply(src[1]), curves[2].Apply(src[2])}
+		return ciexyz.ColorFromV(columns.MulV(v))
+	}
+	fromPCS := func(dst []float64, c ciexyz.Color) {
+		v := inverse.MulV(c.ToV())
+		for i := range v {
+			dst[i] = inverseCurves[i].Apply(v[i])
+		}
+	}
+
+	p.channels = 3
+	for i := range p.toPCS {
+		p.toPCS[i] = toPCS
+		p.fromPCS[i] = fromPCS
+	}
+	return true, nil
+}
+
+// checkChannels verifies that a lookup table maps between the channels of the
+// colour space and the three channels of the PCS, also establishing the
+// number of channels of the colour space.
+func (p *Profile) checkChannels(sig icc.Signature, deviceChannels, pcsChannels int) error {
+	if pcsChannels != 3 {
+		return fmt.Errorf("expected 3 PCS channels for %v tag but got %d", sig, pcsChannels)
+	}
+	if p.channels == 0 {
+		p.channels = deviceChannels
+	} else if deviceChannels != p.channels {
+		return fmt.Errorf("expected %d device channels for %v tag but got %d", p.channels, sig, deviceChannels)
+	}
+	return nil
+}
+
+// TransformRGBToXYZ returns the column matrix for converting linear colour
+// values in a built-in RGB colour space to CIE XYZ, as given by Profile.ToXYZ
+// for the specified rendering intent.
+func TransformRGBToXYZ(space colorspace.RGB, intent icc.RenderingIntent) matrix.Matrix3 {
+	white := ciexyz.ColorFromXYY(space.WhitePoint)
+	m := matrix.Matrix3(ciexyz.AdaptBetweenXYZWhitePoints(white, ciexyz.D50)).MulM(space.TransformToXYZ())
+
+	if intent == icc.AbsoluteColorimetricRenderingIntent {
+		m = whiteScaling(ciexyz.D50, white).MulM(m)
+	}
+	return m
+}
+
+// TransformXYZToRGB returns the column matrix for converting CIE XYZ colour
+// values, as accepted by Profile.FromXYZ for the specified rendering intent,
+// to linear values in a built-in RGB colour space.
+func TransformXYZToRGB(space colorspace.RGB, intent icc.RenderingIntent) matrix.Matrix3 {
+	white := ciexyz.ColorFromXYY(space.WhitePoint)
+	m := space.TransformFromXYZ().MulM(matrix.Matrix3(ciexyz.AdaptBetweenXYZWhitePoints(ciexyz.D50, white)))
+
+	if intent == icc.AbsoluteColorimetricRenderingIntent {
+		m = m.MulM(whiteScaling(white, ciexyz.D50))
+	}
+	return m
+}
+
+func absoluteToRelative(c ciexyz.Color, mediaWhite ciexyz.Color) ciexyz.Color {
+	return ciexyz.ColorFromV(whiteScaling(mediaWhite, ciexyz.D50).MulV(c.ToV()))
+}
+
+func determinant(m matrix.Matrix3) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[2][1]*m[1][2]) -
+		m[1][0]*(m[0][1]*m[2][2]-m[2][1]*m[0][2]) +
+		m[2][0]*(m[0][1]*m[1][2]-m[1][1]*m[0][2])
+}
+
+func decodeXYZ(profile *icc.Profile, sig icc.Signature) (ciexyz.Color, bool, error) {
+	value, err := profile.TagTable.Decode(sig)
+	if err != nil || value == nil {
+		return ciexyz.Color{}, false, err
+	}
+
+	numbers, ok := value.([]icc.XYZNumber)
+	if !ok || len(numbers) == 0 {
+		typeSig, _ := profile.TagTable.Type(sig)
+		return ciexyz.Color{}, false, fmt.Errorf("tag %v of type %v is not an XYZ value", sig, typeSig)
+	}
+	return ciexyz.Color{X: float32(numbers[0].X), Y: float32(numbers[0].Y), Z: float32(numbers[0].Z)}, true, nil
+}
+
+// intentIndex returns the index of the conversions used for a rendering
+// intent. The absolute colorimetric intent shares the relative colorimetric
+// conversions, and unknown intents are treated as perceptual.
+func intentIndex(intent icc.RenderingIntent) int {
+	switch intent {
+	case icc.RelativeColorimetricRenderingIntent, icc.AbsoluteColorimetricRenderingIntent:
+		return 1
+	case icc.SaturationRenderingIntent:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// loadLUTIntents loads the lookup tables with the specified signatures (one
+// per intent), substituting the perceptual table (or failing that, any other)
+// for intents without one. The signatures of the tables actually used are
+// returned, along with false if there are no tables at all.
+func loadLUTIntents(profile *icc.Profile, sigs [3]icc.Signature) (transforms [3]icc.Transform, used [3]icc.Signature, ok bool, err error) {
+	fallback := -1
+	for i, sig := range sigs {
+		if transforms[i], err = profile.TagTable.Transform(sig); err != nil {
+			return transforms, used, false, err
+		}
+		if transforms[i] != nil && fallback < 0 {
+			fallback = i
+		}
+	}
+	if fallback < 0 {
+		return transforms, used, false, nil
+	}
+
+	for i := range transforms {
+		used[i] = sigs[i]
+		if transforms[i] == nil {
+			transforms[i] = transforms[fallback]
+			used[i] = sigs[fallback]
+		}
+	}
+	return transforms, used, true, nil
+}
+
+func lutFromPCS(transform icc.Transform, encoding pcsEncoding) fromPCSFunc {
+	return func(dst []float64, c ciexyz.Color) {
+		var pcs [3]float64
+		encoding.encode(pcs[:], c)
+		transform.Apply(dst, pcs[:])
+	}
+}
+
+func lutToPCS(transform icc.Transform, encoding pcsEncoding) toPCSFunc {
+	return func(src []float64) ciexyz.Color {
+		var pcs [3]float64
+		transform.Apply(pcs[:], src)
+		return encoding.decode(pcs[:])
+	}
+}
+
+func relativeToAbsolute(c ciexyz.Color, mediaWhite ciexyz.Color) ciexyz.Color {
+	return ciexyz.ColorFromV(whiteScaling(ciexyz.D50, mediaWhite).MulV(c.ToV()))
+}
+
+// whiteScaling returns the diagonal matrix scaling each component of the from
+// white point to the to white point, as used to convert between relative and
+// absolute colorimetry.
+func whiteScaling(from, to ciexyz.Color) matrix.Matrix3 {
+	return matrix.Matrix3{
+		{float64(to.X) / float64(from.X), 0, 0},
+		{0, float64(to.Y) / float64(from.Y), 0},
+		{0, 0, float64(to.Z) / float64(from.Z)},
+	}
+}
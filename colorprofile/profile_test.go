@@ -0,0 +1,179 @@
+package colorprofile
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/meta/icc"
+	"github.com/mandykoh/prism/meta/jpegmeta"
+	"math"
+	"os"
+	"testing"
+)
+
+func loadTestProfile(t *testing.T, path string) *icc.Profile {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	profile, err := icc.NewProfileReader(bytes.NewReader(data)).ReadProfile()
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	return profile
+}
+
+func loadTestImageProfile(t *testing.T, path string) *icc.Profile {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	defer f.Close()
+
+	md, err := jpegmeta.LoadSeeker(f)
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	profile, err := md.ICCProfile()
+	if err != nil {
+		t.Fatalf("Expected success but got error: %v", err)
+	}
+	return profile
+}
+
+func assertXYZ(t *testing.T, expected, actual ciexyz.Color, tolerance float64) {
+	t.Helper()
+
+	if math.Abs(float64(expected.X-actual.X)) > tolerance ||
+		math.Abs(float64(expected.Y-actual.Y)) > tolerance ||
+		math.Abs(float64(expected.Z-actual.Z)) > tolerance {
+		t.Errorf("Expected %+v but got %+v", expected, actual)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	intents := []icc.RenderingIntent{
+		icc.PerceptualRenderingIntent,
+		icc.RelativeColorimetricRenderingIntent,
+		icc.SaturationRenderingIntent,
+		icc.AbsoluteColorimetricRenderingIntent,
+	}
+
+	t.Run("NewProfile()", func(t *testing.T) {
+
+		t.Run("loads matrix/TRC profiles", func(t *testing.T) {
+			profile, err := NewProfile(loadTestProfile(t, "../test-profiles/display-p3-v4-with-v2-desc.icc"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+			builtIn := NewRGBProfile(colorspace.DisplayP3)
+
+			if expected, actual := 3, profile.Channels(); expected != actual {
+				t.Errorf("Expected %d channels but got %d", expected, actual)
+			}
+
+			for _, intent := range intents {
+				for _, c := range [][]float64{{1, 1, 1}, {0.2, 0.5, 0.9}, {1, 0, 0}, {0.05, 0.02, 0.01}} {
+					assertXYZ(t, builtIn.ToXYZ(c, intent), profile.ToXYZ(c, intent), 0.002)
+				}
+			}
+		})
+
+		t.Run("loads lookup table profiles", func(t *testing.T) {
+			profile, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			if expected, actual := icc.ColorSpaceCMYK, profile.ColorSpace(); expected != actual {
+				t.Errorf("Expected colour space %v but got %v", expected, actual)
+			}
+			if expected, actual := 4, profile.Channels(); expected != actual {
+				t.Errorf("Expected %d channels but got %d", expected, actual)
+			}
+			if !profile.SupportsFromXYZ() {
+				t.Errorf("Expected profile to support conversion from XYZ")
+			}
+		})
+
+		t.Run("returns an error for profiles without conversions", func(t *testing.T) {
+			iccProfile := loadTestProfile(t, "../test-profiles/display-p3-v4-with-v2-desc.icc")
+			iccProfile.Header.DataColorSpace = icc.ColorSpaceCMYK
+
+			if _, err := NewProfile(iccProfile); err == nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	})
+
+	t.Run("ToXYZ()", func(t *testing.T) {
+
+		t.Run("selects lookup tables by intent", func(t *testing.T) {
+			profile, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			c := []float64{0, 1, 1, 0}
+			perceptual := profile.ToXYZ(c, icc.PerceptualRenderingIntent)
+			colorimetric := profile.ToXYZ(c, icc.RelativeColorimetricRenderingIntent)
+
+			if perceptual == colorimetric {
+				t.Errorf("Expected perceptual and colorimetric tables to differ but both gave %+v", perceptual)
+			}
+		})
+
+		t.Run("maps paper white to D50 for relative colorimetric intent", func(t *testing.T) {
+			profile, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			assertXYZ(t, ciexyz.D50, profile.ToXYZ([]float64{0, 0, 0, 0}, icc.RelativeColorimetricRenderingIntent), 0.01)
+		})
+
+		t.Run("maps paper white to media white for absolute colorimetric intent", func(t *testing.T) {
+			profile, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			assertXYZ(t, profile.MediaWhitePoint(), profile.ToXYZ([]float64{0, 0, 0, 0}, icc.AbsoluteColorimetricRenderingIntent), 0.01)
+		})
+
+		t.Run("maps built-in white to its reference white for absolute colorimetric intent", func(t *testing.T) {
+			profile := NewRGBProfile(colorspace.SRGB)
+
+			assertXYZ(t, ciexyz.ColorFromXYY(colorspace.SRGB.WhitePoint), profile.ToXYZ([]float64{1, 1, 1}, icc.AbsoluteColorimetricRenderingIntent), 0.0001)
+			assertXYZ(t, ciexyz.D50, profile.ToXYZ([]float64{1, 1, 1}, icc.RelativeColorimetricRenderingIntent), 0.0001)
+		})
+	})
+
+	t.Run("FromXYZ()", func(t *testing.T) {
+
+		t.Run("inverts ToXYZ()", func(t *testing.T) {
+			profile, err := NewProfile(loadTestProfile(t, "../test-profiles/display-p3-v4-with-v2-desc.icc"))
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			for _, intent := range intents {
+				for _, expected := range [][]float64{{1, 1, 1}, {0.2, 0.5, 0.9}, {0.01, 0.3, 0}} {
+					actual := make([]float64, 3)
+					profile.FromXYZ(actual, profile.ToXYZ(expected, intent), intent)
+
+					for i := range expected {
+						if math.Abs(expected[i]-actual[i]) > 0.0001 {
+							t.Errorf("Expected %v for %v but got %v", expected, intent, actual)
+							break
+						}
+					}
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,89 @@
+package colorprofile
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/meta/icc"
+)
+
+// Transform converts colours from one profile's colour space to another's via
+// the profile connection space, using a particular rendering intent. Colours
+// are represented as normalised device values, as for Profile.
+//
+// Transform implements icc.Transform, and is safe for concurrent use.
+type Transform struct {
+	src        *Profile
+	dst        *Profile
+	intent     icc.RenderingIntent
+	compensate bool
+	bpc        BlackPointCompensation
+}
+
+// TransformOptions specifies how a Transform converts colours.
+type TransformOptions struct {
+	// Intent is the rendering intent to use. The zero value is the perceptual
+	// intent.
+	Intent icc.RenderingIntent
+
+	// BlackPointCompensation enables mapping of the source profile's black
+	// point to the destination's (see BlackPointCompensation). This has no
+	// effect for the absolute colorimetric intent.
+	BlackPointCompensation bool
+}
+
+// NewTransform creates a Transform from colours of the src profile to colours
+// of the dst profile, using the specified rendering intent.
+//
+// An error is returned if colours can't be converted to the dst profile's
+// colour space.
+func NewTransform(src, dst *Profile, intent icc.RenderingIntent) (*Transform, error) {
+	return NewTransformWithOptions(src, dst, TransformOptions{Intent: intent})
+}
+
+// NewTransformWithOptions creates a Transform as per NewTransform, with the
+// specified options.
+func NewTransformWithOptions(src, dst *Profile, options TransformOptions) (*Transform, error) {
+	if !dst.SupportsFromXYZ() {
+		return nil, fmt.Errorf("destination profile doesn't support conversion from the profile connection space")
+	}
+
+	t := &Transform{
+		src:    src,
+		dst:    dst,
+		intent: options.Intent,
+	}
+	if options.BlackPointCompensation && options.Intent != icc.AbsoluteColorimetricRenderingIntent {
+		t.compensate = true
+		t.bpc = NewBlackPointCompensation(src, dst, options.Intent)
+	}
+	return t, nil
+}
+
+// Apply converts the colour src, writing the result to dst.
+func (t *Transform) Apply(dst, src []float64) {
+	c := t.src.ToXYZ(src, t.intent)
+	if t.compensate {
+		c = t.bpc.Apply(c)
+	}
+	t.dst.FromXYZ(dst, c, t.intent)
+}
+
+// BlackPointCompensation returns the black point compensation performed by
+// this transform, and false if black point compensation isn't enabled.
+func (t *Transform) BlackPointCompensation() (BlackPointCompensation, bool) {
+	return t.bpc, t.compensate
+}
+
+// InputChannels returns the number of channels of source colours.
+func (t *Transform) InputChannels() int {
+	return t.src.Channels()
+}
+
+// Intent returns the rendering intent used by this transform.
+func (t *Transform) Intent() icc.RenderingIntent {
+	return t.intent
+}
+
+// OutputChannels returns the number of channels of destination colours.
+func (t *Transform) OutputChannels() int {
+	return t.dst.Channels()
+}
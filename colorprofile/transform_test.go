@@ -0,0 +1,135 @@
+package colorprofile
+
+import (
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/meta/icc"
+	"math"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+
+	t.Run("preserves white between built-in spaces for relative colorimetric intent", func(t *testing.T) {
+		transform, err := NewTransform(NewRGBProfile(colorspace.SRGB), NewRGBProfile(colorspace.ProPhotoRGB), icc.RelativeColorimetricRenderingIntent)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		actual := make([]float64, 3)
+		transform.Apply(actual, []float64{1, 1, 1})
+
+		for _, v := range actual {
+			if math.Abs(v-1) > 0.001 {
+				t.Errorf("Expected white but got %v", actual)
+				break
+			}
+		}
+	})
+
+	t.Run("reproduces the source white for absolute colorimetric intent", func(t *testing.T) {
+		transform, err := NewTransform(NewRGBProfile(colorspace.SRGB), NewRGBProfile(colorspace.ProPhotoRGB), icc.AbsoluteColorimetricRenderingIntent)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		actual := make([]float64, 3)
+		transform.Apply(actual, []float64{0.5, 0.5, 0.5})
+
+		// D65 is bluer than the D50 white of Pro Photo RGB
+		if actual[2] <= actual[0] {
+			t.Errorf("Expected a blue tint but got %v", actual)
+		}
+	})
+
+	t.Run("simulates paper for absolute colorimetric intent", func(t *testing.T) {
+		cmyk, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		relative, err := NewTransform(cmyk, NewRGBProfile(colorspace.SRGB), icc.RelativeColorimetricRenderingIntent)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		absolute, err := NewTransform(cmyk, NewRGBProfile(colorspace.SRGB), icc.AbsoluteColorimetricRenderingIntent)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if expected, actual := 4, absolute.InputChannels(); expected != actual {
+			t.Errorf("Expected %d input channels but got %d", expected, actual)
+		}
+		if expected, actual := 3, absolute.OutputChannels(); expected != actual {
+			t.Errorf("Expected %d output channels but got %d", expected, actual)
+		}
+
+		paper := []float64{0, 0, 0, 0}
+		relativeWhite := make([]float64, 3)
+		relative.Apply(relativeWhite, paper)
+		absoluteWhite := make([]float64, 3)
+		absolute.Apply(absoluteWhite, paper)
+
+		if relativeWhite[0] < 0.98 || relativeWhite[1] < 0.98 || relativeWhite[2] < 0.98 {
+			t.Errorf("Expected white paper but got %v", relativeWhite)
+		}
+		if absoluteWhite[1] > 0.98 || absoluteWhite[2] >= absoluteWhite[0] {
+			t.Errorf("Expected darker, yellowish paper but got %v", absoluteWhite)
+		}
+	})
+
+	t.Run("preserves shadow detail with black point compensation", func(t *testing.T) {
+		cmyk, err := NewProfile(loadTestImageProfile(t, "../test-images/pizza-cmyk8-usswop.jpg"))
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		srgb := NewRGBProfile(colorspace.SRGB)
+
+		// Measures the lightness difference between black and a dark grey
+		// after conversion to CMYK
+		shadowContrast := func(t *testing.T, options TransformOptions) float32 {
+			transform, err := NewTransformWithOptions(srgb, cmyk, options)
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			black := make([]float64, 4)
+			transform.Apply(black, []float64{0, 0, 0})
+			grey := make([]float64, 4)
+			transform.Apply(grey, []float64{0.15, 0.15, 0.15})
+
+			blackL := cmyk.ToXYZ(black, icc.RelativeColorimetricRenderingIntent).ToLAB(ciexyz.D50).L
+			greyL := cmyk.ToXYZ(grey, icc.RelativeColorimetricRenderingIntent).ToLAB(ciexyz.D50).L
+			return greyL - blackL
+		}
+
+		withoutBPC := shadowContrast(t, TransformOptions{Intent: icc.RelativeColorimetricRenderingIntent})
+		withBPC := shadowContrast(t, TransformOptions{Intent: icc.RelativeColorimetricRenderingIntent, BlackPointCompensation: true})
+
+		if withBPC <= withoutBPC+1 {
+			t.Errorf("Expected black point compensation to increase shadow contrast but got %f without and %f with", withoutBPC, withBPC)
+		}
+	})
+
+	t.Run("ignores black point compensation for absolute colorimetric intent", func(t *testing.T) {
+		transform, err := NewTransformWithOptions(NewRGBProfile(colorspace.SRGB), NewRGBProfile(colorspace.SRGB), TransformOptions{
+			Intent:                 icc.AbsoluteColorimetricRenderingIntent,
+			BlackPointCompensation: true,
+		})
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if _, ok := transform.BlackPointCompensation(); ok {
+			t.Errorf("Expected black point compensation to be disabled")
+		}
+	})
+
+	t.Run("returns an error if the destination can't be converted to", func(t *testing.T) {
+		_, err := NewTransform(NewRGBProfile(colorspace.SRGB), &Profile{channels: 3}, icc.PerceptualRenderingIntent)
+
+		if err == nil {
+			t.Errorf("Expected error but got none")
+		}
+	})
+}
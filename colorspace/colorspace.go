@@ -0,0 +1,60 @@
+package colorspace
+
+import (
+	"github.com/mandykoh/prism/adobergb"
+	"github.com/mandykoh/prism/displayp3"
+	"github.com/mandykoh/prism/prophotorgb"
+	"github.com/mandykoh/prism/srgb"
+)
+
+// AdobeRGB describes the Adobe RGB (1998) colour space.
+var AdobeRGB = RGB{
+	Name:         "Adobe RGB (1998)",
+	PrimaryRed:   adobergb.PrimaryRed,
+	PrimaryGreen: adobergb.PrimaryGreen,
+	PrimaryBlue:  adobergb.PrimaryBlue,
+	WhitePoint:   adobergb.StandardWhitePoint,
+	From8Bit:     adobergb.From8Bit,
+	From16Bit:    adobergb.From16Bit,
+	To8Bit:       adobergb.To8Bit,
+	To16Bit:      adobergb.To16Bit,
+}
+
+// DisplayP3 describes the Display P3 colour space.
+var DisplayP3 = RGB{
+	Name:         "Display P3",
+	PrimaryRed:   displayp3.PrimaryRed,
+	PrimaryGreen: displayp3.PrimaryGreen,
+	PrimaryBlue:  displayp3.PrimaryBlue,
+	WhitePoint:   displayp3.StandardWhitePoint,
+	From8Bit:     srgb.From8Bit,
+	From16Bit:    srgb.From16Bit,
+	To8Bit:       srgb.To8Bit,
+	To16Bit:      srgb.To16Bit,
+}
+
+// ProPhotoRGB describes the Pro Photo RGB colour space.
+var ProPhotoRGB = RGB{
+	Name:         "Pro Photo RGB",
+	PrimaryRed:   prophotorgb.PrimaryRed,
+	PrimaryGreen: prophotorgb.PrimaryGreen,
+	PrimaryBlue:  prophotorgb.PrimaryBlue,
+	WhitePoint:   prophotorgb.StandardWhitePoint,
+	From8Bit:     prophotorgb.From8Bit,
+	From16Bit:    prophotorgb.From16Bit,
+	To8Bit:       prophotorgb.To8Bit,
+	To16Bit:      prophotorgb.To16Bit,
+}
+
+// SRGB describes the sRGB colour space.
+var SRGB = RGB{
+	Name:         "sRGB",
+	PrimaryRed:   srgb.PrimaryRed,
+	PrimaryGreen: srgb.PrimaryGreen,
+	PrimaryBlue:  srgb.PrimaryBlue,
+	WhitePoint:   srgb.StandardWhitePoint,
+	From8Bit:     srgb.From8Bit,
+	From16Bit:    srgb.From16Bit,
+	To8Bit:       srgb.To8Bit,
+	To16Bit:      srgb.To16Bit,
+}
@@ -0,0 +1,3 @@
+// Package colorspace provides descriptions of RGB colour spaces, allowing
+// operations to be performed generically on images encoded in any of them.
+package colorspace
@@ -0,0 +1,131 @@
+package colorspace
+
+import (
+	"github.com/mandykoh/prism/linear"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// EncodedPixelWriter returns a function which writes linear colour values to an
+// image, encoding them in this colour space.
+//
+// alpha is the normalised alpha value and will be clipped to 0.0–1.0.
+//
+// Straight alpha images (image.NRGBA and image.NRGBA64) are written as such.
+// Colour values written to other images are associated with alpha in linear
+// light (see linear.LinearAlphaAssociation).
+func (s RGB) EncodedPixelWriter(img draw.Image) func(x, y int, col linear.RGB, alpha float32) {
+	clip := func(alpha float32) float32 {
+		if alpha < 0 {
+			return 0
+		}
+		if alpha > 1 {
+			return 1
+		}
+		return alpha
+	}
+
+	switch img := img.(type) {
+
+	case *image.NRGBA:
+		return func(x, y int, c linear.RGB, alpha float32) {
+			nrgba := c.ToEncodedNRGBA(alpha, s.To8Bit)
+			p := img.Pix[img.PixOffset(x, y):]
+			p[0] = nrgba.R
+			p[1] = nrgba.G
+			p[2] = nrgba.B
+			p[3] = nrgba.A
+		}
+
+	case *image.NRGBA64:
+		return func(x, y int, c linear.RGB, alpha float32) {
+			img.SetNRGBA64(x, y, color.NRGBA64{
+				R: s.To16Bit(c.R),
+				G: s.To16Bit(c.G),
+				B: s.To16Bit(c.B),
+				A: linear.NormalisedTo16Bit(alpha),
+			})
+		}
+
+	case *image.RGBA:
+		return func(x, y int, c linear.RGB, alpha float32) {
+			rgba := c.ToEncodedRGBA(clip(alpha), s.To8Bit)
+			p := img.Pix[img.PixOffset(x, y):]
+			p[0] = rgba.R
+			p[1] = rgba.G
+			p[2] = rgba.B
+			p[3] = rgba.A
+		}
+
+	case *image.RGBA64:
+		return func(x, y int, c linear.RGB, alpha float32) {
+			img.SetRGBA64(x, y, c.ToEncodedRGBA64(clip(alpha), s.To16Bit))
+		}
+
+	default:
+		return func(x, y int, c linear.RGB, alpha float32) {
+			img.Set(x, y, c.ToEncodedRGBA64(clip(alpha), s.To16Bit))
+		}
+	}
+}
+
+// LinearPixelReader returns a function which reads pixels from an image encoded
+// in this colour space, returning them as linear colour values. The alpha value
+// is returned as a normalised value between 0.0–1.0.
+//
+// Straight alpha images (image.NRGBA and image.NRGBA64) are read as such.
+// Colour values of other images are assumed to be associated with alpha in
+// linear light (see linear.LinearAlphaAssociation).
+func (s RGB) LinearPixelReader(img image.Image) func(x, y int) (col linear.RGB, alpha float32) {
+	switch img := img.(type) {
+
+	case *image.NRGBA:
+		return func(x, y int) (linear.RGB, float32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			return linear.RGB{R: s.From8Bit(p[0]), G: s.From8Bit(p[1]), B: s.From8Bit(p[2])}, float32(p[3]) / 255
+		}
+
+	case *image.NRGBA64:
+		return func(x, y int) (linear.RGB, float32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			return linear.RGB{
+					R: s.From16Bit(uint16(p[0])<<8 | uint16(p[1])),
+					G: s.From16Bit(uint16(p[2])<<8 | uint16(p[3])),
+					B: s.From16Bit(uint16(p[4])<<8 | uint16(p[5])),
+				},
+				float32(uint16(p[6])<<8|uint16(p[7])) / 65535
+		}
+
+	case *image.RGBA:
+		return func(x, y int) (linear.RGB, float32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			if p[3] == 0 {
+				return linear.RGB{}, 0
+			}
+			alpha := float32(p[3]) / 255
+			return linear.RGB{R: s.From8Bit(p[0]) / alpha, G: s.From8Bit(p[1]) / alpha, B: s.From8Bit(p[2]) / alpha}, alpha
+		}
+
+	case *image.RGBA64:
+		return func(x, y int) (linear.RGB, float32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			a := uint16(p[6])<<8 | uint16(p[7])
+			if a == 0 {
+				return linear.RGB{}, 0
+			}
+			alpha := float32(a) / 65535
+			return linear.RGB{
+					R: s.From16Bit(uint16(p[0])<<8|uint16(p[1])) / alpha,
+					G: s.From16Bit(uint16(p[2])<<8|uint16(p[3])) / alpha,
+					B: s.From16Bit(uint16(p[4])<<8|uint16(p[5])) / alpha,
+				},
+				alpha
+		}
+
+	default:
+		return func(x, y int) (linear.RGB, float32) {
+			return linear.RGBFromEncoded(img.At(x, y), s.From16Bit)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package colorspace
+
+import (
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/linear"
+	"github.com/mandykoh/prism/matrix"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// RGB describes an RGB colour space in terms of its primaries, reference white
+// point, and tonal response curve.
+type RGB struct {
+	Name         string
+	PrimaryRed   ciexyy.Color
+	PrimaryGreen ciexyy.Color
+	PrimaryBlue  ciexyy.Color
+	WhitePoint   ciexyy.Color
+
+	// From8Bit converts an 8-bit encoded value to a normalised linear value.
+	From8Bit func(uint8) float32
+
+	// From16Bit converts a 16-bit encoded value to a normalised linear value.
+	From16Bit func(uint16) float32
+
+	// To8Bit converts a linear value to an 8-bit encoded value.
+	To8Bit func(float32) uint8
+
+	// To16Bit converts a linear value to a 16-bit encoded value.
+	To16Bit func(float32) uint16
+}
+
+// EncodeColor converts a linear colour value to one encoded in this colour
+// space.
+func (s RGB) EncodeColor(c color.Color) color.RGBA64 {
+	rgb, alpha := linear.RGBFromLinear(c)
+	return rgb.ToEncodedRGBA64(alpha, s.To16Bit)
+}
+
+// EncodeImage converts an image with linear colour into one encoded in this
+// colour space.
+//
+// src is the linearised image to be encoded.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func (s RGB) EncodeImage(dst draw.Image, src image.Image, parallelism int) {
+	linear.TransformImageColor(dst, src, parallelism, s.EncodeColor)
+}
+
+// LineariseColor converts a colour encoded in this colour space into a linear
+// one.
+func (s RGB) LineariseColor(c color.Color) color.RGBA64 {
+	rgb, alpha := linear.RGBFromEncoded(c, s.From16Bit)
+	return rgb.ToLinearRGBA64(alpha)
+}
+
+// LineariseImage converts an image with colour encoded in this colour space to
+// linear colour.
+//
+// src is the encoded image to be linearised.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func (s RGB) LineariseImage(dst draw.Image, src image.Image, parallelism int) {
+	linear.TransformImageColor(dst, src, parallelism, s.LineariseColor)
+}
+
+func (s RGB) String() string {
+	return s.Name
+}
+
+// TransformFromXYZ returns the column matrix for converting linear colour
+// values from CIE XYZ to this colour space.
+func (s RGB) TransformFromXYZ() matrix.Matrix3 {
+	return ciexyz.TransformFromXYZForXYYPrimaries(s.PrimaryRed, s.PrimaryGreen, s.PrimaryBlue, s.WhitePoint)
+}
+
+// TransformTo returns the column matrix for converting linear colour values in
+// this colour space to another, including a chromatic adaptation between their
+// white points if necessary.
+func (s RGB) TransformTo(other RGB) matrix.Matrix3 {
+	toXYZ := s.TransformToXYZ()
+	if s.WhitePoint != other.WhitePoint {
+		adaptation := ciexyz.AdaptBetweenXYYWhitePoints(s.WhitePoint, other.WhitePoint)
+		toXYZ = matrix.Matrix3(adaptation).MulM(toXYZ)
+	}
+	return other.TransformFromXYZ().MulM(toXYZ)
+}
+
+// TransformToXYZ returns the column matrix for converting linear colour values
+// in this colour space to CIE XYZ.
+func (s RGB) TransformToXYZ() matrix.Matrix3 {
+	return ciexyz.TransformToXYZForXYYPrimaries(s.PrimaryRed, s.PrimaryGreen, s.PrimaryBlue, s.WhitePoint)
+}
@@ -0,0 +1,40 @@
+package colorspace
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestRGB(t *testing.T) {
+
+	t.Run("TransformToXYZ()", func(t *testing.T) {
+
+		t.Run("matches built-in colour space conversion", func(t *testing.T) {
+			c := srgb.ColorFromLinear(0.2, 0.5, 0.9)
+
+			expected := c.ToXYZ()
+			actual := SRGB.TransformToXYZ().MulV([3]float64{float64(c.R), float64(c.G), float64(c.B)})
+
+			if math.Abs(float64(expected.X)-actual[0]) > 0.0001 ||
+				math.Abs(float64(expected.Y)-actual[1]) > 0.0001 ||
+				math.Abs(float64(expected.Z)-actual[2]) > 0.0001 {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+		})
+	})
+
+	t.Run("LineariseColor()", func(t *testing.T) {
+
+		t.Run("matches built-in colour space linearisation", func(t *testing.T) {
+			for i := 0; i < 256; i++ {
+				c := color.NRGBA{R: uint8(i), G: uint8(255 - i), B: 128, A: 255}
+
+				if expected, actual := srgb.LineariseColor(c), SRGB.LineariseColor(c); expected != actual {
+					t.Errorf("Expected %+v to linearise to %+v but got %+v", c, expected, actual)
+				}
+			}
+		})
+	})
+}
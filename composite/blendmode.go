@@ -0,0 +1,114 @@
+package composite
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/linear"
+	"math"
+)
+
+// BlendMode specifies how the colours of a source and destination (backdrop)
+// are mixed where they overlap.
+type BlendMode int
+
+const (
+	// Normal uses the source colour without mixing.
+	Normal BlendMode = iota
+
+	// Multiply multiplies the source and destination colours, which always
+	// results in a darker colour.
+	Multiply
+
+	// Screen multiplies the complements of the source and destination colours,
+	// which always results in a lighter colour.
+	Screen
+
+	// Overlay multiplies or screens the colours depending on the destination
+	// colour, preserving its highlights and shadows.
+	Overlay
+
+	// SoftLight darkens or lightens the colours depending on the source colour,
+	// similar to shining a diffused spotlight on the destination.
+	SoftLight
+
+	// Add sums the source and destination colours, clipping the result at
+	// full intensity.
+	Add
+)
+
+// Blend returns the result of mixing a source colour with a destination colour
+// using this blend mode. Colour values are clipped to 0.0–1.0 before blending.
+func (bm BlendMode) Blend(dst linear.RGB, src linear.RGB) linear.RGB {
+	return linear.RGB{
+		R: bm.blendChannel(clip(dst.R), clip(src.R)),
+		G: bm.blendChannel(clip(dst.G), clip(src.G)),
+		B: bm.blendChannel(clip(dst.B), clip(src.B)),
+	}
+}
+
+func (bm BlendMode) String() string {
+	switch bm {
+	case Normal:
+		return "Normal"
+	case Multiply:
+		return "Multiply"
+	case Screen:
+		return "Screen"
+	case Overlay:
+		return "Overlay"
+	case SoftLight:
+		return "Soft light"
+	case Add:
+		return "Add"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(bm))
+	}
+}
+
+func (bm BlendMode) blendChannel(cb, cs float32) float32 {
+	switch bm {
+
+	case Multiply:
+		return cb * cs
+
+	case Screen:
+		return cb + cs - cb*cs
+
+	case Overlay:
+		if cb <= 0.5 {
+			return cs * 2 * cb
+		}
+		d := 2*cb - 1
+		return cs + d - cs*d
+
+	case SoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		var d float32
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		} else {
+			d = float32(math.Sqrt(float64(cb)))
+		}
+		return cb + (2*cs-1)*(d-cb)
+
+	case Add:
+		if sum := cb + cs; sum < 1 {
+			return sum
+		}
+		return 1
+
+	default:
+		return cs
+	}
+}
+
+func clip(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+package composite
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+	"image"
+	"image/draw"
+)
+
+// Draw composites src onto dst in linear light, converting between their
+// colour spaces as necessary.
+//
+// As with draw.Draw, r is the rectangle of dst to be drawn to, and sp is the
+// point in src aligned with r.Min; r is clipped to the bounds of both images.
+//
+// dstSpace and srcSpace are the colour spaces in which dst and src are encoded.
+// Straight alpha images (image.NRGBA and image.NRGBA64) are read and written
+// as such. Colour values of other images are assumed to be associated with
+// alpha in linear light (see linear.LinearAlphaAssociation).
+//
+// op is the Porter–Duff operator and mode is the blend mode to use, eg SrcOver
+// and Normal to simply place src over dst.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func Draw(dst draw.Image, dstSpace colorspace.RGB, r image.Rectangle, src image.Image, srcSpace colorspace.RGB, sp image.Point, op Operator, mode BlendMode, parallelism int) {
+	r = r.Intersect(dst.Bounds())
+	r = r.Intersect(src.Bounds().Add(r.Min.Sub(sp)))
+	if r.Empty() {
+		return
+	}
+
+	srcOffset := sp.Sub(r.Min)
+
+	readSrc := srcSpace.LinearPixelReader(src)
+	readDst := dstSpace.LinearPixelReader(dst)
+	writeDst := dstSpace.EncodedPixelWriter(dst)
+
+	sameSpace := srcSpace.PrimaryRed == dstSpace.PrimaryRed &&
+		srcSpace.PrimaryGreen == dstSpace.PrimaryGreen &&
+		srcSpace.PrimaryBlue == dstSpace.PrimaryBlue &&
+		srcSpace.WhitePoint == dstSpace.WhitePoint
+
+	transform := srcSpace.TransformTo(dstSpace)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := r.Min.Y + workerNum; i < r.Max.Y; i += workerCount {
+			for j := r.Min.X; j < r.Max.X; j++ {
+				s, sa := readSrc(j+srcOffset.X, i+srcOffset.Y)
+				if !sameSpace {
+					v := transform.MulV([3]float64{float64(s.R), float64(s.G), float64(s.B)})
+					s = linear.RGB{R: float32(v[0]), G: float32(v[1]), B: float32(v[2])}
+				}
+
+				d, da := readDst(j, i)
+
+				c, a := op.Apply(d, da, s, sa, mode)
+				writeDst(j, i, c, a)
+			}
+		}
+	})
+}
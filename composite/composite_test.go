@@ -0,0 +1,172 @@
+package composite
+
+import (
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDraw(t *testing.T) {
+
+	filled := func(c color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i] = c.R
+			img.Pix[i+1] = c.G
+			img.Pix[i+2] = c.B
+			img.Pix[i+3] = c.A
+		}
+		return img
+	}
+
+	t.Run("composites semi-transparent colours in linear light", func(t *testing.T) {
+		dst := filled(color.NRGBA{A: 255})
+		src := filled(color.NRGBA{R: 255, G: 255, B: 255, A: 128})
+
+		Draw(dst, colorspace.SRGB, dst.Bounds(), src, colorspace.SRGB, image.Point{}, SrcOver, Normal, 2)
+
+		if expected, actual := (color.NRGBA{R: 188, G: 188, B: 188, A: 255}), dst.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+
+	t.Run("only draws within the clipped rectangle", func(t *testing.T) {
+		dst := filled(color.NRGBA{A: 255})
+		src := filled(color.NRGBA{R: 255, A: 255})
+
+		Draw(dst, colorspace.SRGB, image.Rect(2, 2, 10, 10), src, colorspace.SRGB, image.Pt(1, 1), SrcOver, Normal, 2)
+
+		if expected, actual := (color.NRGBA{A: 255}), dst.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+		if expected, actual := (color.NRGBA{R: 255, A: 255}), dst.NRGBAAt(3, 3); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+
+	t.Run("converts between colour spaces", func(t *testing.T) {
+		dst := filled(color.NRGBA{})
+		src := filled(color.NRGBA{R: 255, A: 255})
+
+		Draw(dst, colorspace.DisplayP3, dst.Bounds(), src, colorspace.SRGB, image.Point{}, Src, Normal, 2)
+
+		if expected, actual := (color.NRGBA{R: 234, G: 51, B: 36, A: 255}), dst.NRGBAAt(0, 0); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+
+	t.Run("blends colours with a blend mode", func(t *testing.T) {
+		dst := filled(color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		src := filled(color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		Draw(dst, colorspace.SRGB, dst.Bounds(), src, colorspace.SRGB, image.Point{}, SrcOver, Multiply, 2)
+
+		if expected, actual := (color.NRGBA{R: 200, G: 100, B: 50, A: 255}), dst.NRGBAAt(2, 2); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+}
+
+func TestOperator(t *testing.T) {
+
+	t.Run("Apply()", func(t *testing.T) {
+		red := linear.RGB{R: 1}
+		blue := linear.RGB{B: 1}
+
+		cases := []struct {
+			op            Operator
+			expectedColor linear.RGB
+			expectedAlpha float32
+		}{
+			{Clear, linear.RGB{}, 0},
+			{Src, red, 0.5},
+			{Dst, blue, 0.5},
+			{SrcOver, linear.RGB{R: 2.0 / 3, B: 1.0 / 3}, 0.75},
+			{DstOver, linear.RGB{R: 1.0 / 3, B: 2.0 / 3}, 0.75},
+			{SrcIn, red, 0.25},
+			{DstIn, blue, 0.25},
+			{SrcOut, red, 0.25},
+			{DstOut, blue, 0.25},
+			{SrcAtop, linear.RGB{R: 0.5, B: 0.5}, 0.5},
+			{DstAtop, linear.RGB{R: 0.5, B: 0.5}, 0.5},
+			{Xor, linear.RGB{R: 0.5, B: 0.5}, 0.5},
+			{Plus, linear.RGB{R: 0.5, B: 0.5}, 1},
+		}
+
+		for _, c := range cases {
+			col, alpha := c.op.Apply(blue, 0.5, red, 0.5, Normal)
+
+			if math.Abs(float64(alpha-c.expectedAlpha)) > 0.0001 {
+				t.Errorf("Expected %v to produce alpha %v but got %v", c.op, c.expectedAlpha, alpha)
+			}
+			if math.Abs(float64(col.R-c.expectedColor.R)) > 0.0001 ||
+				math.Abs(float64(col.G-c.expectedColor.G)) > 0.0001 ||
+				math.Abs(float64(col.B-c.expectedColor.B)) > 0.0001 {
+				t.Errorf("Expected %v to produce %+v but got %+v", c.op, c.expectedColor, col)
+			}
+		}
+	})
+
+	t.Run("Apply() adds opaque colours for Plus", func(t *testing.T) {
+		grey := linear.RGB{R: 0.2, G: 0.2, B: 0.2}
+
+		col, alpha := Plus.Apply(grey, 1, grey, 1, Normal)
+
+		if expected := float32(1); alpha != expected {
+			t.Errorf("Expected alpha %v but got %v", expected, alpha)
+		}
+		if expected := (linear.RGB{R: 0.4, G: 0.4, B: 0.4}); math.Abs(float64(col.R-expected.R)) > 0.0001 ||
+			math.Abs(float64(col.G-expected.G)) > 0.0001 ||
+			math.Abs(float64(col.B-expected.B)) > 0.0001 {
+			t.Errorf("Expected %+v but got %+v", expected, col)
+		}
+	})
+
+	t.Run("Apply() saturates opaque colours for Plus", func(t *testing.T) {
+		col, alpha := Plus.Apply(linear.RGB{R: 0.8, G: 0.1}, 1, linear.RGB{R: 0.7, G: 0.2}, 1, Normal)
+
+		if expected := float32(1); alpha != expected {
+			t.Errorf("Expected alpha %v but got %v", expected, alpha)
+		}
+		if expected := (linear.RGB{R: 1, G: 0.3}); math.Abs(float64(col.R-expected.R)) > 0.0001 ||
+			math.Abs(float64(col.G-expected.G)) > 0.0001 ||
+			math.Abs(float64(col.B-expected.B)) > 0.0001 {
+			t.Errorf("Expected %+v but got %+v", expected, col)
+		}
+	})
+}
+
+func TestBlendMode(t *testing.T) {
+
+	t.Run("Blend()", func(t *testing.T) {
+		backdrop := linear.RGB{R: 0.2, G: 0.5, B: 0.8}
+
+		cases := []struct {
+			mode     BlendMode
+			src      linear.RGB
+			expected linear.RGB
+		}{
+			{Normal, linear.RGB{R: 0.3, G: 0.3, B: 0.3}, linear.RGB{R: 0.3, G: 0.3, B: 0.3}},
+			{Multiply, linear.RGB{R: 1, G: 1, B: 1}, backdrop},
+			{Multiply, linear.RGB{R: 0.5, G: 0.5, B: 0.5}, linear.RGB{R: 0.1, G: 0.25, B: 0.4}},
+			{Screen, linear.RGB{}, backdrop},
+			{Screen, linear.RGB{R: 0.5, G: 0.5, B: 0.5}, linear.RGB{R: 0.6, G: 0.75, B: 0.9}},
+			{Overlay, linear.RGB{R: 0.5, G: 0.5, B: 0.5}, backdrop},
+			{SoftLight, linear.RGB{R: 0.5, G: 0.5, B: 0.5}, backdrop},
+			{Add, linear.RGB{R: 0.5, G: 0.5, B: 0.5}, linear.RGB{R: 0.7, G: 1, B: 1}},
+		}
+
+		for _, c := range cases {
+			actual := c.mode.Blend(backdrop, c.src)
+
+			if math.Abs(float64(actual.R-c.expected.R)) > 0.0001 ||
+				math.Abs(float64(actual.G-c.expected.G)) > 0.0001 ||
+				math.Abs(float64(actual.B-c.expected.B)) > 0.0001 {
+				t.Errorf("Expected %v blend of %+v to produce %+v but got %+v", c.mode, c.src, c.expected, actual)
+			}
+		}
+	})
+}
@@ -0,0 +1,8 @@
+// Package composite provides support for compositing and blending images in
+// linear light, following the model of the W3C Compositing and Blending
+// specification.
+//
+// Unlike the standard image/draw package, which composites encoded colour
+// values directly, compositing is performed on linearised colour so that
+// semi-transparent edges and blended overlays have the correct brightness.
+package composite
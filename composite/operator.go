@@ -0,0 +1,165 @@
+package composite
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/linear"
+)
+
+// Operator is a Porter–Duff compositing operator, describing how the source
+// and destination (backdrop) colours contribute to the result.
+type Operator int
+
+const (
+	// Clear produces a fully transparent result.
+	Clear Operator = iota
+
+	// Src replaces the destination with the source.
+	Src
+
+	// Dst leaves the destination unchanged.
+	Dst
+
+	// SrcOver places the source over the destination.
+	SrcOver
+
+	// DstOver places the destination over the source.
+	DstOver
+
+	// SrcIn shows the source only where the destination is opaque.
+	SrcIn
+
+	// DstIn shows the destination only where the source is opaque.
+	DstIn
+
+	// SrcOut shows the source only where the destination is transparent.
+	SrcOut
+
+	// DstOut shows the destination only where the source is transparent.
+	DstOut
+
+	// SrcAtop places the source over the destination, but only where the
+	// destination is opaque.
+	SrcAtop
+
+	// DstAtop places the destination over the source, but only where the
+	// source is opaque.
+	DstAtop
+
+	// Xor shows the source and destination only where they don’t overlap.
+	Xor
+
+	// Plus adds the source and destination together.
+	Plus
+)
+
+// Apply composites a source colour with a destination colour using this
+// operator, with the source first blended with the destination using the
+// specified blend mode. The resulting alpha is returned as a normalised value
+// between 0.0–1.0.
+//
+// Colours are linear and not premultiplied; alpha values are normalised and
+// between 0.0–1.0.
+func (op Operator) Apply(dst linear.RGB, dstAlpha float32, src linear.RGB, srcAlpha float32, mode BlendMode) (col linear.RGB, alpha float32) {
+	if mode != Normal && dstAlpha > 0 {
+		blended := mode.Blend(dst, src)
+		src = linear.RGB{
+			R: (1-dstAlpha)*src.R + dstAlpha*blended.R,
+			G: (1-dstAlpha)*src.G + dstAlpha*blended.G,
+			B: (1-dstAlpha)*src.B + dstAlpha*blended.B,
+		}
+	}
+
+	fa, fb := op.factors(srcAlpha, dstAlpha)
+
+	sa := fa * srcAlpha
+	da := fb * dstAlpha
+	alpha = sa + da
+	if alpha <= 0 {
+		return linear.RGB{}, 0
+	}
+
+	premultiplied := linear.RGB{
+		R: sa*src.R + da*dst.R,
+		G: sa*src.G + da*dst.G,
+		B: sa*src.B + da*dst.B,
+	}
+
+	// Only Plus can exceed full opacity, in which case both alpha and the
+	// premultiplied colour saturate
+	if alpha > 1 {
+		alpha = 1
+		premultiplied = linear.RGB{R: clip(premultiplied.R), G: clip(premultiplied.G), B: clip(premultiplied.B)}
+	}
+
+	return linear.RGB{
+		R: premultiplied.R / alpha,
+		G: premultiplied.G / alpha,
+		B: premultiplied.B / alpha,
+	}, alpha
+}
+
+func (op Operator) String() string {
+	switch op {
+	case Clear:
+		return "Clear"
+	case Src:
+		return "Src"
+	case Dst:
+		return "Dst"
+	case SrcOver:
+		return "SrcOver"
+	case DstOver:
+		return "DstOver"
+	case SrcIn:
+		return "SrcIn"
+	case DstIn:
+		return "DstIn"
+	case SrcOut:
+		return "SrcOut"
+	case DstOut:
+		return "DstOut"
+	case SrcAtop:
+		return "SrcAtop"
+	case DstAtop:
+		return "DstAtop"
+	case Xor:
+		return "Xor"
+	case Plus:
+		return "Plus"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(op))
+	}
+}
+
+// factors returns the Porter–Duff fractions of the source and destination
+// which contribute to the result.
+func (op Operator) factors(srcAlpha, dstAlpha float32) (fa, fb float32) {
+	switch op {
+	case Src:
+		return 1, 0
+	case Dst:
+		return 0, 1
+	case SrcOver:
+		return 1, 1 - srcAlpha
+	case DstOver:
+		return 1 - dstAlpha, 1
+	case SrcIn:
+		return dstAlpha, 0
+	case DstIn:
+		return 0, srcAlpha
+	case SrcOut:
+		return 1 - dstAlpha, 0
+	case DstOut:
+		return 0, 1 - srcAlpha
+	case SrcAtop:
+		return dstAlpha, 1 - srcAlpha
+	case DstAtop:
+		return 1 - dstAlpha, srcAlpha
+	case Xor:
+		return 1 - dstAlpha, 1 - srcAlpha
+	case Plus:
+		return 1, 1
+	default:
+		return 0, 0
+	}
+}
@@ -0,0 +1,32 @@
+package linear
+
+import "fmt"
+
+// AlphaAssociation specifies the convention by which the colour values of an
+// encoded image are associated with (premultiplied by) alpha.
+type AlphaAssociation int
+
+const (
+	// LinearAlphaAssociation indicates that colour values are premultiplied by
+	// alpha in linear light, before being encoded. This is physically correct
+	// for compositing and is the convention assumed by functions which don’t
+	// otherwise specify an alpha association.
+	LinearAlphaAssociation AlphaAssociation = iota
+
+	// EncodedAlphaAssociation indicates that colour values are premultiplied by
+	// alpha after being encoded. This is the convention used by the standard
+	// image and image/color packages, eg when a color.NRGBA is converted to a
+	// color.RGBA or when image/draw composites encoded images.
+	EncodedAlphaAssociation
+)
+
+func (aa AlphaAssociation) String() string {
+	switch aa {
+	case LinearAlphaAssociation:
+		return "Linear"
+	case EncodedAlphaAssociation:
+		return "Encoded"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(aa))
+	}
+}
@@ -0,0 +1,143 @@
+package linear
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const blueNoiseSize = 64
+
+var initBlueNoiseOnce sync.Once
+var blueNoiseThresholds []float32
+
+func blueNoiseThreshold(x, y int) float32 {
+	return blueNoiseThresholds[(y&(blueNoiseSize-1))*blueNoiseSize+(x&(blueNoiseSize-1))]
+}
+
+func initBlueNoise() {
+	initBlueNoiseOnce.Do(func() {
+		blueNoiseThresholds = buildBlueNoiseThresholds()
+	})
+}
+
+// buildBlueNoiseThresholds generates a tileable blue noise threshold texture
+// using Ulichney’s void-and-cluster method.
+func buildBlueNoiseThresholds() []float32 {
+	const n = blueNoiseSize * blueNoiseSize
+	const sigma = 1.5
+
+	// Gaussian energy filter with toroidal wrapping
+	kernel := make([]float32, n)
+	for dy := 0; dy < blueNoiseSize; dy++ {
+		for dx := 0; dx < blueNoiseSize; dx++ {
+			wx := math.Min(float64(dx), float64(blueNoiseSize-dx))
+			wy := math.Min(float64(dy), float64(blueNoiseSize-dy))
+			kernel[dy*blueNoiseSize+dx] = float32(math.Exp(-(wx*wx + wy*wy) / (2 * sigma * sigma)))
+		}
+	}
+
+	updateEnergy := func(energy []float32, q int, sign float32) {
+		qx, qy := q%blueNoiseSize, q/blueNoiseSize
+		for py := 0; py < blueNoiseSize; py++ {
+			row := ((py - qy) & (blueNoiseSize - 1)) * blueNoiseSize
+			for px := 0; px < blueNoiseSize; px++ {
+				energy[py*blueNoiseSize+px] += sign * kernel[row+(px-qx)&(blueNoiseSize-1)]
+			}
+		}
+	}
+
+	tightestCluster := func(pattern []bool, energy []float32, value bool) int {
+		best := -1
+		for p := range pattern {
+			if pattern[p] == value && (best < 0 || energy[p] > energy[best]) {
+				best = p
+			}
+		}
+		return best
+	}
+
+	largestVoid := func(pattern []bool, energy []float32) int {
+		best := -1
+		for p := range pattern {
+			if !pattern[p] && (best < 0 || energy[p] < energy[best]) {
+				best = p
+			}
+		}
+		return best
+	}
+
+	// Initial binary pattern of randomly placed minority pixels
+	random := rand.New(rand.NewSource(1))
+	initial := make([]bool, n)
+	initialEnergy := make([]float32, n)
+	ones := 0
+	for ones < n/10 {
+		p := random.Intn(n)
+		if !initial[p] {
+			initial[p] = true
+			updateEnergy(initialEnergy, p, 1)
+			ones++
+		}
+	}
+
+	// Redistribute minority pixels until they are evenly spaced
+	for {
+		cluster := tightestCluster(initial, initialEnergy, true)
+		initial[cluster] = false
+		updateEnergy(initialEnergy, cluster, -1)
+
+		void := largestVoid(initial, initialEnergy)
+		initial[void] = true
+		updateEnergy(initialEnergy, void, 1)
+
+		if void == cluster {
+			break
+		}
+	}
+
+	ranks := make([]int, n)
+
+	// Phase 1: rank the minority pixels of the initial pattern
+	pattern := append([]bool(nil), initial...)
+	energy := append([]float32(nil), initialEnergy...)
+	for rank := ones - 1; rank >= 0; rank-- {
+		cluster := tightestCluster(pattern, energy, true)
+		pattern[cluster] = false
+		updateEnergy(energy, cluster, -1)
+		ranks[cluster] = rank
+	}
+
+	// Phase 2: fill the largest voids until half the pixels are set
+	pattern = initial
+	energy = initialEnergy
+	rank := ones
+	for ; rank < n/2; rank++ {
+		void := largestVoid(pattern, energy)
+		pattern[void] = true
+		updateEnergy(energy, void, 1)
+		ranks[void] = rank
+	}
+
+	// Phase 3: rank the remaining pixels by the clustering of the unset ones
+	for p := range energy {
+		energy[p] = 0
+	}
+	for p := range pattern {
+		if !pattern[p] {
+			updateEnergy(energy, p, 1)
+		}
+	}
+	for ; rank < n; rank++ {
+		cluster := tightestCluster(pattern, energy, false)
+		pattern[cluster] = true
+		updateEnergy(energy, cluster, -1)
+		ranks[cluster] = rank
+	}
+
+	thresholds := make([]float32, n)
+	for p, r := range ranks {
+		thresholds[p] = (float32(r) + 0.5) / n
+	}
+	return thresholds
+}
@@ -0,0 +1,216 @@
+package linear
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Dithering specifies a method of dithering used when quantising encoded
+// colour values to a lower bit depth.
+type Dithering int
+
+const (
+	// NoDithering quantises values by rounding to the nearest level.
+	NoDithering Dithering = iota
+
+	// BayerDithering applies ordered dithering using an 8x8 Bayer threshold
+	// matrix. This is fast and fully parallelisable, but produces a visible
+	// cross-hatched pattern.
+	BayerDithering
+
+	// BlueNoiseDithering applies ordered dithering using a 64x64 blue noise
+	// threshold texture. This is fast and fully parallelisable, and produces
+	// a less conspicuous pattern than BayerDithering.
+	BlueNoiseDithering
+
+	// FloydSteinbergDithering applies Floyd–Steinberg error diffusion with
+	// serpentine scanning. This produces the most accurate average tones but
+	// is inherently sequential, so processing is not parallelised.
+	FloydSteinbergDithering
+)
+
+var bayerMatrix8 = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+func (d Dithering) String() string {
+	switch d {
+	case NoDithering:
+		return "None"
+	case BayerDithering:
+		return "Bayer"
+	case BlueNoiseDithering:
+		return "Blue noise"
+	case FloydSteinbergDithering:
+		return "Floyd–Steinberg"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(d))
+	}
+}
+
+// threshold returns the quantisation threshold (in the range 0.0–1.0) to be
+// added to an encoded value at the specified pixel position before truncating.
+func (d Dithering) threshold(x, y int) float32 {
+	switch d {
+	case BayerDithering:
+		return (float32(bayerMatrix8[y&7][x&7]) + 0.5) / 64
+	case BlueNoiseDithering:
+		return blueNoiseThreshold(x, y)
+	default:
+		return 0.5
+	}
+}
+
+// EncodeImageDithered converts an image with linear colour into an encoded
+// one, dithering the encoded colour values as they are quantised to 8 bits.
+//
+// Dithering is only applied when dst is an *image.NRGBA or *image.RGBA. Other
+// destination images are encoded without dithering.
+//
+// src is the linearised image to be encoded.
+//
+// dst is the image to write the result to, beginning at its origin.
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads. Error
+// diffusion dithering is not parallelised.
+//
+// trcEncode is a tonal response curve encoding function.
+func EncodeImageDithered(dst draw.Image, src image.Image, dithering Dithering, parallelism int, trcEncode func(float32) uint16) {
+	var pix []uint8
+	var pixOffset func(x, y int) int
+	var premultiplied bool
+
+	switch dstImg := dst.(type) {
+
+	case *image.NRGBA:
+		pix = dstImg.Pix
+		pixOffset = dstImg.PixOffset
+
+	case *image.RGBA:
+		pix = dstImg.Pix
+		pixOffset = dstImg.PixOffset
+		premultiplied = true
+
+	default:
+		TransformImageColor(dst, src, parallelism, func(c color.Color) color.RGBA64 {
+			rgb, alpha := RGBFromLinear(c)
+			return rgb.ToEncodedRGBA64(alpha, trcEncode)
+		})
+		return
+	}
+
+	if dithering == BlueNoiseDithering {
+		initBlueNoise()
+	}
+
+	bounds := src.Bounds()
+	dstOffsetX := dst.Bounds().Min.X - bounds.Min.X
+	dstOffsetY := dst.Bounds().Min.Y - bounds.Min.Y
+
+	encodePixel := func(x, y int) (v [3]float32, a uint8) {
+		rgb, alpha := RGBFromLinear(src.At(x, y))
+		if premultiplied {
+			rgb.R *= alpha
+			rgb.G *= alpha
+			rgb.B *= alpha
+		}
+		v[0] = float32(trcEncode(rgb.R)) / 257
+		v[1] = float32(trcEncode(rgb.G)) / 257
+		v[2] = float32(trcEncode(rgb.B)) / 257
+		return v, NormalisedTo8Bit(alpha)
+	}
+
+	if dithering == FloydSteinbergDithering {
+		width := bounds.Dx()
+		errCurr := make([][3]float32, width+2)
+		errNext := make([][3]float32, width+2)
+
+		for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+			dir := 1
+			if (i-bounds.Min.Y)%2 != 0 {
+				dir = -1
+			}
+
+			for k := 0; k < width; k++ {
+				col := k
+				if dir < 0 {
+					col = width - 1 - k
+				}
+
+				j := bounds.Min.X + col
+				v, a := encodePixel(j, i)
+				offset := pixOffset(j+dstOffsetX, i+dstOffsetY)
+
+				for ch := 0; ch < 3; ch++ {
+					e := clampEncoded8(v[ch] + errCurr[col+1][ch])
+					q := quantiseEncoded8(e, 0.5)
+					diff := e - float32(q)
+
+					errCurr[col+1+dir][ch] += diff * 7 / 16
+					errNext[col+1-dir][ch] += diff * 3 / 16
+					errNext[col+1][ch] += diff * 5 / 16
+					errNext[col+1+dir][ch] += diff * 1 / 16
+
+					pix[offset+ch] = q
+				}
+				pix[offset+3] = a
+			}
+
+			errCurr, errNext = errNext, errCurr
+			for k := range errNext {
+				errNext[k] = [3]float32{}
+			}
+		}
+
+		return
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				v, a := encodePixel(j, i)
+				t := dithering.threshold(j, i)
+
+				offset := pixOffset(j+dstOffsetX, i+dstOffsetY)
+				pix[offset] = quantiseEncoded8(v[0], t)
+				pix[offset+1] = quantiseEncoded8(v[1], t)
+				pix[offset+2] = quantiseEncoded8(v[2], t)
+				pix[offset+3] = a
+			}
+		}
+	})
+}
+
+func clampEncoded8(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+func quantiseEncoded8(v float32, threshold float32) uint8 {
+	q := v + threshold
+	if q <= 0 {
+		return 0
+	}
+	if q >= 255 {
+		return 255
+	}
+	return uint8(q)
+}
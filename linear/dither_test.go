@@ -0,0 +1,108 @@
+package linear
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestEncodeImageDithered(t *testing.T) {
+	identity := func(v float32) uint16 { return NormalisedTo16Bit(v) }
+
+	flatImage := func(v uint16) *image.RGBA64 {
+		img := image.NewRGBA64(image.Rect(0, 0, 128, 128))
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				img.SetRGBA64(j, i, color.RGBA64{R: v, G: v, B: v, A: 65535})
+			}
+		}
+		return img
+	}
+
+	meanRed := func(img *image.NRGBA) float64 {
+		sum := 0.0
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				sum += float64(img.NRGBAAt(j, i).R)
+			}
+		}
+		return sum / float64(img.Rect.Dx()*img.Rect.Dy())
+	}
+
+	t.Run("rounds to the nearest level without dithering", func(t *testing.T) {
+		src := flatImage(25893)
+		dst := image.NewNRGBA(src.Rect)
+
+		EncodeImageDithered(dst, src, NoDithering, 4, identity)
+
+		for i := 0; i < len(dst.Pix); i += 4 {
+			if expected, actual := uint8(101), dst.Pix[i]; expected != actual {
+				t.Fatalf("Expected encoded value %d but got %d", expected, actual)
+			}
+			if expected, actual := uint8(255), dst.Pix[i+3]; expected != actual {
+				t.Fatalf("Expected alpha %d but got %d", expected, actual)
+			}
+		}
+	})
+
+	t.Run("preserves the mean of intermediate levels", func(t *testing.T) {
+		for _, dithering := range []Dithering{BayerDithering, BlueNoiseDithering, FloydSteinbergDithering} {
+			for _, level := range []float64{10.25, 100.5, 200.75} {
+				src := flatImage(uint16(level*257 + 0.5))
+				dst := image.NewNRGBA(src.Rect)
+
+				EncodeImageDithered(dst, src, dithering, 4, identity)
+
+				if actual := meanRed(dst); math.Abs(actual-level) > 0.02 {
+					t.Errorf("Expected %v dithering to produce a mean level of %v but got %v", dithering, level, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("only uses the two nearest levels for flat input", func(t *testing.T) {
+		for _, dithering := range []Dithering{BayerDithering, BlueNoiseDithering, FloydSteinbergDithering} {
+			src := flatImage(25829)
+			dst := image.NewNRGBA(src.Rect)
+
+			EncodeImageDithered(dst, src, dithering, 4, identity)
+
+			for i := 0; i < len(dst.Pix); i += 4 {
+				if v := dst.Pix[i]; v != 100 && v != 101 {
+					t.Fatalf("Expected %v dithering to produce only levels 100 and 101 but got %d", dithering, v)
+				}
+			}
+		}
+	})
+
+	t.Run("encodes without dithering for other destination types", func(t *testing.T) {
+		src := flatImage(25829)
+		dst := image.NewRGBA64(src.Rect)
+
+		EncodeImageDithered(dst, src, BayerDithering, 4, identity)
+
+		if expected, actual := src.RGBA64At(5, 5), dst.RGBA64At(5, 5); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+}
+
+func TestBlueNoiseThresholds(t *testing.T) {
+	thresholds := buildBlueNoiseThresholds()
+
+	seen := make(map[float32]bool)
+	for _, v := range thresholds {
+		if v <= 0 || v >= 1 {
+			t.Fatalf("Expected threshold to be between 0 and 1 but got %v", v)
+		}
+		if seen[v] {
+			t.Fatalf("Expected thresholds to be unique but found %v more than once", v)
+		}
+		seen[v] = true
+	}
+
+	if expected, actual := blueNoiseSize*blueNoiseSize, len(seen); expected != actual {
+		t.Errorf("Expected %d thresholds but got %d", expected, actual)
+	}
+}
@@ -7,6 +7,29 @@ import (
 	"image/draw"
 )
 
+// ConvertImageAlphaAssociation converts an encoded image whose colour values
+// are associated with alpha using one convention to another, writing the
+// results to dst at its origin.
+//
+// Non-premultiplied destination images such as image.NRGBA always store
+// straight colour values, and should only be written to using
+// EncodedAlphaAssociation (which is how the standard library interprets colours
+// set on such images).
+//
+// src and dst may be the same image.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+//
+// trcDecode and trcEncode are the tonal response curve decoding and encoding
+// functions for the colour space of the image.
+func ConvertImageAlphaAssociation(dst draw.Image, src image.Image, from, to AlphaAssociation, parallelism int, trcDecode func(uint16) float32, trcEncode func(float32) uint16) {
+	TransformImageColor(dst, src, parallelism, func(c color.Color) color.RGBA64 {
+		rgb, alpha := RGBFromEncodedAssociated(c, from, trcDecode)
+		return rgb.ToEncodedRGBA64Associated(alpha, to, trcEncode)
+	})
+}
+
 // NormalisedTo8Bit clamps and scales a normalised value to the range 0-255.
 func NormalisedTo8Bit(v float32) uint8 {
 	if v <= 0 {
@@ -47,6 +47,28 @@ func (c RGB) ToEncodedRGBA(alpha float32, trcEncode func(float32) uint8) color.R
 	}
 }
 
+// ToEncodedRGBAAssociated returns an encoded 8-bit RGBA representation of this
+// colour suitable for use with instances of image.RGBA, with the colour values
+// associated with alpha using the specified convention.
+//
+// alpha is the normalised alpha value and will be clipped to 0.0–1.0.
+//
+// trcEncode is a tonal response curve encoding function.
+func (c RGB) ToEncodedRGBAAssociated(alpha float32, assoc AlphaAssociation, trcEncode func(float32) uint8) color.RGBA {
+	if assoc == LinearAlphaAssociation {
+		return c.ToEncodedRGBA(alpha, trcEncode)
+	}
+
+	a := uint32(NormalisedTo8Bit(alpha))
+
+	return color.RGBA{
+		R: uint8((uint32(trcEncode(c.R))*a + 127) / 255),
+		G: uint8((uint32(trcEncode(c.G))*a + 127) / 255),
+		B: uint8((uint32(trcEncode(c.B))*a + 127) / 255),
+		A: uint8(a),
+	}
+}
+
 // ToEncodedRGBA64 returns an encoded 16-bit RGBA representation of this colour
 // suitable for use with instances of image.RGBA64.
 //
@@ -62,6 +84,28 @@ func (c RGB) ToEncodedRGBA64(alpha float32, trcEncode func(float32) uint16) colo
 	}
 }
 
+// ToEncodedRGBA64Associated returns an encoded 16-bit RGBA representation of
+// this colour suitable for use with instances of image.RGBA64, with the colour
+// values associated with alpha using the specified convention.
+//
+// alpha is the normalised alpha value and will be clipped to 0.0–1.0.
+//
+// trcEncode is a tonal response curve encoding function.
+func (c RGB) ToEncodedRGBA64Associated(alpha float32, assoc AlphaAssociation, trcEncode func(float32) uint16) color.RGBA64 {
+	if assoc == LinearAlphaAssociation {
+		return c.ToEncodedRGBA64(alpha, trcEncode)
+	}
+
+	a := uint32(NormalisedTo16Bit(alpha))
+
+	return color.RGBA64{
+		R: uint16((uint32(trcEncode(c.R))*a + 32767) / 65535),
+		G: uint16((uint32(trcEncode(c.G))*a + 32767) / 65535),
+		B: uint16((uint32(trcEncode(c.B))*a + 32767) / 65535),
+		A: uint16(a),
+	}
+}
+
 // ToLinearRGBA64 returns a linear 16-bit RGBA representation of this colour
 // suitable for use with instances of image.RGBA64.
 //
@@ -97,6 +141,31 @@ func RGBFromEncoded(c color.Color, trcDecode func(uint16) float32) (col RGB, alp
 		alpha
 }
 
+// RGBFromEncodedAssociated returns a normalised RGB instance representing the
+// specified color.Color value, whose colour values are associated with alpha
+// using the specified convention. The alpha component is returned as a
+// normalised value in the range 0.0-1.0.
+//
+// c is assumed to be an encoded colour.
+func RGBFromEncodedAssociated(c color.Color, assoc AlphaAssociation, trcDecode func(uint16) float32) (col RGB, alpha float32) {
+	if assoc == LinearAlphaAssociation {
+		return RGBFromEncoded(c, trcDecode)
+	}
+
+	r, g, b, a := c.RGBA()
+
+	if a == 0 {
+		return RGB{}, 0
+	}
+
+	return RGB{
+			R: trcDecode(unassociate16Bit(r, a)),
+			G: trcDecode(unassociate16Bit(g, a)),
+			B: trcDecode(unassociate16Bit(b, a)),
+		},
+		float32(a) / 65535
+}
+
 // RGBFromLinear returns a normalised RGB instance representing the specified
 // color.Color value. The alpha component is returned as a normalised value in
 // the range 0.0-1.0.
@@ -118,3 +187,10 @@ func RGBFromLinear(c color.Color) (col RGB, alpha float32) {
 		},
 		alpha / 65535
 }
+
+func unassociate16Bit(v, alpha uint32) uint16 {
+	if v >= alpha {
+		return 65535
+	}
+	return uint16((v*65535 + alpha/2) / alpha)
+}
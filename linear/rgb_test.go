@@ -175,4 +175,92 @@ func TestRGB(t *testing.T) {
 			}
 		})
 	})
+	t.Run("RGBFromEncodedAssociated()", func(t *testing.T) {
+		identity := func(v uint16) float32 { return float32(v) / 65535 }
+
+		t.Run("unpremultiplies after decoding for linear association", func(t *testing.T) {
+			c := color.RGBA64{R: 16384, G: 16384, B: 16384, A: 32768}
+			square := func(v uint16) float32 { f := float32(v) / 65535; return f * f }
+
+			col, alpha := RGBFromEncodedAssociated(c, LinearAlphaAssociation, square)
+
+			if expected, actual := square(16384)/alpha, col.R; math.Abs(float64(expected)-float64(actual)) > 0.0001 {
+				t.Errorf("Expected red component %v but got %v", expected, actual)
+			}
+		})
+
+		t.Run("unpremultiplies before decoding for encoded association", func(t *testing.T) {
+			c := color.RGBA64{R: 16384, G: 16384, B: 16384, A: 32768}
+			square := func(v uint16) float32 { f := float32(v) / 65535; return f * f }
+
+			col, alpha := RGBFromEncodedAssociated(c, EncodedAlphaAssociation, square)
+
+			if expected, actual := float32(0.25), col.R; math.Abs(float64(expected)-float64(actual)) > 0.0001 {
+				t.Errorf("Expected red component %v but got %v", expected, actual)
+			}
+			if expected, actual := float32(0.5), alpha; math.Abs(float64(expected)-float64(actual)) > 0.0001 {
+				t.Errorf("Expected alpha %v but got %v", expected, actual)
+			}
+		})
+
+		t.Run("matches straight values of an NRGBA64 colour for encoded association", func(t *testing.T) {
+			for a := 4096; a <= 65535; a += 257 {
+				nrgba := color.NRGBA64{R: 40000, G: 20000, B: 65535, A: uint16(a)}
+
+				col, _ := RGBFromEncodedAssociated(nrgba, EncodedAlphaAssociation, identity)
+
+				if expected, actual := identity(nrgba.B), col.B; math.Abs(float64(expected)-float64(actual)) > 0.001 {
+					t.Errorf("Expected blue component %v for %+v but got %v", expected, nrgba, actual)
+				}
+				if expected, actual := identity(nrgba.R), col.R; math.Abs(float64(expected)-float64(actual)) > 0.001 {
+					t.Errorf("Expected red component %v for %+v but got %v", expected, nrgba, actual)
+				}
+			}
+		})
+
+		t.Run("returns transparent black for zero alpha", func(t *testing.T) {
+			col, alpha := RGBFromEncodedAssociated(color.RGBA64{}, EncodedAlphaAssociation, identity)
+
+			if col != (RGB{}) || alpha != 0 {
+				t.Errorf("Expected transparent black but got %+v with alpha %v", col, alpha)
+			}
+		})
+	})
+
+	t.Run("ToEncodedRGBA64Associated()", func(t *testing.T) {
+		identity := func(v float32) uint16 { return NormalisedTo16Bit(v) }
+
+		t.Run("premultiplies after encoding for encoded association", func(t *testing.T) {
+			sqrt := func(v float32) uint16 { return NormalisedTo16Bit(float32(math.Sqrt(float64(v)))) }
+
+			actual := RGB{0.25, 0.25, 0.25}.ToEncodedRGBA64Associated(0.5, EncodedAlphaAssociation, sqrt)
+
+			if expected := (color.RGBA64{R: 16384, G: 16384, B: 16384, A: 32768}); expected != actual {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+		})
+
+		t.Run("matches ToEncodedRGBA64() for linear association", func(t *testing.T) {
+			c := RGB{0.2, 0.4, 0.8}
+
+			if expected, actual := c.ToEncodedRGBA64(0.3, identity), c.ToEncodedRGBA64Associated(0.3, LinearAlphaAssociation, identity); expected != actual {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+		})
+
+		t.Run("round trips with RGBFromEncodedAssociated()", func(t *testing.T) {
+			c := RGB{0.2, 0.4, 0.8}
+			decode := func(v uint16) float32 { return float32(v) / 65535 }
+
+			encoded := c.ToEncodedRGBA64Associated(0.75, EncodedAlphaAssociation, identity)
+			actual, alpha := RGBFromEncodedAssociated(encoded, EncodedAlphaAssociation, decode)
+
+			if math.Abs(float64(c.R-actual.R)) > 0.0001 || math.Abs(float64(c.G-actual.G)) > 0.0001 || math.Abs(float64(c.B-actual.B)) > 0.0001 {
+				t.Errorf("Expected %+v but got %+v", c, actual)
+			}
+			if expected := float32(0.75); math.Abs(float64(expected-alpha)) > 0.0001 {
+				t.Errorf("Expected alpha %v but got %v", expected, alpha)
+			}
+		})
+	})
 }
@@ -0,0 +1,32 @@
+package meta
+
+import "fmt"
+
+// AdobeTransform is the colour transform specified by the Adobe APP14 segment
+// of a JPEG image, indicating how its components are encoded.
+type AdobeTransform int
+
+const (
+	// AdobeTransformNone indicates untransformed components, being RGB for
+	// three-component images and CMYK for four-component images.
+	AdobeTransformNone AdobeTransform = 0
+
+	// AdobeTransformYCbCr indicates that three components are YCbCr.
+	AdobeTransformYCbCr AdobeTransform = 1
+
+	// AdobeTransformYCCK indicates that four components are YCCK.
+	AdobeTransformYCCK AdobeTransform = 2
+)
+
+func (at AdobeTransform) String() string {
+	switch at {
+	case AdobeTransformNone:
+		return "None"
+	case AdobeTransformYCbCr:
+		return "YCbCr"
+	case AdobeTransformYCCK:
+		return "YCCK"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(at))
+	}
+}
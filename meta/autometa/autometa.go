@@ -1,17 +1,40 @@
 package autometa
 
 import (
-	"fmt"
+	"bytes"
 	"io"
 
 	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/bmpmeta"
+	"github.com/mandykoh/prism/meta/gifmeta"
+	"github.com/mandykoh/prism/meta/heifmeta"
 	"github.com/mandykoh/prism/meta/jpegmeta"
+	"github.com/mandykoh/prism/meta/jxlmeta"
 	"github.com/mandykoh/prism/meta/pngmeta"
+	"github.com/mandykoh/prism/meta/tiffmeta"
 	"github.com/mandykoh/prism/meta/webpmeta"
 )
 
+func init() {
+	RegisterFormat("\x89PNG\r\n\x1a\n", pngmeta.LoadWithLimits, pngmeta.LoadSeekerWithLimits)
+	RegisterFormat("\xff\xd8", jpegmeta.LoadWithLimits, jpegmeta.LoadSeekerWithLimits)
+	RegisterFormat("RIFF????WEBP", webpmeta.LoadWithLimits, webpmeta.LoadSeekerWithLimits)
+	RegisterFormat("II*\x00", tiffmeta.LoadWithLimits, tiffmeta.LoadSeekerWithLimits)
+	RegisterFormat("MM\x00*", tiffmeta.LoadWithLimits, tiffmeta.LoadSeekerWithLimits)
+	RegisterFormat("II+\x00", tiffmeta.LoadWithLimits, tiffmeta.LoadSeekerWithLimits)
+	RegisterFormat("MM\x00+", tiffmeta.LoadWithLimits, tiffmeta.LoadSeekerWithLimits)
+	RegisterFormat("????ftyp", heifmeta.LoadWithLimits, heifmeta.LoadSeekerWithLimits)
+	RegisterFormat("\xff\x0a", jxlmeta.LoadWithLimits, jxlmeta.LoadSeekerWithLimits)
+	RegisterFormat("\x00\x00\x00\x0cJXL \r\n\x87\n", jxlmeta.LoadWithLimits, jxlmeta.LoadSeekerWithLimits)
+	RegisterFormat("BM", bmpmeta.LoadWithLimits, bmpmeta.LoadSeekerWithLimits)
+	RegisterFormat("GIF87a", gifmeta.LoadWithLimits, gifmeta.LoadSeekerWithLimits)
+	RegisterFormat("GIF89a", gifmeta.LoadWithLimits, gifmeta.LoadSeekerWithLimits)
+}
+
 // Load loads the metadata for an image stream, which may be one of the
-// supported image formats.
+// supported image formats or any format added with RegisterFormat. The format
+// is identified from the leading bytes of the stream.
 //
 // Only as much of the stream is consumed as necessary to extract the metadata;
 // the returned stream contains a buffered copy of the consumed data such that
@@ -19,26 +42,97 @@ import (
 // stream. This provides a convenient way to load the full image after loading
 // the metadata.
 //
-// An error is returned if basic metadata could not be extracted. The returned
-// stream still provides the full image data.
+// An error is returned if the format is not recognised or basic metadata could
+// not be extracted. The returned stream still provides the full image data.
 func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for an image stream as per Load, subject to
+// the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
+	formats, _ := atomicFormats.Load().([]format)
+
+	header := make([]byte, peekLength(formats))
+	n, err := io.ReadFull(r, header)
+	header = header[:n]
+
+	inputStream := io.MultiReader(bytes.NewReader(header), r)
+
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, inputStream, err
+	}
 
-	loaders := []func(r io.Reader) (*meta.Data, io.Reader, error){
-		pngmeta.Load,
-		jpegmeta.Load,
-		webpmeta.Load,
+	f := sniff(formats, header)
+	if f == nil {
+		return nil, inputStream, binary.Errorf(meta.ErrFormatMismatch, "unrecognised image format")
 	}
 
-	inputStream := r
+	return f.load(inputStream, limits)
+}
 
-	for _, loader := range loaders {
-		md, nextStream, err := loader(inputStream)
-		if err == nil {
-			return md, nextStream, nil
-		}
+// LoadSeeker loads the metadata for an image stream which supports seeking, as
+// per Load.
+//
+// Reading begins at the current position of the stream, and data which
+// doesn't contain metadata is skipped by seeking past it where the format
+// allows. The stream is returned to its original position afterwards, so that
+// the full image can then be loaded from it.
+//
+// An error is returned if the format is not recognised or basic metadata could
+// not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for an image stream which supports
+// seeking as per LoadSeeker, subject to the specified limits rather than
+// meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	formats, _ := atomicFormats.Load().([]format)
 
-		inputStream = nextStream
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, inputStream, fmt.Errorf("unrecognised image format")
+	header := make([]byte, peekLength(formats))
+	n, err := io.ReadFull(r, header)
+	header = header[:n]
+
+	if _, seekErr := r.Seek(start, io.SeekStart); seekErr != nil {
+		return nil, seekErr
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	f := sniff(formats, header)
+	if f == nil {
+		return nil, binary.Errorf(meta.ErrFormatMismatch, "unrecognised image format")
+	}
+
+	if f.loadSeeker != nil {
+		return f.loadSeeker(r, limits)
+	}
+
+	md, _, err = f.load(r, limits)
+
+	if _, seekErr := r.Seek(start, io.SeekStart); seekErr != nil && err == nil {
+		return nil, seekErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for an image of the specified size in bytes,
+// as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for an image of the specified size
+// in bytes as per LoadReaderAt, subject to the specified limits rather than
+// meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mandykoh/prism/meta"
 	"github.com/mandykoh/prism/meta/autometa"
+	"golang.org/x/image/tiff"
 	"golang.org/x/image/webp"
 )
 
@@ -105,3 +106,31 @@ func ExampleLoad_basicWebPMetadata() {
 	// Actual image height: 1200
 	// Actual image width: 1200
 }
+
+func ExampleLoad_basicTIFFMetadata() {
+	inFile, err := os.Open("../../test-images/pizza-rgb8-prophotorgb.tiff")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, imgStream, err := autometa.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	img, err := tiff.Decode(imgStream)
+	if err != nil {
+		panic(err)
+	}
+
+	printMetadata(md, img)
+
+	// Output:
+	// Format: TIFF
+	// BitsPerComponent: 8
+	// PixelHeight: 64
+	// PixelWidth: 64
+	// Actual image height: 64
+	// Actual image width: 64
+}
@@ -2,11 +2,24 @@ package autometa
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/mandykoh/prism/meta"
 )
 
+func unregisterLatestFormat() {
+	formatsMu.Lock()
+	formats := atomicFormats.Load().([]format)
+	atomicFormats.Store(formats[1:])
+	formatsMu.Unlock()
+}
+
 func TestLoad(t *testing.T) {
 
 	t.Run("returns original image data when format is unrecognised", func(t *testing.T) {
@@ -26,6 +39,9 @@ func TestLoad(t *testing.T) {
 		if expected, actual := "unrecognised image format", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but was '%s'", expected, actual)
 		}
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
 
 		if md != nil {
 			t.Errorf("Expected no metadata to be returned but was %+v", md)
@@ -43,4 +59,237 @@ func TestLoad(t *testing.T) {
 			t.Errorf("Expected returned stream to contain original image data but was different.\n\nExpected:%v\nActual:%v\n", randomBytes, returnedBytes)
 		}
 	})
+
+	t.Run("selects a registered format by its signature", func(t *testing.T) {
+		testFormat := meta.ImageFormat("Test")
+		loaded := 0
+
+		RegisterFormat("PRISM?TEST", func(r io.Reader, limits meta.Limits) (*meta.Data, io.Reader, error) {
+			loaded++
+			return &meta.Data{Format: testFormat}, r, nil
+		}, nil)
+		defer unregisterLatestFormat()
+
+		input := []byte("PRISM-TEST image data")
+
+		md, stream, err := Load(bytes.NewReader(input))
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := 1, loaded; expected != actual {
+			t.Errorf("Expected registered loader to be called %d time but was called %d times", expected, actual)
+		}
+		if expected, actual := testFormat, md.Format; expected != actual {
+			t.Errorf("Expected format %s but got %s", expected, actual)
+		}
+
+		returnedBytes, err := io.ReadAll(stream)
+		if err != nil {
+			t.Fatalf("Expected to be able to read returned stream but got error: %v", err)
+		}
+		if !bytes.Equal(input, returnedBytes) {
+			t.Errorf("Expected returned stream to contain %v but was %v", input, returnedBytes)
+		}
+	})
+
+	t.Run("passes limits to the registered loader", func(t *testing.T) {
+		limits := meta.Limits{MaxICCProfileSize: 1234, MaxSegments: 56}
+		var passed, passedSeeker meta.Limits
+
+		RegisterFormat("PRISM?TEST", func(r io.Reader, l meta.Limits) (*meta.Data, io.Reader, error) {
+			passed = l
+			return &meta.Data{}, r, nil
+		}, func(r io.ReadSeeker, l meta.Limits) (*meta.Data, error) {
+			passedSeeker = l
+			return &meta.Data{}, nil
+		})
+		defer unregisterLatestFormat()
+
+		input := []byte("PRISM-TEST image data")
+
+		if _, _, err := LoadWithLimits(bytes.NewReader(input), limits); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if _, err := LoadSeekerWithLimits(bytes.NewReader(input), limits); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if passed != limits {
+			t.Errorf("Expected limits %+v but got %+v", limits, passed)
+		}
+		if passedSeeker != limits {
+			t.Errorf("Expected limits %+v but got %+v", limits, passedSeeker)
+		}
+	})
+
+	t.Run("gives precedence to formats registered later", func(t *testing.T) {
+		RegisterFormat("\x89PNG", func(r io.Reader, limits meta.Limits) (*meta.Data, io.Reader, error) {
+			return &meta.Data{Format: "Not PNG"}, r, nil
+		}, nil)
+		defer unregisterLatestFormat()
+
+		md, _, err := Load(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n")))
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := meta.ImageFormat("Not PNG"), md.Format; expected != actual {
+			t.Errorf("Expected format %s but got %s", expected, actual)
+		}
+	})
+
+	t.Run("returns loader error for stream shorter than signature", func(t *testing.T) {
+		input := []byte{0xff, 0xd8}
+
+		md, stream, err := Load(bytes.NewReader(input))
+
+		if err == nil {
+			t.Fatalf("Expected error but succeeded with %+v", md)
+		}
+
+		returnedBytes, err := io.ReadAll(stream)
+		if err != nil {
+			t.Fatalf("Expected to be able to read returned stream but got error: %v", err)
+		}
+		if !bytes.Equal(input, returnedBytes) {
+			t.Errorf("Expected returned stream to contain %v but was %v", input, returnedBytes)
+		}
+	})
+
+	t.Run("returns truncated error for truncated images", func(t *testing.T) {
+		for _, name := range []string{"pizza-rgb8-prophotorgb-v5.bmp", "pizza-displayp3-animated.gif"} {
+			t.Run(name, func(t *testing.T) {
+				input, err := os.ReadFile(filepath.Join("../../test-images", name))
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				_, _, err = Load(bytes.NewReader(input[:20]))
+
+				if !errors.Is(err, meta.ErrTruncated) {
+					t.Errorf("Expected truncated error but got %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("returns format mismatch error for invalid signature", func(t *testing.T) {
+		for _, input := range [][]byte{[]byte("BX\x00\x00\x00\x00"), []byte("GIF88a\x00\x00")} {
+			_, _, err := Load(bytes.NewReader(input))
+
+			if !errors.Is(err, meta.ErrFormatMismatch) {
+				t.Errorf("Expected format mismatch error for %q but got %v", input, err)
+			}
+		}
+	})
+}
+
+func TestLoadSeeker(t *testing.T) {
+
+	t.Run("returns the same metadata as Load for all test images", func(t *testing.T) {
+		paths, err := filepath.Glob("../../test-images/*")
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		for _, path := range paths {
+			t.Run(filepath.Base(path), func(t *testing.T) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				expected, _, err := Load(bytes.NewReader(data))
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				r := bytes.NewReader(data)
+				actual, err := LoadSeeker(r)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("Expected %+v but got %+v", expected, actual)
+				}
+				if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+					t.Errorf("Expected stream to be returned to its original position but was at %d", pos)
+				}
+
+				actual, err = LoadReaderAt(bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("Expected %+v but got %+v", expected, actual)
+				}
+			})
+		}
+	})
+
+	t.Run("applies limits when loading from a ReaderAt", func(t *testing.T) {
+		paths, err := filepath.Glob("../../test-images/*")
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		for _, path := range paths {
+			t.Run(filepath.Base(path), func(t *testing.T) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				md, err := LoadReaderAt(bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if profile, _ := md.ICCProfileData(); profile == nil {
+					return
+				}
+
+				md, err = LoadReaderAtWithLimits(bytes.NewReader(data), int64(len(data)), meta.Limits{MaxICCProfileSize: 1})
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				var limitErr *meta.LimitError
+				if _, err := md.ICCProfileData(); !errors.As(err, &limitErr) {
+					t.Errorf("Expected limit error but got %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("returns error when format is unrecognised", func(t *testing.T) {
+		_, err := LoadSeeker(bytes.NewReader([]byte("not an image")))
+
+		if err == nil {
+			t.Fatalf("Expected error but succeeded")
+		}
+		if expected, actual := "unrecognised image format", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but was '%s'", expected, actual)
+		}
+	})
+
+	t.Run("falls back to streaming loader for formats without a seeking loader", func(t *testing.T) {
+		RegisterFormat("PRISM?TEST", func(r io.Reader, limits meta.Limits) (*meta.Data, io.Reader, error) {
+			_, err := io.ReadAll(r)
+			return &meta.Data{Format: "Test"}, r, err
+		}, nil)
+		defer unregisterLatestFormat()
+
+		r := bytes.NewReader([]byte("PRISM-TEST image data"))
+		md, err := LoadSeeker(r)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := meta.ImageFormat("Test"), md.Format; expected != actual {
+			t.Errorf("Expected format %s but got %s", expected, actual)
+		}
+		if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+			t.Errorf("Expected stream to be returned to its original position but was at %d", pos)
+		}
+	})
 }
@@ -0,0 +1,63 @@
+package autometa
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/gifmeta"
+	"github.com/mandykoh/prism/meta/jpegmeta"
+	"github.com/mandykoh/prism/meta/pngmeta"
+	"github.com/mandykoh/prism/meta/tiffmeta"
+	"github.com/mandykoh/prism/meta/webpmeta"
+)
+
+type editor struct {
+	magic string
+	edit  func(w io.Writer, r io.Reader, edit meta.ProfileEdit) error
+}
+
+var editors = []editor{
+	{"\x89PNG\r\n\x1a\n", pngmeta.EditProfile},
+	{"\xff\xd8", jpegmeta.EditProfile},
+	{"RIFF????WEBP", webpmeta.EditProfile},
+	{"II*\x00", tiffmeta.EditProfile},
+	{"MM\x00*", tiffmeta.EditProfile},
+	{"II+\x00", tiffmeta.EditProfile},
+	{"MM\x00+", tiffmeta.EditProfile},
+	{"GIF87a", gifmeta.EditProfile},
+	{"GIF89a", gifmeta.EditProfile},
+}
+
+// EditProfile copies an image stream from r to w, changing its colour profile
+// as specified by edit without re-encoding the image. The format is
+// identified from the leading bytes of the stream.
+//
+// PNG, JPEG, WebP, TIFF and GIF images are supported. Editing the profiles of
+// BMP, JPEG XL and HEIF/AVIF images isn't supported, and an error satisfying
+// errors.Is(err, meta.ErrUnsupported) is returned for these (and any other
+// registered formats). An error satisfying errors.Is(err,
+// meta.ErrFormatMismatch) is returned for unrecognised formats.
+func EditProfile(w io.Writer, r io.Reader, edit meta.ProfileEdit) error {
+	formats, _ := atomicFormats.Load().([]format)
+
+	header := make([]byte, peekLength(formats))
+	n, err := io.ReadFull(r, header)
+	header = header[:n]
+
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	for _, e := range editors {
+		if match(e.magic, header) {
+			return e.edit(w, io.MultiReader(bytes.NewReader(header), r), edit)
+		}
+	}
+
+	if sniff(formats, header) != nil {
+		return binary.Errorf(meta.ErrUnsupported, "editing profiles is not supported for this image format")
+	}
+	return binary.Errorf(meta.ErrFormatMismatch, "unrecognised image format")
+}
@@ -0,0 +1,62 @@
+package autometa
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mandykoh/prism/meta"
+)
+
+func TestEditProfile(t *testing.T) {
+
+	newProfile := []byte("new profile data")
+
+	t.Run("replaces the profile of supported images", func(t *testing.T) {
+		for _, name := range []string{"pizza-rgb8-displayp3.jpg", "pizza-rgb8-srgb.png", "pizza-rgb8-displayp3-vp8x.webp", "pizza-rgb8-prophotorgb.tiff", "pizza-displayp3-animated.gif"} {
+			t.Run(name, func(t *testing.T) {
+				data, err := os.ReadFile(filepath.Join("../../test-images", name))
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				output := &bytes.Buffer{}
+				err = EditProfile(output, bytes.NewReader(data), meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				md, _, err := Load(output)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if actual, _ := md.ICCProfileData(); !bytes.Equal(newProfile, actual) {
+					t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+				}
+			})
+		}
+	})
+
+	t.Run("returns error for recognised formats which can't be edited", func(t *testing.T) {
+		data, err := os.ReadFile("../../test-images/pizza-rgb8-prophotorgb-v5.bmp")
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		err = EditProfile(&bytes.Buffer{}, bytes.NewReader(data), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrUnsupported) {
+			t.Errorf("Expected unsupported error but got %v", err)
+		}
+	})
+
+	t.Run("returns error when format is unrecognised", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader([]byte("not an image")), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+}
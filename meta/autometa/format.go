@@ -0,0 +1,87 @@
+package autometa
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mandykoh/prism/meta"
+)
+
+// LoadFunc loads the metadata for an image stream of a particular format, as
+// per LoadWithLimits. Format-specific loaders such as jpegmeta.LoadWithLimits
+// satisfy this.
+type LoadFunc func(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error)
+
+// LoadSeekerFunc loads the metadata for an image stream of a particular format
+// which supports seeking, as per LoadSeekerWithLimits. Format-specific loaders
+// such as jpegmeta.LoadSeekerWithLimits satisfy this.
+type LoadSeekerFunc func(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error)
+
+type format struct {
+	magic      string
+	load       LoadFunc
+	loadSeeker LoadSeekerFunc
+}
+
+var (
+	formatsMu     sync.Mutex
+	atomicFormats atomic.Value
+)
+
+// RegisterFormat registers an image format for use by Load and LoadSeeker. The
+// format is selected for any stream whose leading bytes match magic, in which
+// any "?" matches any single byte.
+//
+// loadSeeker is used for streams which support seeking, and may be nil if the
+// format has no such loader, in which case load is used instead.
+//
+// Formats registered later take precedence over those registered earlier, so
+// that the built-in formats (which are registered first) may be overridden
+// by more specific ones. A format with several possible signatures may be
+// registered once for each.
+//
+// RegisterFormat is typically called from an init function.
+func RegisterFormat(magic string, load LoadFunc, loadSeeker LoadSeekerFunc) {
+	formatsMu.Lock()
+	formats, _ := atomicFormats.Load().([]format)
+	atomicFormats.Store(append([]format{{magic, load, loadSeeker}}, formats...))
+	formatsMu.Unlock()
+}
+
+// sniff returns the format matching the leading bytes of an image stream, or
+// nil if there is none.
+func sniff(formats []format, header []byte) *format {
+	for i := range formats {
+		if match(formats[i].magic, header) {
+			return &formats[i]
+		}
+	}
+	return nil
+}
+
+// match reports whether header begins with the magic string, in which "?"
+// matches any single byte.
+func match(magic string, header []byte) bool {
+	if len(header) < len(magic) {
+		return false
+	}
+	for i := 0; i < len(magic); i++ {
+		if magic[i] != header[i] && magic[i] != '?' {
+			return false
+		}
+	}
+	return true
+}
+
+// peekLength returns the number of leading bytes of a stream needed to match
+// against all the specified formats.
+func peekLength(formats []format) int {
+	n := 0
+	for _, f := range formats {
+		if len(f.magic) > n {
+			n = len(f.magic)
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrFormatMismatch indicates that data isn't of the expected format, eg
+	// because its signature doesn't match.
+	ErrFormatMismatch = errors.New("format mismatch")
+
+	// ErrTruncated indicates that data ended before it was complete.
+	ErrTruncated = errors.New("truncated data")
+
+	// ErrMalformed indicates that data doesn't conform to its format.
+	ErrMalformed = errors.New("malformed data")
+
+	// ErrUnsupported indicates that data uses a feature of its format which
+	// isn't supported.
+	ErrUnsupported = errors.New("unsupported feature")
+)
+
+// kindError is an error of one of the kinds described by the sentinel errors
+// above, which keeps its own more specific message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// Errorf formats an error as per fmt.Errorf, which is also reported by
+// errors.Is as being of the specified kind (eg ErrFormatMismatch).
+func Errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// Truncated returns err as an ErrTruncated error if it is io.EOF or
+// io.ErrUnexpectedEOF, as data ending prematurely indicates that it has been
+// truncated. Other errors are returned unchanged.
+func Truncated(err error) error {
+	if err != nil && !errors.Is(err, ErrTruncated) && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return &kindError{kind: ErrTruncated, err: err}
+	}
+	return err
+}
+
+// MalformedError describes a malformed chunk, segment, box or other part of a
+// data stream, along with where it was found. It is reported by errors.Is as
+// being ErrMalformed.
+type MalformedError struct {
+	// Segment is the type of the malformed part of the stream, eg "iCCP".
+	Segment string
+
+	// Offset is the position of the start of the malformed part of the
+	// stream, relative to the start of the stream.
+	Offset int64
+
+	// Err describes what is malformed.
+	Err error
+}
+
+// Malformed returns a MalformedError for the segment at the specified offset,
+// with a description formatted as per fmt.Errorf.
+func Malformed(segment string, offset int64, format string, args ...interface{}) *MalformedError {
+	return &MalformedError{Segment: segment, Offset: offset, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("malformed %s at offset %d: %v", e.Segment, e.Offset, e.Err)
+}
+
+func (e *MalformedError) Is(target error) bool {
+	return target == ErrMalformed
+}
+
+func (e *MalformedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,77 @@
+package binary
+
+import (
+	"fmt"
+	"io"
+)
+
+// LimitError is returned when reading data would exceed a configured limit.
+type LimitError struct {
+	// Limit is the name of the limit which was exceeded.
+	Limit string
+
+	// Value is the configured value of the limit.
+	Value int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Limit, e.Value)
+}
+
+// LimitedReader reads from an underlying stream, failing with a LimitError
+// once more than a specified number of bytes have been read.
+type LimitedReader struct {
+	r         io.Reader
+	remaining int64
+	err       *LimitError
+	exceeded  bool
+}
+
+// NewLimitedReader returns a LimitedReader which allows up to n bytes to be
+// read from r, with exceeding the limit being reported as the named limit. A
+// limit of zero means that reading is not limited.
+func NewLimitedReader(r io.Reader, n int64, limit string) *LimitedReader {
+	lr := &LimitedReader{
+		r:         r,
+		remaining: n,
+		err:       &LimitError{Limit: limit, Value: n},
+	}
+	if n <= 0 {
+		lr.err = nil
+	}
+	return lr
+}
+
+// Read implements io.Reader.
+func (lr *LimitedReader) Read(p []byte) (int, error) {
+	if lr.err == nil {
+		return lr.r.Read(p)
+	}
+
+	if lr.remaining <= 0 {
+		// Only report the limit as exceeded if there is actually more data
+		var probe [1]byte
+		if n, err := lr.r.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		lr.exceeded = true
+		return 0, lr.err
+	}
+
+	if int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	return n, err
+}
+
+// Cause returns the LimitError if the limit has been exceeded and err is
+// non-nil, or err otherwise. This allows errors which resulted from the limit
+// being exceeded, but which were reported differently, to be identified.
+func (lr *LimitedReader) Cause(err error) error {
+	if err != nil && lr.exceeded {
+		return lr.err
+	}
+	return err
+}
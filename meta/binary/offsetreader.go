@@ -0,0 +1,44 @@
+package binary
+
+// OffsetReader is a Reader which keeps track of how far into a stream it has
+// read, so that the locations of errors can be reported.
+type OffsetReader struct {
+	r      Reader
+	offset int64
+}
+
+// NewOffsetReader returns an OffsetReader which reads from r, treating the
+// current position of r as offset zero.
+func NewOffsetReader(r Reader) *OffsetReader {
+	return &OffsetReader{r: r}
+}
+
+// Offset returns the number of bytes which have been read or skipped.
+func (r *OffsetReader) Offset() int64 {
+	return r.offset
+}
+
+// Read implements io.Reader.
+func (r *OffsetReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// ReadByte implements io.ByteReader.
+func (r *OffsetReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+// Skip implements Skipper, skipping efficiently if the underlying Reader can.
+func (r *OffsetReader) Skip(n int64) error {
+	err := Skip(r.r, n)
+	if err == nil {
+		r.offset += n
+	}
+	return err
+}
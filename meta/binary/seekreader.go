@@ -0,0 +1,164 @@
+package binary
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// Skipper is implemented by readers which can skip over data more efficiently
+// than by reading it.
+type Skipper interface {
+	Skip(n int64) error
+}
+
+// Skip discards the next n bytes from r, seeking past them if r is a Skipper
+// and reading them otherwise. io.EOF is returned if fewer than n bytes remain.
+func Skip(r io.Reader, n int64) error {
+	if s, ok := r.(Skipper); ok {
+		return s.Skip(n)
+	}
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// SeekReader is a buffered Reader for an io.ReadSeeker, which skips over data
+// by seeking past it rather than reading it.
+type SeekReader struct {
+	rs       io.ReadSeeker
+	br       *bufio.Reader
+	start    int64
+	pos      int64
+	end      int64
+	limit    int64
+	limitErr *LimitError
+	exceeded bool
+}
+
+// NewSeekReader returns a SeekReader which reads from the current position of
+// the specified stream.
+func NewSeekReader(rs io.ReadSeeker) (*SeekReader, error) {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return &SeekReader{
+		rs:    rs,
+		br:    bufio.NewReader(rs),
+		start: start,
+		pos:   start,
+		end:   end,
+	}, nil
+}
+
+// SetLimit limits the number of bytes which may be read or skipped to n, with
+// exceeding the limit being reported as the named limit. A limit of zero means
+// that reading is not limited.
+func (r *SeekReader) SetLimit(n int64, limit string) {
+	r.limit = r.start + n
+	r.limitErr = &LimitError{Limit: limit, Value: n}
+	if n <= 0 {
+		r.limitErr = nil
+	}
+}
+
+// Cause returns the LimitError if the limit has been exceeded and err is
+// non-nil, or err otherwise. This allows errors which resulted from the limit
+// being exceeded, but which were reported differently, to be identified.
+func (r *SeekReader) Cause(err error) error {
+	if err != nil && r.exceeded {
+		return r.limitErr
+	}
+	return err
+}
+
+// checkLimit returns an error if reading any of the next n bytes would exceed
+// the limit, or the number of them which may be read otherwise.
+func (r *SeekReader) checkLimit(n int64) (int64, error) {
+	if r.limitErr == nil || r.pos+n <= r.limit {
+		return n, nil
+	}
+	if r.pos >= r.limit {
+		if r.pos >= r.end {
+			return 0, io.EOF
+		}
+		r.exceeded = true
+		return 0, r.limitErr
+	}
+	return r.limit - r.pos, nil
+}
+
+// Read implements io.Reader.
+func (r *SeekReader) Read(p []byte) (int, error) {
+	allowed, err := r.checkLimit(int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.br.Read(p[:allowed])
+	r.pos += int64(n)
+	return n, err
+}
+
+// ReadByte implements io.ByteReader.
+func (r *SeekReader) ReadByte() (byte, error) {
+	if _, err := r.checkLimit(1); err != nil {
+		return 0, err
+	}
+	b, err := r.br.ReadByte()
+	if err == nil {
+		r.pos++
+	}
+	return b, err
+}
+
+// Skip implements Skipper. Data which has already been buffered is discarded,
+// and the underlying stream is seeked past the remainder.
+func (r *SeekReader) Skip(n int64) error {
+	if n < 0 {
+		return errors.New("negative skip length")
+	}
+	if r.limitErr != nil && r.pos+n > r.limit && r.pos+n <= r.end {
+		r.exceeded = true
+		return r.limitErr
+	}
+
+	if n <= int64(r.br.Buffered()) {
+		_, err := r.br.Discard(int(n))
+		r.pos += n
+		return err
+	}
+
+	target := r.pos + n
+	if target > r.end || target < r.pos {
+		target = r.end
+	}
+
+	if _, err := r.rs.Seek(target, io.SeekStart); err != nil {
+		return err
+	}
+	r.br.Reset(r.rs)
+
+	skipped := target - r.pos
+	r.pos = target
+	if skipped < n {
+		return io.EOF
+	}
+	return nil
+}
+
+// Restore seeks the underlying stream back to the position it was at when the
+// SeekReader was created.
+func (r *SeekReader) Restore() error {
+	_, err := r.rs.Seek(r.start, io.SeekStart)
+	r.br.Reset(r.rs)
+	r.pos = r.start
+	return err
+}
@@ -0,0 +1,387 @@
+package bmpmeta
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"errors"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/icc"
+	"io"
+	"math/bits"
+)
+
+// Format specifies the image format handled by this package
+var Format = meta.ImageFormat("BMP")
+
+var signature = [2]byte{'B', 'M'}
+
+const fileHeaderSize = 14
+
+const (
+	coreHeaderSize = 12
+	infoHeaderSize = 40
+	v4HeaderSize   = 108
+	v5HeaderSize   = 124
+)
+
+const (
+	compressionBitFields      = 3
+	compressionAlphaBitFields = 6
+)
+
+// Colour space types (bV5CSType)
+const (
+	lcsCalibratedRGB     = 0
+	lcsSRGB              = 0x73524742 // 'sRGB'
+	lcsWindowsColorSpace = 0x57696E20 // 'Win '
+	profileLinked        = 0x4C494E4B // 'LINK'
+	profileEmbedded      = 0x4D424544 // 'MBED'
+)
+
+const fixedPoint2Dot30Scale = 1 << 30
+const fixedPoint16Dot16Scale = 1 << 16
+
+// Rendering intents (bV5Intent)
+var renderingIntents = map[uint32]icc.RenderingIntent{
+	1: icc.SaturationRenderingIntent,
+	2: icc.RelativeColorimetricRenderingIntent,
+	4: icc.PerceptualRenderingIntent,
+	8: icc.AbsoluteColorimetricRenderingIntent,
+}
+
+var srgbPrimaries = meta.Primaries{
+	Red:   ciexyy.Color{X: 0.64, Y: 0.33, YY: 1},
+	Green: ciexyy.Color{X: 0.3, Y: 0.6, YY: 1},
+	Blue:  ciexyy.Color{X: 0.15, Y: 0.06, YY: 1},
+}
+
+// Load loads the metadata for a BMP image stream.
+//
+// Only as much of the stream is consumed as necessary to extract the metadata;
+// the returned stream contains a buffered copy of the consumed data such that
+// reading from it will produce the same results as fully reading the input
+// stream. This provides a convenient way to load the full image after loading
+// the metadata.
+//
+// Because an embedded ICC profile is usually stored after the pixel data, this
+// may require reading up to the entire stream.
+//
+// An error is returned if basic metadata could not be extracted. The returned
+// stream still provides the full image data.
+func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a BMP image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
+	rewindBuffer := &bytes.Buffer{}
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(lr, limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a BMP image stream which supports seeking.
+//
+// Reading begins at the current position of the stream, and the pixel data
+// preceding an embedded ICC profile is skipped by seeking past it. The stream
+// is returned to its original position afterwards, so that the full image can
+// then be loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a BMP image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a BMP image of the specified size in
+// bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a BMP image of the specified
+// size in bytes as per LoadReaderAt, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
+}
+
+func extractMetadata(r io.Reader, limits meta.Limits) (md *meta.Data, err error) {
+	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
+
+	defer func() {
+		if r := recover(); r != nil {
+			md = nil
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
+		}
+		err = binary.Truncated(err)
+	}()
+
+	var fileHeader [fileHeaderSize + 4]byte
+	if _, err := io.ReadFull(r, fileHeader[:]); err != nil {
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+	}
+	if fileHeader[0] != signature[0] || fileHeader[1] != signature[1] {
+		return nil, binary.Errorf(meta.ErrFormatMismatch, "invalid BMP signature")
+	}
+
+	headerSize := encbinary.LittleEndian.Uint32(fileHeader[fileHeaderSize:])
+	switch headerSize {
+	case coreHeaderSize, 16, infoHeaderSize, 52, 56, 64, v4HeaderSize, v5HeaderSize:
+	default:
+		return nil, binary.Errorf(meta.ErrUnsupported, "unsupported BMP header size %d", headerSize)
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, fileHeader[fileHeaderSize:])
+	if _, err := io.ReadFull(r, header[4:]); err != nil {
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+	}
+	consumed := uint64(fileHeaderSize + headerSize)
+
+	le := encbinary.LittleEndian
+
+	var bitCount uint16
+	var compression uint32
+
+	if headerSize == coreHeaderSize {
+		md.PixelWidth = uint32(le.Uint16(header[4:]))
+		md.PixelHeight = uint32(le.Uint16(header[6:]))
+		bitCount = le.Uint16(header[10:])
+
+	} else {
+		width := int32(le.Uint32(header[4:]))
+		height := int32(le.Uint32(header[8:]))
+		if width < 0 {
+			return nil, binary.Errorf(meta.ErrMalformed, "invalid BMP width %d", width)
+		}
+		if height < 0 {
+			height = -height
+		}
+		md.PixelWidth = uint32(width)
+		md.PixelHeight = uint32(height)
+		bitCount = le.Uint16(header[14:])
+		if headerSize >= infoHeaderSize {
+			compression = le.Uint32(header[16:])
+		}
+	}
+
+	// Colour masks follow the header when not included in it
+	var masks []byte
+	switch {
+	case headerSize >= 56:
+		masks = header[40:56]
+	case headerSize >= 52:
+		masks = header[40:52]
+	case headerSize == infoHeaderSize && (compression == compressionBitFields || compression == compressionAlphaBitFields):
+		masks = make([]byte, 12)
+		if compression == compressionAlphaBitFields {
+			masks = make([]byte, 16)
+		}
+		if _, err := io.ReadFull(r, masks); err != nil {
+			return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+		}
+		consumed += uint64(len(masks))
+	}
+
+	describePixelFormat(md, bitCount, compression, masks)
+
+	if headerSize < v4HeaderSize {
+		return md, nil
+	}
+
+	switch le.Uint32(header[56:]) {
+
+	case lcsCalibratedRGB:
+		describeCalibratedRGB(md, header[60:108])
+
+	case lcsSRGB, lcsWindowsColorSpace:
+		primaries := srgbPrimaries
+		whitePoint := ciexyy.D65
+		md.Primaries = &primaries
+		md.WhitePoint = &whitePoint
+
+	case profileEmbedded, profileLinked:
+		if headerSize < v5HeaderSize {
+			break
+		}
+		profile, err := readProfileData(r, consumed, header, limits)
+		if err != nil {
+			md.SetICCProfileError(err)
+		} else if le.Uint32(header[56:]) == profileEmbedded {
+			md.SetICCProfileData(profile)
+		} else {
+			md.SetICCProfileError(&LinkedProfileError{FileName: linkedProfileName(profile)})
+		}
+	}
+
+	if headerSize >= v5HeaderSize {
+		if intent, ok := renderingIntents[le.Uint32(header[108:])]; ok {
+			md.RenderingIntent = &intent
+		}
+	}
+
+	return md, nil
+}
+
+func describePixelFormat(md *meta.Data, bitCount uint16, compression uint32, masks []byte) {
+	if bitCount == 0 {
+		return
+	}
+
+	if bitCount <= 8 {
+		md.ColorModel = meta.ColorModelPalette
+		md.BitsPerComponent = uint32(bitCount)
+		md.ChannelCount = 1
+		return
+	}
+
+	md.ColorModel = meta.ColorModelRGB
+	md.ChannelCount = 3
+
+	hasMasks := compression == compressionBitFields || compression == compressionAlphaBitFields
+	if !hasMasks {
+		switch bitCount {
+		case 16:
+			md.BitsPerComponent = 5
+		case 64:
+			md.BitsPerComponent = 16
+			md.ChannelCount = 4
+			md.HasAlpha = true
+		default:
+			md.BitsPerComponent = 8
+		}
+		return
+	}
+
+	le := encbinary.LittleEndian
+	for i := 0; i < 3 && len(masks) >= i*4+4; i++ {
+		if n := uint32(bits.OnesCount32(le.Uint32(masks[i*4:]))); n > md.BitsPerComponent {
+			md.BitsPerComponent = n
+		}
+	}
+	if len(masks) >= 16 && le.Uint32(masks[12:]) != 0 {
+		md.ChannelCount = 4
+		md.HasAlpha = true
+	}
+}
+
+// describeCalibratedRGB interprets the endpoints and gamma values of a
+// calibrated RGB colour space.
+func describeCalibratedRGB(md *meta.Data, data []byte) {
+	le := encbinary.LittleEndian
+
+	var endpoints [3][3]float64
+	var white [3]float64
+	for i := range endpoints {
+		for j := range endpoints[i] {
+			endpoints[i][j] = float64(le.Uint32(data[i*12+j*4:])) / fixedPoint2Dot30Scale
+			white[j] += endpoints[i][j]
+		}
+	}
+
+	if white[0]+white[1]+white[2] > 0 {
+		md.Primaries = &meta.Primaries{
+			Red:   chromaticity(endpoints[0]),
+			Green: chromaticity(endpoints[1]),
+			Blue:  chromaticity(endpoints[2]),
+		}
+		whitePoint := chromaticity(white)
+		md.WhitePoint = &whitePoint
+	}
+
+	gammaRed := le.Uint32(data[36:])
+	if gammaRed != 0 && gammaRed == le.Uint32(data[40:]) && gammaRed == le.Uint32(data[44:]) {
+		md.Gamma = float64(gammaRed) / fixedPoint16Dot16Scale
+	}
+}
+
+func chromaticity(xyz [3]float64) ciexyy.Color {
+	sum := xyz[0] + xyz[1] + xyz[2]
+	if sum == 0 {
+		return ciexyy.Color{}
+	}
+	return ciexyy.Color{X: float32(xyz[0] / sum), Y: float32(xyz[1] / sum), YY: float32(xyz[1])}
+}
+
+// readProfileData reads the profile data referred to by a V5 header, skipping
+// any intervening data (usually the pixel data). Exceeding a limit while doing
+// so is reported as such rather than as an unexpected EOF.
+func readProfileData(r io.Reader, consumed uint64, header []byte, limits meta.Limits) ([]byte, error) {
+	le := encbinary.LittleEndian
+
+	offset := uint64(fileHeaderSize) + uint64(le.Uint32(header[112:]))
+	size := le.Uint32(header[116:])
+
+	if offset < consumed {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid profile offset %d", offset)
+	}
+	if size == 0 {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid profile size 0")
+	}
+	if err := limits.CheckICCProfileSize(int64(size)); err != nil {
+		return nil, err
+	}
+
+	var limitErr *meta.LimitError
+
+	if err := binary.Skip(r, int64(offset-consumed)); err != nil {
+		if errors.As(err, &limitErr) {
+			return nil, err
+		}
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading profile data")
+	}
+
+	profile := &bytes.Buffer{}
+	if _, err := io.CopyN(profile, r, int64(size)); err != nil {
+		if errors.As(err, &limitErr) {
+			return nil, err
+		}
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading profile data")
+	}
+
+	return profile.Bytes(), nil
+}
+
+// linkedProfileName interprets the data of a linked profile as a
+// null-terminated Windows-1252 file name.
+func linkedProfileName(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+
+	name := make([]rune, len(data))
+	for i, b := range data {
+		name[i] = rune(b)
+		if b >= 0x80 && b < 0xA0 {
+			name[i] = windows1252[b-0x80]
+		}
+	}
+	return string(name)
+}
+
+// windows1252 maps the bytes 0x80–0x9F of Windows-1252, which differ from
+// ISO-8859-1, to their Unicode code points.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
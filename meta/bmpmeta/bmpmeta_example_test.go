@@ -0,0 +1,97 @@
+package bmpmeta_test
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/bmpmeta"
+	"golang.org/x/image/bmp"
+)
+
+func printICCProfile(md *meta.Data) {
+	profile, err := md.ICCProfile()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("ProfileSize: %d\n", profile.Header.ProfileSize)
+	fmt.Printf("ProfileVersion: %s\n", profile.Header.Version)
+	fmt.Printf("DeviceClass: %s\n", profile.Header.DeviceClass)
+	fmt.Printf("DataColorSpace: %s\n", profile.Header.DataColorSpace)
+	fmt.Printf("PCS: %s\n", profile.Header.ProfileConnectionSpace)
+
+	if desc, err := profile.Description(); err != nil {
+		panic(err)
+	} else {
+		fmt.Printf("Description: %s\n", desc)
+	}
+}
+
+func printMetadata(md *meta.Data, img image.Image) {
+	fmt.Printf("Format: %s\n", md.Format)
+	fmt.Printf("BitsPerComponent: %d\n", md.BitsPerComponent)
+	fmt.Printf("PixelHeight: %d\n", md.PixelHeight)
+	fmt.Printf("PixelWidth: %d\n", md.PixelWidth)
+	fmt.Printf("ColorModel: %s\n", md.ColorModel)
+	fmt.Printf("ChannelCount: %d\n", md.ChannelCount)
+	fmt.Printf("RenderingIntent: %v\n", *md.RenderingIntent)
+
+	fmt.Printf("Actual image height: %d\n", img.Bounds().Dy())
+	fmt.Printf("Actual image width: %d\n", img.Bounds().Dx())
+}
+
+func ExampleLoad_basicBMPMetadata() {
+	inFile, err := os.Open("../../test-images/pizza-rgb8-prophotorgb-v5.bmp")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, imgStream, err := bmpmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	img, err := bmp.Decode(imgStream)
+	if err != nil {
+		panic(err)
+	}
+
+	printMetadata(md, img)
+
+	// Output:
+	// Format: BMP
+	// BitsPerComponent: 8
+	// PixelHeight: 64
+	// PixelWidth: 64
+	// ColorModel: RGB
+	// ChannelCount: 3
+	// RenderingIntent: Perceptual
+	// Actual image height: 64
+	// Actual image width: 64
+}
+
+func ExampleLoad_embeddedICCv2() {
+	inFile, err := os.Open("../../test-images/pizza-rgb8-prophotorgb-v5.bmp")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, _, err := bmpmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	printICCProfile(md)
+
+	// Output:
+	// ProfileSize: 940
+	// ProfileVersion: 2.1.0
+	// DeviceClass: Display
+	// DataColorSpace: RGB
+	// PCS: XYZ
+	// Description: ProPhoto RGB
+}
@@ -0,0 +1,252 @@
+package bmpmeta
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"errors"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/icc"
+	"math"
+	"testing"
+)
+
+// buildBMP constructs a BMP stream with the specified DIB header, followed by
+// the specified trailing data (such as masks, pixels, or profile data).
+func buildBMP(header []byte, trailing ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("BM")
+	encbinary.Write(buf, encbinary.LittleEndian, uint32(0))
+	encbinary.Write(buf, encbinary.LittleEndian, uint32(0))
+	encbinary.Write(buf, encbinary.LittleEndian, uint32(fileHeaderSize+len(header)))
+	buf.Write(header)
+	for _, t := range trailing {
+		buf.Write(t)
+	}
+	return buf.Bytes()
+}
+
+func infoHeader(size int, width, height int32, bitCount uint16, compression uint32) []byte {
+	h := make([]byte, size)
+	le := encbinary.LittleEndian
+	le.PutUint32(h[0:], uint32(size))
+	le.PutUint32(h[4:], uint32(width))
+	le.PutUint32(h[8:], uint32(height))
+	le.PutUint16(h[12:], 1)
+	le.PutUint16(h[14:], bitCount)
+	le.PutUint32(h[16:], compression)
+	return h
+}
+
+func u32s(values ...uint32) []byte {
+	b := make([]byte, len(values)*4)
+	for i, v := range values {
+		encbinary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+func TestExtractMetadata(t *testing.T) {
+
+	t.Run("returns metadata for core header", func(t *testing.T) {
+		h := make([]byte, coreHeaderSize)
+		copy(h, u32s(coreHeaderSize))
+		copy(h[4:], []byte{0x20, 0x01, 0xF0, 0x00, 1, 0, 8, 0})
+
+		md, err := extractMetadata(bytes.NewReader(buildBMP(h)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if md.PixelWidth != 288 || md.PixelHeight != 240 {
+			t.Errorf("Expected dimensions 288x240 but got %dx%d", md.PixelWidth, md.PixelHeight)
+		}
+		if expected, actual := meta.ColorModelPalette, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		if expected, actual := uint32(8), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected %d bits per component but got %d", expected, actual)
+		}
+	})
+
+	t.Run("returns metadata for top-down info header with bit field masks", func(t *testing.T) {
+		h := infoHeader(infoHeaderSize, 640, -480, 32, compressionAlphaBitFields)
+		masks := u32s(0x3FF00000, 0x000FFC00, 0x000003FF, 0xC0000000)
+
+		md, err := extractMetadata(bytes.NewReader(buildBMP(h, masks)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if md.PixelWidth != 640 || md.PixelHeight != 480 {
+			t.Errorf("Expected dimensions 640x480 but got %dx%d", md.PixelWidth, md.PixelHeight)
+		}
+		if expected, actual := uint32(10), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected %d bits per component but got %d", expected, actual)
+		}
+		if expected, actual := uint32(4), md.ChannelCount; expected != actual {
+			t.Errorf("Expected %d channels but got %d", expected, actual)
+		}
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+		if md.Primaries != nil || md.CICP != nil {
+			t.Errorf("Expected no colour space information for info header")
+		}
+	})
+
+	t.Run("interprets sRGB colour space", func(t *testing.T) {
+		for _, csType := range []uint32{lcsSRGB, lcsWindowsColorSpace} {
+			h := infoHeader(v4HeaderSize, 16, 16, 24, 0)
+			copy(h[56:], u32s(csType))
+
+			md, err := extractMetadata(bytes.NewReader(buildBMP(h)), meta.DefaultLimits)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if md.CICP != nil {
+				t.Errorf("Expected no CICP but got %+v", md.CICP)
+			}
+			if md.WhitePoint == nil || *md.WhitePoint != ciexyy.D65 {
+				t.Errorf("Expected D65 white point but got %+v", md.WhitePoint)
+			}
+			if md.Primaries == nil || *md.Primaries != srgbPrimaries {
+				t.Errorf("Expected sRGB primaries but got %+v", md.Primaries)
+			}
+		}
+	})
+
+	t.Run("interprets calibrated RGB endpoints and gamma", func(t *testing.T) {
+		h := infoHeader(v4HeaderSize, 16, 16, 24, 0)
+		copy(h[56:], u32s(lcsCalibratedRGB))
+
+		// Adobe RGB primaries with D65 white
+		xyz := [][3]float64{
+			{0.57667, 0.29734, 0.02703},
+			{0.18556, 0.62736, 0.07069},
+			{0.18823, 0.07529, 0.99134},
+		}
+		for i, e := range xyz {
+			for j, v := range e {
+				copy(h[60+i*12+j*4:], u32s(uint32(v*fixedPoint2Dot30Scale)))
+			}
+		}
+		gamma := uint32(2.19921875 * fixedPoint16Dot16Scale)
+		copy(h[96:], u32s(gamma, gamma, gamma))
+
+		md, err := extractMetadata(bytes.NewReader(buildBMP(h)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		checkChromaticity := func(name string, expected ciexyy.Color, actual ciexyy.Color) {
+			if math.Abs(float64(expected.X-actual.X)) > 0.0005 || math.Abs(float64(expected.Y-actual.Y)) > 0.0005 {
+				t.Errorf("Expected %s chromaticity %+v but got %+v", name, expected, actual)
+			}
+		}
+
+		if md.Primaries == nil || md.WhitePoint == nil {
+			t.Fatalf("Expected primaries and white point but got %+v and %+v", md.Primaries, md.WhitePoint)
+		}
+		checkChromaticity("red", ciexyy.Color{X: 0.64, Y: 0.33}, md.Primaries.Red)
+		checkChromaticity("green", ciexyy.Color{X: 0.21, Y: 0.71}, md.Primaries.Green)
+		checkChromaticity("blue", ciexyy.Color{X: 0.15, Y: 0.06}, md.Primaries.Blue)
+		checkChromaticity("white", ciexyy.D65, *md.WhitePoint)
+
+		if expected, actual := 2.19921875, md.Gamma; expected != actual {
+			t.Errorf("Expected gamma %v but got %v", expected, actual)
+		}
+		if md.CICP != nil {
+			t.Errorf("Expected no CICP but got %+v", md.CICP)
+		}
+	})
+
+	t.Run("extracts embedded profile after pixel data", func(t *testing.T) {
+		profile := []byte("profile data")
+		pixels := make([]byte, 4*4*3)
+
+		h := infoHeader(v5HeaderSize, 4, 4, 24, 0)
+		copy(h[56:], u32s(profileEmbedded))
+		copy(h[108:], u32s(2, uint32(v5HeaderSize+len(pixels)), uint32(len(profile))))
+
+		md, err := extractMetadata(bytes.NewReader(buildBMP(h, pixels, profile)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		iccData, err := md.ICCProfileData()
+		if err != nil {
+			t.Errorf("Expected ICC profile data but got error: %v", err)
+		} else if !bytes.Equal(profile, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", profile, iccData)
+		}
+		if md.RenderingIntent == nil || *md.RenderingIntent != icc.RelativeColorimetricRenderingIntent {
+			t.Errorf("Expected relative colorimetric rendering intent but got %v", md.RenderingIntent)
+		}
+	})
+
+	t.Run("reports truncated embedded profile as profile error", func(t *testing.T) {
+		h := infoHeader(v5HeaderSize, 4, 4, 24, 0)
+		copy(h[56:], u32s(profileEmbedded))
+		copy(h[108:], u32s(4, v5HeaderSize, 100))
+
+		md, err := extractMetadata(bytes.NewReader(buildBMP(h, []byte("short"))), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if _, err := md.ICCProfileData(); err == nil {
+			t.Errorf("Expected an ICC profile error but got none")
+		}
+	})
+
+	t.Run("reports linked profile name", func(t *testing.T) {
+		name := append([]byte("C:\\Profiles\\Caf\xe9 \x93RGB\x94.icc"), 0, 0, 0)
+
+		h := infoHeader(v5HeaderSize, 4, 4, 24, 0)
+		copy(h[56:], u32s(profileLinked))
+		copy(h[108:], u32s(4, v5HeaderSize, uint32(len(name))))
+
+		md, err := extractMetadata(bytes.NewReader(buildBMP(h, name)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		_, iccErr := md.ICCProfileData()
+
+		var linkedErr *LinkedProfileError
+		if !errors.As(iccErr, &linkedErr) {
+			t.Fatalf("Expected a linked profile error but got: %v", iccErr)
+		}
+		if expected, actual := "C:\\Profiles\\Café “RGB”.icc", linkedErr.FileName; expected != actual {
+			t.Errorf("Expected file name '%s' but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("returns error for invalid signature", func(t *testing.T) {
+		data := buildBMP(infoHeader(infoHeaderSize, 1, 1, 24, 0))
+		data[0] = 'X'
+
+		_, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for unsupported header size", func(t *testing.T) {
+		_, err := extractMetadata(bytes.NewReader(buildBMP(infoHeader(44, 1, 1, 24, 0))), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrUnsupported) {
+			t.Errorf("Expected unsupported error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for truncated header", func(t *testing.T) {
+		data := buildBMP(infoHeader(infoHeaderSize, 1, 1, 24, 0))
+
+		_, err := extractMetadata(bytes.NewReader(data[:30]), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,2 @@
+// Package bmpmeta provides support for working with embedded BMP metadata.
+package bmpmeta
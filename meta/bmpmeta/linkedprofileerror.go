@@ -0,0 +1,15 @@
+package bmpmeta
+
+import "fmt"
+
+// LinkedProfileError is reported as the ICC profile error of a BMP image whose
+// colour space refers to an ICC profile stored in an external file, rather
+// than one embedded in the image.
+type LinkedProfileError struct {
+	// FileName is the name of the file containing the linked profile.
+	FileName string
+}
+
+func (e *LinkedProfileError) Error() string {
+	return fmt.Sprintf("ICC profile is linked to external file '%s'", e.FileName)
+}
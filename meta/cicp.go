@@ -0,0 +1,11 @@
+package meta
+
+// CICP holds the coding-independent code points (as defined in ITU-T H.273)
+// which describe the colour of an image without an ICC profile, such as those
+// from a HEIF/AVIF nclx colour box.
+type CICP struct {
+	ColorPrimaries          uint16
+	TransferCharacteristics uint16
+	MatrixCoefficients      uint16
+	FullRange               bool
+}
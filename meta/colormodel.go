@@ -0,0 +1,40 @@
+package meta
+
+import "fmt"
+
+// ColorModel describes how the colour of each pixel of an image is stored.
+type ColorModel int
+
+const (
+	ColorModelUnknown ColorModel = iota
+	ColorModelGreyscale
+	ColorModelRGB
+	ColorModelPalette
+	ColorModelCMYK
+	ColorModelYCbCr
+	ColorModelYCCK
+	ColorModelLab
+)
+
+func (cm ColorModel) String() string {
+	switch cm {
+	case ColorModelUnknown:
+		return "Unknown"
+	case ColorModelGreyscale:
+		return "Greyscale"
+	case ColorModelRGB:
+		return "RGB"
+	case ColorModelPalette:
+		return "Palette"
+	case ColorModelCMYK:
+		return "CMYK"
+	case ColorModelYCbCr:
+		return "YCbCr"
+	case ColorModelYCCK:
+		return "YCCK"
+	case ColorModelLab:
+		return "Lab"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(cm))
+	}
+}
@@ -0,0 +1,15 @@
+package meta
+
+// Component describes one of the colour components of an image as stored,
+// such as those declared by the frame header of a JPEG image.
+type Component struct {
+	// ID is the identifier of the component.
+	ID uint8
+
+	// HorizontalSampling and VerticalSampling are the sampling factors of the
+	// component relative to the other components. A component sampled at
+	// half the horizontal resolution of another has half its horizontal
+	// sampling factor.
+	HorizontalSampling uint8
+	VerticalSampling   uint8
+}
@@ -0,0 +1,11 @@
+package meta
+
+// ContentLightLevel describes the light levels of HDR content.
+type ContentLightLevel struct {
+	// MaxCLL is the maximum light level of any pixel in the content in cd/m².
+	MaxCLL float64
+
+	// MaxFALL is the maximum frame-average light level of the content in
+	// cd/m².
+	MaxFALL float64
+}
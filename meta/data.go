@@ -2,7 +2,10 @@ package meta
 
 import (
 	"bytes"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta/exif"
 	"github.com/mandykoh/prism/meta/icc"
+	"github.com/mandykoh/prism/meta/xmp"
 )
 
 // Data represents the metadata for an image.
@@ -11,12 +14,87 @@ type Data struct {
 	PixelWidth       uint32
 	PixelHeight      uint32
 	BitsPerComponent uint32
-	iccProfileData   []byte
-	iccProfileErr    error
+
+	// ColorModel is the model by which pixel colours are stored, if known.
+	ColorModel ColorModel
+
+	// ChannelCount is the number of channels (including alpha) stored for each
+	// pixel, if known.
+	ChannelCount uint32
+
+	// HasAlpha indicates whether the image has an alpha channel.
+	HasAlpha bool
+
+	// Interlaced indicates whether the image data is stored interlaced or
+	// progressively, such that a coarse version of the image can be displayed
+	// before all the data has been decoded.
+	Interlaced bool
+
+	// Animated indicates whether the image is an animation.
+	Animated bool
+
+	// Components describes each of the colour components of the image as
+	// stored, or nil if not known. This is currently only populated for JPEG
+	// images.
+	Components []Component
+
+	// AdobeTransform is the colour transform specified by the Adobe APP14
+	// segment of a JPEG image, or nil if none was found.
+	AdobeTransform *AdobeTransform
+
+	// FrameCount is the number of frames in the image, if known. Still images
+	// have a single frame.
+	FrameCount uint32
+
+	// LoopCount is the number of times an animated image is intended to be
+	// played, or zero if it should loop indefinitely.
+	LoopCount uint32
+
+	// CICP holds the colour description of the image in terms of code points
+	// from ITU-T H.273, or nil if none was found. Where an image has both CICP
+	// and an ICC profile (eg a PNG image with cICP and iCCP chunks), both are
+	// reported and CICP takes precedence.
+	CICP *CICP
+
+	// WhitePoint is the chromaticity of the image's reference white, or nil if
+	// none was specified independently of an ICC profile.
+	WhitePoint *ciexyy.Color
+
+	// Primaries are the chromaticities of the image's RGB primaries, or nil if
+	// none were specified independently of an ICC profile.
+	Primaries *Primaries
+
+	// Gamma is the exponent of a simple power law used to decode pixel values
+	// to linear light (eg 2.2), or zero if none was specified.
+	Gamma float64
+
+	// RenderingIntent is the rendering intent specified for the image
+	// independently of an ICC profile, or nil if none was specified.
+	RenderingIntent *icc.RenderingIntent
+
+	// MasteringDisplay describes the display on which HDR content was
+	// mastered, or nil if not specified.
+	MasteringDisplay *MasteringDisplay
+
+	// ContentLightLevel describes the light levels of HDR content, or nil if
+	// not specified.
+	ContentLightLevel *ContentLightLevel
+
+	// EXIF holds the colour-related tags from the image's EXIF metadata, or nil
+	// if none was found or it could not be parsed.
+	EXIF *exif.Data
+
+	limits          *Limits
+	iccProfileData  []byte
+	iccProfileErr   error
+	xmpData         []byte
+	extendedXMPData []byte
 }
 
 // ICCProfile returns an extracted ICC profile from this metadata.
 //
+// The profile is parsed subject to the MaxICCProfileSize and MaxICCTagCount
+// limits the metadata was loaded with (or DefaultLimits if none were set).
 // An error is returned if the ICC profile could not be correctly parsed.
 //
 // If no profile data was found, nil is returned without an error.
@@ -25,7 +103,15 @@ func (md *Data) ICCProfile() (*icc.Profile, error) {
 		return nil, md.iccProfileErr
 	}
 
-	return icc.NewProfileReader(bytes.NewReader(md.iccProfileData)).ReadProfile()
+	limits := DefaultLimits
+	if md.limits != nil {
+		limits = *md.limits
+	}
+
+	reader := icc.NewProfileReader(bytes.NewReader(md.iccProfileData))
+	reader.MaxProfileSize = limits.MaxICCProfileSize
+	reader.MaxTagCount = limits.MaxICCTagCount
+	return reader.ReadProfile()
 }
 
 // ICCProfile returns the raw ICC profile data from this metadata.
@@ -38,6 +124,12 @@ func (md *Data) ICCProfileData() ([]byte, error) {
 	return md.iccProfileData, md.iccProfileErr
 }
 
+// SetLimits sets the limits to which parsing of the metadata (such as the ICC
+// profile) is subject. Loaders set these to the limits they were given.
+func (md *Data) SetLimits(limits Limits) {
+	md.limits = &limits
+}
+
 func (md *Data) SetICCProfileData(data []byte) {
 	md.iccProfileData = data
 	md.iccProfileErr = nil
@@ -47,3 +139,41 @@ func (md *Data) SetICCProfileError(err error) {
 	md.iccProfileData = nil
 	md.iccProfileErr = err
 }
+
+// XMP returns the colour-related properties from the XMP metadata, combining
+// the standard and extended XMP packets if both are present.
+//
+// An error is returned if the XMP packets could not be correctly parsed.
+//
+// If no XMP packet was found, nil is returned without an error.
+func (md *Data) XMP() (*xmp.Properties, error) {
+	switch {
+	case md.xmpData == nil:
+		return nil, nil
+	case md.extendedXMPData == nil:
+		return xmp.Parse(md.xmpData)
+	default:
+		return xmp.Parse(md.xmpData, md.extendedXMPData)
+	}
+}
+
+// XMPData returns the raw standard XMP packet from this metadata, or nil if
+// none was found.
+func (md *Data) XMPData() []byte {
+	return md.xmpData
+}
+
+// ExtendedXMPData returns the raw extended XMP packet from this metadata, or
+// nil if none was found. Extended XMP is used by JPEG images for XMP metadata
+// too large to fit in a single segment.
+func (md *Data) ExtendedXMPData() []byte {
+	return md.extendedXMPData
+}
+
+func (md *Data) SetXMPData(data []byte) {
+	md.xmpData = data
+}
+
+func (md *Data) SetExtendedXMPData(data []byte) {
+	md.extendedXMPData = data
+}
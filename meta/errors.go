@@ -0,0 +1,32 @@
+package meta
+
+import (
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// The kinds of error returned when extracting metadata, which can be
+// distinguished using errors.Is. These are shared with the icc package, so
+// that errors from parsing ICC profiles can be distinguished in the same way.
+var (
+	// ErrFormatMismatch indicates that an image isn't of the format expected
+	// by the loader, or isn't of any recognised format.
+	ErrFormatMismatch = binary.ErrFormatMismatch
+
+	// ErrTruncated indicates that an image (or an ICC profile) ended before
+	// the metadata was complete.
+	ErrTruncated = binary.ErrTruncated
+
+	// ErrMalformed indicates that the metadata of an image (or an ICC profile)
+	// is corrupt. Where the location of the corruption is known, a
+	// MalformedError is returned.
+	ErrMalformed = binary.ErrMalformed
+
+	// ErrUnsupported indicates that an image uses a feature of its format
+	// which isn't supported.
+	ErrUnsupported = binary.ErrUnsupported
+)
+
+// MalformedError describes a malformed chunk, segment or box of an image, along
+// with its offset from the start of the image stream. It can be retrieved from
+// an error using errors.As.
+type MalformedError = binary.MalformedError
@@ -0,0 +1,33 @@
+package exif
+
+import "fmt"
+
+// ColorSpace is the value of the EXIF ColorSpace tag.
+type ColorSpace uint16
+
+const (
+	// ColorSpaceUnspecified indicates that the ColorSpace tag was not present.
+	ColorSpaceUnspecified ColorSpace = 0
+
+	// ColorSpaceSRGB indicates that the image is in the sRGB colour space.
+	ColorSpaceSRGB ColorSpace = 1
+
+	// ColorSpaceUncalibrated indicates that the image is in some colour space
+	// other than sRGB. Under the Design rule for Camera File system (DCF),
+	// this combined with an interoperability index of "R03" indicates the
+	// Adobe RGB colour space.
+	ColorSpaceUncalibrated ColorSpace = 0xFFFF
+)
+
+func (cs ColorSpace) String() string {
+	switch cs {
+	case ColorSpaceUnspecified:
+		return "Unspecified"
+	case ColorSpaceSRGB:
+		return "sRGB"
+	case ColorSpaceUncalibrated:
+		return "Uncalibrated"
+	default:
+		return fmt.Sprintf("Unknown (%d)", cs)
+	}
+}
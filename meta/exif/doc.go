@@ -0,0 +1,3 @@
+// Package exif provides support for working with the colour-related tags of
+// embedded EXIF metadata.
+package exif
@@ -0,0 +1,365 @@
+package exif
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"fmt"
+	"github.com/mandykoh/prism/ciexyy"
+	"io"
+	"math"
+)
+
+var exifIdentifier = []byte("Exif\x00\x00")
+
+// Values of the supported tags are small, so larger values are rejected to
+// avoid allocating memory for malformed data
+const maxValueLength = 1024
+
+const (
+	classicTIFFVersion = 42
+	bigTIFFVersion     = 43
+)
+
+// Data holds the colour-related tags extracted from EXIF metadata. Tags which
+// were not present are left with their zero values.
+type Data struct {
+	// ColorSpace is the value of the ColorSpace tag.
+	ColorSpace ColorSpace
+
+	// InteroperabilityIndex identifies the interoperability rule set the image
+	// conforms to; "R98" indicates sRGB and "R03" indicates Adobe RGB under
+	// DCF.
+	InteroperabilityIndex string
+
+	// Orientation is the value of the Orientation tag.
+	Orientation Orientation
+
+	// WhitePoint is the chromaticity of the image's white point, or nil if not
+	// specified.
+	WhitePoint *ciexyy.Color
+
+	// PrimaryChromaticities are the chromaticities of the red, green, and blue
+	// primaries (in that order), or nil if not specified.
+	PrimaryChromaticities *[3]ciexyy.Color
+
+	// Gamma is the exponent of the transfer function of the image, or zero if
+	// not specified.
+	Gamma float64
+}
+
+// IsAdobeRGB returns true if the EXIF data indicates the Adobe RGB colour
+// space according to DCF conventions, being an uncalibrated colour space with
+// an interoperability index of "R03".
+func (d *Data) IsAdobeRGB() bool {
+	return d.ColorSpace == ColorSpaceUncalibrated && d.InteroperabilityIndex == "R03"
+}
+
+// Parse extracts the colour-related tags from a block of EXIF data, being a
+// TIFF structure optionally preceded by the "Exif\0\0" identifier used by JPEG
+// APP1 segments.
+func Parse(data []byte) (*Data, error) {
+	if bytes.HasPrefix(data, exifIdentifier) {
+		data = data[len(exifIdentifier):]
+	}
+	return Read(bytes.NewReader(data))
+}
+
+// Read extracts the colour-related tags from EXIF data stored as a TIFF or
+// BigTIFF structure, beginning at offset zero of the specified reader.
+func Read(r io.ReaderAt) (*Data, error) {
+	er := &reader{r: r}
+
+	header, err := er.bytesAt(0, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	switch string(header[:2]) {
+	case "II":
+		er.order = encbinary.LittleEndian
+	case "MM":
+		er.order = encbinary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid EXIF byte order")
+	}
+
+	var ifdOffset uint64
+
+	switch er.order.Uint16(header[2:]) {
+
+	case classicTIFFVersion:
+		ifdOffset = uint64(er.order.Uint32(header[4:]))
+
+	case bigTIFFVersion:
+		er.bigTIFF = true
+
+		offset, err := er.bytesAt(8, 8)
+		if err != nil {
+			return nil, err
+		}
+		ifdOffset = er.order.Uint64(offset)
+
+	default:
+		return nil, fmt.Errorf("invalid EXIF signature")
+	}
+
+	d := &Data{}
+
+	entries, err := er.readIFD(ifdOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		switch e.tag {
+
+		case tagOrientation:
+			if v, err := er.uintValue(e); err == nil {
+				d.Orientation = Orientation(v)
+			}
+
+		case tagWhitePoint:
+			if v, err := er.rationalValues(e, 2); err == nil {
+				d.WhitePoint = &ciexyy.Color{X: float32(v[0]), Y: float32(v[1]), YY: 1}
+			}
+
+		case tagPrimaryChromaticities:
+			if v, err := er.rationalValues(e, 6); err == nil {
+				d.PrimaryChromaticities = &[3]ciexyy.Color{
+					{X: float32(v[0]), Y: float32(v[1]), YY: 1},
+					{X: float32(v[2]), Y: float32(v[3]), YY: 1},
+					{X: float32(v[4]), Y: float32(v[5]), YY: 1},
+				}
+			}
+
+		case tagExifIFD:
+			offset, err := er.uintValue(e)
+			if err != nil {
+				return nil, err
+			}
+			if err := er.readExifIFD(offset, d); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func (er *reader) readExifIFD(offset uint64, d *Data) error {
+	entries, err := er.readIFD(offset)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		switch e.tag {
+
+		case tagColorSpace:
+			if v, err := er.uintValue(e); err == nil {
+				d.ColorSpace = ColorSpace(v)
+			}
+
+		case tagGamma:
+			if v, err := er.rationalValues(e, 1); err == nil {
+				d.Gamma = v[0]
+			}
+
+		case tagInteroperabilityIFD:
+			offset, err := er.uintValue(e)
+			if err != nil {
+				return err
+			}
+			if err := er.readInteroperabilityIFD(offset, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (er *reader) readInteroperabilityIFD(offset uint64, d *Data) error {
+	entries, err := er.readIFD(offset)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.tag == tagInteroperabilityIndex && (e.fieldType == fieldTypeASCII || e.fieldType == fieldTypeUndefined) {
+			v, err := er.valueBytes(e)
+			if err != nil {
+				return err
+			}
+			if i := bytes.IndexByte(v, 0); i >= 0 {
+				v = v[:i]
+			}
+			d.InteroperabilityIndex = string(v)
+		}
+	}
+
+	return nil
+}
+
+type ifdEntry struct {
+	tag       tag
+	fieldType fieldType
+	count     uint64
+	value     []byte
+}
+
+type reader struct {
+	r       io.ReaderAt
+	order   encbinary.ByteOrder
+	bigTIFF bool
+}
+
+func (er *reader) bytesAt(offset, length uint64) ([]byte, error) {
+	end := offset + length
+	if end < offset || end > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+
+	data := make([]byte, length)
+	n, err := er.r.ReadAt(data, int64(offset))
+	if uint64(n) < length {
+		if err == nil || err == io.EOF {
+			return nil, fmt.Errorf("unexpected EOF")
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (er *reader) readIFD(offset uint64) ([]ifdEntry, error) {
+	countSize, entrySize, valueSize := uint64(2), uint64(12), uint64(4)
+	if er.bigTIFF {
+		countSize, entrySize, valueSize = 8, 20, 8
+	}
+
+	countBytes, err := er.bytesAt(offset, countSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var count uint64
+	if er.bigTIFF {
+		count = er.order.Uint64(countBytes)
+	} else {
+		count = uint64(er.order.Uint16(countBytes))
+	}
+
+	if count > 0xFFFF {
+		return nil, fmt.Errorf("invalid IFD entry count %d", count)
+	}
+
+	data, err := er.bytesAt(offset+countSize, count*entrySize)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ifdEntry, count)
+	for i := range entries {
+		e := data[uint64(i)*entrySize:]
+		entries[i].tag = tag(er.order.Uint16(e))
+		entries[i].fieldType = fieldType(er.order.Uint16(e[2:]))
+		if er.bigTIFF {
+			entries[i].count = er.order.Uint64(e[4:])
+			entries[i].value = e[12 : 12+valueSize]
+		} else {
+			entries[i].count = uint64(er.order.Uint32(e[4:]))
+			entries[i].value = e[8 : 8+valueSize]
+		}
+	}
+
+	return entries, nil
+}
+
+// uintValue returns the first value of an integer or offset field.
+func (er *reader) uintValue(e ifdEntry) (uint64, error) {
+	if e.count == 0 {
+		return 0, fmt.Errorf("missing value for tag %d", e.tag)
+	}
+
+	v, err := er.valueBytes(e)
+	if err != nil {
+		return 0, err
+	}
+
+	switch e.fieldType {
+	case fieldTypeByte:
+		return uint64(v[0]), nil
+	case fieldTypeShort:
+		return uint64(er.order.Uint16(v)), nil
+	case fieldTypeLong, fieldTypeIFD:
+		return uint64(er.order.Uint32(v)), nil
+	case fieldTypeLong8, fieldTypeIFD8:
+		return er.order.Uint64(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected field type %d for tag %d", e.fieldType, e.tag)
+	}
+}
+
+// rationalValues returns the specified number of values of a rational field.
+func (er *reader) rationalValues(e ifdEntry, count int) ([]float64, error) {
+	if e.count < uint64(count) {
+		return nil, fmt.Errorf("expected %d values for tag %d but found %d", count, e.tag, e.count)
+	}
+	if e.fieldType != fieldTypeRational && e.fieldType != fieldTypeSRational {
+		return nil, fmt.Errorf("unexpected field type %d for tag %d", e.fieldType, e.tag)
+	}
+
+	v, err := er.valueBytes(e)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, count)
+	for i := range values {
+		numerator := er.order.Uint32(v[i*8:])
+		denominator := er.order.Uint32(v[i*8+4:])
+		if denominator == 0 {
+			return nil, fmt.Errorf("invalid rational value for tag %d", e.tag)
+		}
+
+		if e.fieldType == fieldTypeSRational {
+			values[i] = float64(int32(numerator)) / float64(int32(denominator))
+		} else {
+			values[i] = float64(numerator) / float64(denominator)
+		}
+	}
+
+	return values, nil
+}
+
+// valueBytes returns the raw bytes of all the values of a field.
+func (er *reader) valueBytes(e ifdEntry) ([]byte, error) {
+	size := e.fieldType.size()
+	if size == 0 {
+		return nil, fmt.Errorf("unknown field type %d for tag %d", e.fieldType, e.tag)
+	}
+
+	length := size * e.count
+	if e.count != 0 && length/e.count != size {
+		return nil, fmt.Errorf("invalid value count for tag %d", e.tag)
+	}
+	if length > maxValueLength {
+		return nil, fmt.Errorf("value too large for tag %d", e.tag)
+	}
+
+	// Values which fit within the entry are stored inline; otherwise the entry
+	// contains their offset
+	if length <= uint64(len(e.value)) {
+		return e.value[:length], nil
+	}
+
+	var offset uint64
+	if er.bigTIFF {
+		offset = er.order.Uint64(e.value)
+	} else {
+		offset = uint64(er.order.Uint32(e.value))
+	}
+
+	return er.bytesAt(offset, length)
+}
@@ -0,0 +1,222 @@
+package exif
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"math"
+	"testing"
+)
+
+type testEntry struct {
+	tag       tag
+	fieldType fieldType
+	count     uint32
+	value     []byte
+}
+
+// buildEXIF lays out a classic TIFF structure containing the specified IFDs.
+// Pointers to the Exif and Interoperability IFDs are added automatically when
+// those IFDs are non-nil.
+func buildEXIF(order encbinary.ByteOrder, ifd0, exifIFD, interopIFD []testEntry) []byte {
+	ifdSize := func(entries []testEntry) uint32 {
+		return uint32(2 + 12*len(entries) + 4)
+	}
+
+	if exifIFD != nil {
+		ifd0 = append(ifd0, testEntry{tagExifIFD, fieldTypeLong, 1, nil})
+	}
+	if interopIFD != nil {
+		exifIFD = append(exifIFD, testEntry{tagInteroperabilityIFD, fieldTypeLong, 1, nil})
+	}
+
+	exifOffset := 8 + ifdSize(ifd0)
+	interopOffset := exifOffset + ifdSize(exifIFD)
+	dataOffset := interopOffset + ifdSize(interopIFD)
+	if interopIFD == nil {
+		dataOffset = interopOffset
+	}
+
+	header := &bytes.Buffer{}
+	extra := &bytes.Buffer{}
+
+	if order == encbinary.LittleEndian {
+		header.WriteString("II")
+	} else {
+		header.WriteString("MM")
+	}
+	_ = encbinary.Write(header, order, uint16(classicTIFFVersion))
+	_ = encbinary.Write(header, order, uint32(8))
+
+	writeIFD := func(entries []testEntry) {
+		_ = encbinary.Write(header, order, uint16(len(entries)))
+		for _, e := range entries {
+			switch e.tag {
+			case tagExifIFD:
+				e.value = make([]byte, 4)
+				order.PutUint32(e.value, exifOffset)
+			case tagInteroperabilityIFD:
+				e.value = make([]byte, 4)
+				order.PutUint32(e.value, interopOffset)
+			}
+
+			_ = encbinary.Write(header, order, uint16(e.tag))
+			_ = encbinary.Write(header, order, uint16(e.fieldType))
+			_ = encbinary.Write(header, order, e.count)
+
+			if len(e.value) <= 4 {
+				value := [4]byte{}
+				copy(value[:], e.value)
+				header.Write(value[:])
+			} else {
+				_ = encbinary.Write(header, order, dataOffset+uint32(extra.Len()))
+				extra.Write(e.value)
+			}
+		}
+		_ = encbinary.Write(header, order, uint32(0))
+	}
+
+	writeIFD(ifd0)
+	if exifIFD != nil {
+		writeIFD(exifIFD)
+	}
+	if interopIFD != nil {
+		writeIFD(interopIFD)
+	}
+
+	return append(header.Bytes(), extra.Bytes()...)
+}
+
+func shortEntry(order encbinary.ByteOrder, t tag, v uint16) testEntry {
+	value := make([]byte, 2)
+	order.PutUint16(value, v)
+	return testEntry{t, fieldTypeShort, 1, value}
+}
+
+func rationalEntry(order encbinary.ByteOrder, t tag, values ...uint32) testEntry {
+	value := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(value[i*4:], v)
+	}
+	return testEntry{t, fieldTypeRational, uint32(len(values) / 2), value}
+}
+
+func TestParse(t *testing.T) {
+
+	for _, order := range []encbinary.ByteOrder{encbinary.LittleEndian, encbinary.BigEndian} {
+
+		t.Run("extracts colour-related tags in "+order.String(), func(t *testing.T) {
+			data := buildEXIF(order,
+				[]testEntry{
+					shortEntry(order, tagOrientation, 6),
+					rationalEntry(order, tagWhitePoint, 3127, 10000, 329, 1000),
+					rationalEntry(order, tagPrimaryChromaticities, 64, 100, 33, 100, 21, 100, 71, 100, 15, 100, 6, 100),
+				},
+				[]testEntry{
+					shortEntry(order, tagColorSpace, 0xFFFF),
+					rationalEntry(order, tagGamma, 22, 10),
+				},
+				[]testEntry{
+					{tagInteroperabilityIndex, fieldTypeASCII, 4, []byte("R03\x00")},
+				},
+			)
+
+			d, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			if expected, actual := OrientationRightTop, d.Orientation; expected != actual {
+				t.Errorf("Expected orientation %v but got %v", expected, actual)
+			}
+			if expected, actual := ColorSpaceUncalibrated, d.ColorSpace; expected != actual {
+				t.Errorf("Expected colour space %v but got %v", expected, actual)
+			}
+			if expected, actual := "R03", d.InteroperabilityIndex; expected != actual {
+				t.Errorf("Expected interoperability index '%s' but got '%s'", expected, actual)
+			}
+			if !d.IsAdobeRGB() {
+				t.Errorf("Expected Adobe RGB to be indicated")
+			}
+			if expected, actual := 2.2, d.Gamma; math.Abs(expected-actual) > 1e-9 {
+				t.Errorf("Expected gamma %v but got %v", expected, actual)
+			}
+
+			if d.WhitePoint == nil {
+				t.Errorf("Expected a white point but got none")
+			} else if d.WhitePoint.X != 0.3127 || d.WhitePoint.Y != 0.329 {
+				t.Errorf("Expected D65 white point but got %+v", *d.WhitePoint)
+			}
+
+			if d.PrimaryChromaticities == nil {
+				t.Errorf("Expected primary chromaticities but got none")
+			} else {
+				if expected, actual := float32(0.64), d.PrimaryChromaticities[0].X; expected != actual {
+					t.Errorf("Expected red x of %v but got %v", expected, actual)
+				}
+				if expected, actual := float32(0.71), d.PrimaryChromaticities[1].Y; expected != actual {
+					t.Errorf("Expected green y of %v but got %v", expected, actual)
+				}
+				if expected, actual := float32(0.06), d.PrimaryChromaticities[2].Y; expected != actual {
+					t.Errorf("Expected blue y of %v but got %v", expected, actual)
+				}
+			}
+		})
+	}
+
+	t.Run("accepts a leading Exif identifier", func(t *testing.T) {
+		order := encbinary.BigEndian
+		data := append([]byte("Exif\x00\x00"), buildEXIF(order, nil, []testEntry{shortEntry(order, tagColorSpace, 1)}, nil)...)
+
+		d, err := Parse(data)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if expected, actual := ColorSpaceSRGB, d.ColorSpace; expected != actual {
+			t.Errorf("Expected colour space %v but got %v", expected, actual)
+		}
+		if d.IsAdobeRGB() {
+			t.Errorf("Expected Adobe RGB not to be indicated")
+		}
+	})
+
+	t.Run("ignores tags with unexpected types", func(t *testing.T) {
+		order := encbinary.LittleEndian
+		data := buildEXIF(order, []testEntry{
+			{tagOrientation, fieldTypeASCII, 2, []byte("1\x00")},
+			rationalEntry(order, tagWhitePoint, 1, 0, 1, 1),
+		}, nil, nil)
+
+		d, err := Parse(data)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if expected, actual := (Data{}), *d; expected != actual {
+			t.Errorf("Expected no tags but got %+v", actual)
+		}
+	})
+
+	t.Run("returns error for invalid byte order", func(t *testing.T) {
+		_, err := Parse([]byte("XX\x00\x2a\x00\x00\x00\x08"))
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "invalid EXIF byte order", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("returns error for truncated data", func(t *testing.T) {
+		order := encbinary.LittleEndian
+		data := buildEXIF(order, []testEntry{shortEntry(order, tagOrientation, 1)}, nil, nil)
+
+		_, err := Parse(data[:12])
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "unexpected EOF", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package exif
+
+import "fmt"
+
+// Orientation is the value of the EXIF Orientation tag, describing where the
+// first row and column of the stored image should appear when displayed.
+type Orientation uint16
+
+const (
+	OrientationUnspecified Orientation = 0
+	OrientationTopLeft     Orientation = 1
+	OrientationTopRight    Orientation = 2
+	OrientationBottomRight Orientation = 3
+	OrientationBottomLeft  Orientation = 4
+	OrientationLeftTop     Orientation = 5
+	OrientationRightTop    Orientation = 6
+	OrientationRightBottom Orientation = 7
+	OrientationLeftBottom  Orientation = 8
+)
+
+func (o Orientation) String() string {
+	switch o {
+	case OrientationUnspecified:
+		return "Unspecified"
+	case OrientationTopLeft:
+		return "Top left"
+	case OrientationTopRight:
+		return "Top right"
+	case OrientationBottomRight:
+		return "Bottom right"
+	case OrientationBottomLeft:
+		return "Bottom left"
+	case OrientationLeftTop:
+		return "Left top"
+	case OrientationRightTop:
+		return "Right top"
+	case OrientationRightBottom:
+		return "Right bottom"
+	case OrientationLeftBottom:
+		return "Left bottom"
+	default:
+		return fmt.Sprintf("Unknown (%d)", o)
+	}
+}
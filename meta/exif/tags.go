@@ -0,0 +1,47 @@
+package exif
+
+type tag uint16
+
+const (
+	tagInteroperabilityIndex tag = 0x0001
+	tagOrientation           tag = 0x0112
+	tagWhitePoint            tag = 0x013E
+	tagPrimaryChromaticities tag = 0x013F
+	tagExifIFD               tag = 0x8769
+	tagColorSpace            tag = 0xA001
+	tagInteroperabilityIFD   tag = 0xA005
+	tagGamma                 tag = 0xA500
+)
+
+type fieldType uint16
+
+const (
+	fieldTypeByte      fieldType = 1
+	fieldTypeASCII     fieldType = 2
+	fieldTypeShort     fieldType = 3
+	fieldTypeLong      fieldType = 4
+	fieldTypeRational  fieldType = 5
+	fieldTypeUndefined fieldType = 7
+	fieldTypeSLong     fieldType = 9
+	fieldTypeSRational fieldType = 10
+	fieldTypeIFD       fieldType = 13
+	fieldTypeLong8     fieldType = 16
+	fieldTypeIFD8      fieldType = 18
+)
+
+// size returns the size in bytes of a single value of this type, or zero if
+// the type is not one used by the supported tags.
+func (ft fieldType) size() uint64 {
+	switch ft {
+	case fieldTypeByte, fieldTypeASCII, fieldTypeUndefined:
+		return 1
+	case fieldTypeShort:
+		return 2
+	case fieldTypeLong, fieldTypeSLong, fieldTypeIFD:
+		return 4
+	case fieldTypeRational, fieldTypeSRational, fieldTypeLong8, fieldTypeIFD8:
+		return 8
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,2 @@
+// Package gifmeta provides support for working with embedded GIF metadata.
+package gifmeta
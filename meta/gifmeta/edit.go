@@ -0,0 +1,193 @@
+package gifmeta
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+)
+
+// EditProfile copies a GIF image stream from r to w, changing its colour
+// profile as specified by edit. All other blocks are copied byte-for-byte, and
+// the image data is not re-encoded.
+//
+// Any existing ICC profile application extensions are removed, and the new one
+// is written immediately after the global colour table. As GIF has no other
+// means of tagging an image as sRGB, meta.ProfileActionSRGB simply removes the
+// profile.
+func EditProfile(w io.Writer, r io.Reader, edit meta.ProfileEdit) error {
+	if err := edit.Validate(); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+
+	var header [13]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return binary.Errorf(meta.ErrTruncated, "unexpected EOF reading GIF header")
+	}
+
+	var sig [6]byte
+	copy(sig[:], header[:])
+	if sig != gif87aSignature && sig != gif89aSignature {
+		return binary.Errorf(meta.ErrFormatMismatch, "invalid GIF signature")
+	}
+
+	// Application extensions are only defined by GIF89a
+	if edit.Action == meta.ProfileActionReplace {
+		copy(header[:], gif89aSignature[:])
+	}
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if err := copyColourTable(w, br, header[10]); err != nil {
+		return err
+	}
+
+	if edit.Action == meta.ProfileActionReplace {
+		if _, err := w.Write(iccProfileExtension(edit.ICCProfile)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		blockType, err := br.ReadByte()
+		if err != nil {
+			return binary.Truncated(err)
+		}
+
+		switch blockType {
+
+		case blockExtension:
+			if err := copyExtension(w, br); err != nil {
+				return err
+			}
+
+		case blockImageDescriptor:
+			var descriptor [10]byte
+			descriptor[0] = blockType
+			if _, err := io.ReadFull(br, descriptor[1:]); err != nil {
+				return binary.Truncated(err)
+			}
+			if _, err := w.Write(descriptor[:]); err != nil {
+				return err
+			}
+			if err := copyColourTable(w, br, descriptor[9]); err != nil {
+				return err
+			}
+
+			// LZW minimum code size, followed by the image data
+			if _, err := io.CopyN(w, br, 1); err != nil {
+				return binary.Truncated(err)
+			}
+			if err := copySubBlocks(w, br); err != nil {
+				return err
+			}
+
+		case blockTrailer:
+			if _, err := w.Write([]byte{blockType}); err != nil {
+				return err
+			}
+			_, err := io.Copy(w, br)
+			return err
+
+		default:
+			return binary.Errorf(meta.ErrMalformed, "invalid GIF block type 0x%02x", blockType)
+		}
+	}
+}
+
+// copyColourTable copies the colour table indicated by the specified flags of
+// a logical screen or image descriptor, if there is one.
+func copyColourTable(w io.Writer, r io.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	size := int64(3) << (flags&0x07 + 1)
+	if _, err := io.CopyN(w, r, size); err != nil {
+		return binary.Truncated(err)
+	}
+	return nil
+}
+
+// copyExtension copies an extension block other than an ICC profile, which is
+// skipped instead.
+func copyExtension(w io.Writer, r binary.Reader) error {
+	label, err := r.ReadByte()
+	if err != nil {
+		return binary.Truncated(err)
+	}
+
+	block := []byte{blockExtension, label}
+
+	// The first sub-block of an application extension contains the
+	// application identifier
+	if label == extensionApplication {
+		size, err := r.ReadByte()
+		if err != nil {
+			return binary.Truncated(err)
+		}
+		identifier := make([]byte, size)
+		if _, err := io.ReadFull(r, identifier); err != nil {
+			return binary.Truncated(err)
+		}
+
+		if string(identifier) == applicationICCProfile {
+			return skipSubBlocks(r)
+		}
+
+		block = append(append(block, size), identifier...)
+		if size == 0 {
+			_, err := w.Write(block)
+			return err
+		}
+	}
+
+	if _, err := w.Write(block); err != nil {
+		return err
+	}
+	return copySubBlocks(w, r)
+}
+
+// copySubBlocks copies a sequence of data sub-blocks up to and including the
+// block terminator.
+func copySubBlocks(w io.Writer, r binary.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return binary.Truncated(err)
+		}
+		if _, err := w.Write([]byte{size}); err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err := io.CopyN(w, r, int64(size)); err != nil {
+			return binary.Truncated(err)
+		}
+	}
+}
+
+// iccProfileExtension returns a complete application extension block
+// containing the specified profile.
+func iccProfileExtension(profile []byte) []byte {
+	block := &bytes.Buffer{}
+	block.Write([]byte{blockExtension, extensionApplication, byte(len(applicationICCProfile))})
+	block.WriteString(applicationICCProfile)
+
+	for len(profile) > 0 {
+		n := len(profile)
+		if n > 255 {
+			n = 255
+		}
+		block.WriteByte(byte(n))
+		block.Write(profile[:n])
+		profile = profile[n:]
+	}
+	block.WriteByte(0x00) // Block terminator
+
+	return block.Bytes()
+}
@@ -0,0 +1,133 @@
+package gifmeta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"image/gif"
+	"os"
+	"testing"
+)
+
+func TestEditProfile(t *testing.T) {
+
+	loopBlock := applicationBlock(applicationNetscape, []byte{1, 0, 0})
+
+	edit := func(t *testing.T, input []byte, e meta.ProfileEdit) []byte {
+		output := &bytes.Buffer{}
+		if err := EditProfile(output, bytes.NewReader(input), e); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return output.Bytes()
+	}
+
+	load := func(t *testing.T, data []byte) *meta.Data {
+		md, err := extractMetadata(bufio.NewReader(bytes.NewReader(data)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return md
+	}
+
+	t.Run("replaces an existing profile", func(t *testing.T) {
+		oldProfile := bytes.Repeat([]byte("old profile "), 30)
+		newProfile := bytes.Repeat([]byte("new profile "), 50)
+		input := buildGIF(1, 1, 2, loopBlock, applicationBlock(applicationICCProfile, oldProfile), imageBlock(0), imageBlock(1), []byte{blockTrailer})
+
+		output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+		md := load(t, output)
+		if actual, _ := md.ICCProfileData(); !bytes.Equal(newProfile, actual) {
+			t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+		}
+		if bytes.Contains(output, []byte("old profile")) {
+			t.Errorf("Expected old profile to be removed")
+		}
+		if md.FrameCount != 2 {
+			t.Errorf("Expected frame count of 2 but got %d", md.FrameCount)
+		}
+		if md.LoopCount != 0 {
+			t.Errorf("Expected loop count of 0 but got %d", md.LoopCount)
+		}
+	})
+
+	t.Run("embeds a profile in a GIF87a image", func(t *testing.T) {
+		input := buildGIF(1, 1, 0, imageBlock(0), []byte{blockTrailer})
+		copy(input, gif87aSignature[:])
+		newProfile := []byte("new profile data")
+
+		output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+		if !bytes.HasPrefix(output, gif89aSignature[:]) {
+			t.Errorf("Expected GIF89a signature but got %q", output[:6])
+		}
+		if actual, _ := load(t, output).ICCProfileData(); !bytes.Equal(newProfile, actual) {
+			t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+		}
+	})
+
+	t.Run("removes the profile", func(t *testing.T) {
+		for _, action := range []meta.ProfileAction{meta.ProfileActionRemove, meta.ProfileActionSRGB} {
+			t.Run(action.String(), func(t *testing.T) {
+				expected := buildGIF(1, 1, 1, loopBlock, imageBlock(0), []byte{blockTrailer})
+				input := buildGIF(1, 1, 1, loopBlock, applicationBlock(applicationICCProfile, []byte("old profile")), imageBlock(0), []byte{blockTrailer})
+
+				output := edit(t, input, meta.ProfileEdit{Action: action})
+
+				if !bytes.Equal(expected, output) {
+					t.Errorf("Expected output %v but got %v", expected, output)
+				}
+			})
+		}
+	})
+
+	t.Run("preserves the frames of a real image", func(t *testing.T) {
+		input, err := os.ReadFile("../../test-images/pizza-displayp3-animated.gif")
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		newProfile := []byte("new profile data")
+
+		output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+		if actual, _ := load(t, output).ICCProfileData(); !bytes.Equal(newProfile, actual) {
+			t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+		}
+
+		expected, err := gif.DecodeAll(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		actual, err := gif.DecodeAll(bytes.NewReader(output))
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if len(expected.Image) != len(actual.Image) {
+			t.Fatalf("Expected %d frames but got %d", len(expected.Image), len(actual.Image))
+		}
+		for i := range expected.Image {
+			if !bytes.Equal(expected.Image[i].Pix, actual.Image[i].Pix) {
+				t.Errorf("Expected frame %d to be unchanged", i)
+			}
+		}
+	})
+
+	t.Run("returns error for invalid signature", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader([]byte("NOTGIF-------")), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for truncated image", func(t *testing.T) {
+		input := buildGIF(1, 1, 1, imageBlock(0), []byte{blockTrailer})
+
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader(input[:len(input)-4]), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
+}
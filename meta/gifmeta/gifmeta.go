@@ -0,0 +1,324 @@
+package gifmeta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+	"math"
+)
+
+// Format specifies the image format handled by this package
+var Format = meta.ImageFormat("GIF")
+
+var (
+	gif87aSignature = [6]byte{'G', 'I', 'F', '8', '7', 'a'}
+	gif89aSignature = [6]byte{'G', 'I', 'F', '8', '9', 'a'}
+)
+
+const (
+	blockExtension       = 0x21
+	blockImageDescriptor = 0x2C
+	blockTrailer         = 0x3B
+)
+
+const (
+	extensionGraphicControl = 0xF9
+	extensionApplication    = 0xFF
+)
+
+const (
+	applicationICCProfile = "ICCRGBG1012"
+	applicationNetscape   = "NETSCAPE2.0"
+	applicationAnimExts   = "ANIMEXTS1.0"
+)
+
+// Only the leading bytes of extensions other than ICC profiles are needed
+const maxExtensionLength = 256
+
+// Load loads the metadata for a GIF image stream.
+//
+// Only as much of the stream is consumed as necessary to extract the metadata;
+// the returned stream contains a buffered copy of the consumed data such that
+// reading from it will produce the same results as fully reading the input
+// stream. This provides a convenient way to load the full image after loading
+// the metadata.
+//
+// Because the frames of an animated GIF must be counted, this reads up to the
+// entire stream.
+//
+// An error is returned if basic metadata could not be extracted. The returned
+// stream still provides the full image data.
+func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a GIF image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
+	rewindBuffer := &bytes.Buffer{}
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(bufio.NewReader(lr), limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a GIF image stream which supports seeking.
+//
+// Reading begins at the current position of the stream, and image data is
+// skipped by seeking past each of its sub-blocks. The stream is returned to its
+// original position afterwards, so that the full image can then be loaded from
+// it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a GIF image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a GIF image of the specified size in
+// bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a GIF image of the specified
+// size in bytes as per LoadReaderAt, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
+}
+
+func extractMetadata(r binary.Reader, limits meta.Limits) (md *meta.Data, err error) {
+	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
+
+	defer func() {
+		if r := recover(); r != nil {
+			md = nil
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
+		}
+		err = binary.Truncated(err)
+	}()
+
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+	}
+
+	var sig [6]byte
+	copy(sig[:], header[:])
+	if sig != gif87aSignature && sig != gif89aSignature {
+		return nil, binary.Errorf(meta.ErrFormatMismatch, "invalid GIF signature")
+	}
+
+	md.PixelWidth = uint32(header[6]) | uint32(header[7])<<8
+	md.PixelHeight = uint32(header[8]) | uint32(header[9])<<8
+	md.ColorModel = meta.ColorModelPalette
+	md.ChannelCount = 1
+	md.LoopCount = 1
+
+	flags := header[10]
+	if flags&0x80 != 0 {
+		md.BitsPerComponent = uint32(flags&0x07) + 1
+		if err := skipColourTable(r, flags); err != nil {
+			return nil, err
+		}
+	}
+
+	blockCount := 0
+
+	for {
+		blockType, err := r.ReadByte()
+		if err != nil {
+			// Tolerate a missing trailer after complete blocks
+			if errors.Is(err, io.EOF) && md.FrameCount > 0 {
+				return md, nil
+			}
+			return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+		}
+
+		blockCount++
+		if err := limits.CheckSegmentCount(blockCount); err != nil {
+			return nil, err
+		}
+
+		switch blockType {
+
+		case blockExtension:
+			if err := readExtension(r, md, limits); err != nil {
+				return nil, err
+			}
+
+		case blockImageDescriptor:
+			var descriptor [9]byte
+			if _, err := io.ReadFull(r, descriptor[:]); err != nil {
+				return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+			}
+
+			flags := descriptor[8]
+			if md.FrameCount == 0 {
+				md.Interlaced = flags&0x40 != 0
+			}
+			if flags&0x80 != 0 {
+				if size := uint32(flags&0x07) + 1; size > md.BitsPerComponent {
+					md.BitsPerComponent = size
+				}
+				if err := skipColourTable(r, flags); err != nil {
+					return nil, err
+				}
+			}
+
+			// LZW minimum code size, followed by the image data
+			if _, err := r.ReadByte(); err != nil {
+				return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+			}
+			if err := skipSubBlocks(r); err != nil {
+				return nil, err
+			}
+
+			md.FrameCount++
+			md.Animated = md.FrameCount > 1
+
+		case blockTrailer:
+			return md, nil
+
+		default:
+			return nil, binary.Errorf(meta.ErrMalformed, "invalid GIF block type 0x%02x", blockType)
+		}
+	}
+}
+
+func skipColourTable(r io.Reader, flags byte) error {
+	size := int64(3) << (flags&0x07 + 1)
+	if err := binary.Skip(r, size); err != nil {
+		return binary.Errorf(meta.ErrTruncated, "unexpected EOF reading colour table")
+	}
+	return nil
+}
+
+// readSubBlocks reads a sequence of data sub-blocks until the block terminator
+// is reached. Only up to maxLength bytes of their contents are returned, with
+// the remainder being skipped, and n is the full length of the contents.
+func readSubBlocks(r binary.Reader, maxLength int64) (data []byte, n int64, err error) {
+	buf := &bytes.Buffer{}
+
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return nil, n, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading data sub-block")
+		}
+		if size == 0 {
+			return buf.Bytes(), n, nil
+		}
+
+		toRead := int64(size)
+		if remaining := maxLength - n; toRead > remaining {
+			toRead = remaining
+		}
+		if toRead < 0 {
+			toRead = 0
+		}
+		if _, err := io.CopyN(buf, r, toRead); err != nil {
+			return nil, n, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading data sub-block")
+		}
+		if err := binary.Skip(r, int64(size)-toRead); err != nil {
+			return nil, n, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading data sub-block")
+		}
+		n += int64(size)
+	}
+}
+
+// skipSubBlocks skips a sequence of data sub-blocks until the block terminator
+// is reached.
+func skipSubBlocks(r binary.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return binary.Errorf(meta.ErrTruncated, "unexpected EOF reading data sub-block")
+		}
+		if size == 0 {
+			return nil
+		}
+
+		if err := binary.Skip(r, int64(size)); err != nil {
+			return binary.Errorf(meta.ErrTruncated, "unexpected EOF reading data sub-block")
+		}
+	}
+}
+
+func readExtension(r binary.Reader, md *meta.Data, limits meta.Limits) error {
+	label, err := r.ReadByte()
+	if err != nil {
+		return binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+	}
+
+	// Application extensions may contain an ICC profile following the
+	// application identifier
+	maxLength := int64(maxExtensionLength)
+	if label == extensionApplication {
+		maxLength = math.MaxInt64
+		if limits.MaxICCProfileSize > 0 {
+			maxLength = limits.MaxICCProfileSize + 11
+		}
+	}
+
+	contents, length, err := readSubBlocks(r, maxLength)
+	if err != nil {
+		return err
+	}
+
+	switch label {
+
+	case extensionGraphicControl:
+		if len(contents) >= 1 && contents[0]&0x01 != 0 {
+			md.HasAlpha = true
+		}
+
+	case extensionApplication:
+		if len(contents) < 11 {
+			return nil
+		}
+
+		switch string(contents[:11]) {
+
+		case applicationICCProfile:
+			if existing, iccErr := md.ICCProfileData(); existing != nil || iccErr != nil {
+				break
+			}
+			if err := limits.CheckICCProfileSize(length - 11); err != nil {
+				md.SetICCProfileError(err)
+			} else if len(contents) > 11 {
+				md.SetICCProfileData(contents[11:])
+			}
+
+		case applicationNetscape, applicationAnimExts:
+			if len(contents) >= 14 && contents[11] == 1 {
+				loops := uint32(contents[12]) | uint32(contents[13])<<8
+				if loops == 0 {
+					md.LoopCount = 0
+				} else {
+					md.LoopCount = loops + 1
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package gifmeta_test
+
+import (
+	"fmt"
+	"image/gif"
+	"os"
+
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/gifmeta"
+)
+
+func printICCProfile(md *meta.Data) {
+	profile, err := md.ICCProfile()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("ProfileSize: %d\n", profile.Header.ProfileSize)
+	fmt.Printf("ProfileVersion: %s\n", profile.Header.Version)
+	fmt.Printf("DeviceClass: %s\n", profile.Header.DeviceClass)
+	fmt.Printf("DataColorSpace: %s\n", profile.Header.DataColorSpace)
+	fmt.Printf("PCS: %s\n", profile.Header.ProfileConnectionSpace)
+
+	if desc, err := profile.Description(); err != nil {
+		panic(err)
+	} else {
+		fmt.Printf("Description: %s\n", desc)
+	}
+}
+
+func printMetadata(md *meta.Data, img *gif.GIF) {
+	fmt.Printf("Format: %s\n", md.Format)
+	fmt.Printf("BitsPerComponent: %d\n", md.BitsPerComponent)
+	fmt.Printf("PixelHeight: %d\n", md.PixelHeight)
+	fmt.Printf("PixelWidth: %d\n", md.PixelWidth)
+	fmt.Printf("ColorModel: %s\n", md.ColorModel)
+	fmt.Printf("FrameCount: %d\n", md.FrameCount)
+	fmt.Printf("LoopCount: %d\n", md.LoopCount)
+
+	fmt.Printf("Actual image height: %d\n", img.Config.Height)
+	fmt.Printf("Actual image width: %d\n", img.Config.Width)
+	fmt.Printf("Actual frame count: %d\n", len(img.Image))
+}
+
+func ExampleLoad_animatedGIFMetadata() {
+	inFile, err := os.Open("../../test-images/pizza-displayp3-animated.gif")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, imgStream, err := gifmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	img, err := gif.DecodeAll(imgStream)
+	if err != nil {
+		panic(err)
+	}
+
+	printMetadata(md, img)
+
+	// Output:
+	// Format: GIF
+	// BitsPerComponent: 8
+	// PixelHeight: 64
+	// PixelWidth: 64
+	// ColorModel: Palette
+	// FrameCount: 3
+	// LoopCount: 0
+	// Actual image height: 64
+	// Actual image width: 64
+	// Actual frame count: 3
+}
+
+func ExampleLoad_embeddedICCv4() {
+	inFile, err := os.Open("../../test-images/pizza-displayp3-animated.gif")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, _, err := gifmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	printICCProfile(md)
+
+	// Output:
+	// ProfileSize: 492
+	// ProfileVersion: 4.0.0
+	// DeviceClass: Display
+	// DataColorSpace: RGB
+	// PCS: XYZ
+	// Description: Display P3
+}
@@ -0,0 +1,172 @@
+package gifmeta
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"testing"
+)
+
+// subBlocks splits data into sub-blocks followed by a block terminator.
+func subBlocks(data []byte) []byte {
+	var result []byte
+	for len(data) > 0 {
+		n := len(data)
+		if n > 255 {
+			n = 255
+		}
+		result = append(result, byte(n))
+		result = append(result, data[:n]...)
+		data = data[n:]
+	}
+	return append(result, 0)
+}
+
+func buildGIF(width, height uint16, globalTableBits int, blocks ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("GIF89a")
+	buf.Write([]byte{byte(width), byte(width >> 8), byte(height), byte(height >> 8)})
+
+	if globalTableBits > 0 {
+		buf.Write([]byte{0x80 | byte(globalTableBits-1), 0, 0})
+		buf.Write(make([]byte, 3<<globalTableBits))
+	} else {
+		buf.Write([]byte{0, 0, 0})
+	}
+
+	for _, b := range blocks {
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+func imageBlock(localTableBits int) []byte {
+	b := []byte{blockImageDescriptor, 0, 0, 0, 0, 1, 0, 1, 0, 0}
+	if localTableBits > 0 {
+		b[9] = 0x80 | byte(localTableBits-1)
+		b = append(b, make([]byte, 3<<localTableBits)...)
+	}
+	b = append(b, 2)
+	return append(b, subBlocks([]byte{0x4C, 0x01})...)
+}
+
+func applicationBlock(identifier string, data []byte) []byte {
+	b := []byte{blockExtension, extensionApplication, 11}
+	b = append(b, identifier...)
+	return append(b, subBlocks(data)...)
+}
+
+func TestExtractMetadata(t *testing.T) {
+
+	iccProfile := bytes.Repeat([]byte("icc profile data "), 40)
+
+	t.Run("returns metadata for still image", func(t *testing.T) {
+		data := buildGIF(320, 200, 4, imageBlock(0), []byte{blockTrailer})
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if md.PixelWidth != 320 || md.PixelHeight != 200 {
+			t.Errorf("Expected dimensions 320x200 but got %dx%d", md.PixelWidth, md.PixelHeight)
+		}
+		if expected, actual := uint32(4), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected %d bits per component but got %d", expected, actual)
+		}
+		if expected, actual := uint32(1), md.FrameCount; expected != actual {
+			t.Errorf("Expected %d frames but got %d", expected, actual)
+		}
+		if expected, actual := uint32(1), md.LoopCount; expected != actual {
+			t.Errorf("Expected loop count %d but got %d", expected, actual)
+		}
+		if md.HasAlpha {
+			t.Errorf("Expected image not to have alpha")
+		}
+		if md.Animated {
+			t.Errorf("Expected image not to be animated")
+		}
+		if iccData, err := md.ICCProfileData(); iccData != nil || err != nil {
+			t.Errorf("Expected no ICC profile but got %v, %v", iccData, err)
+		}
+	})
+
+	t.Run("returns ICC profile, frame count and loop count", func(t *testing.T) {
+		gce := []byte{blockExtension, extensionGraphicControl, 4, 0x01, 10, 0, 0, 0}
+
+		data := buildGIF(16, 16, 0,
+			applicationBlock(applicationICCProfile, iccProfile),
+			applicationBlock(applicationNetscape, []byte{1, 4, 0}),
+			gce, imageBlock(8),
+			gce, imageBlock(2),
+			gce, imageBlock(0),
+			[]byte{blockTrailer},
+		)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		iccData, err := md.ICCProfileData()
+		if err != nil {
+			t.Errorf("Expected ICC profile data but got error: %v", err)
+		} else if !bytes.Equal(iccProfile, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", iccProfile, iccData)
+		}
+
+		if expected, actual := uint32(3), md.FrameCount; expected != actual {
+			t.Errorf("Expected %d frames but got %d", expected, actual)
+		}
+		if expected, actual := uint32(5), md.LoopCount; expected != actual {
+			t.Errorf("Expected loop count %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(8), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected %d bits per component but got %d", expected, actual)
+		}
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+		if !md.Animated {
+			t.Errorf("Expected image to be animated")
+		}
+	})
+
+	t.Run("reports infinite looping as zero loop count", func(t *testing.T) {
+		data := buildGIF(16, 16, 1,
+			applicationBlock(applicationNetscape, []byte{1, 0, 0}),
+			imageBlock(0), imageBlock(0),
+		)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if expected, actual := uint32(0), md.LoopCount; expected != actual {
+			t.Errorf("Expected loop count %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(2), md.FrameCount; expected != actual {
+			t.Errorf("Expected %d frames but got %d", expected, actual)
+		}
+	})
+
+	t.Run("returns error for invalid signature", func(t *testing.T) {
+		data := buildGIF(16, 16, 0, []byte{blockTrailer})
+		data[4] = '8'
+
+		_, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for truncated image data", func(t *testing.T) {
+		data := buildGIF(16, 16, 0, imageBlock(0))
+
+		_, err := extractMetadata(bytes.NewReader(data[:len(data)-2]), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
+}
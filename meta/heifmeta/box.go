@@ -0,0 +1,127 @@
+package heifmeta
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+	"math"
+)
+
+type boxHeader struct {
+	BoxType [4]byte
+
+	// Length is the size of the box contents, excluding the header.
+	Length uint64
+
+	// ToEnd indicates that the box extends to the end of the stream.
+	ToEnd bool
+}
+
+type box struct {
+	BoxType [4]byte
+	Data    []byte
+}
+
+func readBoxHeader(r binary.Reader) (boxHeader, error) {
+	var bh boxHeader
+
+	size, err := binary.ReadU32Big(r)
+	if err != nil {
+		return bh, err
+	}
+
+	if _, err := io.ReadFull(r, bh.BoxType[:]); err != nil {
+		return bh, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading box type")
+	}
+
+	switch size {
+
+	case 0:
+		bh.ToEnd = true
+
+	case 1:
+		largeSize, err := binary.ReadU64Big(r)
+		if err != nil {
+			return bh, err
+		}
+		if largeSize < 16 || largeSize > math.MaxInt64 {
+			return bh, binary.Errorf(meta.ErrMalformed, "invalid box size %d", largeSize)
+		}
+		bh.Length = largeSize - 16
+
+	default:
+		if size < 8 {
+			return bh, binary.Errorf(meta.ErrMalformed, "invalid box size %d", size)
+		}
+		bh.Length = uint64(size) - 8
+	}
+
+	return bh, nil
+}
+
+// readBoxContents reads the contents of a box with the specified header. Boxes
+// which extend to the end of the stream are read in their entirety.
+func readBoxContents(r io.Reader, bh boxHeader) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	if bh.ToEnd {
+		_, err := io.Copy(buf, r)
+		return buf.Bytes(), err
+	}
+
+	if _, err := io.CopyN(buf, r, int64(bh.Length)); err != nil {
+		if err == io.EOF {
+			return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading %s box", string(bh.BoxType[:]))
+		}
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readBoxes parses a sequence of boxes contained in the specified data.
+func readBoxes(data []byte) ([]box, error) {
+	var boxes []box
+
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		bh, err := readBoxHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if bh.ToEnd {
+			bh.Length = uint64(r.Len())
+		}
+		if bh.Length > uint64(r.Len()) {
+			return nil, binary.Errorf(meta.ErrMalformed, "%s box extends beyond its container", string(bh.BoxType[:]))
+		}
+
+		contents := make([]byte, bh.Length)
+		io.ReadFull(r, contents)
+
+		boxes = append(boxes, box{BoxType: bh.BoxType, Data: contents})
+	}
+
+	return boxes, nil
+}
+
+// readFullBoxHeader reads the version and flags at the beginning of a full box.
+func readFullBoxHeader(r binary.Reader) (version uint8, flags uint32, err error) {
+	versionAndFlags, err := binary.ReadU32Big(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint8(versionAndFlags >> 24), versionAndFlags & 0xFFFFFF, nil
+}
+
+// readItemID reads an item ID, which is 16 or 32 bits depending on the version
+// of the containing box.
+func readItemID(r binary.Reader, wide bool) (uint32, error) {
+	if wide {
+		return binary.ReadU32Big(r)
+	}
+	id, err := binary.ReadU16Big(r)
+	return uint32(id), err
+}
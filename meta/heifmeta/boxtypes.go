@@ -0,0 +1,25 @@
+package heifmeta
+
+var (
+	boxTypeAuxC = [4]byte{'a', 'u', 'x', 'C'}
+	boxTypeAuxl = [4]byte{'a', 'u', 'x', 'l'}
+	boxTypeAv1C = [4]byte{'a', 'v', '1', 'C'}
+	boxTypeColr = [4]byte{'c', 'o', 'l', 'r'}
+	boxTypeDimg = [4]byte{'d', 'i', 'm', 'g'}
+	boxTypeFtyp = [4]byte{'f', 't', 'y', 'p'}
+	boxTypeHvcC = [4]byte{'h', 'v', 'c', 'C'}
+	boxTypeIpco = [4]byte{'i', 'p', 'c', 'o'}
+	boxTypeIpma = [4]byte{'i', 'p', 'm', 'a'}
+	boxTypeIprp = [4]byte{'i', 'p', 'r', 'p'}
+	boxTypeIref = [4]byte{'i', 'r', 'e', 'f'}
+	boxTypeIspe = [4]byte{'i', 's', 'p', 'e'}
+	boxTypeMeta = [4]byte{'m', 'e', 't', 'a'}
+	boxTypePitm = [4]byte{'p', 'i', 't', 'm'}
+	boxTypePixi = [4]byte{'p', 'i', 'x', 'i'}
+)
+
+var (
+	colourTypeNclx = [4]byte{'n', 'c', 'l', 'x'}
+	colourTypeProf = [4]byte{'p', 'r', 'o', 'f'}
+	colourTypeRICC = [4]byte{'r', 'I', 'C', 'C'}
+)
@@ -0,0 +1,3 @@
+// Package heifmeta provides support for working with embedded metadata in
+// HEIF-based image formats, including HEIC and AVIF.
+package heifmeta
@@ -0,0 +1,560 @@
+package heifmeta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+	"strings"
+)
+
+// HEIFFormat specifies the HEIF (including HEIC) image format handled by this
+// package
+var HEIFFormat = meta.ImageFormat("HEIF")
+
+// AVIFFormat specifies the AVIF image format handled by this package
+var AVIFFormat = meta.ImageFormat("AVIF")
+
+var heifBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1"}
+var avifBrands = []string{"avif", "avis"}
+
+// Load loads the metadata for a HEIF or AVIF image stream.
+//
+// Only as much of the stream is consumed as necessary to extract the metadata;
+// the returned stream contains a buffered copy of the consumed data such that
+// reading from it will produce the same results as fully reading the input
+// stream. This provides a convenient way to load the full image after loading
+// the metadata.
+//
+// An error is returned if basic metadata could not be extracted. The returned
+// stream still provides the full image data.
+func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a HEIF or AVIF image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
+	rewindBuffer := &bytes.Buffer{}
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(bufio.NewReader(lr), limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a HEIF or AVIF image stream which supports
+// seeking.
+//
+// Reading begins at the current position of the stream, and boxes which don't
+// contain metadata (such as media data preceding the metadata) are skipped by
+// seeking past them. The stream is returned to its original position
+// afterwards, so that the full image can then be loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a HEIF or AVIF image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a HEIF or AVIF image of the specified
+// size in bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a HEIF or AVIF image of the
+// specified size in bytes as per LoadReaderAt, subject to the specified limits
+// rather than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
+}
+
+func extractMetadata(r binary.Reader, limits meta.Limits) (md *meta.Data, err error) {
+	md = &meta.Data{}
+	md.SetLimits(limits)
+
+	defer func() {
+		if r := recover(); r != nil {
+			md = nil
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
+		}
+		err = binary.Truncated(err)
+	}()
+
+	bh, err := readBoxHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if bh.BoxType != boxTypeFtyp {
+		return nil, binary.Errorf(meta.ErrFormatMismatch, "missing ftyp box")
+	}
+
+	ftyp, err := readBoxContents(r, bh)
+	if err != nil {
+		return nil, err
+	}
+
+	md.Format, err = formatForBrands(ftyp)
+	if err != nil {
+		return nil, err
+	}
+
+	boxCount := 1
+
+	for {
+		bh, err := readBoxHeader(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, binary.Errorf(meta.ErrMalformed, "no metadata found")
+			}
+			return nil, err
+		}
+
+		boxCount++
+		if err := limits.CheckSegmentCount(boxCount); err != nil {
+			return nil, err
+		}
+
+		if bh.BoxType != boxTypeMeta {
+			if bh.ToEnd {
+				return nil, binary.Errorf(meta.ErrMalformed, "no metadata found")
+			}
+			if err := binary.Skip(r, int64(bh.Length)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		data, err := readBoxContents(r, bh)
+		if err != nil {
+			return nil, err
+		}
+
+		err = parseMeta(data, md, limits)
+		if err != nil {
+			return nil, err
+		}
+
+		return md, nil
+	}
+}
+
+// formatForBrands determines the image format from the major and compatible
+// brands of an ftyp box.
+func formatForBrands(ftyp []byte) (meta.ImageFormat, error) {
+	if len(ftyp) < 8 {
+		return "", binary.Errorf(meta.ErrMalformed, "invalid ftyp box")
+	}
+
+	brands := []string{string(ftyp[0:4])}
+	for i := 8; i+4 <= len(ftyp); i += 4 {
+		brands = append(brands, string(ftyp[i:i+4]))
+	}
+
+	contains := func(candidates []string) bool {
+		for _, b := range brands {
+			for _, c := range candidates {
+				if b == c {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if contains(avifBrands) {
+		return AVIFFormat, nil
+	}
+	if contains(heifBrands) {
+		return HEIFFormat, nil
+	}
+	return "", binary.Errorf(meta.ErrFormatMismatch, "not a HEIF or AVIF file")
+}
+
+type itemReference struct {
+	referenceType [4]byte
+	fromItemID    uint32
+	toItemIDs     []uint32
+}
+
+func parseMeta(data []byte, md *meta.Data, limits meta.Limits) error {
+	r := bytes.NewReader(data)
+	if _, _, err := readFullBoxHeader(r); err != nil {
+		return err
+	}
+
+	boxes, err := readBoxes(data[4:])
+	if err != nil {
+		return err
+	}
+
+	var primaryItemID uint32
+	var references []itemReference
+	var properties []box
+	associations := make(map[uint32][]int)
+	hasPrimaryItem := false
+
+	for _, b := range boxes {
+		switch b.BoxType {
+
+		case boxTypePitm:
+			br := bytes.NewReader(b.Data)
+			version, _, err := readFullBoxHeader(br)
+			if err != nil {
+				return err
+			}
+			primaryItemID, err = readItemID(br, version > 0)
+			if err != nil {
+				return err
+			}
+			hasPrimaryItem = true
+
+		case boxTypeIref:
+			references, err = parseItemReferences(b.Data)
+			if err != nil {
+				return err
+			}
+
+		case boxTypeIprp:
+			properties, associations, err = parseItemProperties(b.Data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !hasPrimaryItem {
+		return binary.Errorf(meta.ErrMalformed, "no primary item found")
+	}
+
+	propertiesOf := func(itemID uint32) []box {
+		var result []box
+		for _, index := range associations[itemID] {
+			if index > 0 && index <= len(properties) {
+				result = append(result, properties[index-1])
+			}
+		}
+		return result
+	}
+
+	// Derived images (such as grids) may only carry some properties, leaving
+	// the others to the images they're derived from
+	primaryProperties := propertiesOf(primaryItemID)
+	for _, ref := range references {
+		if ref.referenceType == boxTypeDimg && ref.fromItemID == primaryItemID && len(ref.toItemIDs) > 0 {
+			primaryProperties = append(primaryProperties, propertiesOf(ref.toItemIDs[0])...)
+			break
+		}
+	}
+
+	foundSize := false
+	foundCICP := false
+	foundICC := false
+	foundBitDepth := false
+	monochrome := false
+
+	for _, p := range primaryProperties {
+		switch p.BoxType {
+
+		case boxTypeIspe:
+			if foundSize {
+				continue
+			}
+			br := bytes.NewReader(p.Data)
+			if _, _, err := readFullBoxHeader(br); err != nil {
+				return err
+			}
+			if md.PixelWidth, err = binary.ReadU32Big(br); err != nil {
+				return err
+			}
+			if md.PixelHeight, err = binary.ReadU32Big(br); err != nil {
+				return err
+			}
+			foundSize = true
+
+		case boxTypePixi:
+			if foundBitDepth {
+				continue
+			}
+			br := bytes.NewReader(p.Data)
+			if _, _, err := readFullBoxHeader(br); err != nil {
+				return err
+			}
+			channels, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if channels == 0 {
+				continue
+			}
+			bits, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			md.ChannelCount = uint32(channels)
+			md.BitsPerComponent = uint32(bits)
+			monochrome = channels == 1
+			foundBitDepth = true
+
+		case boxTypeAv1C:
+			if foundBitDepth || len(p.Data) < 3 {
+				continue
+			}
+			flags := p.Data[2]
+			md.BitsPerComponent = 8
+			if flags&0x40 != 0 {
+				md.BitsPerComponent = 10
+				if flags&0x20 != 0 {
+					md.BitsPerComponent = 12
+				}
+			}
+			monochrome = flags&0x10 != 0
+			md.ChannelCount = 3
+			if monochrome {
+				md.ChannelCount = 1
+			}
+			foundBitDepth = true
+
+		case boxTypeHvcC:
+			if foundBitDepth || len(p.Data) < 19 {
+				continue
+			}
+			md.BitsPerComponent = uint32(p.Data[17]&0x07) + 8
+			monochrome = p.Data[16]&0x03 == 0
+			md.ChannelCount = 3
+			if monochrome {
+				md.ChannelCount = 1
+			}
+			foundBitDepth = true
+
+		case boxTypeColr:
+			// An image may have both CICP and ICC colour information, each
+			// in its own colr box
+			var colourType [4]byte
+			copy(colourType[:], p.Data)
+			switch colourType {
+			case colourTypeNclx:
+				if foundCICP {
+					continue
+				}
+				foundCICP = true
+			case colourTypeProf, colourTypeRICC:
+				if foundICC {
+					continue
+				}
+				foundICC = true
+			}
+			if err := parseColour(p.Data, md, limits); err != nil {
+				md.SetICCProfileError(err)
+			}
+		}
+	}
+
+	if !foundSize {
+		return binary.Errorf(meta.ErrMalformed, "no metadata found")
+	}
+
+	switch {
+	case monochrome:
+		md.ColorModel = meta.ColorModelGreyscale
+	case md.CICP != nil && md.CICP.MatrixCoefficients == 0:
+		md.ColorModel = meta.ColorModelRGB
+	case foundBitDepth:
+		md.ColorModel = meta.ColorModelYCbCr
+	}
+
+	md.HasAlpha = hasAlpha(primaryItemID, references, propertiesOf)
+	if md.HasAlpha && md.ChannelCount > 0 {
+		md.ChannelCount++
+	}
+
+	return nil
+}
+
+// hasAlpha determines whether the primary item has an auxiliary alpha image.
+func hasAlpha(primaryItemID uint32, references []itemReference, propertiesOf func(uint32) []box) bool {
+	for _, ref := range references {
+		if ref.referenceType != boxTypeAuxl {
+			continue
+		}
+
+		isForPrimary := false
+		for _, id := range ref.toItemIDs {
+			if id == primaryItemID {
+				isForPrimary = true
+				break
+			}
+		}
+		if !isForPrimary {
+			continue
+		}
+
+		for _, p := range propertiesOf(ref.fromItemID) {
+			if p.BoxType == boxTypeAuxC && len(p.Data) > 4 {
+				auxType := string(p.Data[4:])
+				if i := strings.IndexByte(auxType, 0); i >= 0 {
+					auxType = auxType[:i]
+				}
+				if auxType == "urn:mpeg:mpegB:cicp:systems:auxiliary:alpha" || auxType == "urn:mpeg:hevc:2015:auxid:1" {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func parseColour(data []byte, md *meta.Data, limits meta.Limits) error {
+	if len(data) < 4 {
+		return binary.Errorf(meta.ErrMalformed, "invalid colr box")
+	}
+
+	var colourType [4]byte
+	copy(colourType[:], data)
+
+	switch colourType {
+
+	case colourTypeNclx:
+		if len(data) < 11 {
+			return binary.Errorf(meta.ErrMalformed, "invalid nclx colour information")
+		}
+		md.CICP = &meta.CICP{
+			ColorPrimaries:          uint16(data[4])<<8 | uint16(data[5]),
+			TransferCharacteristics: uint16(data[6])<<8 | uint16(data[7]),
+			MatrixCoefficients:      uint16(data[8])<<8 | uint16(data[9]),
+			FullRange:               data[10]&0x80 != 0,
+		}
+
+	case colourTypeProf, colourTypeRICC:
+		if err := limits.CheckICCProfileSize(int64(len(data) - 4)); err != nil {
+			md.SetICCProfileError(err)
+		} else {
+			md.SetICCProfileData(data[4:])
+		}
+	}
+
+	return nil
+}
+
+func parseItemProperties(data []byte) (properties []box, associations map[uint32][]int, err error) {
+	associations = make(map[uint32][]int)
+
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, b := range boxes {
+		switch b.BoxType {
+
+		case boxTypeIpco:
+			properties, err = readBoxes(b.Data)
+			if err != nil {
+				return nil, nil, err
+			}
+
+		case boxTypeIpma:
+			r := bytes.NewReader(b.Data)
+			version, flags, err := readFullBoxHeader(r)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			entryCount, err := binary.ReadU32Big(r)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			for i := uint32(0); i < entryCount; i++ {
+				itemID, err := readItemID(r, version > 0)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				count, err := r.ReadByte()
+				if err != nil {
+					return nil, nil, err
+				}
+
+				for j := 0; j < int(count); j++ {
+					var index int
+					if flags&1 != 0 {
+						v, err := binary.ReadU16Big(r)
+						if err != nil {
+							return nil, nil, err
+						}
+						index = int(v & 0x7FFF)
+					} else {
+						v, err := r.ReadByte()
+						if err != nil {
+							return nil, nil, err
+						}
+						index = int(v & 0x7F)
+					}
+					associations[itemID] = append(associations[itemID], index)
+				}
+			}
+		}
+	}
+
+	return properties, associations, nil
+}
+
+func parseItemReferences(data []byte) ([]itemReference, error) {
+	r := bytes.NewReader(data)
+	version, _, err := readFullBoxHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	boxes, err := readBoxes(data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	var references []itemReference
+
+	for _, b := range boxes {
+		br := bytes.NewReader(b.Data)
+		ref := itemReference{referenceType: b.BoxType}
+
+		ref.fromItemID, err = readItemID(br, version > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		count, err := binary.ReadU16Big(br)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := uint16(0); i < count; i++ {
+			id, err := readItemID(br, version > 0)
+			if err != nil {
+				return nil, err
+			}
+			ref.toItemIDs = append(ref.toItemIDs, id)
+		}
+
+		references = append(references, ref)
+	}
+
+	return references, nil
+}
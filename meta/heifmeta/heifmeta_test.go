@@ -0,0 +1,260 @@
+package heifmeta
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"testing"
+)
+
+func makeBox(boxType string, contents ...[]byte) []byte {
+	data := bytes.Join(contents, nil)
+	buf := &bytes.Buffer{}
+	binary.WriteU32Big(buf, uint32(len(data)+8))
+	buf.WriteString(boxType)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func makeFullBox(boxType string, version uint8, flags uint32, contents ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return makeBox(boxType, append([][]byte{header}, contents...)...)
+}
+
+func u16(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func u32(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func TestExtractMetadata(t *testing.T) {
+
+	iccProfileData := []byte{1, 2, 3, 4}
+
+	ispe := makeFullBox("ispe", 0, 0, u32(4032), u32(3024))
+	pixi := makeFullBox("pixi", 0, 0, []byte{3, 10, 10, 10})
+	av1C := makeBox("av1C", []byte{0x81, 0x0d, 0x4c, 0x00})
+	hvcC := makeBox("hvcC", make([]byte, 16), []byte{0xfd, 0xfa, 0xfa}, make([]byte, 4))
+	nclx := makeBox("colr", []byte("nclx"), u16(12), u16(13), u16(6), []byte{0x80})
+	prof := makeBox("colr", []byte("prof"), iccProfileData)
+	alpha := makeFullBox("auxC", 0, 0, []byte("urn:mpeg:mpegB:cicp:systems:auxiliary:alpha\x00"))
+
+	buildFile := func(brand string, properties [][]byte, associations []byte, extraMeta ...[]byte) []byte {
+		metaBox := makeFullBox("meta", 0, 0,
+			makeFullBox("hdlr", 0, 0, u32(0), []byte("pict"), make([]byte, 13)),
+			makeFullBox("pitm", 0, 0, u16(1)),
+			makeBox("iprp",
+				makeBox("ipco", properties...),
+				makeFullBox("ipma", 0, 0, associations),
+			),
+			bytes.Join(extraMeta, nil),
+		)
+
+		return bytes.Join([][]byte{
+			makeBox("ftyp", []byte(brand), u32(0), []byte("mif1"), []byte(brand)),
+			makeBox("free", []byte("padding")),
+			metaBox,
+			makeBox("mdat", []byte("image data")),
+		}, nil)
+	}
+
+	t.Run("returns all AVIF metadata", func(t *testing.T) {
+		associations := bytes.Join([][]byte{
+			u32(2),
+			u16(1), {4, 1, 2, 3, 0x84},
+			u16(2), {2, 1, 5},
+		}, nil)
+		iref := makeFullBox("iref", 0, 0, makeBox("auxl", u16(2), u16(1), u16(1)))
+
+		data := buildFile("avif", [][]byte{ispe, av1C, nclx, prof, alpha}, associations, iref)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := AVIFFormat, md.Format; expected != actual {
+			t.Errorf("Expected format %s but got %s", expected, actual)
+		}
+		if expected, actual := uint32(4032), md.PixelWidth; expected != actual {
+			t.Errorf("Expected image width of %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(3024), md.PixelHeight; expected != actual {
+			t.Errorf("Expected image height of %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(10), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected image bits per component of %d but got %d", expected, actual)
+		}
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+		if expected, actual := uint32(4), md.ChannelCount; expected != actual {
+			t.Errorf("Expected channel count of %d but got %d", expected, actual)
+		}
+		if expected, actual := meta.ColorModelYCbCr, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+
+		if md.CICP == nil {
+			t.Errorf("Expected CICP colour information but got none")
+		} else if expected, actual := (meta.CICP{ColorPrimaries: 12, TransferCharacteristics: 13, MatrixCoefficients: 6, FullRange: true}), *md.CICP; expected != actual {
+			t.Errorf("Expected CICP colour information %+v but got %+v", expected, actual)
+		}
+
+		iccData, iccErr := md.ICCProfileData()
+		if iccErr != nil {
+			t.Errorf("Expected ICC profile data but got error: %v", iccErr)
+		} else if !bytes.Equal(iccProfileData, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", iccProfileData, iccData)
+		}
+	})
+
+	t.Run("uses only the first colour box of each kind", func(t *testing.T) {
+		otherNclx := makeBox("colr", []byte("nclx"), u16(1), u16(13), u16(0), []byte{0x80})
+		otherProf := makeBox("colr", []byte("prof"), []byte("other profile"))
+		associations := bytes.Join([][]byte{
+			u32(1),
+			u16(1), {5, 1, 2, 3, 4, 5},
+		}, nil)
+
+		data := buildFile("avif", [][]byte{ispe, nclx, otherNclx, prof, otherProf}, associations)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if md.CICP == nil {
+			t.Errorf("Expected CICP colour information but got none")
+		} else if expected, actual := uint16(12), md.CICP.ColorPrimaries; expected != actual {
+			t.Errorf("Expected colour primaries %d but got %d", expected, actual)
+		}
+
+		iccData, _ := md.ICCProfileData()
+		if !bytes.Equal(iccProfileData, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", iccProfileData, iccData)
+		}
+	})
+
+	t.Run("returns all HEIC metadata", func(t *testing.T) {
+		associations := bytes.Join([][]byte{
+			u32(1),
+			u16(1), {4, 1, 2, 3, 4},
+		}, nil)
+
+		data := buildFile("heic", [][]byte{ispe, pixi, hvcC, prof}, associations)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := HEIFFormat, md.Format; expected != actual {
+			t.Errorf("Expected format %s but got %s", expected, actual)
+		}
+		if expected, actual := uint32(10), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected image bits per component of %d but got %d", expected, actual)
+		}
+		if md.HasAlpha {
+			t.Errorf("Expected image not to have alpha")
+		}
+		if expected, actual := uint32(3), md.ChannelCount; expected != actual {
+			t.Errorf("Expected channel count of %d but got %d", expected, actual)
+		}
+		if md.CICP != nil {
+			t.Errorf("Expected no CICP colour information but got %+v", md.CICP)
+		}
+
+		iccData, iccErr := md.ICCProfileData()
+		if iccErr != nil {
+			t.Errorf("Expected ICC profile data but got error: %v", iccErr)
+		} else if !bytes.Equal(iccProfileData, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", iccProfileData, iccData)
+		}
+	})
+
+	t.Run("uses properties of the first tile of a grid image", func(t *testing.T) {
+		associations := bytes.Join([][]byte{
+			u32(2),
+			u16(1), {1, 1},
+			u16(2), {2, 2, 3},
+		}, nil)
+		iref := makeFullBox("iref", 0, 0, makeBox("dimg", u16(1), u16(2), u16(2), u16(3)))
+
+		data := buildFile("heic", [][]byte{ispe, hvcC, prof}, associations, iref)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := uint32(4032), md.PixelWidth; expected != actual {
+			t.Errorf("Expected image width of %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(10), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected image bits per component of %d but got %d", expected, actual)
+		}
+
+		iccData, _ := md.ICCProfileData()
+		if !bytes.Equal(iccProfileData, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", iccProfileData, iccData)
+		}
+	})
+
+	t.Run("returns error with missing ftyp box", func(t *testing.T) {
+		_, err := extractMetadata(bytes.NewReader(makeBox("free", []byte("not a HEIF file"))), meta.DefaultLimits)
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "missing ftyp box", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error with unrecognised brands", func(t *testing.T) {
+		_, err := extractMetadata(bytes.NewReader(makeBox("ftyp", []byte("jxl "), u32(0), []byte("jxl "))), meta.DefaultLimits)
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "not a HEIF or AVIF file", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for truncated boxes", func(t *testing.T) {
+		data := makeBox("ftyp", []byte("avif"), u32(0), []byte("avif"))
+
+		_, err := extractMetadata(bytes.NewReader(data[:len(data)-2]), meta.DefaultLimits)
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
+
+	t.Run("returns error if meta box is not found", func(t *testing.T) {
+		data := bytes.Join([][]byte{
+			makeBox("ftyp", []byte("avif"), u32(0), []byte("avif")),
+			makeBox("mdat", []byte("image data")),
+		}, nil)
+
+		_, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "no metadata found", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+		if !errors.Is(err, meta.ErrMalformed) {
+			t.Errorf("Expected malformed error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// Chromaticity holds the chromaticities of the channels of a device, as found
+// in tags of type 'chrm'.
+type Chromaticity struct {
+	// Colorant identifies a standard set of phosphors or colorants, or is
+	// zero if the channels are specified explicitly.
+	Colorant uint16
+
+	// Channels holds the CIE xy chromaticity of each channel.
+	Channels [][2]float64
+}
+
+func parseChromaticity(data []byte) (Chromaticity, error) {
+	result := Chromaticity{}
+
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, ChromaticitySignature); err != nil {
+		return result, err
+	}
+
+	channelCount, err := binary.ReadU16Big(reader)
+	if err != nil {
+		return result, err
+	}
+
+	result.Colorant, err = binary.ReadU16Big(reader)
+	if err != nil {
+		return result, err
+	}
+
+	if int(channelCount)*8 > reader.Len() {
+		return result, binary.Errorf(binary.ErrTruncated, "expected %d channels but only found data for %d", channelCount, reader.Len()/8)
+	}
+
+	result.Channels = make([][2]float64, channelCount)
+	for i := range result.Channels {
+		if result.Channels[i][0], err = readU16Fixed16(reader); err != nil {
+			return result, err
+		}
+		if result.Channels[i][1], err = readU16Fixed16(reader); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package icc
+
+import (
+	"github.com/mandykoh/prism/meta/binary"
+	"sort"
+)
+
+// The maximum number of channels of a colour space supported by ICC profiles
+const maxChannels = 15
+
+// Interpolation is a method of interpolating between the grid points of a
+// CLUT.
+type Interpolation int
+
+const (
+	// TetrahedralInterpolation interpolates between the vertices of the
+	// simplex (a tetrahedron, for three inputs) enclosing the input colour.
+	// This is faster than multilinear interpolation and avoids some hue
+	// shifts along the neutral axis.
+	TetrahedralInterpolation Interpolation = iota
+
+	// MultilinearInterpolation interpolates between all the vertices of the
+	// grid cell enclosing the input colour (ie trilinear interpolation, for
+	// three inputs).
+	MultilinearInterpolation
+)
+
+// CLUT is a multidimensional colour lookup table, mapping normalised input
+// colours to normalised output colours by interpolating between grid points.
+type CLUT struct {
+	// GridPoints is the number of grid points along each input dimension.
+	GridPoints []int
+
+	// OutputChannelCount is the number of output values at each grid point.
+	OutputChannelCount int
+
+	// Values holds the normalised output values at each grid point, with the
+	// first input dimension varying least rapidly.
+	Values []float64
+
+	// Interpolation is the method used to interpolate between grid points.
+	Interpolation Interpolation
+}
+
+// InputChannels returns the number of dimensions of the table.
+func (c *CLUT) InputChannels() int {
+	return len(c.GridPoints)
+}
+
+// OutputChannels returns the number of output values at each grid point.
+func (c *CLUT) OutputChannels() int {
+	return c.OutputChannelCount
+}
+
+// Apply looks up the colour src in the table, writing the interpolated result
+// to dst.
+func (c *CLUT) Apply(dst, src []float64) {
+	n := len(c.GridPoints)
+
+	var steps [maxChannels]int
+	var fracs [maxChannels]float64
+	base := 0
+	stride := c.OutputChannelCount
+
+	for i := n - 1; i >= 0; i-- {
+		g := c.GridPoints[i]
+		pos := clamp01(src[i]) * float64(g-1)
+		index := int(pos)
+		if index >= g-1 {
+			index = g - 1
+		}
+
+		base += index * stride
+		if index < g-1 {
+			steps[i] = stride
+			fracs[i] = pos - float64(index)
+		}
+		stride *= g
+	}
+
+	out := dst[:c.OutputChannelCount]
+	for j := range out {
+		out[j] = 0
+	}
+
+	if c.Interpolation == MultilinearInterpolation {
+		c.interpolateMultilinear(out, base, steps[:n], fracs[:n])
+	} else {
+		c.interpolateTetrahedral(out, base, steps[:n], fracs[:n])
+	}
+}
+
+func (c *CLUT) interpolateMultilinear(out []float64, base int, steps []int, fracs []float64) {
+	for corner := 0; corner < 1<<len(steps); corner++ {
+		weight := 1.0
+		offset := base
+		for i := range steps {
+			if corner&(1<<i) != 0 {
+				weight *= fracs[i]
+				offset += steps[i]
+			} else {
+				weight *= 1 - fracs[i]
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		for j := range out {
+			out[j] += weight * c.Values[offset+j]
+		}
+	}
+}
+
+func (c *CLUT) interpolateTetrahedral(out []float64, base int, steps []int, fracs []float64) {
+	var order [maxChannels]int
+	dims := order[:len(steps)]
+	for i := range dims {
+		dims[i] = i
+	}
+	sort.SliceStable(dims, func(a, b int) bool { return fracs[dims[a]] > fracs[dims[b]] })
+
+	// Walk from the base vertex towards the opposite corner of the cell,
+	// adding the dimensions in order of decreasing fractional position
+	offset := base
+	prev := 1.0
+	for _, d := range dims {
+		if weight := prev - fracs[d]; weight != 0 {
+			for j := range out {
+				out[j] += weight * c.Values[offset+j]
+			}
+		}
+		offset += steps[d]
+		prev = fracs[d]
+	}
+	if prev != 0 {
+		for j := range out {
+			out[j] += prev * c.Values[offset+j]
+		}
+	}
+}
+
+// parseCLUT parses a table with the specified grid points and number of
+// output channels, whose values are bytesPerValue wide.
+func parseCLUT(data []byte, gridPoints []int, outputChannels int, bytesPerValue int) (*CLUT, []byte, error) {
+	if len(gridPoints) == 0 || len(gridPoints) > maxChannels {
+		return nil, nil, binary.Errorf(binary.ErrMalformed, "invalid CLUT input channel count %d", len(gridPoints))
+	}
+	if outputChannels == 0 || outputChannels > maxChannels {
+		return nil, nil, binary.Errorf(binary.ErrMalformed, "invalid CLUT output channel count %d", outputChannels)
+	}
+
+	valueCount := uint64(outputChannels)
+	for _, g := range gridPoints {
+		if g == 0 {
+			return nil, nil, binary.Errorf(binary.ErrMalformed, "invalid CLUT grid point count %d", g)
+		}
+		valueCount *= uint64(g)
+		if valueCount*uint64(bytesPerValue) > uint64(len(data)) {
+			return nil, nil, binary.Errorf(binary.ErrTruncated, "insufficient data for CLUT")
+		}
+	}
+
+	values := readNormalisedValues(data, int(valueCount), bytesPerValue)
+
+	clut := &CLUT{
+		GridPoints:         append([]int(nil), gridPoints...),
+		OutputChannelCount: outputChannels,
+		Values:             values,
+	}
+	return clut, data[int(valueCount)*bytesPerValue:], nil
+}
+
+// readNormalisedValues reads count unsigned big-endian values of the specified
+// width from data, normalised to the range [0, 1]. data must be long enough.
+func readNormalisedValues(data []byte, count int, bytesPerValue int) []float64 {
+	values := make([]float64, count)
+	if bytesPerValue == 1 {
+		for i := range values {
+			values[i] = float64(data[i]) / 255
+		}
+	} else {
+		for i := range values {
+			values[i] = float64(uint16(data[i*2])<<8|uint16(data[i*2+1])) / 65535
+		}
+	}
+	return values
+}
@@ -0,0 +1,178 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+	"math"
+)
+
+// Curve is a one-dimensional tone reproduction curve, as found in tags of type
+// 'curv'. Input and output values are normalised to the range [0, 1].
+type Curve struct {
+	// Table holds evenly spaced samples of the curve, scaled to the range
+	// [0, 65535]. An empty table denotes the identity function, and a table
+	// with a single entry denotes a simple power law whose exponent is the
+	// entry divided by 256.
+	Table []uint16
+}
+
+// Gamma returns the exponent of the power law which the curve represents, or
+// false if the curve is sampled rather than a simple power law.
+func (c Curve) Gamma() (float64, bool) {
+	switch len(c.Table) {
+	case 0:
+		return 1, true
+	case 1:
+		return float64(c.Table[0]) / 256, true
+	default:
+		return 0, false
+	}
+}
+
+// Apply returns the value of the curve at x, interpolating linearly between
+// samples.
+func (c Curve) Apply(x float64) float64 {
+	if gamma, ok := c.Gamma(); ok {
+		return math.Pow(clamp01(x), gamma)
+	}
+
+	pos := clamp01(x) * float64(len(c.Table)-1)
+	i := int(pos)
+	if i >= len(c.Table)-1 {
+		return float64(c.Table[len(c.Table)-1]) / 65535
+	}
+	frac := pos - float64(i)
+
+	return (float64(c.Table[i])*(1-frac) + float64(c.Table[i+1])*frac) / 65535
+}
+
+func parseCurve(data []byte) (Curve, error) {
+	result := Curve{}
+
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, CurveSignature); err != nil {
+		return result, err
+	}
+
+	entryCount, err := binary.ReadU32Big(reader)
+	if err != nil {
+		return result, err
+	}
+	if uint64(entryCount)*2 > uint64(reader.Len()) {
+		return result, binary.Errorf(binary.ErrTruncated, "expected %d curve entries but only found %d", entryCount, reader.Len()/2)
+	}
+
+	result.Table = make([]uint16, entryCount)
+	for i := range result.Table {
+		if result.Table[i], err = binary.ReadU16Big(reader); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// ParametricCurve is a tone reproduction curve defined by one of the
+// parameterised functions of the ICC specification, as found in tags of type
+// 'para'. Input and output values are normalised to the range [0, 1].
+type ParametricCurve struct {
+	// FunctionType identifies the function, from 0 to 4.
+	FunctionType uint16
+
+	// Params holds the parameters of the function, in the order g, a, b, c,
+	// d, e, f. Only as many as the function requires are present. For
+	// function types 1 and 2, a must not be zero.
+	Params []float64
+}
+
+// Apply returns the value of the curve at x.
+func (pc ParametricCurve) Apply(x float64) float64 {
+	p := pc.Params
+	g := p[0]
+
+	switch pc.FunctionType {
+	case 0:
+		return pow(x, g)
+	case 1:
+		if x >= -p[2]/p[1] {
+			return pow(p[1]*x+p[2], g)
+		}
+		return 0
+	case 2:
+		if x >= -p[2]/p[1] {
+			return pow(p[1]*x+p[2], g) + p[3]
+		}
+		return p[3]
+	case 3:
+		if x >= p[4] {
+			return pow(p[1]*x+p[2], g)
+		}
+		return p[3] * x
+	default:
+		if x >= p[4] {
+			return pow(p[1]*x+p[2], g) + p[5]
+		}
+		return p[3]*x + p[6]
+	}
+}
+
+func parseParametricCurve(data []byte) (ParametricCurve, error) {
+	result := ParametricCurve{}
+
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, ParametricCurveSignature); err != nil {
+		return result, err
+	}
+
+	functionType, err := binary.ReadU16Big(reader)
+	if err != nil {
+		return result, err
+	}
+
+	// Reserved field
+	if _, err = binary.ReadU16Big(reader); err != nil {
+		return result, err
+	}
+
+	paramCounts := []int{1, 3, 4, 5, 7}
+	if int(functionType) >= len(paramCounts) {
+		return result, binary.Errorf(binary.ErrUnsupported, "unknown parametric curve function type %d", functionType)
+	}
+	result.FunctionType = functionType
+
+	result.Params = make([]float64, paramCounts[functionType])
+	for i := range result.Params {
+		if result.Params[i], err = readS15Fixed16(reader); err != nil {
+			return result, err
+		}
+	}
+
+	// Function types 1 and 2 begin at x = -b/a, which is undefined when a is
+	// zero
+	if (functionType == 1 || functionType == 2) && result.Params[1] == 0 {
+		return result, binary.Errorf(binary.ErrMalformed, "parametric curve function type %d with zero gradient", functionType)
+	}
+
+	return result, nil
+}
+
+func clamp01(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	if x > 1 {
+		return 1
+	}
+	return x
+}
+
+// pow raises x to the power y, treating negative bases as zero so that curves
+// remain defined outside their intended domain.
+func pow(x, y float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return math.Pow(x, y)
+}
@@ -0,0 +1,29 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+	"time"
+)
+
+func readDateTimeNumber(reader binary.Reader) (result time.Time, err error) {
+	var fields [6]uint16
+	for i := range fields {
+		fields[i], err = binary.ReadU16Big(reader)
+		if err != nil {
+			return
+		}
+	}
+
+	return time.Date(int(fields[0]), time.Month(fields[1]), int(fields[2]), int(fields[3]), int(fields[4]), int(fields[5]), 0, time.UTC), nil
+}
+
+func parseDateTime(data []byte) (time.Time, error) {
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, DateTimeSignature); err != nil {
+		return time.Time{}, err
+	}
+
+	return readDateTimeNumber(reader)
+}
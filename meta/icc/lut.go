@@ -0,0 +1,154 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// LUT is a transform consisting of a matrix, input curves, a CLUT and output
+// curves, as found in tags of type 'mft1' (with 8-bit precision) and 'mft2'
+// (with 16-bit precision).
+type LUT struct {
+	// Matrix is a 3x3 matrix in row-major order, applied only when there are
+	// three input channels. It is the identity matrix unless the input colour
+	// space is XYZ.
+	Matrix [9]float64
+
+	InputCurves  []Curve
+	CLUT         *CLUT
+	OutputCurves []Curve
+}
+
+// InputChannels returns the number of channels of input colours.
+func (l *LUT) InputChannels() int {
+	return len(l.InputCurves)
+}
+
+// OutputChannels returns the number of channels of output colours.
+func (l *LUT) OutputChannels() int {
+	return len(l.OutputCurves)
+}
+
+// Apply transforms the colour src, writing the result to dst.
+func (l *LUT) Apply(dst, src []float64) {
+	var in, out [maxChannels]float64
+	values := in[:len(l.InputCurves)]
+	copy(values, src)
+
+	if len(values) == 3 {
+		applyMatrix(l.Matrix[:], values)
+	}
+	for i, c := range l.InputCurves {
+		values[i] = c.Apply(values[i])
+	}
+
+	l.CLUT.Apply(out[:], values)
+
+	for i, c := range l.OutputCurves {
+		dst[i] = c.Apply(out[i])
+	}
+}
+
+func parseLUT(data []byte) (*LUT, error) {
+	reader := bytes.NewReader(data)
+
+	typeSig, err := binary.ReadU32Big(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var bytesPerValue int
+	switch Signature(typeSig) {
+	case Lut8Signature:
+		bytesPerValue = 1
+	case Lut16Signature:
+		bytesPerValue = 2
+	default:
+		return nil, binary.Errorf(binary.ErrMalformed, "expected %v or %v but got %v", Lut8Signature, Lut16Signature, Signature(typeSig))
+	}
+
+	// Reserved field
+	if _, err = binary.ReadU32Big(reader); err != nil {
+		return nil, err
+	}
+
+	var counts [4]byte
+	if _, err := reader.Read(counts[:]); err != nil {
+		return nil, err
+	}
+	inputChannels, outputChannels, gridPoints := int(counts[0]), int(counts[1]), int(counts[2])
+
+	lut := &LUT{}
+	for i := range lut.Matrix {
+		if lut.Matrix[i], err = readS15Fixed16(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	inputEntries, outputEntries := 256, 256
+	if bytesPerValue == 2 {
+		in, err := binary.ReadU16Big(reader)
+		if err != nil {
+			return nil, err
+		}
+		out, err := binary.ReadU16Big(reader)
+		if err != nil {
+			return nil, err
+		}
+		inputEntries, outputEntries = int(in), int(out)
+
+		if inputEntries < 2 || inputEntries > 4096 || outputEntries < 2 || outputEntries > 4096 {
+			return nil, binary.Errorf(binary.ErrMalformed, "invalid table sizes %d and %d", inputEntries, outputEntries)
+		}
+	}
+
+	rest := data[len(data)-reader.Len():]
+
+	if lut.InputCurves, rest, err = parseLUTCurves(rest, inputChannels, inputEntries, bytesPerValue); err != nil {
+		return nil, err
+	}
+
+	grid := make([]int, inputChannels)
+	for i := range grid {
+		grid[i] = gridPoints
+	}
+	if lut.CLUT, rest, err = parseCLUT(rest, grid, outputChannels, bytesPerValue); err != nil {
+		return nil, err
+	}
+
+	if lut.OutputCurves, _, err = parseLUTCurves(rest, outputChannels, outputEntries, bytesPerValue); err != nil {
+		return nil, err
+	}
+
+	return lut, nil
+}
+
+// parseLUTCurves parses the input or output tables of an 'mft1' or 'mft2' tag,
+// returning them as sampled curves along with the remaining data.
+func parseLUTCurves(data []byte, channels int, entries int, bytesPerValue int) ([]Curve, []byte, error) {
+	if channels == 0 || channels > maxChannels {
+		return nil, nil, binary.Errorf(binary.ErrMalformed, "invalid channel count %d", channels)
+	}
+
+	length := channels * entries * bytesPerValue
+	if length > len(data) {
+		return nil, nil, binary.Errorf(binary.ErrTruncated, "insufficient data for %d tables of %d entries", channels, entries)
+	}
+
+	curves := make([]Curve, channels)
+	for i := range curves {
+		table := make([]uint16, entries)
+		for j := range table {
+			if bytesPerValue == 1 {
+				table[j] = uint16(data[0]) * 257
+				data = data[1:]
+			} else {
+				table[j] = uint16(data[0])<<8 | uint16(data[1])
+				data = data[2:]
+			}
+		}
+		curves[i] = Curve{Table: table}
+	}
+
+	return curves, data, nil
+}
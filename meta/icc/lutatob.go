@@ -0,0 +1,293 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// LUTAToB is a transform from device colours to the profile connection space,
+// as found in tags of type 'mAB '. Colours pass through the A curves, CLUT, M
+// curves, matrix and B curves in turn, any of which other than the B curves
+// may be absent.
+type LUTAToB struct {
+	ACurves []ToneCurve
+	CLUT    *CLUT
+	MCurves []ToneCurve
+
+	// Matrix is a 3x3 matrix in row-major order followed by three offsets,
+	// or nil if absent.
+	Matrix []float64
+
+	BCurves []ToneCurve
+
+	inputChannels  int
+	outputChannels int
+}
+
+// InputChannels returns the number of channels of input colours.
+func (l *LUTAToB) InputChannels() int {
+	return l.inputChannels
+}
+
+// OutputChannels returns the number of channels of output colours.
+func (l *LUTAToB) OutputChannels() int {
+	return l.outputChannels
+}
+
+// Apply transforms the colour src, writing the result to dst.
+func (l *LUTAToB) Apply(dst, src []float64) {
+	var in, out [maxChannels]float64
+	values := in[:l.inputChannels]
+	copy(values, src)
+
+	if l.CLUT != nil {
+		applyCurves(l.ACurves, values)
+		l.CLUT.Apply(out[:], values)
+		values = out[:l.outputChannels]
+	}
+	if l.Matrix != nil {
+		applyCurves(l.MCurves, values)
+		applyMatrix(l.Matrix, values)
+	}
+	applyCurves(l.BCurves, values)
+
+	copy(dst, values)
+}
+
+// LUTBToA is a transform from the profile connection space to device colours,
+// as found in tags of type 'mBA '. Colours pass through the B curves, matrix,
+// M curves, CLUT and A curves in turn, any of which other than the B curves
+// may be absent.
+type LUTBToA struct {
+	BCurves []ToneCurve
+
+	// Matrix is a 3x3 matrix in row-major order followed by three offsets,
+	// or nil if absent.
+	Matrix []float64
+
+	MCurves []ToneCurve
+	CLUT    *CLUT
+	ACurves []ToneCurve
+
+	inputChannels  int
+	outputChannels int
+}
+
+// InputChannels returns the number of channels of input colours.
+func (l *LUTBToA) InputChannels() int {
+	return l.inputChannels
+}
+
+// OutputChannels returns the number of channels of output colours.
+func (l *LUTBToA) OutputChannels() int {
+	return l.outputChannels
+}
+
+// Apply transforms the colour src, writing the result to dst.
+func (l *LUTBToA) Apply(dst, src []float64) {
+	var in, out [maxChannels]float64
+	values := in[:l.inputChannels]
+	copy(values, src)
+
+	applyCurves(l.BCurves, values)
+	if l.Matrix != nil {
+		applyMatrix(l.Matrix, values)
+		applyCurves(l.MCurves, values)
+	}
+	if l.CLUT != nil {
+		l.CLUT.Apply(out[:], values)
+		values = out[:l.outputChannels]
+		applyCurves(l.ACurves, values)
+	}
+
+	copy(dst, values)
+}
+
+// lutStages holds the elements of an 'mAB ' or 'mBA ' tag, which differ only
+// in the order in which they're applied.
+type lutStages struct {
+	inputChannels  int
+	outputChannels int
+	aCurves        []ToneCurve
+	clut           *CLUT
+	mCurves        []ToneCurve
+	matrix         []float64
+	bCurves        []ToneCurve
+}
+
+func parseLUTAToB(data []byte) (*LUTAToB, error) {
+	s, err := parseLUTStages(data, LutAToBSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	// The A curves and CLUT map from input channels, and the remaining
+	// elements operate on output channels
+	if err := s.validate(s.inputChannels, s.outputChannels); err != nil {
+		return nil, err
+	}
+
+	return &LUTAToB{
+		ACurves:        s.aCurves,
+		CLUT:           s.clut,
+		MCurves:        s.mCurves,
+		Matrix:         s.matrix,
+		BCurves:        s.bCurves,
+		inputChannels:  s.inputChannels,
+		outputChannels: s.outputChannels,
+	}, nil
+}
+
+func parseLUTBToA(data []byte) (*LUTBToA, error) {
+	s, err := parseLUTStages(data, LutBToASignature)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CLUT and A curves map to output channels, and the remaining
+	// elements operate on input channels
+	if err := s.validate(s.outputChannels, s.inputChannels); err != nil {
+		return nil, err
+	}
+
+	return &LUTBToA{
+		BCurves:        s.bCurves,
+		Matrix:         s.matrix,
+		MCurves:        s.mCurves,
+		CLUT:           s.clut,
+		ACurves:        s.aCurves,
+		inputChannels:  s.inputChannels,
+		outputChannels: s.outputChannels,
+	}, nil
+}
+
+// validate checks that the elements are consistent, where aChannels is the
+// number of channels on the A curve side of the CLUT and bChannels the number
+// on the B curve side.
+func (s *lutStages) validate(aChannels, bChannels int) error {
+	if len(s.bCurves) != bChannels {
+		return binary.Errorf(binary.ErrMalformed, "expected %d B curves but got %d", bChannels, len(s.bCurves))
+	}
+	if s.matrix != nil {
+		if bChannels != 3 {
+			return binary.Errorf(binary.ErrMalformed, "matrix requires 3 channels but got %d", bChannels)
+		}
+		if len(s.mCurves) != bChannels {
+			return binary.Errorf(binary.ErrMalformed, "expected %d M curves but got %d", bChannels, len(s.mCurves))
+		}
+	} else if s.mCurves != nil {
+		return binary.Errorf(binary.ErrMalformed, "M curves without a matrix")
+	}
+	if s.clut == nil {
+		if aChannels != bChannels {
+			return binary.Errorf(binary.ErrMalformed, "channel counts %d and %d differ without a CLUT", s.inputChannels, s.outputChannels)
+		}
+		if s.aCurves != nil {
+			return binary.Errorf(binary.ErrMalformed, "A curves without a CLUT")
+		}
+	} else if len(s.aCurves) != aChannels {
+		return binary.Errorf(binary.ErrMalformed, "expected %d A curves but got %d", aChannels, len(s.aCurves))
+	}
+	return nil
+}
+
+func parseLUTStages(data []byte, expected Signature) (*lutStages, error) {
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, expected); err != nil {
+		return nil, err
+	}
+
+	var header [4]byte
+	if _, err := reader.Read(header[:]); err != nil {
+		return nil, err
+	}
+
+	s := &lutStages{
+		inputChannels:  int(header[0]),
+		outputChannels: int(header[1]),
+	}
+	if s.inputChannels == 0 || s.inputChannels > maxChannels || s.outputChannels == 0 || s.outputChannels > maxChannels {
+		return nil, binary.Errorf(binary.ErrMalformed, "invalid channel counts %d and %d", s.inputChannels, s.outputChannels)
+	}
+
+	var offsets [5]uint32
+	for i := range offsets {
+		var err error
+		if offsets[i], err = binary.ReadU32Big(reader); err != nil {
+			return nil, err
+		}
+	}
+	bOffset, matrixOffset, mOffset, clutOffset, aOffset := offsets[0], offsets[1], offsets[2], offsets[3], offsets[4]
+
+	// The B curves are adjacent to the input for 'mBA ' tags and to the
+	// output for 'mAB ' tags
+	aChannels, bChannels := s.inputChannels, s.outputChannels
+	if expected == LutBToASignature {
+		aChannels, bChannels = bChannels, aChannels
+	}
+
+	var err error
+
+	if bOffset == 0 {
+		return nil, binary.Errorf(binary.ErrMalformed, "missing B curves")
+	}
+	if s.bCurves, err = parseCurveSequence(data, bOffset, bChannels); err != nil {
+		return nil, err
+	}
+
+	if matrixOffset != 0 {
+		if uint64(matrixOffset)+48 > uint64(len(data)) {
+			return nil, binary.Errorf(binary.ErrTruncated, "insufficient data for matrix")
+		}
+		matrixReader := bytes.NewReader(data[matrixOffset:])
+		s.matrix = make([]float64, 12)
+		for i := range s.matrix {
+			if s.matrix[i], err = readS15Fixed16(matrixReader); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if mOffset != 0 {
+		if s.mCurves, err = parseCurveSequence(data, mOffset, bChannels); err != nil {
+			return nil, err
+		}
+	}
+
+	if clutOffset != 0 {
+		if s.clut, err = parseLUTStagesCLUT(data, clutOffset, s.inputChannels, s.outputChannels); err != nil {
+			return nil, err
+		}
+	}
+
+	if aOffset != 0 {
+		if s.aCurves, err = parseCurveSequence(data, aOffset, aChannels); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// parseLUTStagesCLUT parses the CLUT of an 'mAB ' or 'mBA ' tag, which begins
+// with its grid point counts and precision.
+func parseLUTStagesCLUT(data []byte, offset uint32, inputChannels, outputChannels int) (*CLUT, error) {
+	if uint64(offset)+20 > uint64(len(data)) {
+		return nil, binary.Errorf(binary.ErrTruncated, "insufficient data for CLUT")
+	}
+	header := data[offset : offset+20]
+
+	grid := make([]int, inputChannels)
+	for i := range grid {
+		grid[i] = int(header[i])
+	}
+
+	precision := int(header[16])
+	if precision != 1 && precision != 2 {
+		return nil, binary.Errorf(binary.ErrMalformed, "invalid CLUT precision %d", precision)
+	}
+
+	clut, _, err := parseCLUT(data[offset+20:], grid, outputChannels, precision)
+	return clut, err
+}
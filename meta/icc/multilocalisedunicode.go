@@ -4,13 +4,48 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mandykoh/prism/meta/binary"
+	"sort"
 	"unicode/utf16"
 )
 
+// MultiLocalisedUnicode holds text in multiple languages, as found in tags of
+// type 'mluc'.
 type MultiLocalisedUnicode struct {
 	entriesByLanguageCountry map[[2]byte]map[[2]byte]string
 }
 
+// Text returns the English text, or the text for any other language if there
+// is no English text.
+func (mluc *MultiLocalisedUnicode) Text() string {
+	if en := mluc.getStringForLanguage([2]byte{'e', 'n'}); en != "" {
+		return en
+	}
+	return mluc.getAnyString()
+}
+
+// Localised returns the text for the specified ISO 639-1 language code and
+// ISO 3166-1 country code (eg "en" and "US"), or an empty string if there is
+// none.
+func (mluc *MultiLocalisedUnicode) Localised(language, country string) string {
+	var lc languageCountry
+	copy(lc.language[:], language)
+	copy(lc.country[:], country)
+	return mluc.getString(lc.language, lc.country)
+}
+
+// Locales returns the language and country of each localised text, in the
+// form "en_US".
+func (mluc *MultiLocalisedUnicode) Locales() []string {
+	var locales []string
+	for language, countries := range mluc.entriesByLanguageCountry {
+		for country := range countries {
+			locales = append(locales, languageCountry{language, country}.String())
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
 func (mluc *MultiLocalisedUnicode) getAnyString() string {
 	for _, country := range mluc.entriesByLanguageCountry {
 		for _, s := range country {
@@ -61,7 +96,7 @@ func parseMultiLocalisedUnicode(data []byte) (MultiLocalisedUnicode, error) {
 		return result, err
 	}
 	if s := Signature(sig); s != MultiLocalisedUnicodeSignature {
-		return result, fmt.Errorf("expected %v but got %v", MultiLocalisedUnicodeSignature, s)
+		return result, binary.Errorf(binary.ErrMalformed, "expected %v but got %v", MultiLocalisedUnicodeSignature, s)
 	}
 
 	// Reserved field
@@ -88,7 +123,7 @@ func parseMultiLocalisedUnicode(data []byte) (MultiLocalisedUnicode, error) {
 			return result, err
 		}
 		if n < len(language) {
-			return result, fmt.Errorf("unexpected eof when reading language code")
+			return result, binary.Errorf(binary.ErrTruncated, "unexpected eof when reading language code")
 		}
 
 		country := [2]byte{}
@@ -97,7 +132,7 @@ func parseMultiLocalisedUnicode(data []byte) (MultiLocalisedUnicode, error) {
 			return result, err
 		}
 		if n < len(country) {
-			return result, fmt.Errorf("unexpected eof when reading country code")
+			return result, binary.Errorf(binary.ErrTruncated, "unexpected eof when reading country code")
 		}
 
 		stringLength, err := binary.ReadU32Big(reader)
@@ -110,17 +145,14 @@ func parseMultiLocalisedUnicode(data []byte) (MultiLocalisedUnicode, error) {
 			return result, err
 		}
 
-		if uint64(stringOffset+stringLength) > uint64(len(data)) {
-			return result, fmt.Errorf("record exceeds tag data length")
+		if uint64(stringOffset)+uint64(stringLength) > uint64(len(data)) {
+			return result, binary.Errorf(binary.ErrMalformed, "record exceeds tag data length")
 		}
 
 		recordStringBytes := data[stringOffset : stringOffset+stringLength]
 		recordStringUTF16 := make([]uint16, len(recordStringBytes)/2)
 		for j := 0; j < len(recordStringUTF16); j++ {
-			recordStringUTF16[j], err = binary.ReadU16Big(reader)
-			if err != nil {
-				return result, err
-			}
+			recordStringUTF16[j] = uint16(recordStringBytes[j*2])<<8 | uint16(recordStringBytes[j*2+1])
 		}
 		result.setString(language, country, string(utf16.Decode(recordStringUTF16)))
 
@@ -1,12 +1,15 @@
 package icc
 
+import "github.com/mandykoh/prism/meta/binary"
+
 type Profile struct {
 	Header   Header
 	TagTable TagTable
 }
 
 func (p *Profile) Description() (string, error) {
-	return p.TagTable.getProfileDescription()
+	desc, err := p.TagTable.getProfileDescription()
+	return desc, binary.Truncated(err)
 }
 
 func newProfile() *Profile {
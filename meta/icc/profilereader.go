@@ -1,21 +1,30 @@
 package icc
 
 import (
-	"fmt"
+	"bytes"
 	"github.com/mandykoh/prism/meta/binary"
-	"time"
+	"io"
 )
 
 type ProfileReader struct {
 	reader binary.Reader
+
+	// MaxProfileSize is the maximum size in bytes of a profile which will be
+	// read. Zero means that the size is not limited.
+	MaxProfileSize int64
+
+	// MaxTagCount is the maximum number of tags a profile may have. Zero means
+	// that the number of tags is not limited.
+	MaxTagCount int
 }
 
 func (pr *ProfileReader) ReadProfile() (p *Profile, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			p = nil
-			err = fmt.Errorf("panic while parsing ICC profile: %v", r)
+			err = binary.Errorf(binary.ErrMalformed, "panic while parsing ICC profile: %v", r)
 		}
+		err = binary.Truncated(err)
 	}()
 
 	profile := newProfile()
@@ -33,40 +42,6 @@ func (pr *ProfileReader) ReadProfile() (p *Profile, err error) {
 	return profile, nil
 }
 
-func (pr *ProfileReader) readDateTimeNumber() (result time.Time, err error) {
-	year, err := binary.ReadU16Big(pr.reader)
-	if err != nil {
-		return
-	}
-
-	month, err := binary.ReadU16Big(pr.reader)
-	if err != nil {
-		return
-	}
-
-	day, err := binary.ReadU16Big(pr.reader)
-	if err != nil {
-		return
-	}
-
-	hour, err := binary.ReadU16Big(pr.reader)
-	if err != nil {
-		return
-	}
-
-	minute, err := binary.ReadU16Big(pr.reader)
-	if err != nil {
-		return
-	}
-
-	second, err := binary.ReadU16Big(pr.reader)
-	if err != nil {
-		return
-	}
-
-	return time.Date(int(year), time.Month(month), int(day), int(hour), int(minute), int(second), 0, time.UTC), nil
-}
-
 func (pr *ProfileReader) readHeader(header *Header) error {
 	var err error
 
@@ -118,7 +93,7 @@ func (pr *ProfileReader) readHeader(header *Header) error {
 	}
 	header.ProfileConnectionSpace = ColorSpace(value)
 
-	header.CreatedAt, err = pr.readDateTimeNumber()
+	header.CreatedAt, err = readDateTimeNumber(pr.reader)
 	if err != nil {
 		return err
 	}
@@ -128,7 +103,7 @@ func (pr *ProfileReader) readHeader(header *Header) error {
 		return err
 	}
 	if s := Signature(value); s != ProfileFileSignature {
-		return fmt.Errorf("invalid profile file signature %s", s)
+		return binary.Errorf(binary.ErrFormatMismatch, "invalid profile file signature %s", s)
 	}
 
 	value, err = binary.ReadU32Big(pr.reader)
@@ -185,7 +160,7 @@ func (pr *ProfileReader) readHeader(header *Header) error {
 		return err
 	}
 	if bytesRead < len(header.ProfileID) {
-		return fmt.Errorf("unexpected EOF when reading profile ID")
+		return binary.Errorf(binary.ErrTruncated, "unexpected EOF when reading profile ID")
 	}
 
 	// 28 reserved bytes
@@ -205,6 +180,9 @@ func (pr *ProfileReader) readTagTable(tagTable *TagTable) error {
 	if err != nil {
 		return err
 	}
+	if pr.MaxTagCount > 0 && uint64(tagCount) > uint64(pr.MaxTagCount) {
+		return &binary.LimitError{Limit: "MaxTagCount", Value: int64(pr.MaxTagCount)}
+	}
 
 	type tagIndexEntry struct {
 		offset uint32
@@ -212,7 +190,7 @@ func (pr *ProfileReader) readTagTable(tagTable *TagTable) error {
 	}
 	tagIndex := make(map[Signature]tagIndexEntry)
 
-	endOfTagData := uint32(0)
+	endOfTagData := uint64(0)
 	for i := uint32(0); i < tagCount; i++ {
 		sig, err := binary.ReadU32Big(pr.reader)
 		if err != nil {
@@ -229,8 +207,8 @@ func (pr *ProfileReader) readTagTable(tagTable *TagTable) error {
 			return err
 		}
 
-		if offset+size > endOfTagData {
-			endOfTagData = offset + size
+		if end := uint64(offset) + uint64(size); end > endOfTagData {
+			endOfTagData = end
 		}
 
 		tagIndex[Signature(sig)] = tagIndexEntry{
@@ -239,20 +217,33 @@ func (pr *ProfileReader) readTagTable(tagTable *TagTable) error {
 		}
 	}
 
-	tagDataOffset := tagTableOffset + 4 + (tagCount * 12)
-	tagData := make([]byte, endOfTagData-tagDataOffset)
-	bytesRead, err := pr.reader.Read(tagData)
-	if err != nil {
+	if pr.MaxProfileSize > 0 && endOfTagData > uint64(pr.MaxProfileSize) {
+		return &binary.LimitError{Limit: "MaxProfileSize", Value: pr.MaxProfileSize}
+	}
+
+	tagDataOffset := uint64(tagTableOffset) + 4 + uint64(tagCount)*12
+	if endOfTagData < tagDataOffset {
+		endOfTagData = tagDataOffset
+	}
+
+	// Tag data is read incrementally so that memory is only allocated for data
+	// which is actually present
+	tagData := &bytes.Buffer{}
+	bytesRead, err := io.CopyN(tagData, pr.reader, int64(endOfTagData-tagDataOffset))
+	if err != nil && err != io.EOF {
 		return err
 	}
-	if bytesRead < len(tagData) {
-		return fmt.Errorf("expected %d bytes of tag data but only got %d", len(tagData), bytesRead)
+	if uint64(bytesRead) < endOfTagData-tagDataOffset {
+		return binary.Errorf(binary.ErrTruncated, "expected %d bytes of tag data but only got %d", endOfTagData-tagDataOffset, bytesRead)
 	}
 
 	for sig, entry := range tagIndex {
-		startOffset := entry.offset - tagDataOffset
-		endOffset := startOffset + entry.size
-		tagTable.add(sig, tagData[startOffset:endOffset])
+		if uint64(entry.offset) < tagDataOffset {
+			return binary.Malformed(sig.String(), int64(entry.offset), "tag data overlaps tag table")
+		}
+		startOffset := uint64(entry.offset) - tagDataOffset
+		endOffset := startOffset + uint64(entry.size)
+		tagTable.add(sig, tagData.Bytes()[startOffset:endOffset])
 	}
 
 	return nil
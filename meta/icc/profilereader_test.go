@@ -3,6 +3,7 @@ package icc
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/mandykoh/prism/meta/binary"
 	"io"
@@ -123,6 +124,8 @@ func TestProfileReader(t *testing.T) {
 				t.Errorf("Expected error but operation succeeded")
 			} else if expected, actual := "invalid profile file signature 'bad!'", err.Error(); expected != actual {
 				t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+			} else if !errors.Is(err, binary.ErrFormatMismatch) {
+				t.Errorf("Expected format mismatch error but got %v", err)
 			}
 		})
 
@@ -141,6 +144,89 @@ func TestProfileReader(t *testing.T) {
 				t.Errorf("Expected error but operation succeeded")
 			} else if expected, actual := "EOF", err.Error(); expected != actual {
 				t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+			} else if !errors.Is(err, binary.ErrTruncated) {
+				t.Errorf("Expected truncation error but got %v", err)
+			}
+		})
+
+		t.Run("returns an error when the tag count limit is exceeded", func(t *testing.T) {
+			profileData := &bytes.Buffer{}
+			writeHeader(profileData, [4]byte{'a', 'c', 's', 'p'})
+			writeTagTable(profileData, map[[4]byte][]byte{
+				[4]byte{'t', 'e', 's', '1'}: {1, 2, 3, 4},
+				[4]byte{'t', 'e', 's', '2'}: {5, 6, 7, 8},
+			})
+
+			reader := NewProfileReader(profileData)
+			reader.MaxTagCount = 1
+			_, err := reader.ReadProfile()
+
+			var limitErr *binary.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Errorf("Expected limit error but got %v", err)
+			} else if expected, actual := "MaxTagCount", limitErr.Limit; expected != actual {
+				t.Errorf("Expected limit '%s' to be exceeded but got '%s'", expected, actual)
+			}
+		})
+
+		t.Run("returns an error when the profile size limit is exceeded", func(t *testing.T) {
+			profileData := &bytes.Buffer{}
+			writeHeader(profileData, [4]byte{'a', 'c', 's', 'p'})
+			writeTagTable(profileData, map[[4]byte][]byte{
+				[4]byte{'t', 'e', 's', 't'}: make([]byte, 1000),
+			})
+
+			reader := NewProfileReader(profileData)
+			reader.MaxProfileSize = 500
+			_, err := reader.ReadProfile()
+
+			var limitErr *binary.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Errorf("Expected limit error but got %v", err)
+			} else if expected, actual := "MaxProfileSize", limitErr.Limit; expected != actual {
+				t.Errorf("Expected limit '%s' to be exceeded but got '%s'", expected, actual)
+			}
+		})
+
+		t.Run("returns an error without allocating for tag data which isn't present", func(t *testing.T) {
+			profileData := &bytes.Buffer{}
+			writeHeader(profileData, [4]byte{'a', 'c', 's', 'p'})
+			_, _ = profileData.Write([]byte{
+				0x00, 0x00, 0x00, 0x01, // Tag count
+				't', 'e', 's', 't', // Tag signature
+				0x00, 0x00, 0x00, 0x90, // Tag offset
+				0xF0, 0x00, 0x00, 0x00, // Tag size
+			})
+
+			reader := NewProfileReader(profileData)
+			_, err := reader.ReadProfile()
+
+			if err == nil {
+				t.Errorf("Expected error but operation succeeded")
+			} else if !errors.Is(err, binary.ErrTruncated) {
+				t.Errorf("Expected truncation error but got %v", err)
+			}
+		})
+
+		t.Run("returns an error with the offset of tag data overlapping the tag table", func(t *testing.T) {
+			profileData := &bytes.Buffer{}
+			writeHeader(profileData, [4]byte{'a', 'c', 's', 'p'})
+			_, _ = profileData.Write([]byte{
+				0x00, 0x00, 0x00, 0x01, // Tag count
+				't', 'e', 's', 't', // Tag signature
+				0x00, 0x00, 0x00, 0x40, // Tag offset
+				0x00, 0x00, 0x00, 0x04, // Tag size
+			})
+
+			reader := NewProfileReader(profileData)
+			_, err := reader.ReadProfile()
+
+			var malformedErr *binary.MalformedError
+			if !errors.As(err, &malformedErr) {
+				t.Fatalf("Expected malformed tag error but got %v", err)
+			}
+			if expected, actual := int64(0x40), malformedErr.Offset; expected != actual {
+				t.Errorf("Expected malformed tag at offset %d but got %d", expected, actual)
 			}
 		})
 
@@ -10,6 +10,53 @@ const (
 	MultiLocalisedUnicodeSignature Signature = 0x6D6C7563 // 'mluc'
 )
 
+// Tag signatures
+const (
+	AToB0Signature               Signature = 0x41324230 // 'A2B0'
+	AToB1Signature               Signature = 0x41324231 // 'A2B1'
+	AToB2Signature               Signature = 0x41324232 // 'A2B2'
+	BToA0Signature               Signature = 0x42324130 // 'B2A0'
+	BToA1Signature               Signature = 0x42324131 // 'B2A1'
+	BToA2Signature               Signature = 0x42324132 // 'B2A2'
+	BlueMatrixColumnSignature    Signature = 0x6258595A // 'bXYZ'
+	BlueTRCSignature             Signature = 0x62545243 // 'bTRC'
+	CalibrationDateTimeSignature Signature = 0x63616C74 // 'calt'
+	CharTargetSignature          Signature = 0x74617267 // 'targ'
+	ChromaticAdaptationSignature Signature = 0x63686164 // 'chad'
+	ChromaticitySignature        Signature = 0x6368726D // 'chrm'
+	CopyrightSignature           Signature = 0x63707274 // 'cprt'
+	DeviceMfgDescSignature       Signature = 0x646D6E64 // 'dmnd'
+	DeviceModelDescSignature     Signature = 0x646D6464 // 'dmdd'
+	GamutSignature               Signature = 0x67616D74 // 'gamt'
+	GrayTRCSignature             Signature = 0x6B545243 // 'kTRC'
+	GreenMatrixColumnSignature   Signature = 0x6758595A // 'gXYZ'
+	GreenTRCSignature            Signature = 0x67545243 // 'gTRC'
+	LuminanceSignature           Signature = 0x6C756D69 // 'lumi'
+	MediaBlackPointSignature     Signature = 0x626B7074 // 'bkpt'
+	MediaWhitePointSignature     Signature = 0x77747074 // 'wtpt'
+	RedMatrixColumnSignature     Signature = 0x7258595A // 'rXYZ'
+	RedTRCSignature              Signature = 0x72545243 // 'rTRC'
+	TechnologySignature          Signature = 0x74656368 // 'tech'
+	ViewingCondDescSignature     Signature = 0x76756564 // 'vued'
+)
+
+// Tag type signatures, identifying how the data of a tag is encoded. The
+// 'desc' and 'chrm' types share their signatures with the tags of the same
+// name.
+const (
+	CurveSignature           Signature = 0x63757276 // 'curv'
+	DateTimeSignature        Signature = 0x6474696D // 'dtim'
+	Lut8Signature            Signature = 0x6D667431 // 'mft1'
+	Lut16Signature           Signature = 0x6D667432 // 'mft2'
+	LutAToBSignature         Signature = 0x6D414220 // 'mAB '
+	LutBToASignature         Signature = 0x6D424120 // 'mBA '
+	ParametricCurveSignature Signature = 0x70617261 // 'para'
+	S15Fixed16ArraySignature Signature = 0x73663332 // 'sf32'
+	SignatureTypeSignature   Signature = 0x73696720 // 'sig '
+	TextSignature            Signature = 0x74657874 // 'text'
+	XYZSignature             Signature = 0x58595A20 // 'XYZ '
+)
+
 func (s Signature) String() string {
 	v := [4]byte{
 		maskNull(byte((s >> 24) & 0xff)),
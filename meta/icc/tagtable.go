@@ -2,47 +2,161 @@ package icc
 
 import (
 	"bytes"
-	"fmt"
 	"github.com/mandykoh/prism/meta/binary"
+	"sort"
 )
 
+// TagTable holds the tags of a profile, keyed by tag signature.
 type TagTable struct {
 	entries map[Signature][]byte
 }
 
+// Signatures returns the signatures of all the tags in the table, in
+// ascending order.
+func (t *TagTable) Signatures() []Signature {
+	sigs := make([]Signature, 0, len(t.entries))
+	for sig := range t.entries {
+		sigs = append(sigs, sig)
+	}
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i] < sigs[j] })
+	return sigs
+}
+
+// Data returns the undecoded data of the tag with the specified signature, or
+// false if there is no such tag.
+func (t *TagTable) Data(sig Signature) ([]byte, bool) {
+	data, ok := t.entries[sig]
+	return data, ok
+}
+
+// Type returns the type signature of the tag with the specified signature, or
+// false if there is no such tag.
+func (t *TagTable) Type(sig Signature) (Signature, bool) {
+	data, ok := t.entries[sig]
+	if !ok || len(data) < 4 {
+		return 0, false
+	}
+
+	typeSig, _ := binary.ReadU32Big(bytes.NewReader(data))
+	return Signature(typeSig), true
+}
+
+// Decode returns the value of the tag with the specified signature, according
+// to its type:
+//
+//	'chrm' - Chromaticity
+//	'curv' - Curve
+//	'desc' - TextDescription
+//	'dtim' - time.Time
+//	'mAB ' - *LUTAToB
+//	'mBA ' - *LUTBToA
+//	'mft1' - *LUT
+//	'mft2' - *LUT
+//	'mluc' - MultiLocalisedUnicode
+//	'para' - ParametricCurve
+//	'sf32' - []float64
+//	'sig ' - Signature
+//	'text' - string
+//	'XYZ ' - []XYZNumber
+//
+// If there is no such tag, nil is returned without an error. An error
+// satisfying errors.Is(err, binary.ErrUnsupported) is returned for tags of
+// other types.
+func (t *TagTable) Decode(sig Signature) (interface{}, error) {
+	data, ok := t.entries[sig]
+	if !ok {
+		return nil, nil
+	}
+
+	typeSig, ok := t.Type(sig)
+	if !ok {
+		return nil, binary.Errorf(binary.ErrTruncated, "tag %v is too short to have a type", sig)
+	}
+
+	var value interface{}
+	var err error
+
+	switch typeSig {
+	case ChromaticitySignature:
+		value, err = parseChromaticity(data)
+	case CurveSignature:
+		value, err = parseCurve(data)
+	case DescSignature:
+		value, err = parseTextDescription(data)
+	case DateTimeSignature:
+		value, err = parseDateTime(data)
+	case Lut8Signature, Lut16Signature:
+		value, err = parseLUT(data)
+	case LutAToBSignature:
+		value, err = parseLUTAToB(data)
+	case LutBToASignature:
+		value, err = parseLUTBToA(data)
+	case MultiLocalisedUnicodeSignature:
+		value, err = parseMultiLocalisedUnicode(data)
+	case ParametricCurveSignature:
+		value, err = parseParametricCurve(data)
+	case S15Fixed16ArraySignature:
+		value, err = parseS15Fixed16Array(data)
+	case SignatureTypeSignature:
+		value, err = parseSignatureType(data)
+	case TextSignature:
+		value, err = parseText(data)
+	case XYZSignature:
+		value, err = parseXYZ(data)
+	default:
+		return nil, binary.Errorf(binary.ErrUnsupported, "unsupported type %v for tag %v", typeSig, sig)
+	}
+
+	if err != nil {
+		return nil, binary.Truncated(err)
+	}
+	return value, nil
+}
+
+// Transform returns the transform described by the lookup table tag with the
+// specified signature (eg AToB0Signature), or nil if there is no such tag.
+func (t *TagTable) Transform(sig Signature) (Transform, error) {
+	value, err := t.Decode(sig)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	transform, ok := value.(Transform)
+	if !ok {
+		typeSig, _ := t.Type(sig)
+		return nil, binary.Errorf(binary.ErrMalformed, "tag %v of type %v is not a transform", sig, typeSig)
+	}
+	return transform, nil
+}
+
 func (t *TagTable) add(sig Signature, data []byte) {
 	t.entries[sig] = data
 }
 
 func (t *TagTable) getProfileDescription() (string, error) {
-	data := t.entries[DescSignature]
+	typeSig, ok := t.Type(DescSignature)
+	if !ok {
+		return "", binary.Errorf(binary.ErrMalformed, "missing profile description")
+	}
 
-	sig, err := binary.ReadU32Big(bytes.NewReader(data))
+	switch typeSig {
+	case DescSignature, MultiLocalisedUnicodeSignature, TextSignature:
+	default:
+		return "", binary.Errorf(binary.ErrUnsupported, "unknown profile description type (%v)", typeSig)
+	}
+
+	value, err := t.Decode(DescSignature)
 	if err != nil {
 		return "", err
 	}
 
-	switch Signature(sig) {
-
-	case DescSignature:
-		desc, err := parseTextDescription(data)
-		if err != nil {
-			return "", err
-		}
+	switch desc := value.(type) {
+	case TextDescription:
 		return desc.ASCII, nil
-
-	case MultiLocalisedUnicodeSignature:
-		mluc, err := parseMultiLocalisedUnicode(data)
-		if err != nil {
-			return "", err
-		}
-		if enUS := mluc.getStringForLanguage([2]byte{'e', 'n'}); enUS != "" {
-			return enUS, nil
-		}
-		return mluc.getAnyString(), nil
-
+	case MultiLocalisedUnicode:
+		return desc.Text(), nil
 	default:
-		return "", fmt.Errorf("unknown profile description type (%v)", Signature(sig))
+		return value.(string), nil
 	}
 }
 
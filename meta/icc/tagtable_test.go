@@ -0,0 +1,331 @@
+package icc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta/binary"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagTable(t *testing.T) {
+
+	tagTableWith := func(sig Signature, data []byte) *TagTable {
+		table := emptyTagTable()
+		table.add(sig, data)
+		return &table
+	}
+
+	typeHeader := func(typeSig Signature) *bytes.Buffer {
+		data := &bytes.Buffer{}
+		_ = binary.WriteU32Big(data, uint32(typeSig))
+		_ = binary.WriteU32Big(data, 0)
+		return data
+	}
+
+	decode := func(t *testing.T, table *TagTable, sig Signature) interface{} {
+		t.Helper()
+
+		value, err := table.Decode(sig)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return value
+	}
+
+	assertApproximately := func(t *testing.T, expected, actual float64) {
+		t.Helper()
+
+		if math.Abs(expected-actual) > 0.0001 {
+			t.Errorf("Expected %f but got %f", expected, actual)
+		}
+	}
+
+	t.Run("with a real profile", func(t *testing.T) {
+		profileFile, err := os.Open("../../test-profiles/display-p3-v4-with-v2-desc.icc")
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		defer profileFile.Close()
+
+		profile, err := NewProfileReader(bufio.NewReader(profileFile)).ReadProfile()
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		table := &profile.TagTable
+
+		t.Run("lists tag signatures in order", func(t *testing.T) {
+			expected := []Signature{
+				BlueTRCSignature,
+				BlueMatrixColumnSignature,
+				ChromaticAdaptationSignature,
+				CopyrightSignature,
+				DescSignature,
+				GreenTRCSignature,
+				GreenMatrixColumnSignature,
+				RedTRCSignature,
+				RedMatrixColumnSignature,
+				MediaWhitePointSignature,
+			}
+
+			if actual := table.Signatures(); !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected signatures %v but got %v", expected, actual)
+			}
+		})
+
+		t.Run("reports tag types", func(t *testing.T) {
+			if typeSig, ok := table.Type(RedTRCSignature); !ok {
+				t.Errorf("Expected tag to be found but it wasn't")
+			} else if expected, actual := ParametricCurveSignature, typeSig; expected != actual {
+				t.Errorf("Expected type %v but got %v", expected, actual)
+			}
+		})
+
+		t.Run("decodes XYZ tags", func(t *testing.T) {
+			value := decode(t, table, MediaWhitePointSignature)
+
+			xyz, ok := value.([]XYZNumber)
+			if !ok || len(xyz) != 1 {
+				t.Fatalf("Expected a single XYZ number but got %v", value)
+			}
+			assertApproximately(t, 0.9505, xyz[0].X)
+			assertApproximately(t, 1.0, xyz[0].Y)
+			assertApproximately(t, 1.0891, xyz[0].Z)
+		})
+
+		t.Run("decodes parametric curve tags", func(t *testing.T) {
+			value := decode(t, table, RedTRCSignature)
+
+			curve, ok := value.(ParametricCurve)
+			if !ok {
+				t.Fatalf("Expected a parametric curve but got %v", value)
+			}
+			if expected, actual := uint16(3), curve.FunctionType; expected != actual {
+				t.Errorf("Expected function type %d but got %d", expected, actual)
+			}
+			assertApproximately(t, 2.4, curve.Params[0])
+			assertApproximately(t, 0, curve.Apply(0))
+			assertApproximately(t, 0.02/12.92, curve.Apply(0.02))
+			assertApproximately(t, math.Pow((0.5+0.055)/1.055, 2.4), curve.Apply(0.5))
+			assertApproximately(t, 1, curve.Apply(1))
+		})
+
+		t.Run("decodes s15Fixed16 array tags", func(t *testing.T) {
+			value := decode(t, table, ChromaticAdaptationSignature)
+
+			values, ok := value.([]float64)
+			if !ok || len(values) != 9 {
+				t.Fatalf("Expected 9 values but got %v", value)
+			}
+			assertApproximately(t, 1.0479, values[0])
+		})
+
+		t.Run("decodes text tags", func(t *testing.T) {
+			if expected, actual := "Copyright Apple Inc., 2017", decode(t, table, CopyrightSignature); expected != actual {
+				t.Errorf("Expected '%s' but got '%v'", expected, actual)
+			}
+		})
+
+		t.Run("decodes text description tags", func(t *testing.T) {
+			value := decode(t, table, DescSignature)
+
+			if desc, ok := value.(TextDescription); !ok {
+				t.Errorf("Expected a text description but got %v", value)
+			} else if expected, actual := "Display P3", desc.ASCII; expected != actual {
+				t.Errorf("Expected '%s' but got '%s'", expected, actual)
+			}
+		})
+	})
+
+	t.Run("decodes curves", func(t *testing.T) {
+
+		t.Run("as the identity function when empty", func(t *testing.T) {
+			data := typeHeader(CurveSignature)
+			_ = binary.WriteU32Big(data, 0)
+
+			curve := decode(t, tagTableWith(GrayTRCSignature, data.Bytes()), GrayTRCSignature).(Curve)
+
+			if gamma, ok := curve.Gamma(); !ok || gamma != 1 {
+				t.Errorf("Expected gamma of 1 but got %f", gamma)
+			}
+			assertApproximately(t, 0.25, curve.Apply(0.25))
+		})
+
+		t.Run("as a power law with one entry", func(t *testing.T) {
+			data := typeHeader(CurveSignature)
+			_ = binary.WriteU32Big(data, 1)
+			data.Write([]byte{0x02, 0x33})
+
+			curve := decode(t, tagTableWith(GrayTRCSignature, data.Bytes()), GrayTRCSignature).(Curve)
+
+			if gamma, ok := curve.Gamma(); !ok || gamma != 2.19921875 {
+				t.Errorf("Expected gamma of 2.19921875 but got %f", gamma)
+			}
+			assertApproximately(t, math.Pow(0.5, 2.19921875), curve.Apply(0.5))
+		})
+
+		t.Run("as an interpolated table with multiple entries", func(t *testing.T) {
+			data := typeHeader(CurveSignature)
+			_ = binary.WriteU32Big(data, 3)
+			data.Write([]byte{0x00, 0x00, 0x40, 0x00, 0xFF, 0xFF})
+
+			curve := decode(t, tagTableWith(GrayTRCSignature, data.Bytes()), GrayTRCSignature).(Curve)
+
+			if _, ok := curve.Gamma(); ok {
+				t.Errorf("Expected no gamma for a sampled curve")
+			}
+			assertApproximately(t, 0, curve.Apply(0))
+			assertApproximately(t, float64(0x4000)/65535, curve.Apply(0.5))
+			assertApproximately(t, float64(0x2000)/65535, curve.Apply(0.25))
+			assertApproximately(t, 1, curve.Apply(1))
+		})
+
+		t.Run("returning an error when entries are missing", func(t *testing.T) {
+			data := typeHeader(CurveSignature)
+			_ = binary.WriteU32Big(data, 0xFFFFFFFF)
+
+			_, err := tagTableWith(GrayTRCSignature, data.Bytes()).Decode(GrayTRCSignature)
+
+			if !errors.Is(err, binary.ErrTruncated) {
+				t.Errorf("Expected truncation error but got %v", err)
+			}
+		})
+	})
+
+	t.Run("returns an error for unknown parametric curve functions", func(t *testing.T) {
+		data := typeHeader(ParametricCurveSignature)
+		data.Write([]byte{0x00, 0x05, 0x00, 0x00})
+
+		_, err := tagTableWith(RedTRCSignature, data.Bytes()).Decode(RedTRCSignature)
+
+		if !errors.Is(err, binary.ErrUnsupported) {
+			t.Errorf("Expected unsupported error but got %v", err)
+		}
+	})
+
+	t.Run("returns an error for parametric curve functions with zero gradient", func(t *testing.T) {
+		for _, functionType := range []byte{1, 2} {
+			data := typeHeader(ParametricCurveSignature)
+			data.Write([]byte{0x00, functionType, 0x00, 0x00})
+			_ = binary.WriteU32Big(data, 0x00026666) // g = 2.4
+			_ = binary.WriteU32Big(data, 0)          // a = 0
+			_ = binary.WriteU32Big(data, 0x00010000) // b = 1
+			_ = binary.WriteU32Big(data, 0)          // c = 0
+
+			_, err := tagTableWith(RedTRCSignature, data.Bytes()).Decode(RedTRCSignature)
+
+			if !errors.Is(err, binary.ErrMalformed) {
+				t.Errorf("Expected malformed error for function type %d but got %v", functionType, err)
+			}
+		}
+	})
+
+	t.Run("decodes multi-localised unicode with strings at any offset", func(t *testing.T) {
+		data := typeHeader(MultiLocalisedUnicodeSignature)
+		_ = binary.WriteU32Big(data, 2)  // Record count
+		_ = binary.WriteU32Big(data, 12) // Record size
+		data.Write([]byte{'f', 'r', 'F', 'R'})
+		_ = binary.WriteU32Big(data, 6)  // String length
+		_ = binary.WriteU32Big(data, 48) // String offset
+		data.Write([]byte{'e', 'n', 'U', 'S'})
+		_ = binary.WriteU32Big(data, 6)  // String length
+		_ = binary.WriteU32Big(data, 42) // String offset
+		data.Write([]byte{0, 0})         // Padding
+		data.Write([]byte{0, 'a', 0, 'b', 0, 'c'})
+		data.Write([]byte{0, 'x', 0, 'y', 0, 'z'})
+
+		value := decode(t, tagTableWith(CopyrightSignature, data.Bytes()), CopyrightSignature)
+
+		mluc, ok := value.(MultiLocalisedUnicode)
+		if !ok {
+			t.Fatalf("Expected multi-localised unicode but got %v", value)
+		}
+		if expected, actual := "abc", mluc.Text(); expected != actual {
+			t.Errorf("Expected '%s' but got '%s'", expected, actual)
+		}
+		if expected, actual := "xyz", mluc.Localised("fr", "FR"); expected != actual {
+			t.Errorf("Expected '%s' but got '%s'", expected, actual)
+		}
+		if expected, actual := []string{"en_US", "fr_FR"}, mluc.Locales(); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected locales %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("returns an error for multi-localised unicode records overflowing the tag", func(t *testing.T) {
+		data := typeHeader(MultiLocalisedUnicodeSignature)
+		_ = binary.WriteU32Big(data, 1)
+		_ = binary.WriteU32Big(data, 12)
+		data.Write([]byte{'e', 'n', 'U', 'S'})
+		_ = binary.WriteU32Big(data, 0x10)       // String length
+		_ = binary.WriteU32Big(data, 0xFFFFFFF8) // String offset
+
+		_, err := tagTableWith(CopyrightSignature, data.Bytes()).Decode(CopyrightSignature)
+
+		if !errors.Is(err, binary.ErrMalformed) {
+			t.Errorf("Expected malformed error but got %v", err)
+		}
+	})
+
+	t.Run("decodes chromaticities", func(t *testing.T) {
+		data := typeHeader(ChromaticitySignature)
+		data.Write([]byte{0x00, 0x01, 0x00, 0x02})
+		_ = binary.WriteU32Big(data, 0x0000A3D7) // 0.64
+		_ = binary.WriteU32Big(data, 0x00005476) // 0.33
+
+		value := decode(t, tagTableWith(ChromaticitySignature, data.Bytes()), ChromaticitySignature)
+
+		chrm, ok := value.(Chromaticity)
+		if !ok || len(chrm.Channels) != 1 {
+			t.Fatalf("Expected chromaticity with one channel but got %v", value)
+		}
+		if expected, actual := uint16(2), chrm.Colorant; expected != actual {
+			t.Errorf("Expected colorant %d but got %d", expected, actual)
+		}
+		assertApproximately(t, 0.64, chrm.Channels[0][0])
+		assertApproximately(t, 0.33, chrm.Channels[0][1])
+	})
+
+	t.Run("decodes signatures", func(t *testing.T) {
+		data := typeHeader(SignatureTypeSignature)
+		data.Write([]byte{'f', 'p', 'c', 'e'})
+
+		if expected, actual := Signature(0x66706365), decode(t, tagTableWith(TechnologySignature, data.Bytes()), TechnologySignature); expected != actual {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("decodes dates and times", func(t *testing.T) {
+		data := typeHeader(DateTimeSignature)
+		data.Write([]byte{0x07, 0xE1, 0x00, 0x07, 0x00, 0x0F, 0x00, 0x0D, 0x00, 0x1E, 0x00, 0x05})
+
+		expected := time.Date(2017, time.July, 15, 13, 30, 5, 0, time.UTC)
+		if actual := decode(t, tagTableWith(CalibrationDateTimeSignature, data.Bytes()), CalibrationDateTimeSignature); expected != actual {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("returns nil for missing tags", func(t *testing.T) {
+		table := &TagTable{}
+
+		if value := decode(t, table, CopyrightSignature); value != nil {
+			t.Errorf("Expected nil but got %v", value)
+		}
+		if _, ok := table.Data(CopyrightSignature); ok {
+			t.Errorf("Expected tag not to be found")
+		}
+	})
+
+	t.Run("returns an error for unsupported tag types", func(t *testing.T) {
+		data := typeHeader(Signature(0x6E636C32)) // 'ncl2'
+
+		_, err := tagTableWith(Signature(0x41324230), data.Bytes()).Decode(Signature(0x41324230))
+
+		if !errors.Is(err, binary.ErrUnsupported) {
+			t.Errorf("Expected unsupported error but got %v", err)
+		}
+	})
+}
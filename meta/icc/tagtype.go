@@ -0,0 +1,82 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// readTypeSignature reads the type signature and reserved field which begin
+// the data of every tag, returning an error if the type isn't as expected.
+func readTypeSignature(reader *bytes.Reader, expected Signature) error {
+	sig, err := binary.ReadU32Big(reader)
+	if err != nil {
+		return err
+	}
+	if s := Signature(sig); s != expected {
+		return binary.Errorf(binary.ErrMalformed, "expected %v but got %v", expected, s)
+	}
+
+	// Reserved field
+	_, err = binary.ReadU32Big(reader)
+	return err
+}
+
+func readS15Fixed16(reader *bytes.Reader) (float64, error) {
+	value, err := binary.ReadU32Big(reader)
+	if err != nil {
+		return 0, err
+	}
+	return float64(int32(value)) / 65536, nil
+}
+
+func readU16Fixed16(reader *bytes.Reader) (float64, error) {
+	value, err := binary.ReadU32Big(reader)
+	if err != nil {
+		return 0, err
+	}
+	return float64(value) / 65536, nil
+}
+
+func parseS15Fixed16Array(data []byte) ([]float64, error) {
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, S15Fixed16ArraySignature); err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, reader.Len()/4)
+	for i := range values {
+		var err error
+		if values[i], err = readS15Fixed16(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func parseSignatureType(data []byte) (Signature, error) {
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, SignatureTypeSignature); err != nil {
+		return 0, err
+	}
+
+	sig, err := binary.ReadU32Big(reader)
+	return Signature(sig), err
+}
+
+func parseText(data []byte) (string, error) {
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, TextSignature); err != nil {
+		return "", err
+	}
+
+	text := data[len(data)-reader.Len():]
+	if i := bytes.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+
+	return string(text), nil
+}
@@ -2,7 +2,6 @@ package icc
 
 import (
 	"bytes"
-	"fmt"
 	"github.com/mandykoh/prism/meta/binary"
 )
 
@@ -20,7 +19,7 @@ func parseTextDescription(data []byte) (TextDescription, error) {
 		return desc, err
 	}
 	if s := Signature(sig); s != DescSignature {
-		return desc, fmt.Errorf("expected %v but got %v", DescSignature, s)
+		return desc, binary.Errorf(binary.ErrMalformed, "expected %v but got %v", DescSignature, s)
 	}
 
 	// Reserved field
@@ -34,6 +33,10 @@ func parseTextDescription(data []byte) (TextDescription, error) {
 		return desc, err
 	}
 
+	if asciiCount == 0 || uint64(asciiCount) > uint64(reader.Len()) {
+		return desc, binary.Errorf(binary.ErrMalformed, "invalid ASCII description length %d", asciiCount)
+	}
+
 	asciiBytes := make([]byte, asciiCount-1)
 	for i := 0; i < len(asciiBytes); i++ {
 		asciiBytes[i], err = reader.ReadByte()
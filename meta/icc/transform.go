@@ -0,0 +1,88 @@
+package icc
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// Transform maps colours from one colour space to another, as described by
+// the lookup table tags of a profile (eg 'A2B0' and 'B2A0'). Colour values are
+// normalised, such that the encoded range of each channel maps to [0, 1].
+type Transform interface {
+	// InputChannels returns the number of channels of input colours.
+	InputChannels() int
+
+	// OutputChannels returns the number of channels of output colours.
+	OutputChannels() int
+
+	// Apply transforms the colour src, writing the result to dst. src must
+	// have at least InputChannels() values and dst at least OutputChannels().
+	Apply(dst, src []float64)
+}
+
+// ToneCurve is a one-dimensional curve, such as a Curve or ParametricCurve.
+type ToneCurve interface {
+	Apply(x float64) float64
+}
+
+func applyCurves(curves []ToneCurve, values []float64) {
+	for i, c := range curves {
+		values[i] = c.Apply(values[i])
+	}
+}
+
+// applyMatrix multiplies the first three values by a 3x3 matrix in row-major
+// order, followed by an optional offset.
+func applyMatrix(matrix []float64, values []float64) {
+	x, y, z := values[0], values[1], values[2]
+	values[0] = matrix[0]*x + matrix[1]*y + matrix[2]*z
+	values[1] = matrix[3]*x + matrix[4]*y + matrix[5]*z
+	values[2] = matrix[6]*x + matrix[7]*y + matrix[8]*z
+
+	if len(matrix) == 12 {
+		values[0] += matrix[9]
+		values[1] += matrix[10]
+		values[2] += matrix[11]
+	}
+}
+
+// parseCurveSequence parses the specified number of consecutive 'curv' or
+// 'para' curves starting at offset, as found in 'mAB ' and 'mBA ' tags. Each
+// curve is padded to a multiple of four bytes.
+func parseCurveSequence(data []byte, offset uint32, count int) ([]ToneCurve, error) {
+	curves := make([]ToneCurve, count)
+
+	pos := uint64(offset)
+	for i := range curves {
+		if pos+4 > uint64(len(data)) {
+			return nil, binary.Errorf(binary.ErrTruncated, "expected %d curves but only found %d", count, i)
+		}
+		curveData := data[pos:]
+
+		typeSig, _ := binary.ReadU32Big(bytes.NewReader(curveData))
+		var length uint64
+
+		switch Signature(typeSig) {
+		case CurveSignature:
+			curve, err := parseCurve(curveData)
+			if err != nil {
+				return nil, err
+			}
+			curves[i] = curve
+			length = 12 + uint64(len(curve.Table))*2
+		case ParametricCurveSignature:
+			curve, err := parseParametricCurve(curveData)
+			if err != nil {
+				return nil, err
+			}
+			curves[i] = curve
+			length = 12 + uint64(len(curve.Params))*4
+		default:
+			return nil, binary.Errorf(binary.ErrMalformed, "expected curve but got %v", Signature(typeSig))
+		}
+
+		pos += (length + 3) &^ 3
+	}
+
+	return curves, nil
+}
@@ -0,0 +1,356 @@
+package icc
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta/binary"
+	"math"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+
+	writeS15Fixed16 := func(w *bytes.Buffer, values ...float64) {
+		for _, v := range values {
+			_ = binary.WriteU32Big(w, uint32(int32(math.Round(v*65536))))
+		}
+	}
+
+	identityMatrix := []float64{1, 0, 0, 0, 1, 0, 0, 0, 1}
+
+	identityCurve := func() []byte {
+		data := &bytes.Buffer{}
+		_ = binary.WriteU32Big(data, uint32(CurveSignature))
+		_ = binary.WriteU32Big(data, 0)
+		_ = binary.WriteU32Big(data, 0)
+		return data.Bytes()
+	}
+
+	gammaCurve := func(gamma float64) []byte {
+		data := &bytes.Buffer{}
+		_ = binary.WriteU32Big(data, uint32(ParametricCurveSignature))
+		_ = binary.WriteU32Big(data, 0)
+		data.Write([]byte{0, 0, 0, 0})
+		writeS15Fixed16(data, gamma)
+		return data.Bytes()
+	}
+
+	// gridValues returns the values of a CLUT with the specified dimensions,
+	// where each output is f of the normalised grid coordinates
+	gridValues := func(inputs, gridPoints int, f func(coords []float64) []float64) [][]float64 {
+		var values [][]float64
+		coords := make([]float64, inputs)
+		var walk func(dim int)
+		walk = func(dim int) {
+			if dim == inputs {
+				values = append(values, f(coords))
+				return
+			}
+			for i := 0; i < gridPoints; i++ {
+				coords[dim] = float64(i) / float64(gridPoints-1)
+				walk(dim + 1)
+			}
+		}
+		walk(0)
+		return values
+	}
+
+	writeValues := func(w *bytes.Buffer, values [][]float64, bytesPerValue int) {
+		for _, v := range values {
+			for _, x := range v {
+				if bytesPerValue == 1 {
+					w.WriteByte(byte(math.Round(x * 255)))
+				} else {
+					w.Write([]byte{byte(uint16(math.Round(x*65535)) >> 8), byte(uint16(math.Round(x * 65535)))})
+				}
+			}
+		}
+	}
+
+	writeIdentityTables := func(w *bytes.Buffer, channels, entries, bytesPerValue int) {
+		for c := 0; c < channels; c++ {
+			for i := 0; i < entries; i++ {
+				writeValues(w, [][]float64{{float64(i) / float64(entries-1)}}, bytesPerValue)
+			}
+		}
+	}
+
+	buildLUT := func(typeSig Signature, inputs, outputs, gridPoints int, f func([]float64) []float64) []byte {
+		bytesPerValue := 1
+		if typeSig == Lut16Signature {
+			bytesPerValue = 2
+		}
+
+		data := &bytes.Buffer{}
+		_ = binary.WriteU32Big(data, uint32(typeSig))
+		_ = binary.WriteU32Big(data, 0)
+		data.Write([]byte{byte(inputs), byte(outputs), byte(gridPoints), 0})
+		writeS15Fixed16(data, identityMatrix...)
+		entries := 256
+		if bytesPerValue == 2 {
+			entries = 4
+			data.Write([]byte{0, byte(entries), 0, byte(entries)})
+		}
+		writeIdentityTables(data, inputs, entries, bytesPerValue)
+		writeValues(data, gridValues(inputs, gridPoints, f), bytesPerValue)
+		writeIdentityTables(data, outputs, entries, bytesPerValue)
+		return data.Bytes()
+	}
+
+	type lutElements struct {
+		bCurves [][]byte
+		matrix  []float64
+		mCurves [][]byte
+		clut    []byte
+		aCurves [][]byte
+	}
+
+	buildLUTStages := func(typeSig Signature, inputs, outputs int, e lutElements) []byte {
+		body := &bytes.Buffer{}
+		var offsets [5]uint32
+
+		appendCurves := func(curves [][]byte) uint32 {
+			if curves == nil {
+				return 0
+			}
+			offset := uint32(32 + body.Len())
+			for _, c := range curves {
+				body.Write(c)
+			}
+			return offset
+		}
+
+		offsets[0] = appendCurves(e.bCurves)
+		if e.matrix != nil {
+			offsets[1] = uint32(32 + body.Len())
+			writeS15Fixed16(body, e.matrix...)
+		}
+		offsets[2] = appendCurves(e.mCurves)
+		if e.clut != nil {
+			offsets[3] = uint32(32 + body.Len())
+			body.Write(e.clut)
+		}
+		offsets[4] = appendCurves(e.aCurves)
+
+		data := &bytes.Buffer{}
+		_ = binary.WriteU32Big(data, uint32(typeSig))
+		_ = binary.WriteU32Big(data, 0)
+		data.Write([]byte{byte(inputs), byte(outputs), 0, 0})
+		for _, o := range offsets {
+			_ = binary.WriteU32Big(data, o)
+		}
+		data.Write(body.Bytes())
+		return data.Bytes()
+	}
+
+	buildCLUT := func(inputs, outputs, gridPoints, precision int, f func([]float64) []float64) []byte {
+		data := &bytes.Buffer{}
+		var grid [16]byte
+		for i := 0; i < inputs; i++ {
+			grid[i] = byte(gridPoints)
+		}
+		data.Write(grid[:])
+		data.Write([]byte{byte(precision), 0, 0, 0})
+		writeValues(data, gridValues(inputs, gridPoints, f), precision)
+		return data.Bytes()
+	}
+
+	transformFor := func(t *testing.T, data []byte) Transform {
+		t.Helper()
+
+		table := emptyTagTable()
+		table.add(AToB0Signature, data)
+
+		transform, err := table.Transform(AToB0Signature)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return transform
+	}
+
+	assertApply := func(t *testing.T, transform Transform, src []float64, expected []float64, tolerance float64) {
+		t.Helper()
+
+		if expected, actual := len(src), transform.InputChannels(); expected != actual {
+			t.Fatalf("Expected %d input channels but got %d", expected, actual)
+		}
+		if expected, actual := len(expected), transform.OutputChannels(); expected != actual {
+			t.Fatalf("Expected %d output channels but got %d", expected, actual)
+		}
+
+		actual := make([]float64, len(expected))
+		transform.Apply(actual, src)
+
+		for i := range expected {
+			if math.Abs(expected[i]-actual[i]) > tolerance {
+				t.Errorf("Expected %v for input %v but got %v", expected, src, actual)
+				return
+			}
+		}
+	}
+
+	inverse := func(c []float64) []float64 {
+		return []float64{1 - c[0], 1 - c[1], 1 - c[2]}
+	}
+
+	average := func(c []float64) []float64 {
+		sum := 0.0
+		for _, v := range c {
+			sum += v
+		}
+		return []float64{sum / float64(len(c))}
+	}
+
+	t.Run("evaluates 'mft1' tags", func(t *testing.T) {
+		transform := transformFor(t, buildLUT(Lut8Signature, 3, 3, 2, inverse))
+
+		assertApply(t, transform, []float64{0.25, 0.5, 1}, []float64{0.75, 0.5, 0}, 0.005)
+	})
+
+	t.Run("evaluates 'mft2' tags", func(t *testing.T) {
+		transform := transformFor(t, buildLUT(Lut16Signature, 4, 1, 3, average))
+
+		assertApply(t, transform, []float64{0.1, 0.2, 0.6, 0.9}, []float64{0.45}, 0.0001)
+	})
+
+	t.Run("evaluates 'mAB ' tags", func(t *testing.T) {
+
+		t.Run("with matrix and curves", func(t *testing.T) {
+			transform := transformFor(t, buildLUTStages(LutAToBSignature, 3, 3, lutElements{
+				bCurves: [][]byte{gammaCurve(2), gammaCurve(2), gammaCurve(2)},
+				matrix:  []float64{0.5, 0, 0, 0, 0.5, 0, 0, 0, 0.5, 0.1, 0.2, 0.3},
+				mCurves: [][]byte{identityCurve(), identityCurve(), identityCurve()},
+			}))
+
+			assertApply(t, transform, []float64{0.5, 0.5, 0.5}, []float64{0.35 * 0.35, 0.45 * 0.45, 0.55 * 0.55}, 0.0001)
+		})
+
+		t.Run("with CLUT", func(t *testing.T) {
+			transform := transformFor(t, buildLUTStages(LutAToBSignature, 4, 1, lutElements{
+				bCurves: [][]byte{gammaCurve(2)},
+				clut:    buildCLUT(4, 1, 2, 2, average),
+				aCurves: [][]byte{identityCurve(), identityCurve(), identityCurve(), identityCurve()},
+			}))
+
+			assertApply(t, transform, []float64{0.2, 0.4, 0.6, 0.8}, []float64{0.25}, 0.0001)
+		})
+	})
+
+	t.Run("evaluates 'mBA ' tags", func(t *testing.T) {
+		transform := transformFor(t, buildLUTStages(LutBToASignature, 3, 4, lutElements{
+			bCurves: [][]byte{identityCurve(), identityCurve(), identityCurve()},
+			clut: buildCLUT(3, 4, 3, 1, func(c []float64) []float64 {
+				return []float64{1 - c[0], 1 - c[1], 1 - c[2], 0}
+			}),
+			aCurves: [][]byte{gammaCurve(1), gammaCurve(1), gammaCurve(1), identityCurve()},
+		}))
+
+		assertApply(t, transform, []float64{0, 0.5, 1}, []float64{1, 0.5, 0, 0}, 0.005)
+	})
+
+	t.Run("interpolates CLUTs", func(t *testing.T) {
+		clut := &CLUT{
+			GridPoints:         []int{2, 2},
+			OutputChannelCount: 1,
+			Values:             []float64{0, 0, 0, 1},
+		}
+		out := make([]float64, 1)
+
+		clut.Apply(out, []float64{0.5, 0.5})
+		if expected, actual := 0.5, out[0]; expected != actual {
+			t.Errorf("Expected tetrahedral interpolation to give %f but got %f", expected, actual)
+		}
+
+		clut.Interpolation = MultilinearInterpolation
+		clut.Apply(out, []float64{0.5, 0.5})
+		if expected, actual := 0.25, out[0]; expected != actual {
+			t.Errorf("Expected multilinear interpolation to give %f but got %f", expected, actual)
+		}
+
+		clut.Apply(out, []float64{1, 1})
+		if expected, actual := 1.0, out[0]; expected != actual {
+			t.Errorf("Expected value at grid point %f but got %f", expected, actual)
+		}
+	})
+
+	t.Run("reproduces linear functions with either interpolation", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		writeValues(data, gridValues(4, 5, average), 2)
+		clut, _, err := parseCLUT(data.Bytes(), []int{5, 5, 5, 5}, 1, 2)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		src := []float64{0.13, 0.92, 0.47, 0.61}
+		out := make([]float64, 1)
+
+		for _, interpolation := range []Interpolation{TetrahedralInterpolation, MultilinearInterpolation} {
+			clut.Interpolation = interpolation
+			clut.Apply(out, src)
+
+			if expected, actual := average(src)[0], out[0]; math.Abs(expected-actual) > 0.0001 {
+				t.Errorf("Expected %f but got %f", expected, actual)
+			}
+		}
+	})
+
+	t.Run("returns an error when CLUT data is missing", func(t *testing.T) {
+		data := buildLUT(Lut8Signature, 3, 3, 2, inverse)
+
+		table := emptyTagTable()
+		table.add(AToB0Signature, data[:len(data)-3*256-1])
+		_, err := table.Transform(AToB0Signature)
+
+		if !errors.Is(err, binary.ErrTruncated) {
+			t.Errorf("Expected truncation error but got %v", err)
+		}
+	})
+
+	t.Run("returns an error for inconsistent elements", func(t *testing.T) {
+		table := emptyTagTable()
+		table.add(AToB0Signature, buildLUTStages(LutAToBSignature, 4, 3, lutElements{
+			bCurves: [][]byte{identityCurve(), identityCurve(), identityCurve()},
+		}))
+		_, err := table.Transform(AToB0Signature)
+
+		if !errors.Is(err, binary.ErrMalformed) {
+			t.Errorf("Expected malformed error but got %v", err)
+		}
+	})
+
+	t.Run("returns an error for curves without the element they apply to", func(t *testing.T) {
+		curves := [][]byte{identityCurve(), identityCurve(), identityCurve()}
+
+		cases := []struct {
+			name     string
+			elements lutElements
+		}{
+			{"A curves without a CLUT", lutElements{bCurves: curves, aCurves: curves}},
+			{"M curves without a matrix", lutElements{bCurves: curves, mCurves: curves}},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				for _, sig := range []Signature{LutAToBSignature, LutBToASignature} {
+					table := emptyTagTable()
+					table.add(AToB0Signature, buildLUTStages(sig, 3, 3, c.elements))
+					_, err := table.Transform(AToB0Signature)
+
+					if !errors.Is(err, binary.ErrMalformed) {
+						t.Errorf("Expected malformed error for %v but got %v", sig, err)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("returns an error for tags which aren't transforms", func(t *testing.T) {
+		table := emptyTagTable()
+		table.add(AToB0Signature, identityCurve())
+		_, err := table.Transform(AToB0Signature)
+
+		if !errors.Is(err, binary.ErrMalformed) {
+			t.Errorf("Expected malformed error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package icc
+
+import (
+	"bytes"
+)
+
+// XYZNumber is a CIE XYZ colour, as found in tags of type 'XYZ '.
+type XYZNumber struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+func parseXYZ(data []byte) ([]XYZNumber, error) {
+	reader := bytes.NewReader(data)
+
+	if err := readTypeSignature(reader, XYZSignature); err != nil {
+		return nil, err
+	}
+
+	values := make([]XYZNumber, reader.Len()/12)
+	for i := range values {
+		var err error
+		if values[i].X, err = readS15Fixed16(reader); err != nil {
+			return nil, err
+		}
+		if values[i].Y, err = readS15Fixed16(reader); err != nil {
+			return nil, err
+		}
+		if values[i].Z, err = readS15Fixed16(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,157 @@
+package jpegmeta
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+)
+
+// The maximum amount of profile data in each ICC profile segment, after the
+// segment length, identifier, chunk number and chunk count
+const maxICCProfileChunkSize = 65535 - 2 - 14
+
+// EditProfile copies a JPEG image stream from r to w, changing its colour
+// profile as specified by edit. All other segments are copied byte-for-byte,
+// and the image data is not re-encoded.
+//
+// Any existing ICC profile segments are removed, and the segments of the new
+// profile are written following the JFIF and EXIF segments at the start of the
+// image. As JPEG has no other means of tagging an image as sRGB,
+// meta.ProfileActionSRGB simply removes the profile.
+func EditProfile(w io.Writer, r io.Reader, edit meta.ProfileEdit) error {
+	if err := edit.Validate(); err != nil {
+		return err
+	}
+
+	var newSegments []byte
+	if edit.Action == meta.ProfileActionReplace {
+		var err error
+		if newSegments, err = iccProfileSegments(edit.ICCProfile); err != nil {
+			return err
+		}
+	}
+
+	or := binary.NewOffsetReader(bufio.NewReader(r))
+
+	var soi [2]byte
+	if _, err := io.ReadFull(or, soi[:]); err != nil {
+		return binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+	}
+	if soi[0] != 0xFF || markerType(soi[1]) != markerTypeStartOfImage {
+		return binary.Errorf(meta.ErrFormatMismatch, "stream does not begin with start-of-image")
+	}
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+
+	inserted := newSegments == nil
+
+	for {
+		offset := or.Offset()
+
+		b, err := or.ReadByte()
+		if err != nil {
+			return binary.Truncated(err)
+		}
+		if b != 0xFF {
+			return binary.Malformed("marker", offset, "invalid marker identifier %0x", b)
+		}
+
+		// Markers may be preceded by any number of fill bytes, which are
+		// kept along with the marker
+		marker := []byte{0xFF}
+		for b == 0xFF {
+			if b, err = or.ReadByte(); err != nil {
+				return binary.Truncated(err)
+			}
+			marker = append(marker, b)
+		}
+		mType := markerType(b)
+
+		if mType == markerTypeEndOfImage || (mType >= markerTypeRestart0 && mType <= markerTypeRestart7) || b == 0x01 {
+			if _, err := w.Write(marker); err != nil {
+				return err
+			}
+			if mType == markerTypeEndOfImage {
+				return nil
+			}
+			continue
+		}
+
+		length, err := binary.ReadU16Big(or)
+		if err != nil {
+			return binary.Truncated(err)
+		}
+		if length < 2 {
+			return binary.Malformed(mType.String(), offset, "invalid segment length %d", length)
+		}
+
+		// New profile segments follow the JFIF and EXIF segments, which are
+		// expected to be first
+		if !inserted && mType != markerTypeApp0 && mType != markerTypeApp1 {
+			if _, err := w.Write(newSegments); err != nil {
+				return err
+			}
+			inserted = true
+		}
+
+		header := append(marker, byte(length>>8), byte(length))
+
+		if mType == markerTypeApp2 {
+			data := make([]byte, length-2)
+			if _, err := io.ReadFull(or, data); err != nil {
+				return binary.Truncated(err)
+			}
+			if bytes.HasPrefix(data, iccProfileIdentifier) {
+				continue
+			}
+			if _, err := w.Write(header); err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, or, int64(length)-2); err != nil {
+			return binary.Truncated(err)
+		}
+
+		// Everything from the image data onwards is copied unchanged
+		if mType == markerTypeStartOfScan {
+			_, err := io.Copy(w, or)
+			return err
+		}
+	}
+}
+
+// iccProfileSegments returns the APP2 segments containing the specified ICC
+// profile, split into as many chunks as necessary.
+func iccProfileSegments(profile []byte) ([]byte, error) {
+	chunkCount := (len(profile) + maxICCProfileChunkSize - 1) / maxICCProfileChunkSize
+	if chunkCount > 255 {
+		return nil, binary.Errorf(meta.ErrUnsupported, "ICC profile of %d bytes is too large to embed in a JPEG image", len(profile))
+	}
+
+	segments := &bytes.Buffer{}
+	for i := 0; i < chunkCount; i++ {
+		chunk := profile[i*maxICCProfileChunkSize:]
+		if len(chunk) > maxICCProfileChunkSize {
+			chunk = chunk[:maxICCProfileChunkSize]
+		}
+
+		length := 2 + len(iccProfileIdentifier) + 2 + len(chunk)
+		segments.Write([]byte{0xFF, byte(markerTypeApp2), byte(length >> 8), byte(length)})
+		segments.Write(iccProfileIdentifier)
+		segments.Write([]byte{byte(i + 1), byte(chunkCount)})
+		segments.Write(chunk)
+	}
+
+	return segments.Bytes(), nil
+}
@@ -0,0 +1,126 @@
+package jpegmeta
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"testing"
+)
+
+func TestEditProfile(t *testing.T) {
+
+	app0 := []byte{0xFF, byte(markerTypeApp0), 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00}
+	otherApp2 := []byte{0xFF, byte(markerTypeApp2), 0x00, 0x06, 'M', 'P', 'F', 0x00}
+	sof := []byte{0xFF, byte(markerTypeStartOfFrameBaseline), 0x00, 0x07, 0x08, 0x00, 0x10, 0x00, 0x0F}
+	scan := []byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02, 0x12, 0x34, 0xFF, 0x00, 0x56, 0xFF, byte(markerTypeEndOfImage)}
+
+	buildJPEG := func(profileSegments []byte) []byte {
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		data.Write(app0)
+		data.Write(profileSegments)
+		data.Write(otherApp2)
+		data.Write(sof)
+		data.Write(scan)
+		return data.Bytes()
+	}
+
+	profileSegments := func(profile []byte) []byte {
+		segments, err := iccProfileSegments(profile)
+		if err != nil {
+			panic(err)
+		}
+		return segments
+	}
+
+	t.Run("replaces an existing profile", func(t *testing.T) {
+		newProfile := bytes.Repeat([]byte("new profile data"), 10000)
+
+		output := &bytes.Buffer{}
+		err := EditProfile(output, bytes.NewReader(buildJPEG(profileSegments([]byte("old profile")))), meta.ProfileEdit{
+			Action:     meta.ProfileActionReplace,
+			ICCProfile: newProfile,
+		})
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := buildJPEG(profileSegments(newProfile)), output.Bytes(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected other segments to be unchanged")
+		}
+
+		md, err := extractMetadata(bytes.NewReader(output.Bytes()), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if actual, err := md.ICCProfileData(); err != nil {
+			t.Errorf("Expected success but got error: %v", err)
+		} else if !bytes.Equal(newProfile, actual) {
+			t.Errorf("Expected profile of %d bytes but got %d bytes", len(newProfile), len(actual))
+		}
+	})
+
+	t.Run("splits large profiles across segments", func(t *testing.T) {
+		segments := profileSegments(make([]byte, maxICCProfileChunkSize*2+1))
+
+		if expected, actual := 3, bytes.Count(segments, iccProfileIdentifier); expected != actual {
+			t.Errorf("Expected %d segments but got %d", expected, actual)
+		}
+	})
+
+	t.Run("removes a profile leaving other segments unchanged", func(t *testing.T) {
+		for _, action := range []meta.ProfileAction{meta.ProfileActionRemove, meta.ProfileActionSRGB} {
+			t.Run(action.String(), func(t *testing.T) {
+				output := &bytes.Buffer{}
+				err := EditProfile(output, bytes.NewReader(buildJPEG(profileSegments([]byte("old profile")))), meta.ProfileEdit{Action: action})
+
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if expected, actual := buildJPEG(nil), output.Bytes(); !bytes.Equal(expected, actual) {
+					t.Errorf("Expected output %v but got %v", expected, actual)
+				}
+			})
+		}
+	})
+
+	t.Run("preserves fill bytes preceding markers", func(t *testing.T) {
+		input := &bytes.Buffer{}
+		input.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		input.Write(app0)
+		input.Write([]byte{0xFF, 0xFF})
+		input.Write(otherApp2)
+		input.Write([]byte{0xFF})
+		input.Write(sof)
+		input.Write(scan)
+
+		output := &bytes.Buffer{}
+		err := EditProfile(output, bytes.NewReader(input.Bytes()), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := input.Bytes(), output.Bytes(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected output %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("returns error for non-JPEG input", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader([]byte{0xFF, byte(markerTypeEndOfImage)}), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for profiles too large to embed", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader(buildJPEG(nil)), meta.ProfileEdit{
+			Action:     meta.ProfileActionReplace,
+			ICCProfile: make([]byte, maxICCProfileChunkSize*255+1),
+		})
+
+		if !errors.Is(err, meta.ErrUnsupported) {
+			t.Errorf("Expected unsupported error but got %v", err)
+		}
+	})
+}
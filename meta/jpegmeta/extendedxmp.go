@@ -0,0 +1,62 @@
+package jpegmeta
+
+import "encoding/binary"
+
+// Extended XMP packets larger than this are ignored
+const maxExtendedXMPLength = 16 << 20
+
+// extendedXMPChunks reassembles extended XMP packets from the chunks stored in
+// APP1 segments. Each chunk identifies its packet by a GUID, being the MD5
+// digest of the full packet, along with the packet's length and the offset of
+// the chunk within it.
+type extendedXMPChunks map[string]*extendedXMPPacket
+
+type extendedXMPPacket struct {
+	data     []byte
+	offsets  map[uint32]bool
+	received uint32
+}
+
+// addChunk adds a chunk from an extended XMP segment, excluding the segment's
+// identifier. Invalid chunks are ignored.
+func (ec extendedXMPChunks) addChunk(chunk []byte) {
+	if len(chunk) < 40 {
+		return
+	}
+
+	guid := string(chunk[:32])
+	length := binary.BigEndian.Uint32(chunk[32:])
+	offset := binary.BigEndian.Uint32(chunk[36:])
+	chunkData := chunk[40:]
+
+	if length > maxExtendedXMPLength || uint64(offset)+uint64(len(chunkData)) > uint64(length) {
+		return
+	}
+
+	packet := ec[guid]
+	if packet == nil {
+		packet = &extendedXMPPacket{
+			data:    make([]byte, length),
+			offsets: make(map[uint32]bool),
+		}
+		ec[guid] = packet
+	}
+
+	if uint32(len(packet.data)) != length || packet.offsets[offset] {
+		return
+	}
+
+	copy(packet.data[offset:], chunkData)
+	packet.offsets[offset] = true
+	packet.received += uint32(len(chunkData))
+}
+
+// data returns the reassembled packet with the specified GUID, or nil if no
+// complete packet was found.
+func (ec extendedXMPChunks) data(guid string) []byte {
+	packet := ec[guid]
+	if packet == nil || packet.received != uint32(len(packet.data)) {
+		return nil
+	}
+	return packet.data
+}
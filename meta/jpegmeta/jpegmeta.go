@@ -3,16 +3,22 @@ package jpegmeta
 import (
 	"bufio"
 	"bytes"
-	"fmt"
 	"github.com/mandykoh/prism/meta"
 	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/exif"
+	"github.com/mandykoh/prism/meta/xmp"
 	"io"
 )
 
 // Format specifies the image format handled by this package
 var Format = meta.ImageFormat("JPEG")
 
+var adobeIdentifier = []byte("Adobe")
+var exifIdentifier = []byte("Exif\x00\x00")
+var extendedXMPIdentifier = []byte("http://ns.adobe.com/xmp/extension/\x00")
+var xmpIdentifier = []byte("http://ns.adobe.com/xap/1.0/\x00")
 var iccProfileIdentifier = []byte("ICC_PROFILE\x00")
+var jfifIdentifier = []byte("JFIF\x00")
 
 // Load loads the metadata for a JPEG image stream.
 //
@@ -25,15 +31,63 @@ var iccProfileIdentifier = []byte("ICC_PROFILE\x00")
 // An error is returned if basic metadata could not be extracted. The returned
 // stream still provides the full image data.
 func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a JPEG image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
 	rewindBuffer := &bytes.Buffer{}
-	tee := io.TeeReader(r, rewindBuffer)
-	md, err = extractMetadata(bufio.NewReader(tee))
-	return md, io.MultiReader(rewindBuffer, r), err
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(bufio.NewReader(lr), limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a JPEG image stream which supports seeking.
+//
+// Reading begins at the current position of the stream, and segments which
+// don't contain metadata are skipped by seeking past them. The stream is
+// returned to its original position afterwards, so that the full image can then
+// be loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a JPEG image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a JPEG image of the specified size in
+// bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a JPEG image of the specified
+// size in bytes as per LoadReaderAt, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
 }
 
-func extractMetadata(r binary.Reader) (md *meta.Data, err error) {
+func extractMetadata(r binary.Reader, limits meta.Limits) (md *meta.Data, err error) {
 	metadataExtracted := false
 	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
 	segReader := NewSegmentReader(r)
 
 	defer func() {
@@ -41,12 +95,18 @@ func extractMetadata(r binary.Reader) (md *meta.Data, err error) {
 			if !metadataExtracted {
 				md = nil
 			}
-			err = fmt.Errorf("panic while extracting image metadata: %v", r)
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
 		}
+		err = binary.Truncated(err)
 	}()
 
 	var iccProfileChunks [][]byte
 	var iccProfileChunksExtracted int
+	var iccProfileSize int64
+	segmentCount := 0
+
+	extendedXMP := extendedXMPChunks{}
+	hasJFIF := false
 
 	allMetadataExtracted := func() bool {
 		return metadataExtracted &&
@@ -59,7 +119,7 @@ func extractMetadata(r binary.Reader) (md *meta.Data, err error) {
 		return nil, err
 	}
 	if soiSegment.Marker.Type != markerTypeStartOfImage {
-		return nil, fmt.Errorf("stream does not begin with start-of-image")
+		return nil, binary.Errorf(meta.ErrFormatMismatch, "stream does not begin with start-of-image")
 	}
 
 parseSegments:
@@ -67,28 +127,71 @@ parseSegments:
 		segment, err := segReader.ReadSegment()
 		if err != nil {
 			if err == io.EOF {
-				return nil, fmt.Errorf("unexpected EOF")
+				return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
 			}
 			return nil, err
 		}
 
-		switch segment.Marker.Type {
+		segmentCount++
+		if err := limits.CheckSegmentCount(segmentCount); err != nil {
+			return nil, err
+		}
 
-		case markerTypeStartOfFrameBaseline,
-			markerTypeStartOfFrameProgressive:
-			md.BitsPerComponent = uint32(segment.Data[0])
-			md.PixelHeight = uint32(segment.Data[1])<<8 | uint32(segment.Data[2])
-			md.PixelWidth = uint32(segment.Data[3])<<8 | uint32(segment.Data[4])
-			metadataExtracted = true
+		if segment.Marker.Type.isStartOfFrame() {
+			// Hierarchical images have multiple frames, the first of which
+			// describes the image as a whole
+			if !metadataExtracted {
+				if err := readFrameHeader(segment.Data, md); err != nil {
+					return nil, binary.Malformed(segment.Marker.Type.String(), segment.Offset, "%w", err)
+				}
+				md.Interlaced = segment.Marker.Type.isProgressive()
+				md.FrameCount = 1
+				md.LoopCount = 1
+				metadataExtracted = true
+			}
 
 			if allMetadataExtracted() {
 				break parseSegments
 			}
+			continue
+		}
+
+		switch segment.Marker.Type {
 
 		case markerTypeStartOfScan,
 			markerTypeEndOfImage:
 			break parseSegments
 
+		case markerTypeApp0:
+			if bytes.HasPrefix(segment.Data, jfifIdentifier) {
+				hasJFIF = true
+			}
+
+		case markerTypeApp1:
+			switch {
+
+			case bytes.HasPrefix(segment.Data, exifIdentifier):
+				if md.EXIF == nil {
+					if exifData, err := exif.Parse(segment.Data); err == nil {
+						md.EXIF = exifData
+					}
+				}
+
+			case bytes.HasPrefix(segment.Data, xmpIdentifier):
+				if md.XMPData() == nil {
+					md.SetXMPData(segment.Data[len(xmpIdentifier):])
+				}
+
+			case bytes.HasPrefix(segment.Data, extendedXMPIdentifier):
+				extendedXMP.addChunk(segment.Data[len(extendedXMPIdentifier):])
+			}
+
+		case markerTypeApp14:
+			if md.AdobeTransform == nil && len(segment.Data) >= 12 && bytes.HasPrefix(segment.Data, adobeIdentifier) {
+				transform := meta.AdobeTransform(segment.Data[11])
+				md.AdobeTransform = &transform
+			}
+
 		case markerTypeApp2:
 			if len(segment.Data) < len(iccProfileIdentifier)+2 {
 				continue
@@ -109,21 +212,27 @@ parseSegments:
 			if iccProfileChunks == nil {
 				iccProfileChunks = make([][]byte, chunkTotal)
 			} else if int(chunkTotal) != len(iccProfileChunks) {
-				md.SetICCProfileError(fmt.Errorf("inconsistent ICC profile chunk count"))
+				md.SetICCProfileError(binary.Malformed("APP2", segment.Offset, "inconsistent ICC profile chunk count"))
 				continue
 			}
 
 			chunkNum := segment.Data[len(iccProfileIdentifier)]
 			if chunkNum == 0 || int(chunkNum) > len(iccProfileChunks) {
-				md.SetICCProfileError(fmt.Errorf("invalid ICC profile chunk number"))
+				md.SetICCProfileError(binary.Malformed("APP2", segment.Offset, "invalid ICC profile chunk number"))
 				continue
 			}
 			if iccProfileChunks[chunkNum-1] != nil {
-				md.SetICCProfileError(fmt.Errorf("duplicated ICC profile chunk"))
+				md.SetICCProfileError(binary.Malformed("APP2", segment.Offset, "duplicated ICC profile chunk"))
+				continue
+			}
+			chunk := segment.Data[len(iccProfileIdentifier)+2:]
+			iccProfileSize += int64(len(chunk))
+			if err := limits.CheckICCProfileSize(iccProfileSize); err != nil {
+				md.SetICCProfileError(err)
 				continue
 			}
 			iccProfileChunksExtracted++
-			iccProfileChunks[chunkNum-1] = segment.Data[len(iccProfileIdentifier)+2:]
+			iccProfileChunks[chunkNum-1] = chunk
 
 			if allMetadataExtracted() {
 				break parseSegments
@@ -132,14 +241,23 @@ parseSegments:
 	}
 
 	if !metadataExtracted {
-		return nil, fmt.Errorf("no metadata found")
+		return nil, binary.Errorf(meta.ErrMalformed, "no metadata found")
+	}
+
+	md.ColorModel = colorModel(md.Components, md.AdobeTransform, hasJFIF)
+
+	// Extended XMP is only used if referenced by the standard XMP packet
+	if xmpData := md.XMPData(); xmpData != nil {
+		if properties, err := xmp.Parse(xmpData); err == nil {
+			md.SetExtendedXMPData(extendedXMP.data(properties.HasExtendedXMP))
+		}
 	}
 
 	// Incomplete or missing ICC profile
 	if len(iccProfileChunks) != iccProfileChunksExtracted {
 		_, iccErr := md.ICCProfileData()
 		if iccErr == nil {
-			md.SetICCProfileError(fmt.Errorf("incomplete ICC profile data"))
+			md.SetICCProfileError(binary.Errorf(meta.ErrTruncated, "incomplete ICC profile data"))
 		}
 		return md, nil
 	}
@@ -152,3 +270,66 @@ parseSegments:
 
 	return md, nil
 }
+
+// readFrameHeader populates the metadata from the data of a start-of-frame
+// segment. Component details are omitted if the header is truncated after the
+// image dimensions.
+func readFrameHeader(data []byte, md *meta.Data) error {
+	if len(data) < 5 {
+		return binary.Errorf(meta.ErrMalformed, "invalid frame header length")
+	}
+
+	md.BitsPerComponent = uint32(data[0])
+	md.PixelHeight = uint32(data[1])<<8 | uint32(data[2])
+	md.PixelWidth = uint32(data[3])<<8 | uint32(data[4])
+
+	if len(data) < 6 || len(data) < 6+int(data[5])*3 {
+		return nil
+	}
+
+	componentCount := int(data[5])
+	md.ChannelCount = uint32(componentCount)
+
+	md.Components = make([]meta.Component, componentCount)
+	for i := range md.Components {
+		c := data[6+i*3:]
+		md.Components[i] = meta.Component{
+			ID:                 c[0],
+			HorizontalSampling: c[1] >> 4,
+			VerticalSampling:   c[1] & 0x0F,
+		}
+	}
+
+	return nil
+}
+
+// colorModel determines the colour model of an image from its components and
+// Adobe transform, following the conventions used by libjpeg.
+func colorModel(components []meta.Component, adobeTransform *meta.AdobeTransform, hasJFIF bool) meta.ColorModel {
+	switch len(components) {
+
+	case 1:
+		return meta.ColorModelGreyscale
+
+	case 3:
+		switch {
+		case adobeTransform != nil && *adobeTransform == meta.AdobeTransformNone:
+			return meta.ColorModelRGB
+		case adobeTransform != nil || hasJFIF:
+			return meta.ColorModelYCbCr
+		case components[0].ID == 'R' && components[1].ID == 'G' && components[2].ID == 'B':
+			return meta.ColorModelRGB
+		default:
+			return meta.ColorModelYCbCr
+		}
+
+	case 4:
+		if adobeTransform != nil && *adobeTransform == meta.AdobeTransformYCCK {
+			return meta.ColorModelYCCK
+		}
+		return meta.ColorModelCMYK
+
+	default:
+		return meta.ColorModelUnknown
+	}
+}
@@ -225,3 +225,45 @@ func ExampleLoad_progressiveJPEGMetadata() {
 	// Actual image height: 1200
 	// Actual image width: 1200
 }
+
+func ExampleLoad_cmykJPEGMetadata() {
+	inFile, err := os.Open("../../test-images/pizza-cmyk8-usswop.jpg")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, imgStream, err := jpegmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	img, err := jpeg.Decode(imgStream)
+	if err != nil {
+		panic(err)
+	}
+
+	printMetadata(md, img)
+
+	fmt.Printf("ColorModel: %v\n", md.ColorModel)
+	fmt.Printf("ChannelCount: %d\n", md.ChannelCount)
+	fmt.Printf("AdobeTransform: %v\n", *md.AdobeTransform)
+	for _, c := range md.Components {
+		fmt.Printf("Component %c: %dx%d\n", c.ID, c.HorizontalSampling, c.VerticalSampling)
+	}
+
+	// Output:
+	// Format: JPEG
+	// BitsPerComponent: 8
+	// PixelHeight: 1200
+	// PixelWidth: 1200
+	// Actual image height: 1200
+	// Actual image width: 1200
+	// ColorModel: CMYK
+	// ChannelCount: 4
+	// AdobeTransform: None
+	// Component C: 1x1
+	// Component M: 1x1
+	// Component Y: 1x1
+	// Component K: 1x1
+}
@@ -2,6 +2,8 @@ package jpegmeta
 
 import (
 	"bytes"
+	encbinary "encoding/binary"
+	"errors"
 	"github.com/mandykoh/prism/meta"
 	"testing"
 )
@@ -55,12 +57,14 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte{0xFF, byte(markerTypeEndOfImage)})
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected an error but succeeded")
 		} else if expected, actual := "stream does not begin with start-of-image", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		} else if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
 		}
 	})
 
@@ -69,7 +73,7 @@ func TestExtractMetadata(t *testing.T) {
 		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
 		data.Write([]byte{0xFF, byte(markerTypeEndOfImage)})
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected an error but succeeded")
@@ -85,9 +89,9 @@ func TestExtractMetadata(t *testing.T) {
 		writeICCProfileChunk(data, 2, 1, nil)
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
-		assertMetadataICCProfileError(md, err, "invalid ICC profile chunk number", t)
+		assertMetadataICCProfileError(md, err, "malformed APP2 at offset 11: invalid ICC profile chunk number", t)
 	})
 
 	t.Run("returns metadata without ICC profile if subsequent ICC chunks specify different total chunks", func(t *testing.T) {
@@ -98,9 +102,9 @@ func TestExtractMetadata(t *testing.T) {
 		writeICCProfileChunk(data, 2, 3, nil)
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
-		assertMetadataICCProfileError(md, err, "inconsistent ICC profile chunk count", t)
+		assertMetadataICCProfileError(md, err, "malformed APP2 at offset 29: inconsistent ICC profile chunk count", t)
 	})
 
 	t.Run("returns metadata without ICC profile if an ICC chunk is duplicated", func(t *testing.T) {
@@ -111,9 +115,9 @@ func TestExtractMetadata(t *testing.T) {
 		writeICCProfileChunk(data, 1, 3, nil)
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
-		assertMetadataICCProfileError(md, err, "duplicated ICC profile chunk", t)
+		assertMetadataICCProfileError(md, err, "malformed APP2 at offset 29: duplicated ICC profile chunk", t)
 	})
 
 	t.Run("returns metadata without ICC profile if an ICC chunk is missing", func(t *testing.T) {
@@ -126,7 +130,7 @@ func TestExtractMetadata(t *testing.T) {
 
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		assertMetadataICCProfileError(md, err, "incomplete ICC profile data", t)
 	})
@@ -144,7 +148,7 @@ func TestExtractMetadata(t *testing.T) {
 
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Errorf("Expected success but got error: %v", err)
@@ -176,6 +180,196 @@ func TestExtractMetadata(t *testing.T) {
 		}
 	})
 
+	t.Run("extracts EXIF data from APP1 segment", func(t *testing.T) {
+		exifData := append([]byte("Exif\x00\x00"), []byte{
+			'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+			0x00, 0x01,
+			0x87, 0x69, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x1A,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x02,
+			0xA0, 0x01, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0xFF, 0xFF, 0x00, 0x00,
+			0xA0, 0x05, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x38,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x01,
+			0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x04, 'R', '0', '3', 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		}...)
+
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		data.Write([]byte{0xFF, byte(markerTypeApp1), byte((len(exifData) + 2) >> 8), byte(len(exifData) + 2)})
+		data.Write(exifData)
+		data.Write([]byte{0xFF, byte(markerTypeStartOfFrameBaseline), 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00})
+		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if md.EXIF == nil {
+			t.Errorf("Expected EXIF data but got none")
+		} else if !md.EXIF.IsAdobeRGB() {
+			t.Errorf("Expected EXIF data to indicate Adobe RGB but got %+v", *md.EXIF)
+		}
+	})
+
+	t.Run("extracts frame header from all start-of-frame marker types", func(t *testing.T) {
+		for mt := markerTypeStartOfFrameBaseline; mt <= markerTypeStartOfFrameArithmeticDifferentialLossless; mt++ {
+			if !mt.isStartOfFrame() {
+				continue
+			}
+
+			t.Run(mt.String(), func(t *testing.T) {
+				data := &bytes.Buffer{}
+				data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+				data.Write([]byte{0xFF, byte(mt), 0x00, 0x11, 0x0C, 0x00, 0x10, 0x00, 0x0F, 0x03})
+				data.Write([]byte{0x01, 0x22, 0x00, 0x02, 0x11, 0x01, 0x03, 0x11, 0x01})
+				data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
+
+				md, err := extractMetadata(data, meta.DefaultLimits)
+
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if expected, actual := uint32(12), md.BitsPerComponent; expected != actual {
+					t.Errorf("Expected image bits per component of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(15), md.PixelWidth; expected != actual {
+					t.Errorf("Expected image width of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(16), md.PixelHeight; expected != actual {
+					t.Errorf("Expected image height of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(3), md.ChannelCount; expected != actual {
+					t.Errorf("Expected channel count of %d but got %d", expected, actual)
+				}
+				if expected, actual := mt.isProgressive(), md.Interlaced; expected != actual {
+					t.Errorf("Expected interlaced to be %v but got %v", expected, actual)
+				}
+				if expected, actual := uint32(1), md.FrameCount; expected != actual {
+					t.Errorf("Expected frame count of %d but got %d", expected, actual)
+				}
+
+				expectedComponents := []meta.Component{
+					{ID: 1, HorizontalSampling: 2, VerticalSampling: 2},
+					{ID: 2, HorizontalSampling: 1, VerticalSampling: 1},
+					{ID: 3, HorizontalSampling: 1, VerticalSampling: 1},
+				}
+				if len(md.Components) != len(expectedComponents) {
+					t.Fatalf("Expected components %+v but got %+v", expectedComponents, md.Components)
+				}
+				for i := range expectedComponents {
+					if expected, actual := expectedComponents[i], md.Components[i]; expected != actual {
+						t.Errorf("Expected component %+v but got %+v", expected, actual)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("determines colour model from components and Adobe transform", func(t *testing.T) {
+		adobeTransform := func(transform meta.AdobeTransform) []byte {
+			return []byte{0xFF, byte(markerTypeApp14), 0x00, 0x0E, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, byte(transform)}
+		}
+		jfif := []byte{0xFF, byte(markerTypeApp0), 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00}
+
+		cases := []struct {
+			name       string
+			prefix     []byte
+			ids        []byte
+			colorModel meta.ColorModel
+		}{
+			{"greyscale", nil, []byte{1}, meta.ColorModelGreyscale},
+			{"JFIF YCbCr", jfif, []byte{1, 2, 3}, meta.ColorModelYCbCr},
+			{"untagged YCbCr", nil, []byte{1, 2, 3}, meta.ColorModelYCbCr},
+			{"RGB component IDs", nil, []byte{'R', 'G', 'B'}, meta.ColorModelRGB},
+			{"Adobe RGB", adobeTransform(meta.AdobeTransformNone), []byte{1, 2, 3}, meta.ColorModelRGB},
+			{"Adobe YCbCr", adobeTransform(meta.AdobeTransformYCbCr), []byte{'R', 'G', 'B'}, meta.ColorModelYCbCr},
+			{"untagged CMYK", nil, []byte{1, 2, 3, 4}, meta.ColorModelCMYK},
+			{"Adobe CMYK", adobeTransform(meta.AdobeTransformNone), []byte{1, 2, 3, 4}, meta.ColorModelCMYK},
+			{"Adobe YCCK", adobeTransform(meta.AdobeTransformYCCK), []byte{1, 2, 3, 4}, meta.ColorModelYCCK},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				data := &bytes.Buffer{}
+				data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+				data.Write(c.prefix)
+
+				length := 8 + len(c.ids)*3
+				data.Write([]byte{0xFF, byte(markerTypeStartOfFrameExtendedSequential), byte(length >> 8), byte(length), 0x08, 0x00, 0x10, 0x00, 0x0F, byte(len(c.ids))})
+				for _, id := range c.ids {
+					data.Write([]byte{id, 0x11, 0x00})
+				}
+				data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
+
+				md, err := extractMetadata(data, meta.DefaultLimits)
+
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if expected, actual := c.colorModel, md.ColorModel; expected != actual {
+					t.Errorf("Expected colour model %v but got %v", expected, actual)
+				}
+			})
+		}
+	})
+
+	t.Run("extracts standard and extended XMP from APP1 segments", func(t *testing.T) {
+		guid := "0123456789ABCDEF0123456789ABCDEF"
+		standardXMP := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:xmpNote="http://ns.adobe.com/xmp/note/" xmpNote:HasExtendedXMP="` + guid + `"/></rdf:RDF>`)
+		extendedXMP := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/" photoshop:ICCProfile="Display P3"/></rdf:RDF>`)
+
+		writeAPP1 := func(dest *bytes.Buffer, parts ...[]byte) {
+			length := 2
+			for _, p := range parts {
+				length += len(p)
+			}
+			dest.Write([]byte{0xFF, byte(markerTypeApp1), byte(length >> 8), byte(length)})
+			for _, p := range parts {
+				dest.Write(p)
+			}
+		}
+
+		writeExtendedXMPChunk := func(dest *bytes.Buffer, guid string, offset int, chunk []byte) {
+			header := make([]byte, 8)
+			encbinary.BigEndian.PutUint32(header, uint32(len(extendedXMP)))
+			encbinary.BigEndian.PutUint32(header[4:], uint32(offset))
+			writeAPP1(dest, extendedXMPIdentifier, []byte(guid), header, chunk)
+		}
+
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		writeAPP1(data, xmpIdentifier, standardXMP)
+		writeExtendedXMPChunk(data, "FEDCBA9876543210FEDCBA9876543210", 0, extendedXMP[:40])
+		writeExtendedXMPChunk(data, guid, 40, extendedXMP[40:])
+		writeExtendedXMPChunk(data, guid, 0, extendedXMP[:40])
+		data.Write([]byte{0xFF, byte(markerTypeStartOfFrameBaseline), 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00})
+		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := standardXMP, md.XMPData(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected standard XMP %s but got %s", expected, actual)
+		}
+		if expected, actual := extendedXMP, md.ExtendedXMPData(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected extended XMP %s but got %s", expected, actual)
+		}
+
+		properties, err := md.XMP()
+		if err != nil {
+			t.Fatalf("Expected XMP properties but got error: %v", err)
+		}
+		if expected, actual := "Display P3", properties.ICCProfileName; expected != actual {
+			t.Errorf("Expected ICC profile name '%s' but got '%s'", expected, actual)
+		}
+	})
+
 	t.Run("stops reading after all interesting metadata has been found", func(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
@@ -186,7 +380,7 @@ func TestExtractMetadata(t *testing.T) {
 
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Errorf("Expected success but got error: %v", err)
@@ -212,7 +406,7 @@ func TestExtractMetadata(t *testing.T) {
 		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
 		data.Write([]byte{0xFF, byte(markerTypeEndOfImage)})
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -231,3 +425,73 @@ func TestExtractMetadata(t *testing.T) {
 		}
 	})
 }
+
+func TestErrors(t *testing.T) {
+
+	t.Run("stream ending before the image data is reported as truncated data", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		data.Write([]byte{0xFF, byte(markerTypeStartOfFrameBaseline), 0x00, 0x07, 0x08})
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncation error but got %v", err)
+		}
+	})
+
+	t.Run("malformed frame header is reported with its offset", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		data.Write([]byte{0xFF, byte(markerTypeApp0), 0x00, 0x04, 0x00, 0x00})
+		data.Write([]byte{0xFF, byte(markerTypeStartOfFrameProgressive), 0x00, 0x04, 0x08, 0x00})
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		var malformedErr *meta.MalformedError
+		if !errors.As(err, &malformedErr) {
+			t.Fatalf("Expected malformed segment error but got %v", err)
+		}
+		if expected, actual := "SOF2", malformedErr.Segment; expected != actual {
+			t.Errorf("Expected malformed %s segment but got %s", expected, actual)
+		}
+		if expected, actual := int64(8), malformedErr.Offset; expected != actual {
+			t.Errorf("Expected malformed segment at offset %d but got %d", expected, actual)
+		}
+	})
+
+	t.Run("invalid marker is reported as malformed data", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		data.Write([]byte{0x12, 0x34})
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		var malformedErr *meta.MalformedError
+		if !errors.As(err, &malformedErr) {
+			t.Fatalf("Expected malformed segment error but got %v", err)
+		}
+		if expected, actual := int64(2), malformedErr.Offset; expected != actual {
+			t.Errorf("Expected malformed segment at offset %d but got %d", expected, actual)
+		}
+	})
+
+	t.Run("incomplete ICC profile is reported as truncated data", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte{0xFF, byte(markerTypeStartOfImage)})
+		data.Write([]byte{0xFF, byte(markerTypeStartOfFrameBaseline), 0x00, 0x07, 0x08, 0x00, 0x10, 0x00, 0x0F})
+		data.Write([]byte{0xFF, byte(markerTypeApp2), 0x00, 0x10})
+		data.Write(iccProfileIdentifier)
+		data.Write([]byte{1, 2})
+		data.Write([]byte{0xFF, byte(markerTypeStartOfScan), 0x00, 0x02})
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if _, iccErr := md.ICCProfileData(); !errors.Is(iccErr, meta.ErrTruncated) {
+			t.Errorf("Expected truncation error but got %v", iccErr)
+		}
+	})
+}
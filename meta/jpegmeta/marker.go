@@ -1,7 +1,6 @@
 package jpegmeta
 
 import (
-	"fmt"
 	"github.com/mandykoh/prism/meta/binary"
 	"io"
 )
@@ -32,11 +31,27 @@ func makeMarker(mType byte, r io.ByteReader) (marker, error) {
 		length = 2
 
 	case byte(markerTypeStartOfFrameBaseline),
+		byte(markerTypeStartOfFrameExtendedSequential),
 		byte(markerTypeStartOfFrameProgressive),
+		byte(markerTypeStartOfFrameLossless),
 		byte(markerTypeDefineHuffmanTable),
+		byte(markerTypeStartOfFrameDifferentialSequential),
+		byte(markerTypeStartOfFrameDifferentialProgressive),
+		byte(markerTypeStartOfFrameDifferentialLossless),
+		byte(markerTypeJPEGExtension),
+		byte(markerTypeStartOfFrameArithmeticExtendedSequential),
+		byte(markerTypeStartOfFrameArithmeticProgressive),
+		byte(markerTypeStartOfFrameArithmeticLossless),
+		byte(markerTypeDefineArithmeticCoding),
+		byte(markerTypeStartOfFrameArithmeticDifferentialSequential),
+		byte(markerTypeStartOfFrameArithmeticDifferentialProgressive),
+		byte(markerTypeStartOfFrameArithmeticDifferentialLossless),
 		byte(markerTypeStartOfScan),
 		byte(markerTypeDefineQuantisationTable),
+		byte(markerTypeDefineNumberOfLines),
 		byte(markerTypeDefineRestartInterval),
+		byte(markerTypeDefineHierarchicalProgression),
+		byte(markerTypeExpandReferenceComponents),
 		byte(markerTypeApp0),
 		byte(markerTypeApp1),
 		byte(markerTypeApp2),
@@ -62,7 +77,7 @@ func makeMarker(mType byte, r io.ByteReader) (marker, error) {
 		}
 
 	default:
-		return invalidMarker, fmt.Errorf("unrecognised marker type %0x", mType)
+		return invalidMarker, binary.Errorf(binary.ErrMalformed, "unrecognised marker type %0x", mType)
 	}
 
 	return marker{
@@ -78,7 +93,7 @@ func readMarker(r io.ByteReader) (marker, error) {
 	}
 
 	if b != 0xff {
-		return invalidMarker, fmt.Errorf("invalid marker identifier %0x", b)
+		return invalidMarker, binary.Errorf(binary.ErrMalformed, "invalid marker identifier %0x", b)
 	}
 
 	b, err = r.ReadByte()
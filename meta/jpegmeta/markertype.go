@@ -5,50 +5,92 @@ import "fmt"
 type markerType int
 
 const (
-	markerTypeInvalid                 markerType = 0x00
-	markerTypeStartOfFrameBaseline    markerType = 0xc0
-	markerTypeStartOfFrameProgressive markerType = 0xc2
-	markerTypeDefineHuffmanTable      markerType = 0xc4
-	markerTypeRestart0                markerType = 0xd0
-	markerTypeRestart1                markerType = 0xd1
-	markerTypeRestart2                markerType = 0xd2
-	markerTypeRestart3                markerType = 0xd3
-	markerTypeRestart4                markerType = 0xd4
-	markerTypeRestart5                markerType = 0xd5
-	markerTypeRestart6                markerType = 0xd6
-	markerTypeRestart7                markerType = 0xd7
-	markerTypeStartOfImage            markerType = 0xd8
-	markerTypeEndOfImage              markerType = 0xd9
-	markerTypeStartOfScan             markerType = 0xda
-	markerTypeDefineQuantisationTable markerType = 0xdb
-	markerTypeDefineRestartInterval   markerType = 0xdd
-	markerTypeApp0                    markerType = 0xe0
-	markerTypeApp1                    markerType = 0xe1
-	markerTypeApp2                    markerType = 0xe2
-	markerTypeApp3                    markerType = 0xe3
-	markerTypeApp4                    markerType = 0xe4
-	markerTypeApp5                    markerType = 0xe5
-	markerTypeApp6                    markerType = 0xe6
-	markerTypeApp7                    markerType = 0xe7
-	markerTypeApp8                    markerType = 0xe8
-	markerTypeApp9                    markerType = 0xe9
-	markerTypeApp10                   markerType = 0xea
-	markerTypeApp11                   markerType = 0xeb
-	markerTypeApp12                   markerType = 0xec
-	markerTypeApp13                   markerType = 0xed
-	markerTypeApp14                   markerType = 0xee
-	markerTypeApp15                   markerType = 0xef
-	markerTypeComment                 markerType = 0xfe
+	markerTypeInvalid                                       markerType = 0x00
+	markerTypeStartOfFrameBaseline                          markerType = 0xc0
+	markerTypeStartOfFrameExtendedSequential                markerType = 0xc1
+	markerTypeStartOfFrameProgressive                       markerType = 0xc2
+	markerTypeStartOfFrameLossless                          markerType = 0xc3
+	markerTypeDefineHuffmanTable                            markerType = 0xc4
+	markerTypeStartOfFrameDifferentialSequential            markerType = 0xc5
+	markerTypeStartOfFrameDifferentialProgressive           markerType = 0xc6
+	markerTypeStartOfFrameDifferentialLossless              markerType = 0xc7
+	markerTypeJPEGExtension                                 markerType = 0xc8
+	markerTypeStartOfFrameArithmeticExtendedSequential      markerType = 0xc9
+	markerTypeStartOfFrameArithmeticProgressive             markerType = 0xca
+	markerTypeStartOfFrameArithmeticLossless                markerType = 0xcb
+	markerTypeDefineArithmeticCoding                        markerType = 0xcc
+	markerTypeStartOfFrameArithmeticDifferentialSequential  markerType = 0xcd
+	markerTypeStartOfFrameArithmeticDifferentialProgressive markerType = 0xce
+	markerTypeStartOfFrameArithmeticDifferentialLossless    markerType = 0xcf
+	markerTypeRestart0                                      markerType = 0xd0
+	markerTypeRestart1                                      markerType = 0xd1
+	markerTypeRestart2                                      markerType = 0xd2
+	markerTypeRestart3                                      markerType = 0xd3
+	markerTypeRestart4                                      markerType = 0xd4
+	markerTypeRestart5                                      markerType = 0xd5
+	markerTypeRestart6                                      markerType = 0xd6
+	markerTypeRestart7                                      markerType = 0xd7
+	markerTypeStartOfImage                                  markerType = 0xd8
+	markerTypeEndOfImage                                    markerType = 0xd9
+	markerTypeStartOfScan                                   markerType = 0xda
+	markerTypeDefineQuantisationTable                       markerType = 0xdb
+	markerTypeDefineNumberOfLines                           markerType = 0xdc
+	markerTypeDefineRestartInterval                         markerType = 0xdd
+	markerTypeDefineHierarchicalProgression                 markerType = 0xde
+	markerTypeExpandReferenceComponents                     markerType = 0xdf
+	markerTypeApp0                                          markerType = 0xe0
+	markerTypeApp1                                          markerType = 0xe1
+	markerTypeApp2                                          markerType = 0xe2
+	markerTypeApp3                                          markerType = 0xe3
+	markerTypeApp4                                          markerType = 0xe4
+	markerTypeApp5                                          markerType = 0xe5
+	markerTypeApp6                                          markerType = 0xe6
+	markerTypeApp7                                          markerType = 0xe7
+	markerTypeApp8                                          markerType = 0xe8
+	markerTypeApp9                                          markerType = 0xe9
+	markerTypeApp10                                         markerType = 0xea
+	markerTypeApp11                                         markerType = 0xeb
+	markerTypeApp12                                         markerType = 0xec
+	markerTypeApp13                                         markerType = 0xed
+	markerTypeApp14                                         markerType = 0xee
+	markerTypeApp15                                         markerType = 0xef
+	markerTypeComment                                       markerType = 0xfe
 )
 
 func (mt markerType) String() string {
 	switch mt {
 	case markerTypeStartOfFrameBaseline:
 		return "SOF0"
+	case markerTypeStartOfFrameExtendedSequential:
+		return "SOF1"
 	case markerTypeStartOfFrameProgressive:
 		return "SOF2"
+	case markerTypeStartOfFrameLossless:
+		return "SOF3"
 	case markerTypeDefineHuffmanTable:
 		return "DHT"
+	case markerTypeStartOfFrameDifferentialSequential:
+		return "SOF5"
+	case markerTypeStartOfFrameDifferentialProgressive:
+		return "SOF6"
+	case markerTypeStartOfFrameDifferentialLossless:
+		return "SOF7"
+	case markerTypeJPEGExtension:
+		return "JPG"
+	case markerTypeStartOfFrameArithmeticExtendedSequential:
+		return "SOF9"
+	case markerTypeStartOfFrameArithmeticProgressive:
+		return "SOF10"
+	case markerTypeStartOfFrameArithmeticLossless:
+		return "SOF11"
+	case markerTypeDefineArithmeticCoding:
+		return "DAC"
+	case markerTypeStartOfFrameArithmeticDifferentialSequential:
+		return "SOF13"
+	case markerTypeStartOfFrameArithmeticDifferentialProgressive:
+		return "SOF14"
+	case markerTypeStartOfFrameArithmeticDifferentialLossless:
+		return "SOF15"
 	case markerTypeRestart0:
 		return "RST0"
 	case markerTypeRestart1:
@@ -73,8 +115,14 @@ func (mt markerType) String() string {
 		return "SOS"
 	case markerTypeDefineQuantisationTable:
 		return "DQT"
+	case markerTypeDefineNumberOfLines:
+		return "DNL"
 	case markerTypeDefineRestartInterval:
 		return "DRI"
+	case markerTypeDefineHierarchicalProgression:
+		return "DHP"
+	case markerTypeExpandReferenceComponents:
+		return "EXP"
 	case markerTypeApp0:
 		return "APP0"
 	case markerTypeApp1:
@@ -113,3 +161,51 @@ func (mt markerType) String() string {
 		return fmt.Sprintf("Unknown (%0x)", byte(mt))
 	}
 }
+
+// isStartOfFrame returns true if this is any of the start-of-frame marker
+// types, which all share the same frame header layout.
+func (mt markerType) isStartOfFrame() bool {
+	switch mt {
+	case markerTypeStartOfFrameBaseline,
+		markerTypeStartOfFrameExtendedSequential,
+		markerTypeStartOfFrameProgressive,
+		markerTypeStartOfFrameLossless,
+		markerTypeStartOfFrameDifferentialSequential,
+		markerTypeStartOfFrameDifferentialProgressive,
+		markerTypeStartOfFrameDifferentialLossless,
+		markerTypeStartOfFrameArithmeticExtendedSequential,
+		markerTypeStartOfFrameArithmeticProgressive,
+		markerTypeStartOfFrameArithmeticLossless,
+		markerTypeStartOfFrameArithmeticDifferentialSequential,
+		markerTypeStartOfFrameArithmeticDifferentialProgressive,
+		markerTypeStartOfFrameArithmeticDifferentialLossless:
+		return true
+	default:
+		return false
+	}
+}
+
+// isProgressive returns true if this is a start-of-frame marker type for a
+// progressively coded frame.
+func (mt markerType) isProgressive() bool {
+	switch mt {
+	case markerTypeStartOfFrameProgressive,
+		markerTypeStartOfFrameDifferentialProgressive,
+		markerTypeStartOfFrameArithmeticProgressive,
+		markerTypeStartOfFrameArithmeticDifferentialProgressive:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasMetadata returns true if segments of this marker type may contain
+// metadata, such that their data needs to be read.
+func (mt markerType) hasMetadata() bool {
+	switch mt {
+	case markerTypeApp0, markerTypeApp1, markerTypeApp2, markerTypeApp14:
+		return true
+	default:
+		return mt.isStartOfFrame()
+	}
+}
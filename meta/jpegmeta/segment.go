@@ -1,7 +1,7 @@
 package jpegmeta
 
 import (
-	"fmt"
+	"errors"
 	"github.com/mandykoh/prism/meta/binary"
 	"io"
 )
@@ -11,22 +11,41 @@ var invalidSegment = segment{Marker: invalidMarker}
 type segment struct {
 	Marker marker
 	Data   []byte
+
+	// Offset is the position of the segment's marker from the start of the
+	// stream.
+	Offset int64
 }
 
-func makeSegment(markerType byte, r io.ByteReader) (segment, error) {
+func makeSegment(markerType byte, r io.ByteReader, offset int64) (segment, error) {
 	m, err := makeMarker(markerType, r)
-	return segment{Marker: m}, err
+	if err != nil {
+		return invalidSegment, markerError(err, offset)
+	}
+	return segment{Marker: m, Offset: offset}, nil
 }
 
-func readSegment(r binary.Reader) (segment, error) {
+func readSegment(r *binary.OffsetReader) (segment, error) {
+	offset := r.Offset()
+
 	m, err := readMarker(r)
 	if err != nil {
-		return invalidSegment, err
+		return invalidSegment, markerError(err, offset)
 	}
 
 	seg := segment{
 		Marker: m,
+		Offset: offset,
 	}
+
+	// Data of segments which don't contain metadata is skipped
+	if !m.Type.hasMetadata() {
+		if err := binary.Skip(r, int64(m.DataLength)); err != nil {
+			return invalidSegment, err
+		}
+		return seg, nil
+	}
+
 	if m.DataLength > 0 {
 		seg.Data = make([]byte, m.DataLength)
 	}
@@ -36,8 +55,18 @@ func readSegment(r binary.Reader) (segment, error) {
 		return invalidSegment, err
 	}
 	if n < len(seg.Data) {
-		return invalidSegment, fmt.Errorf("expected %d bytes of segment data but read %d", m.DataLength, n)
+		return invalidSegment, binary.Errorf(binary.ErrTruncated, "expected %d bytes of segment data but read %d", m.DataLength, n)
 	}
 
 	return seg, nil
 }
+
+// markerError reports an invalid marker as a malformed segment at the
+// specified offset. Other errors (such as reaching the end of the stream) are
+// returned unchanged.
+func markerError(err error, offset int64) error {
+	if errors.Is(err, binary.ErrMalformed) {
+		return &binary.MalformedError{Segment: "marker", Offset: offset, Err: err}
+	}
+	return err
+}
@@ -5,7 +5,7 @@ import (
 )
 
 type segmentReader struct {
-	reader             binary.Reader
+	reader             *binary.OffsetReader
 	inEntropyCodedData bool
 }
 
@@ -25,7 +25,7 @@ func (sr *segmentReader) ReadSegment() (segment, error) {
 				}
 
 				if b != 0x00 {
-					seg, err := makeSegment(b, sr.reader)
+					seg, err := makeSegment(b, sr.reader, sr.reader.Offset()-2)
 					if err != nil {
 						return segment{}, err
 					}
@@ -51,6 +51,6 @@ func (sr *segmentReader) ReadSegment() (segment, error) {
 
 func NewSegmentReader(r binary.Reader) *segmentReader {
 	return &segmentReader{
-		reader: r,
+		reader: binary.NewOffsetReader(r),
 	}
 }
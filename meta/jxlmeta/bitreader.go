@@ -0,0 +1,121 @@
+package jxlmeta
+
+import (
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+)
+
+// bitReader reads values from a JPEG XL bit stream, in which bits are packed
+// starting with the least significant bit of each byte.
+//
+// Errors are sticky: once a read fails, subsequent reads return zero bits and
+// the first error is retained in err.
+type bitReader struct {
+	r     io.ByteReader
+	buf   uint64
+	count uint
+	err   error
+}
+
+// u32Dist is one of the four possible distributions of a U32 field, being
+// either a constant value (bits = 0) or a number of bits plus an offset.
+type u32Dist struct {
+	bits   uint
+	offset uint32
+}
+
+func val(v uint32) u32Dist {
+	return u32Dist{offset: v}
+}
+
+func bitsOffset(n uint, offset uint32) u32Dist {
+	return u32Dist{bits: n, offset: offset}
+}
+
+func (br *bitReader) readBits(n uint) uint32 {
+	for br.count < n {
+		b, err := br.r.ReadByte()
+		if err != nil && br.err == nil {
+			if errors.Is(err, io.EOF) {
+				err = binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+			}
+			br.err = err
+		}
+		br.buf |= uint64(b) << br.count
+		br.count += 8
+	}
+
+	v := br.buf & (1<<n - 1)
+	br.buf >>= n
+	br.count -= n
+	return uint32(v)
+}
+
+func (br *bitReader) readBool() bool {
+	return br.readBits(1) == 1
+}
+
+// readEnum reads an enumerated value, which is encoded as a U32.
+func (br *bitReader) readEnum() uint32 {
+	return br.readU32(val(0), val(1), bitsOffset(4, 2), bitsOffset(6, 18))
+}
+
+// readF16 reads a half-precision floating point value.
+func (br *bitReader) readF16() uint32 {
+	return br.readBits(16)
+}
+
+func (br *bitReader) readU32(d0, d1, d2, d3 u32Dist) uint32 {
+	d := [4]u32Dist{d0, d1, d2, d3}[br.readBits(2)]
+	return br.readBits(d.bits) + d.offset
+}
+
+func (br *bitReader) readU64() uint64 {
+	switch br.readBits(2) {
+	case 0:
+		return 0
+	case 1:
+		return 1 + uint64(br.readBits(4))
+	case 2:
+		return 17 + uint64(br.readBits(8))
+	}
+
+	v := uint64(br.readBits(12))
+	for shift := uint(12); br.readBool(); shift += 8 {
+		if shift == 60 {
+			v |= uint64(br.readBits(4)) << shift
+			break
+		}
+		v |= uint64(br.readBits(8)) << shift
+	}
+	return v
+}
+
+// readU8 reads a small variable length value in the range 0–255.
+func (br *bitReader) readU8() uint32 {
+	if !br.readBool() {
+		return 0
+	}
+	n := uint(br.readBits(3))
+	return 1<<n + br.readBits(n)
+}
+
+// readU16 reads a variable length value in the range 0–65535.
+func (br *bitReader) readU16() uint32 {
+	if !br.readBool() {
+		return 0
+	}
+	n := uint(br.readBits(4))
+	return 1<<n + br.readBits(n)
+}
+
+func (br *bitReader) skipBits(n uint64) {
+	for ; n > 32 && br.err == nil; n -= 32 {
+		br.readBits(32)
+	}
+	if br.err == nil {
+		br.readBits(uint(n))
+	}
+}
@@ -0,0 +1,117 @@
+package jxlmeta
+
+import (
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+	"math"
+)
+
+var containerSignature = [12]byte{0x00, 0x00, 0x00, 0x0C, 'J', 'X', 'L', ' ', 0x0D, 0x0A, 0x87, 0x0A}
+
+var (
+	boxTypeJxlc = [4]byte{'j', 'x', 'l', 'c'}
+	boxTypeJxlp = [4]byte{'j', 'x', 'l', 'p'}
+)
+
+// codestreamReader reads the codestream stored in the jxlc box, or split
+// across jxlp boxes, of a JPEG XL container. Other boxes are skipped.
+type codestreamReader struct {
+	r         binary.Reader
+	limits    meta.Limits
+	boxCount  int
+	remaining uint64
+	toEnd     bool
+	last      bool
+}
+
+func (cr *codestreamReader) ReadByte() (byte, error) {
+	for !cr.toEnd && cr.remaining == 0 {
+		if cr.last {
+			return 0, io.EOF
+		}
+		if err := cr.nextCodestreamBox(); err != nil {
+			return 0, err
+		}
+	}
+
+	b, err := cr.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if !cr.toEnd {
+		cr.remaining--
+	}
+	return b, nil
+}
+
+func (cr *codestreamReader) nextCodestreamBox() error {
+	for {
+		size, err := binary.ReadU32Big(cr.r)
+		if err != nil {
+			if err == io.EOF {
+				return binary.Errorf(meta.ErrMalformed, "no codestream found")
+			}
+			return err
+		}
+
+		var boxType [4]byte
+		if _, err := io.ReadFull(cr.r, boxType[:]); err != nil {
+			return binary.Errorf(meta.ErrTruncated, "unexpected EOF reading box type")
+		}
+
+		cr.boxCount++
+		if err := cr.limits.CheckSegmentCount(cr.boxCount); err != nil {
+			return err
+		}
+
+		length := uint64(0)
+		toEnd := false
+
+		switch size {
+		case 0:
+			toEnd = true
+		case 1:
+			largeSize, err := binary.ReadU64Big(cr.r)
+			if err != nil {
+				return err
+			}
+			if largeSize < 16 || largeSize > math.MaxInt64 {
+				return binary.Errorf(meta.ErrMalformed, "invalid box size %d", largeSize)
+			}
+			length = largeSize - 16
+		default:
+			if size < 8 {
+				return binary.Errorf(meta.ErrMalformed, "invalid box size %d", size)
+			}
+			length = uint64(size) - 8
+		}
+
+		switch boxType {
+
+		case boxTypeJxlc:
+			cr.remaining, cr.toEnd, cr.last = length, toEnd, true
+			return nil
+
+		case boxTypeJxlp:
+			index, err := binary.ReadU32Big(cr.r)
+			if err != nil {
+				return err
+			}
+			if !toEnd && length < 4 {
+				return binary.Errorf(meta.ErrMalformed, "invalid jxlp box")
+			}
+			cr.remaining, cr.toEnd = length-4, toEnd
+			cr.last = toEnd || index&0x80000000 != 0
+			return nil
+
+		default:
+			if toEnd {
+				return binary.Errorf(meta.ErrMalformed, "no codestream found")
+			}
+			if err := binary.Skip(cr.r, int64(length)); err != nil {
+				return err
+			}
+		}
+	}
+}
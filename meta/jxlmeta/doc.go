@@ -0,0 +1,2 @@
+// Package jxlmeta provides support for working with embedded JPEG XL metadata.
+package jxlmeta
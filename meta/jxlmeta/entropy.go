@@ -0,0 +1,564 @@
+package jxlmeta
+
+import (
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+const ansLogTableSize = 12
+const ansTableSize = 1 << ansLogTableSize
+const ansSignature = 0x13 << 16
+
+const lz77WindowSize = 1 << 20
+
+// logCountLengths and logCountCodes define the fixed prefix code used to read
+// the logarithmic counts of a complex ANS histogram.
+var logCountLengths = [14]uint8{5, 4, 4, 4, 4, 4, 3, 3, 3, 3, 3, 6, 7, 7}
+var logCountCodes = [14]uint8{17, 11, 15, 3, 9, 7, 4, 2, 5, 6, 0, 33, 1, 65}
+
+type hybridUintConfig struct {
+	splitExponent uint32
+	msbInToken    uint32
+	lsbInToken    uint32
+}
+
+type lz77Params struct {
+	enabled     bool
+	minSymbol   uint32
+	minLength   uint32
+	lengthUint  hybridUintConfig
+	distanceCtx int
+}
+
+type aliasEntry struct {
+	cutoff     uint32
+	rightValue uint32
+	offsets1   uint32
+	freq0      uint32
+	freq1      uint32
+}
+
+// entropyCode is a set of distributions, each used to decode the symbols of
+// one or more contexts.
+type entropyCode struct {
+	lz77          lz77Params
+	contextMap    []int
+	usePrefixCode bool
+	logAlphaSize  uint32
+	configs       []hybridUintConfig
+	prefixCodes   []*prefixCode
+	aliasTables   [][]aliasEntry
+}
+
+// entropyDecoder decodes a stream of integers using an entropy code.
+type entropyDecoder struct {
+	code       *entropyCode
+	state      uint32
+	window     []uint32
+	numDecoded int
+	copyPos    int
+	numToCopy  int
+}
+
+func ceilLog2(x uint32) uint {
+	n := uint(0)
+	for 1<<n < x {
+		n++
+	}
+	return n
+}
+
+func readHybridUintConfig(br *bitReader, logAlphaSize uint32) (hybridUintConfig, error) {
+	c := hybridUintConfig{}
+	c.splitExponent = br.readBits(ceilLog2(logAlphaSize + 1))
+	if c.splitExponent != logAlphaSize {
+		c.msbInToken = br.readBits(ceilLog2(c.splitExponent + 1))
+		if c.msbInToken > c.splitExponent {
+			return c, binary.Errorf(meta.ErrMalformed, "invalid hybrid integer configuration")
+		}
+		c.lsbInToken = br.readBits(ceilLog2(c.splitExponent - c.msbInToken + 1))
+	}
+	if c.msbInToken+c.lsbInToken > c.splitExponent {
+		return c, binary.Errorf(meta.ErrMalformed, "invalid hybrid integer configuration")
+	}
+	return c, br.err
+}
+
+func (c hybridUintConfig) value(br *bitReader, token uint32) uint32 {
+	splitToken := uint32(1) << c.splitExponent
+	if token < splitToken {
+		return token
+	}
+
+	inToken := c.msbInToken + c.lsbInToken
+	nbits := (c.splitExponent - inToken + ((token - splitToken) >> inToken)) & 31
+	low := token & (1<<c.lsbInToken - 1)
+	token >>= c.lsbInToken
+	bits := br.readBits(uint(nbits))
+
+	return ((1<<c.msbInToken|token&(1<<c.msbInToken-1))<<nbits|bits)<<c.lsbInToken | low
+}
+
+// readEntropyCode reads the distributions and context map for the specified
+// number of contexts.
+func readEntropyCode(br *bitReader, numContexts int) (*entropyCode, error) {
+	code := &entropyCode{}
+
+	code.lz77.enabled = br.readBool()
+	if code.lz77.enabled {
+		code.lz77.minSymbol = br.readU32(val(224), val(512), val(4096), bitsOffset(15, 8))
+		code.lz77.minLength = br.readU32(val(3), val(4), bitsOffset(2, 5), bitsOffset(8, 9))
+		numContexts++
+
+		var err error
+		code.lz77.lengthUint, err = readHybridUintConfig(br, 8)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	numClusters := 1
+	code.contextMap = make([]int, numContexts)
+	if numContexts > 1 {
+		var err error
+		numClusters, err = readContextMap(br, code.contextMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	code.lz77.distanceCtx = code.contextMap[numContexts-1]
+
+	code.usePrefixCode = br.readBool()
+	if code.usePrefixCode {
+		code.logAlphaSize = maxPrefixCodeLength
+	} else {
+		code.logAlphaSize = br.readBits(2) + 5
+	}
+
+	for i := 0; i < numClusters; i++ {
+		config, err := readHybridUintConfig(br, code.logAlphaSize)
+		if err != nil {
+			return nil, err
+		}
+		code.configs = append(code.configs, config)
+	}
+
+	if code.usePrefixCode {
+		alphabetSizes := make([]int, numClusters)
+		for i := range alphabetSizes {
+			alphabetSizes[i] = int(br.readU16()) + 1
+		}
+		for _, size := range alphabetSizes {
+			pc, err := readPrefixCode(br, size)
+			if err != nil {
+				return nil, err
+			}
+			code.prefixCodes = append(code.prefixCodes, pc)
+		}
+
+	} else {
+		for i := 0; i < numClusters; i++ {
+			counts, err := readHistogram(br)
+			if err != nil {
+				return nil, err
+			}
+			table, err := newAliasTable(counts, code.logAlphaSize)
+			if err != nil {
+				return nil, err
+			}
+			code.aliasTables = append(code.aliasTables, table)
+		}
+	}
+
+	return code, br.err
+}
+
+// readContextMap reads the mapping of contexts to clusters (distributions),
+// returning the number of clusters.
+func readContextMap(br *bitReader, contextMap []int) (int, error) {
+	if br.readBool() {
+		bitsPerEntry := uint(br.readBits(2))
+		for i := range contextMap {
+			contextMap[i] = int(br.readBits(bitsPerEntry))
+		}
+
+	} else {
+		useMTF := br.readBool()
+
+		code, err := readEntropyCode(br, 1)
+		if err != nil {
+			return 0, err
+		}
+		if code.lz77.enabled && len(contextMap) <= 2 {
+			return 0, binary.Errorf(meta.ErrMalformed, "invalid context map")
+		}
+
+		d := newEntropyDecoder(br, code)
+		for i := range contextMap {
+			contextMap[i] = int(d.readUint(br, 0))
+		}
+		if !d.finished() {
+			return 0, binary.Errorf(meta.ErrMalformed, "invalid context map")
+		}
+
+		if useMTF {
+			var mtf [256]int
+			for i := range mtf {
+				mtf[i] = i
+			}
+			for i, index := range contextMap {
+				if index > 255 {
+					return 0, binary.Errorf(meta.ErrMalformed, "invalid context map")
+				}
+				value := mtf[index]
+				contextMap[i] = value
+				copy(mtf[1:index+1], mtf[:index])
+				mtf[0] = value
+			}
+		}
+	}
+
+	if br.err != nil {
+		return 0, br.err
+	}
+
+	numClusters := 0
+	for _, c := range contextMap {
+		if c >= numClusters {
+			numClusters = c + 1
+		}
+	}
+	if numClusters > 256 {
+		return 0, binary.Errorf(meta.ErrMalformed, "too many clusters in context map")
+	}
+
+	used := make([]bool, numClusters)
+	for _, c := range contextMap {
+		used[c] = true
+	}
+	for _, u := range used {
+		if !u {
+			return 0, binary.Errorf(meta.ErrMalformed, "invalid context map")
+		}
+	}
+
+	return numClusters, nil
+}
+
+// readLogCount reads a symbol using the fixed prefix code for logarithmic
+// histogram counts.
+func readLogCount(br *bitReader) (uint32, error) {
+	code, length := uint8(0), uint8(0)
+	for length < 7 {
+		code |= uint8(br.readBits(1)) << length
+		length++
+		for symbol := range logCountLengths {
+			if logCountLengths[symbol] == length && logCountCodes[symbol] == code {
+				return uint32(symbol), nil
+			}
+		}
+	}
+	return 0, binary.Errorf(meta.ErrMalformed, "invalid histogram count")
+}
+
+func readHistogram(br *bitReader) ([]uint32, error) {
+	if br.readBool() {
+		numSymbols := int(br.readBits(1)) + 1
+		var symbols [2]uint32
+		maxSymbol := uint32(0)
+		for i := 0; i < numSymbols; i++ {
+			symbols[i] = br.readU8()
+			if symbols[i] > maxSymbol {
+				maxSymbol = symbols[i]
+			}
+		}
+
+		counts := make([]uint32, maxSymbol+1)
+		if numSymbols == 1 {
+			counts[symbols[0]] = ansTableSize
+		} else {
+			if symbols[0] == symbols[1] {
+				return nil, binary.Errorf(meta.ErrMalformed, "invalid histogram")
+			}
+			counts[symbols[0]] = br.readBits(ansLogTableSize)
+			counts[symbols[1]] = ansTableSize - counts[symbols[0]]
+		}
+		return counts, br.err
+	}
+
+	if br.readBool() {
+		alphabetSize := br.readU8() + 1
+		counts := make([]uint32, alphabetSize)
+		for i := range counts {
+			counts[i] = ansTableSize / alphabetSize
+			if uint32(i) < ansTableSize%alphabetSize {
+				counts[i]++
+			}
+		}
+		return counts, br.err
+	}
+
+	log := uint(0)
+	for ; log < 3; log++ {
+		if !br.readBool() {
+			break
+		}
+	}
+	shift := (br.readBits(log) | 1<<log) - 1
+	if shift > ansLogTableSize+1 {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid histogram")
+	}
+
+	length := int(br.readU8()) + 3
+	logCounts := make([]uint32, length)
+	same := make([]int, length)
+	omitLog := -1
+	omitPos := -1
+
+	for i := 0; i < length; i++ {
+		lc, err := readLogCount(br)
+		if err != nil {
+			return nil, err
+		}
+		logCounts[i] = lc
+
+		if lc == ansLogTableSize+1 {
+			rle := int(br.readU8())
+			same[i] = rle + 5
+			i += rle + 3
+			continue
+		}
+		if int(lc) > omitLog {
+			omitLog = int(lc)
+			omitPos = i
+		}
+	}
+	if br.err != nil {
+		return nil, br.err
+	}
+	if omitPos < 0 || omitPos+1 < length && logCounts[omitPos+1] == ansLogTableSize+1 {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid histogram")
+	}
+
+	counts := make([]uint32, length)
+	total := uint32(0)
+	prev := uint32(0)
+	numSame := 0
+
+	for i := 0; i < length; i++ {
+		if same[i] != 0 {
+			numSame = same[i] - 1
+			if i > 0 {
+				prev = counts[i-1]
+			} else {
+				prev = 0
+			}
+		}
+
+		if numSame > 0 {
+			counts[i] = prev
+			numSame--
+		} else {
+			lc := logCounts[i]
+			switch {
+			case i == omitPos || lc == 0:
+				continue
+			case lc == 1:
+				counts[i] = 1
+			default:
+				logCount := int(lc - 1)
+				bitCount := int(shift) - (ansLogTableSize-logCount)>>1
+				if bitCount > logCount {
+					bitCount = logCount
+				}
+				if bitCount < 0 {
+					bitCount = 0
+				}
+				counts[i] = 1<<uint(logCount) + br.readBits(uint(bitCount))<<uint(logCount-bitCount)
+			}
+		}
+		total += counts[i]
+	}
+
+	if total >= ansTableSize {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid histogram")
+	}
+	counts[omitPos] = ansTableSize - total
+
+	return counts, br.err
+}
+
+func newAliasTable(counts []uint32, logAlphaSize uint32) ([]aliasEntry, error) {
+	logEntrySize := ansLogTableSize - logAlphaSize
+	entrySize := uint32(1) << logEntrySize
+	tableSize := 1 << logAlphaSize
+
+	for len(counts) > 0 && counts[len(counts)-1] == 0 {
+		counts = counts[:len(counts)-1]
+	}
+	if len(counts) == 0 {
+		counts = []uint32{ansTableSize}
+	}
+	if len(counts) > tableSize {
+		return nil, binary.Errorf(meta.ErrMalformed, "histogram exceeds alphabet size")
+	}
+
+	table := make([]aliasEntry, tableSize)
+
+	sum := uint32(0)
+	for symbol, c := range counts {
+		if c == ansTableSize {
+			for i := range table {
+				table[i] = aliasEntry{rightValue: uint32(symbol), offsets1: entrySize * uint32(i), freq1: ansTableSize}
+			}
+			return table, nil
+		}
+		sum += c
+	}
+	if sum != ansTableSize {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid histogram total")
+	}
+
+	cutoffs := make([]uint32, tableSize)
+	var underfull, overfull []int
+	for i := range cutoffs {
+		if i < len(counts) {
+			cutoffs[i] = counts[i]
+		}
+		if cutoffs[i] > entrySize {
+			overfull = append(overfull, i)
+		} else if cutoffs[i] < entrySize {
+			underfull = append(underfull, i)
+		}
+	}
+
+	for len(overfull) > 0 {
+		o := overfull[len(overfull)-1]
+		overfull = overfull[:len(overfull)-1]
+		u := underfull[len(underfull)-1]
+		underfull = underfull[:len(underfull)-1]
+
+		cutoffs[o] -= entrySize - cutoffs[u]
+		table[u].rightValue = uint32(o)
+		table[u].offsets1 = cutoffs[o]
+
+		if cutoffs[o] < entrySize {
+			underfull = append(underfull, o)
+		} else if cutoffs[o] > entrySize {
+			overfull = append(overfull, o)
+		}
+	}
+
+	countOf := func(symbol uint32) uint32 {
+		if int(symbol) < len(counts) {
+			return counts[symbol]
+		}
+		return 0
+	}
+
+	for i := range table {
+		if cutoffs[i] == entrySize {
+			table[i].rightValue = uint32(i)
+			table[i].offsets1 = 0
+			table[i].cutoff = 0
+		} else {
+			table[i].offsets1 -= cutoffs[i]
+			table[i].cutoff = cutoffs[i]
+		}
+		table[i].freq0 = countOf(uint32(i))
+		table[i].freq1 = countOf(table[i].rightValue)
+	}
+
+	return table, nil
+}
+
+func newEntropyDecoder(br *bitReader, code *entropyCode) *entropyDecoder {
+	d := &entropyDecoder{code: code, state: ansSignature}
+	if !code.usePrefixCode {
+		d.state = br.readBits(32)
+	}
+	if code.lz77.enabled {
+		d.window = make([]uint32, lz77WindowSize)
+	}
+	return d
+}
+
+func (d *entropyDecoder) readSymbol(br *bitReader, cluster int) uint32 {
+	if d.code.usePrefixCode {
+		return d.code.prefixCodes[cluster].readSymbol(br)
+	}
+
+	table := d.code.aliasTables[cluster]
+	logEntrySize := ansLogTableSize - d.code.logAlphaSize
+
+	res := d.state & (ansTableSize - 1)
+	entry := &table[res>>logEntrySize]
+	pos := res & (1<<logEntrySize - 1)
+
+	var symbol, offset, freq uint32
+	if pos >= entry.cutoff {
+		symbol, offset, freq = entry.rightValue, entry.offsets1+pos, entry.freq1
+	} else {
+		symbol, offset, freq = res>>logEntrySize, pos, entry.freq0
+	}
+
+	d.state = freq*(d.state>>ansLogTableSize) + offset
+	if d.state < 1<<16 {
+		d.state = d.state<<16 | br.readBits(16)
+	}
+
+	return symbol
+}
+
+// readUint reads the next integer in the specified context.
+func (d *entropyDecoder) readUint(br *bitReader, ctx int) uint32 {
+	code := d.code
+
+	if d.numToCopy > 0 {
+		return d.copyFromWindow()
+	}
+
+	cluster := code.contextMap[ctx]
+	token := d.readSymbol(br, cluster)
+
+	if code.lz77.enabled && token >= code.lz77.minSymbol {
+		d.numToCopy = int(code.lz77.lengthUint.value(br, token-code.lz77.minSymbol)) + int(code.lz77.minLength)
+
+		distanceToken := d.readSymbol(br, code.lz77.distanceCtx)
+		distance := int(code.configs[code.lz77.distanceCtx].value(br, distanceToken)) + 1
+		if distance > d.numDecoded {
+			distance = d.numDecoded
+		}
+		if distance > lz77WindowSize {
+			distance = lz77WindowSize
+		}
+		d.copyPos = d.numDecoded - distance
+
+		if d.numToCopy <= 0 || br.err != nil {
+			d.numToCopy = 0
+			return 0
+		}
+		return d.copyFromWindow()
+	}
+
+	v := code.configs[cluster].value(br, token)
+	if d.window != nil {
+		d.window[d.numDecoded&(lz77WindowSize-1)] = v
+		d.numDecoded++
+	}
+	return v
+}
+
+func (d *entropyDecoder) copyFromWindow() uint32 {
+	v := d.window[d.copyPos&(lz77WindowSize-1)]
+	d.copyPos++
+	d.numToCopy--
+	d.window[d.numDecoded&(lz77WindowSize-1)] = v
+	d.numDecoded++
+	return v
+}
+
+// finished indicates whether the decoder ended in its expected final state.
+func (d *entropyDecoder) finished() bool {
+	return d.state == ansSignature
+}
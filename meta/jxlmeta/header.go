@@ -0,0 +1,339 @@
+package jxlmeta
+
+import (
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+const (
+	extraChannelAlpha = 0
+	extraChannelBlack = 4
+)
+
+const (
+	colourSpaceRGB  = 0
+	colourSpaceGrey = 1
+	colourSpaceXYB  = 2
+)
+
+const (
+	whitePointD65    = 1
+	whitePointCustom = 2
+	whitePointE      = 10
+	whitePointDCI    = 11
+)
+
+const (
+	primariesSRGB   = 1
+	primariesCustom = 2
+	primaries2100   = 9
+	primariesP3     = 11
+)
+
+const (
+	transferFunction709     = 1
+	transferFunctionUnknown = 2
+	transferFunctionLinear  = 8
+	transferFunctionSRGB    = 13
+	transferFunctionPQ      = 16
+	transferFunctionDCI     = 17
+	transferFunctionHLG     = 18
+)
+
+// sizeRatios are the width:height ratios which may be used to imply the
+// width of an image from its height.
+var sizeRatios = [8][2]uint64{{}, {1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+
+type extraChannel struct {
+	channelType   uint32
+	bitsPerSample uint32
+}
+
+type colourEncoding struct {
+	wantICC          bool
+	colourSpace      uint32
+	whitePoint       uint32
+	white            [2]float64
+	primaries        uint32
+	red              [2]float64
+	green            [2]float64
+	blue             [2]float64
+	haveGamma        bool
+	gamma            uint32
+	transferFunction uint32
+	renderingIntent  uint32
+}
+
+type imageHeader struct {
+	width         uint32
+	height        uint32
+	bitsPerSample uint32
+	extraChannels []extraChannel
+	xybEncoded    bool
+	colour        colourEncoding
+	animated      bool
+	loopCount     uint32
+}
+
+// readImageHeader reads the size header and image metadata which follow the
+// signature at the start of a codestream.
+func readImageHeader(br *bitReader) (*imageHeader, error) {
+	h := &imageHeader{}
+
+	h.width, h.height = readSizeHeader(br)
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	if err := readImageMetadata(br, h); err != nil {
+		return nil, err
+	}
+
+	readCustomTransformData(br, h.xybEncoded)
+
+	return h, br.err
+}
+
+func readSizeHeader(br *bitReader) (width, height uint32) {
+	small := br.readBool()
+
+	readDimension := func() uint32 {
+		if small {
+			return (br.readBits(5) + 1) * 8
+		}
+		return br.readU32(bitsOffset(9, 1), bitsOffset(13, 1), bitsOffset(18, 1), bitsOffset(30, 1))
+	}
+
+	height = readDimension()
+	ratio := br.readBits(3)
+	if ratio == 0 {
+		width = readDimension()
+	} else {
+		width = uint32(uint64(height) * sizeRatios[ratio][0] / sizeRatios[ratio][1])
+	}
+
+	return width, height
+}
+
+func readPreviewHeader(br *bitReader) {
+	div8 := br.readBool()
+
+	readDimension := func() {
+		if div8 {
+			br.readU32(val(16), val(32), bitsOffset(5, 1), bitsOffset(9, 33))
+		} else {
+			br.readU32(bitsOffset(6, 1), bitsOffset(8, 65), bitsOffset(10, 321), bitsOffset(12, 1345))
+		}
+	}
+
+	readDimension()
+	if br.readBits(3) == 0 {
+		readDimension()
+	}
+}
+
+// readAnimationHeader reads an animation header, returning the number of
+// times the animation should be played (or zero for indefinitely).
+func readAnimationHeader(br *bitReader) (loops uint32) {
+	br.readU32(val(100), val(1000), bitsOffset(10, 1), bitsOffset(30, 1))
+	br.readU32(val(1), val(1001), bitsOffset(8, 1), bitsOffset(10, 1))
+	loops = br.readU32(val(0), bitsOffset(3, 0), bitsOffset(16, 0), bitsOffset(32, 0))
+	br.readBool()
+	return loops
+}
+
+func readBitDepth(br *bitReader) uint32 {
+	if br.readBool() {
+		bits := br.readU32(val(32), val(16), val(24), bitsOffset(6, 1))
+		br.readBits(4)
+		return bits
+	}
+	return br.readU32(val(8), val(10), val(12), bitsOffset(6, 1))
+}
+
+func readImageMetadata(br *bitReader, h *imageHeader) error {
+	h.bitsPerSample = 8
+	h.xybEncoded = true
+	h.colour = defaultColourEncoding()
+
+	if br.readBool() {
+		return br.err
+	}
+
+	extraFields := br.readBool()
+	if extraFields {
+		br.readBits(3)
+		if br.readBool() {
+			readSizeHeader(br)
+		}
+		if br.readBool() {
+			readPreviewHeader(br)
+		}
+		if br.readBool() {
+			h.animated = true
+			h.loopCount = readAnimationHeader(br)
+		}
+	}
+
+	h.bitsPerSample = readBitDepth(br)
+	br.readBool()
+
+	numExtraChannels := br.readU32(val(0), val(1), bitsOffset(4, 2), bitsOffset(12, 1))
+	for i := uint32(0); i < numExtraChannels && br.err == nil; i++ {
+		h.extraChannels = append(h.extraChannels, readExtraChannelInfo(br))
+	}
+
+	h.xybEncoded = br.readBool()
+	if err := readColourEncoding(br, &h.colour); err != nil {
+		return err
+	}
+
+	if extraFields {
+		readToneMapping(br)
+	}
+
+	readExtensions(br)
+
+	return br.err
+}
+
+func readExtraChannelInfo(br *bitReader) extraChannel {
+	if br.readBool() {
+		return extraChannel{channelType: extraChannelAlpha, bitsPerSample: 8}
+	}
+
+	ec := extraChannel{}
+	ec.channelType = br.readEnum()
+	ec.bitsPerSample = readBitDepth(br)
+	br.readU32(val(0), val(3), val(4), bitsOffset(3, 1))
+
+	nameLength := br.readU32(val(0), bitsOffset(4, 0), bitsOffset(5, 16), bitsOffset(10, 48))
+	br.skipBits(uint64(nameLength) * 8)
+
+	switch ec.channelType {
+	case extraChannelAlpha:
+		br.readBool()
+	case 2:
+		for i := 0; i < 4; i++ {
+			br.readF16()
+		}
+	case 5:
+		br.readU32(val(1), bitsOffset(2, 0), bitsOffset(4, 3), bitsOffset(8, 19))
+	}
+
+	return ec
+}
+
+func defaultColourEncoding() colourEncoding {
+	return colourEncoding{
+		colourSpace:      colourSpaceRGB,
+		whitePoint:       whitePointD65,
+		primaries:        primariesSRGB,
+		transferFunction: transferFunctionSRGB,
+		renderingIntent:  1,
+	}
+}
+
+func readColourEncoding(br *bitReader, ce *colourEncoding) error {
+	*ce = defaultColourEncoding()
+
+	if br.readBool() {
+		return br.err
+	}
+
+	ce.wantICC = br.readBool()
+	ce.colourSpace = br.readEnum()
+
+	if ce.wantICC {
+		return br.err
+	}
+
+	if ce.colourSpace != colourSpaceXYB {
+		ce.whitePoint = br.readEnum()
+		if ce.whitePoint == whitePointCustom {
+			ce.white = readCustomXY(br)
+		}
+	}
+
+	if ce.colourSpace != colourSpaceXYB && ce.colourSpace != colourSpaceGrey {
+		ce.primaries = br.readEnum()
+		if ce.primaries == primariesCustom {
+			ce.red = readCustomXY(br)
+			ce.green = readCustomXY(br)
+			ce.blue = readCustomXY(br)
+		}
+	}
+
+	if ce.colourSpace != colourSpaceXYB {
+		ce.haveGamma = br.readBool()
+		if ce.haveGamma {
+			ce.gamma = br.readBits(24)
+			if ce.gamma == 0 && br.err == nil {
+				return binary.Errorf(meta.ErrMalformed, "invalid gamma in colour encoding")
+			}
+		} else {
+			ce.transferFunction = br.readEnum()
+		}
+	}
+
+	ce.renderingIntent = br.readEnum()
+	if ce.renderingIntent > 3 && br.err == nil {
+		return binary.Errorf(meta.ErrMalformed, "invalid rendering intent %d in colour encoding", ce.renderingIntent)
+	}
+
+	return br.err
+}
+
+func readCustomXY(br *bitReader) (xy [2]float64) {
+	for i := range xy {
+		u := br.readU32(bitsOffset(19, 0), bitsOffset(19, 524288), bitsOffset(20, 1048576), bitsOffset(21, 2097152))
+		xy[i] = float64(int32(u>>1)^-int32(u&1)) / 1e6
+	}
+	return xy
+}
+
+func readToneMapping(br *bitReader) {
+	if br.readBool() {
+		return
+	}
+	br.readF16()
+	br.readF16()
+	br.readBool()
+	br.readF16()
+}
+
+// readExtensions reads the extension flags of a bundle, skipping the content
+// of any extensions present as none are understood.
+func readExtensions(br *bitReader) {
+	extensions := br.readU64()
+
+	total := uint64(0)
+	for i := 0; i < 64 && br.err == nil; i++ {
+		if extensions&(1<<i) != 0 {
+			total += br.readU64()
+		}
+	}
+
+	br.skipBits(total)
+}
+
+func readCustomTransformData(br *bitReader, xybEncoded bool) {
+	if br.readBool() {
+		return
+	}
+
+	if xybEncoded && !br.readBool() {
+		for i := 0; i < 16; i++ {
+			br.readF16()
+		}
+	}
+
+	mask := br.readBits(3)
+	for bit, count := range []int{15, 55, 210} {
+		if mask&(1<<bit) != 0 {
+			for i := 0; i < count; i++ {
+				br.readF16()
+			}
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package jxlmeta
+
+import (
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"math"
+)
+
+const iccHeaderSize = 128
+const iccNumContexts = 41
+const maxICCEncodedSize = 1 << 28
+
+var iccTagStrings = []string{"cprt", "wtpt", "bkpt", "rXYZ", "gXYZ", "bXYZ", "kXYZ", "rTRC", "gTRC", "bTRC", "kTRC", "chad", "desc", "chrm", "dmnd", "dmdd", "lumi"}
+var iccTypeStrings = []string{"XYZ ", "desc", "text", "mluc", "para", "curv", "sf32", "gbd "}
+
+// readICCProfile reads and decompresses the ICC profile which follows the image
+// header when the colour encoding indicates one is present.
+func readICCProfile(br *bitReader, limits meta.Limits) ([]byte, error) {
+	encSize := br.readU64()
+	if br.err != nil {
+		return nil, br.err
+	}
+	if encSize > maxICCEncodedSize {
+		return nil, binary.Errorf(meta.ErrMalformed, "encoded ICC profile size of %d is too large", encSize)
+	}
+	if max := limits.MaxDecompressedSize; max > 0 && encSize > uint64(max) {
+		return nil, &meta.LimitError{Limit: "MaxDecompressedSize", Value: max}
+	}
+
+	code, err := readEntropyCode(br, iccNumContexts)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newEntropyDecoder(br, code)
+	enc := make([]byte, 0, encSize)
+	for i := 0; i < int(encSize) && br.err == nil; i++ {
+		var b1, b2 byte
+		if i > 0 {
+			b1 = enc[i-1]
+		}
+		if i > 1 {
+			b2 = enc[i-2]
+		}
+		v := d.readUint(br, iccContext(i, b1, b2))
+		if v > 255 {
+			return nil, binary.Errorf(meta.ErrMalformed, "invalid ICC profile byte value %d", v)
+		}
+		enc = append(enc, byte(v))
+	}
+	if br.err != nil {
+		return nil, br.err
+	}
+	if !d.finished() {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid ICC profile stream")
+	}
+
+	// The size of the profile is checked before it is reconstructed
+	pos := 0
+	if len(enc) > 0 {
+		if size := readVarInt(enc, &pos); size <= math.MaxUint32 {
+			if err := limits.CheckICCProfileSize(int64(size)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return unpredictICC(enc)
+}
+
+func iccContext(i int, b1, b2 byte) int {
+	if i <= 128 {
+		return 0
+	}
+	return 1 + byteKind1(b1) + byteKind2(b2)*8
+}
+
+func byteKind1(b byte) int {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z':
+		return 0
+	case '0' <= b && b <= '9', b == '.', b == ',':
+		return 1
+	case b == 0:
+		return 2
+	case b == 1:
+		return 3
+	case b < 16:
+		return 4
+	case b == 255:
+		return 6
+	case b > 240:
+		return 5
+	default:
+		return 7
+	}
+}
+
+func byteKind2(b byte) int {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z':
+		return 0
+	case '0' <= b && b <= '9', b == '.', b == ',':
+		return 1
+	case b < 16:
+		return 2
+	case b > 240:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func readVarInt(data []byte, pos *int) uint64 {
+	v := uint64(0)
+	for shift := uint(0); *pos < len(data) && shift < 70; shift += 7 {
+		b := data[*pos]
+		*pos++
+		v |= uint64(b&127) << shift
+		if b&128 == 0 {
+			break
+		}
+	}
+	return v
+}
+
+func appendU32(data []byte, v uint64) []byte {
+	return append(data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// shuffle transposes data which was stored as interleaved columns of the
+// specified width.
+func shuffle(data []byte, width int) {
+	height := (len(data) + width - 1) / width
+	result := make([]byte, len(data))
+
+	for i, j, s := 0, 0, 0; i < len(data); i++ {
+		result[i] = data[j]
+		j += height
+		if j >= len(data) {
+			s++
+			j = s
+		}
+	}
+
+	copy(data, result)
+}
+
+func predictValue(p1, p2, p3 uint32, order int) uint32 {
+	switch order {
+	case 0:
+		return p1
+	case 1:
+		return 2*p1 - p2
+	default:
+		return 3*p1 - 3*p2 + p3
+	}
+}
+
+func linearPredictICCValue(data []byte, start, i, stride, width, order int) byte {
+	readBig := func(p int) uint32 {
+		v := uint32(0)
+		for k := 0; k < width; k++ {
+			v = v<<8 | uint32(data[p+k])
+		}
+		return v
+	}
+
+	p := start + i&^(width-1)
+	pred := predictValue(readBig(p-stride), readBig(p-stride*2), readBig(p-stride*3), order)
+	shiftBytes := width - 1 - i&(width-1)
+	return byte(pred >> (8 * uint(shiftBytes)))
+}
+
+func initialICCHeaderPrediction(size uint64) []byte {
+	header := make([]byte, iccHeaderSize)
+	appendU32(header[:0], size)
+	header[8] = 4
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	copy(header[68:], []byte{0, 0, 246, 214, 0, 1, 0, 0, 0, 0, 211, 45})
+	return header
+}
+
+func predictICCHeader(icc []byte, header []byte, pos int) {
+	switch {
+	case pos == 8 && len(icc) >= 8:
+		copy(header[80:84], icc[4:8])
+	case pos == 41 && len(icc) >= 41:
+		switch icc[40] {
+		case 'A':
+			copy(header[41:44], "PPL")
+		case 'M':
+			copy(header[41:44], "SFT")
+		}
+	case pos == 42 && len(icc) >= 42:
+		if icc[40] == 'S' && icc[41] == 'G' {
+			copy(header[42:44], "I ")
+		}
+		if icc[40] == 'S' && icc[41] == 'U' {
+			copy(header[42:44], "NW")
+		}
+	}
+}
+
+// unpredictICC reconstructs an ICC profile from its encoded form, which
+// consists of a stream of commands and a stream of data.
+func unpredictICC(enc []byte) ([]byte, error) {
+	errOutOfBounds := binary.Errorf(meta.ErrMalformed, "invalid encoded ICC profile")
+	pos := 0
+
+	if pos >= len(enc) {
+		return nil, errOutOfBounds
+	}
+	outputSize := readVarInt(enc, &pos)
+	if pos >= len(enc) {
+		return nil, errOutOfBounds
+	}
+	commandsSize := readVarInt(enc, &pos)
+	if outputSize > math.MaxUint32 || commandsSize > uint64(len(enc)-pos) {
+		return nil, errOutOfBounds
+	}
+
+	cpos := pos
+	commandsEnd := cpos + int(commandsSize)
+	pos = commandsEnd
+
+	readData := func(n uint64) ([]byte, error) {
+		if n > uint64(len(enc)-pos) {
+			return nil, errOutOfBounds
+		}
+		data := enc[pos : pos+int(n)]
+		pos += int(n)
+		return data, nil
+	}
+
+	readCommandVarInt := func() (uint64, error) {
+		if cpos >= commandsEnd {
+			return 0, errOutOfBounds
+		}
+		return readVarInt(enc, &cpos), nil
+	}
+
+	result := make([]byte, 0, outputSize)
+
+	header := initialICCHeaderPrediction(outputSize)
+	for i := 0; i < iccHeaderSize; i++ {
+		if uint64(len(result)) == outputSize {
+			if cpos != commandsEnd || pos != len(enc) {
+				return nil, errOutOfBounds
+			}
+			return result, nil
+		}
+		predictICCHeader(result, header, i)
+		if pos >= len(enc) {
+			return nil, errOutOfBounds
+		}
+		result = append(result, enc[pos]+header[i])
+		pos++
+	}
+	if uint64(len(result)) == outputSize && cpos == commandsEnd && pos == len(enc) {
+		return result, nil
+	}
+
+	numTags, err := readCommandVarInt()
+	if err != nil {
+		return nil, err
+	}
+
+	if numTags != 0 {
+		numTags--
+		if numTags > math.MaxUint32 {
+			return nil, errOutOfBounds
+		}
+		result = appendU32(result, numTags)
+
+		prevTagStart := iccHeaderSize + numTags*12
+		prevTagSize := uint64(0)
+
+		for cpos < commandsEnd {
+			if uint64(len(result)) > outputSize {
+				return nil, errOutOfBounds
+			}
+
+			command := enc[cpos]
+			cpos++
+			tagCode := command & 63
+
+			var tag string
+			switch {
+			case tagCode == 0:
+			case tagCode == 1:
+				keyword, err := readData(4)
+				if err != nil {
+					return nil, err
+				}
+				tag = string(keyword)
+			case tagCode == 2:
+				tag = "rTRC"
+			case tagCode == 3:
+				tag = "rXYZ"
+			case int(tagCode)-4 < len(iccTagStrings):
+				tag = iccTagStrings[tagCode-4]
+			default:
+				return nil, binary.Errorf(meta.ErrMalformed, "unknown ICC tag code %d", tagCode)
+			}
+			if tagCode == 0 {
+				break
+			}
+
+			result = append(result, tag...)
+
+			tagSize := prevTagSize
+			switch tag {
+			case "rXYZ", "gXYZ", "bXYZ", "kXYZ", "wtpt", "bkpt", "lumi":
+				tagSize = 20
+			}
+
+			tagStart := prevTagStart + prevTagSize
+			if command&64 != 0 {
+				if tagStart, err = readCommandVarInt(); err != nil {
+					return nil, err
+				}
+			}
+			if command&128 != 0 {
+				if tagSize, err = readCommandVarInt(); err != nil {
+					return nil, err
+				}
+			}
+			if tagStart > math.MaxUint32 || tagSize > math.MaxUint32 || tagStart+tagSize*2 > math.MaxUint32 {
+				return nil, errOutOfBounds
+			}
+
+			result = appendU32(appendU32(result, tagStart), tagSize)
+			prevTagStart = tagStart
+			prevTagSize = tagSize
+
+			switch tagCode {
+			case 2:
+				result = appendU32(appendU32(append(result, "gTRC"...), tagStart), tagSize)
+				result = appendU32(appendU32(append(result, "bTRC"...), tagStart), tagSize)
+			case 3:
+				result = appendU32(appendU32(append(result, "gXYZ"...), tagStart+tagSize), tagSize)
+				result = appendU32(appendU32(append(result, "bXYZ"...), tagStart+tagSize*2), tagSize)
+			}
+		}
+	}
+
+	for cpos < commandsEnd {
+		if uint64(len(result)) > outputSize {
+			return nil, errOutOfBounds
+		}
+
+		command := enc[cpos]
+		cpos++
+
+		switch {
+		case command == 1:
+			num, err := readCommandVarInt()
+			if err != nil {
+				return nil, err
+			}
+			data, err := readData(num)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, data...)
+
+		case command == 2 || command == 3:
+			num, err := readCommandVarInt()
+			if err != nil {
+				return nil, err
+			}
+			data, err := readData(num)
+			if err != nil {
+				return nil, err
+			}
+			shuffled := append([]byte(nil), data...)
+			shuffle(shuffled, 2*int(command-1))
+			result = append(result, shuffled...)
+
+		case command == 4:
+			if cpos+2 > commandsEnd {
+				return nil, errOutOfBounds
+			}
+			flags := enc[cpos]
+			cpos++
+
+			width := int(flags&3) + 1
+			order := int(flags&12) >> 2
+			if width == 3 || order == 3 {
+				return nil, binary.Errorf(meta.ErrMalformed, "invalid ICC prediction command")
+			}
+
+			stride := uint64(width)
+			if flags&16 != 0 {
+				if stride, err = readCommandVarInt(); err != nil {
+					return nil, err
+				}
+				if stride < uint64(width) {
+					return nil, binary.Errorf(meta.ErrMalformed, "invalid ICC prediction stride")
+				}
+			}
+			if len(result) == 0 || uint64(len(result)-1)>>2 < stride {
+				return nil, binary.Errorf(meta.ErrMalformed, "invalid ICC prediction stride")
+			}
+
+			num, err := readCommandVarInt()
+			if err != nil {
+				return nil, err
+			}
+			data, err := readData(num)
+			if err != nil {
+				return nil, err
+			}
+			shuffled := append([]byte(nil), data...)
+			if width > 1 {
+				shuffle(shuffled, width)
+			}
+
+			start := len(result)
+			for i, b := range shuffled {
+				result = append(result, linearPredictICCValue(result, start, i, int(stride), width, order)+b)
+			}
+
+		case command == 10:
+			result = append(result, "XYZ \x00\x00\x00\x00"...)
+			data, err := readData(12)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, data...)
+
+		case command >= 16 && int(command)-16 < len(iccTypeStrings):
+			result = append(result, iccTypeStrings[command-16]...)
+			result = append(result, 0, 0, 0, 0)
+
+		default:
+			return nil, binary.Errorf(meta.ErrMalformed, "unknown ICC command %d", command)
+		}
+	}
+
+	if pos != len(enc) || uint64(len(result)) != outputSize {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid encoded ICC profile size")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,259 @@
+package jxlmeta
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/icc"
+	"io"
+)
+
+// Format specifies the image format handled by this package
+var Format = meta.ImageFormat("JPEG XL")
+
+var codestreamSignature = [2]byte{0xFF, 0x0A}
+
+var whitePoints = map[uint32]ciexyy.Color{
+	whitePointD65: {X: 0.3127, Y: 0.329, YY: 1},
+	whitePointE:   {X: 1.0 / 3, Y: 1.0 / 3, YY: 1},
+	whitePointDCI: {X: 0.314, Y: 0.351, YY: 1},
+}
+
+var primaries = map[uint32]meta.Primaries{
+	primariesSRGB: {
+		Red:   ciexyy.Color{X: 0.64, Y: 0.33, YY: 1},
+		Green: ciexyy.Color{X: 0.3, Y: 0.6, YY: 1},
+		Blue:  ciexyy.Color{X: 0.15, Y: 0.06, YY: 1},
+	},
+	primaries2100: {
+		Red:   ciexyy.Color{X: 0.708, Y: 0.292, YY: 1},
+		Green: ciexyy.Color{X: 0.17, Y: 0.797, YY: 1},
+		Blue:  ciexyy.Color{X: 0.131, Y: 0.046, YY: 1},
+	},
+	primariesP3: {
+		Red:   ciexyy.Color{X: 0.68, Y: 0.32, YY: 1},
+		Green: ciexyy.Color{X: 0.265, Y: 0.69, YY: 1},
+		Blue:  ciexyy.Color{X: 0.15, Y: 0.06, YY: 1},
+	},
+}
+
+// Load loads the metadata for a JPEG XL image stream, which may be either a
+// bare codestream or an ISOBMFF-based container.
+//
+// Only as much of the stream is consumed as necessary to extract the metadata;
+// the returned stream contains a buffered copy of the consumed data such that
+// reading from it will produce the same results as fully reading the input
+// stream. This provides a convenient way to load the full image after loading
+// the metadata.
+//
+// An error is returned if basic metadata could not be extracted. The returned
+// stream still provides the full image data.
+func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a JPEG XL image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
+	rewindBuffer := &bytes.Buffer{}
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(bufio.NewReader(lr), limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a JPEG XL image stream which supports
+// seeking.
+//
+// Reading begins at the current position of the stream, and container boxes
+// which don't contain the codestream are skipped by seeking past them. The
+// stream is returned to its original position afterwards, so that the full
+// image can then be loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a JPEG XL image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a JPEG XL image of the specified size in
+// bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a JPEG XL image of the
+// specified size in bytes as per LoadReaderAt, subject to the specified limits
+// rather than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
+}
+
+func extractMetadata(r binary.Reader, limits meta.Limits) (md *meta.Data, err error) {
+	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
+
+	defer func() {
+		if r := recover(); r != nil {
+			md = nil
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
+		}
+		err = binary.Truncated(err)
+	}()
+
+	var sig [2]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+	}
+
+	var codestream io.ByteReader = r
+
+	if sig != codestreamSignature {
+		var containerSig [12]byte
+		copy(containerSig[:], sig[:])
+		if _, err := io.ReadFull(r, containerSig[2:]); err != nil || containerSig != containerSignature {
+			return nil, binary.Errorf(meta.ErrFormatMismatch, "invalid JPEG XL signature")
+		}
+
+		codestream = &codestreamReader{r: r, limits: limits}
+		for i := range sig {
+			if sig[i], err = codestream.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		if sig != codestreamSignature {
+			return nil, binary.Errorf(meta.ErrFormatMismatch, "invalid JPEG XL codestream signature")
+		}
+	}
+
+	br := &bitReader{r: codestream}
+	h, err := readImageHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	md.PixelWidth = h.width
+	md.PixelHeight = h.height
+	md.BitsPerComponent = h.bitsPerSample
+
+	// The number of frames of an animation isn't known without parsing the
+	// frames themselves
+	if h.animated {
+		md.Animated = true
+		md.LoopCount = h.loopCount
+	} else {
+		md.FrameCount = 1
+		md.LoopCount = 1
+	}
+
+	colourChannels := uint32(3)
+	switch h.colour.colourSpace {
+	case colourSpaceGrey:
+		md.ColorModel = meta.ColorModelGreyscale
+		colourChannels = 1
+	case colourSpaceRGB, colourSpaceXYB:
+		md.ColorModel = meta.ColorModelRGB
+	}
+
+	md.ChannelCount = colourChannels + uint32(len(h.extraChannels))
+	for _, ec := range h.extraChannels {
+		switch ec.channelType {
+		case extraChannelAlpha:
+			md.HasAlpha = true
+		case extraChannelBlack:
+			md.ColorModel = meta.ColorModelCMYK
+		}
+	}
+
+	if h.colour.wantICC {
+		profile, err := readICCProfile(br, limits)
+		if err != nil {
+			md.SetICCProfileError(err)
+		} else {
+			md.SetICCProfileData(profile)
+		}
+	} else {
+		describeColourEncoding(&h.colour, md)
+	}
+
+	return md, nil
+}
+
+// describeColourEncoding populates the metadata with the details of an
+// enumerated (non-ICC) colour encoding.
+func describeColourEncoding(ce *colourEncoding, md *meta.Data) {
+	if ce.colourSpace == colourSpaceXYB {
+		return
+	}
+
+	if ce.whitePoint == whitePointCustom {
+		md.WhitePoint = &ciexyy.Color{X: float32(ce.white[0]), Y: float32(ce.white[1]), YY: 1}
+	} else if wp, ok := whitePoints[ce.whitePoint]; ok {
+		md.WhitePoint = &wp
+	}
+
+	if ce.colourSpace == colourSpaceRGB {
+		if ce.primaries == primariesCustom {
+			md.Primaries = &meta.Primaries{
+				Red:   ciexyy.Color{X: float32(ce.red[0]), Y: float32(ce.red[1]), YY: 1},
+				Green: ciexyy.Color{X: float32(ce.green[0]), Y: float32(ce.green[1]), YY: 1},
+				Blue:  ciexyy.Color{X: float32(ce.blue[0]), Y: float32(ce.blue[1]), YY: 1},
+			}
+		} else if p, ok := primaries[ce.primaries]; ok {
+			md.Primaries = &p
+		}
+	}
+
+	if ce.haveGamma {
+		md.Gamma = 1e7 / float64(ce.gamma)
+	}
+
+	intent := icc.RenderingIntent(ce.renderingIntent)
+	md.RenderingIntent = &intent
+
+	// Enumerated transfer functions and (most) primaries use the same code
+	// points as ITU-T H.273
+	if ce.colourSpace == colourSpaceRGB && !ce.haveGamma {
+		var colourPrimaries uint16
+		switch {
+		case ce.primaries == primariesSRGB && ce.whitePoint == whitePointD65:
+			colourPrimaries = 1
+		case ce.primaries == primaries2100 && ce.whitePoint == whitePointD65:
+			colourPrimaries = 9
+		case ce.primaries == primariesP3 && ce.whitePoint == whitePointD65:
+			colourPrimaries = 12
+		case ce.primaries == primariesP3 && ce.whitePoint == whitePointDCI:
+			colourPrimaries = 11
+		default:
+			return
+		}
+
+		switch ce.transferFunction {
+		case transferFunction709, transferFunctionUnknown, transferFunctionLinear, transferFunctionSRGB, transferFunctionPQ, transferFunctionDCI, transferFunctionHLG:
+		default:
+			return
+		}
+
+		md.CICP = &meta.CICP{
+			ColorPrimaries:          colourPrimaries,
+			TransferCharacteristics: uint16(ce.transferFunction),
+			FullRange:               true,
+		}
+	}
+}
@@ -0,0 +1,519 @@
+package jxlmeta
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/icc"
+	"math"
+	"testing"
+)
+
+type bitWriter struct {
+	data  []byte
+	count uint
+}
+
+func (bw *bitWriter) writeBits(v uint64, n uint) {
+	for i := uint(0); i < n; i++ {
+		if bw.count%8 == 0 {
+			bw.data = append(bw.data, 0)
+		}
+		bw.data[len(bw.data)-1] |= byte((v>>i)&1) << (bw.count % 8)
+		bw.count++
+	}
+}
+
+func (bw *bitWriter) writeBool(b bool) {
+	if b {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+}
+
+func (bw *bitWriter) writeU32(v uint32, dists ...u32Dist) {
+	for sel, d := range dists {
+		if v >= d.offset && uint64(v-d.offset) < 1<<d.bits {
+			bw.writeBits(uint64(sel), 2)
+			bw.writeBits(uint64(v-d.offset), d.bits)
+			return
+		}
+	}
+	panic("value not representable")
+}
+
+func (bw *bitWriter) writeEnum(v uint32) {
+	bw.writeU32(v, val(0), val(1), bitsOffset(4, 2), bitsOffset(6, 18))
+}
+
+func (bw *bitWriter) writeU64(v uint64) {
+	if v == 0 {
+		bw.writeBits(0, 2)
+		return
+	}
+	bw.writeBits(3, 2)
+	bw.writeBits(v&0xFFF, 12)
+	for v >>= 12; v != 0; v >>= 8 {
+		bw.writeBool(true)
+		bw.writeBits(v&0xFF, 8)
+	}
+	bw.writeBool(false)
+}
+
+func (bw *bitWriter) writeU8(v uint32) {
+	if v == 0 {
+		bw.writeBool(false)
+		return
+	}
+	n := uint(0)
+	for v>>(n+1) != 0 {
+		n++
+	}
+	bw.writeBool(true)
+	bw.writeBits(uint64(n), 3)
+	bw.writeBits(uint64(v-1<<n), n)
+}
+
+func appendVarInt(data []byte, v int) []byte {
+	for ; v >= 128; v >>= 7 {
+		data = append(data, byte(v&127|128))
+	}
+	return append(data, byte(v))
+}
+
+func (bw *bitWriter) writeCustomXY(x, y float64) {
+	for _, v := range []float64{x, y} {
+		i := int32(math.Round(v * 1e6))
+		packed := uint32(i) << 1
+		if i < 0 {
+			packed = uint32(-i)<<1 - 1
+		}
+		bw.writeU32(packed, bitsOffset(19, 0), bitsOffset(19, 524288), bitsOffset(20, 1048576), bitsOffset(21, 2097152))
+	}
+}
+
+// predictedICCHeaderByte returns the predicted value of a byte of an ICC
+// profile header, given the profile size and the preceding bytes. This follows
+// the structure of PredictHeader in libjxl rather than using the decoder's own
+// prediction, so that encoding and decoding aren't checked against each other.
+func predictedICCHeaderByte(icc []byte, size int, pos int) byte {
+	switch {
+	case pos < 4:
+		return byte(size >> uint((3-pos)*8))
+	case pos == 8:
+		return 4
+	case pos >= 12 && pos < 24:
+		return "mntrRGB XYZ "[pos-12]
+	case pos >= 36 && pos < 40:
+		return "acsp"[pos-36]
+	case pos >= 41 && pos < 44 && icc[40] == 'A':
+		return "PPL"[pos-41]
+	case pos >= 41 && pos < 44 && icc[40] == 'M':
+		return "SFT"[pos-41]
+	case pos >= 42 && pos < 44 && icc[40] == 'S' && icc[41] == 'G':
+		return "I "[pos-42]
+	case pos >= 42 && pos < 44 && icc[40] == 'S' && icc[41] == 'U':
+		return "NW"[pos-42]
+	case pos >= 68 && pos < 80:
+		return []byte{0, 0, 246, 214, 0, 1, 0, 0, 0, 0, 211, 45}[pos-68]
+	case pos >= 80 && pos < 84:
+		return icc[pos-76]
+	}
+	return 0
+}
+
+// writeEncodedICC writes an ICC profile using a single prefix code, or a flat
+// ANS distribution, for all contexts. The encoded profile consists of the
+// header followed by a single insert command for the remaining bytes.
+func (bw *bitWriter) writeEncodedICC(profile []byte, useANS bool) {
+	tail := len(profile) - iccHeaderSize
+
+	var commands []byte
+	commands = append(commands, 0, 1, byte(tail))
+
+	enc := appendVarInt(appendVarInt(nil, len(profile)), len(commands))
+	enc = append(enc, commands...)
+	for i := 0; i < iccHeaderSize; i++ {
+		enc = append(enc, profile[i]-predictedICCHeaderByte(profile, len(profile), i))
+	}
+	enc = append(enc, profile[iccHeaderSize:]...)
+
+	bw.writeU64(uint64(len(enc)))
+
+	// No LZ77, and a simple context map putting all contexts in one cluster
+	bw.writeBool(false)
+	bw.writeBool(true)
+	bw.writeBits(0, 2)
+
+	if useANS {
+		bw.writeBool(false)
+		bw.writeBits(3, 2)
+		bw.writeBits(8, 4)
+
+		// Flat histogram over 256 symbols
+		bw.writeBool(false)
+		bw.writeBool(true)
+		bw.writeU8(255)
+
+		state := uint32(ansSignature)
+		var words []uint16
+		for i := len(enc) - 1; i >= 0; i-- {
+			if state>>(32-ansLogTableSize) >= 16 {
+				words = append(words, uint16(state))
+				state >>= 16
+			}
+			state = (state/16)<<ansLogTableSize + uint32(enc[i])*16 + state%16
+		}
+		bw.writeBits(uint64(state), 32)
+		for i := len(words) - 1; i >= 0; i-- {
+			bw.writeBits(uint64(words[i]), 16)
+		}
+
+	} else {
+		bw.writeBool(true)
+		bw.writeBits(15, 4)
+
+		// Alphabet size of 256 with all code lengths being 8
+		bw.writeBool(true)
+		bw.writeBits(7, 4)
+		bw.writeBits(127, 7)
+		bw.writeBits(0, 2)
+		for _, symbol := range codeLengthCodeOrder {
+			if symbol == 8 {
+				bw.writeBits(7, 4)
+			} else {
+				bw.writeBits(0, 2)
+			}
+		}
+
+		for _, b := range enc {
+			for i := 7; i >= 0; i-- {
+				bw.writeBits(uint64(b>>uint(i)), 1)
+			}
+		}
+	}
+}
+
+type testHeader struct {
+	width, height     uint32
+	bitDepth          uint32
+	extraChannelTypes []uint32
+	writeColour       func(bw *bitWriter)
+}
+
+func (th testHeader) codestream(iccProfile []byte, useANS bool) []byte {
+	bw := &bitWriter{}
+	bw.writeBits(0x0AFF, 16)
+
+	// Size header
+	bw.writeBool(false)
+	bw.writeU32(th.height, bitsOffset(9, 1), bitsOffset(13, 1), bitsOffset(18, 1), bitsOffset(30, 1))
+	bw.writeBits(0, 3)
+	bw.writeU32(th.width, bitsOffset(9, 1), bitsOffset(13, 1), bitsOffset(18, 1), bitsOffset(30, 1))
+
+	// Image metadata
+	bw.writeBool(false)
+	bw.writeBool(false)
+	bw.writeBool(false)
+	bw.writeU32(th.bitDepth, val(8), val(10), val(12), bitsOffset(6, 1))
+	bw.writeBool(true)
+	bw.writeU32(uint32(len(th.extraChannelTypes)), val(0), val(1), bitsOffset(4, 2), bitsOffset(12, 1))
+	for _, ecType := range th.extraChannelTypes {
+		bw.writeBool(false)
+		bw.writeEnum(ecType)
+		bw.writeBool(false)
+		bw.writeU32(8, val(8), val(10), val(12), bitsOffset(6, 1))
+		bw.writeU32(0, val(0), val(3), val(4), bitsOffset(3, 1))
+		bw.writeU32(4, val(0), bitsOffset(4, 0), bitsOffset(5, 16), bitsOffset(10, 48))
+		bw.writeBits(0x656D616E, 32)
+		if ecType == extraChannelAlpha {
+			bw.writeBool(false)
+		}
+	}
+	bw.writeBool(true)
+	th.writeColour(bw)
+	bw.writeU64(0)
+
+	// Custom transform data
+	bw.writeBool(true)
+
+	if iccProfile != nil {
+		bw.writeEncodedICC(iccProfile, useANS)
+	}
+
+	bw.writeBits(0xFFFFFFFF, 32)
+
+	return bw.data
+}
+
+func makeBox(boxType string, contents ...[]byte) []byte {
+	data := bytes.Join(contents, nil)
+	buf := &bytes.Buffer{}
+	binary.WriteU32Big(buf, uint32(len(data)+8))
+	buf.WriteString(boxType)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestExtractMetadata(t *testing.T) {
+
+	iccProfile := make([]byte, 200)
+	copy(iccProfile, []byte{0, 0, 0, 200, 'l', 'c', 'm', 's', 4, 0x30, 0, 0})
+	copy(iccProfile[12:], "mntrRGB XYZ ")
+	copy(iccProfile[36:], "acspAPPL")
+	for i := iccHeaderSize; i < len(iccProfile); i++ {
+		iccProfile[i] = byte(i * 7)
+	}
+
+	enumeratedRGB := func(bw *bitWriter) {
+		bw.writeBool(false)
+		bw.writeBool(false)
+		bw.writeEnum(colourSpaceRGB)
+		bw.writeEnum(whitePointD65)
+		bw.writeEnum(primariesP3)
+		bw.writeBool(false)
+		bw.writeEnum(transferFunctionPQ)
+		bw.writeEnum(0)
+	}
+
+	wantICC := func(colourSpace uint32) func(bw *bitWriter) {
+		return func(bw *bitWriter) {
+			bw.writeBool(false)
+			bw.writeBool(true)
+			bw.writeEnum(colourSpace)
+		}
+	}
+
+	checkICCProfile := func(t *testing.T, md *meta.Data) {
+		iccData, err := md.ICCProfileData()
+		if err != nil {
+			t.Errorf("Expected ICC profile data but got error: %v", err)
+		} else if !bytes.Equal(iccProfile, iccData) {
+			t.Errorf("Expected ICC profile data %v but got %v", iccProfile, iccData)
+		}
+	}
+
+	t.Run("returns metadata for enumerated colour encoding", func(t *testing.T) {
+		th := testHeader{width: 1920, height: 1080, bitDepth: 10, extraChannelTypes: []uint32{extraChannelAlpha}, writeColour: enumeratedRGB}
+
+		md, err := extractMetadata(bytes.NewReader(th.codestream(nil, false)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if expected, actual := Format, md.Format; expected != actual {
+			t.Errorf("Expected format %s but got %s", expected, actual)
+		}
+		if md.PixelWidth != 1920 || md.PixelHeight != 1080 {
+			t.Errorf("Expected dimensions 1920x1080 but got %dx%d", md.PixelWidth, md.PixelHeight)
+		}
+		if expected, actual := uint32(10), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected %d bits per component but got %d", expected, actual)
+		}
+		if expected, actual := meta.ColorModelRGB, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		if expected, actual := uint32(4), md.ChannelCount; expected != actual {
+			t.Errorf("Expected %d channels but got %d", expected, actual)
+		}
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+		if md.WhitePoint == nil || md.WhitePoint.X != 0.3127 || md.WhitePoint.Y != 0.329 {
+			t.Errorf("Expected D65 white point but got %+v", md.WhitePoint)
+		}
+		if md.Primaries == nil || md.Primaries.Red.X != 0.68 || md.Primaries.Green.Y != 0.69 {
+			t.Errorf("Expected P3 primaries but got %+v", md.Primaries)
+		}
+		if expected := (meta.CICP{ColorPrimaries: 12, TransferCharacteristics: 16, FullRange: true}); md.CICP == nil || *md.CICP != expected {
+			t.Errorf("Expected CICP %+v but got %+v", expected, md.CICP)
+		}
+		if md.RenderingIntent == nil || *md.RenderingIntent != icc.PerceptualRenderingIntent {
+			t.Errorf("Expected perceptual rendering intent but got %v", md.RenderingIntent)
+		}
+		if iccData, err := md.ICCProfileData(); iccData != nil || err != nil {
+			t.Errorf("Expected no ICC profile but got %v, %v", iccData, err)
+		}
+	})
+
+	t.Run("returns custom chromaticities and gamma", func(t *testing.T) {
+		th := testHeader{width: 100, height: 80, bitDepth: 8, writeColour: func(bw *bitWriter) {
+			bw.writeBool(false)
+			bw.writeBool(false)
+			bw.writeEnum(colourSpaceRGB)
+			bw.writeEnum(whitePointCustom)
+			bw.writeCustomXY(0.3457, 0.3585)
+			bw.writeEnum(primariesCustom)
+			bw.writeCustomXY(0.7347, 0.2653)
+			bw.writeCustomXY(0.1596, 0.8404)
+			bw.writeCustomXY(0.0366, 0.0001)
+			bw.writeBool(true)
+			bw.writeBits(4545455, 24)
+			bw.writeEnum(3)
+		}}
+
+		md, err := extractMetadata(bytes.NewReader(th.codestream(nil, false)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if md.WhitePoint == nil || md.WhitePoint.X != 0.3457 || md.WhitePoint.Y != 0.3585 {
+			t.Errorf("Expected D50 white point but got %+v", md.WhitePoint)
+		}
+		if md.Primaries == nil || md.Primaries.Red.X != 0.7347 || md.Primaries.Blue.Y != 0.0001 {
+			t.Errorf("Expected custom primaries but got %+v", md.Primaries)
+		}
+		if math.Abs(md.Gamma-2.2) > 1e-6 {
+			t.Errorf("Expected gamma of 2.2 but got %v", md.Gamma)
+		}
+		if md.CICP != nil {
+			t.Errorf("Expected no CICP but got %+v", md.CICP)
+		}
+		if md.RenderingIntent == nil || *md.RenderingIntent != icc.AbsoluteColorimetricRenderingIntent {
+			t.Errorf("Expected absolute colorimetric rendering intent but got %v", md.RenderingIntent)
+		}
+		if md.HasAlpha {
+			t.Errorf("Expected image not to have alpha")
+		}
+	})
+
+	t.Run("decompresses ICC profile using prefix codes", func(t *testing.T) {
+		th := testHeader{width: 640, height: 480, bitDepth: 8, writeColour: wantICC(colourSpaceGrey)}
+
+		md, err := extractMetadata(bytes.NewReader(th.codestream(iccProfile, false)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if expected, actual := meta.ColorModelGreyscale, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		if expected, actual := uint32(1), md.ChannelCount; expected != actual {
+			t.Errorf("Expected %d channels but got %d", expected, actual)
+		}
+		if md.WhitePoint != nil || md.CICP != nil || md.RenderingIntent != nil {
+			t.Errorf("Expected no enumerated colour encoding details when an ICC profile is present")
+		}
+		checkICCProfile(t, md)
+	})
+
+	t.Run("decompresses ICC profile using ANS", func(t *testing.T) {
+		th := testHeader{width: 640, height: 480, bitDepth: 8, extraChannelTypes: []uint32{extraChannelBlack}, writeColour: wantICC(colourSpaceRGB)}
+
+		md, err := extractMetadata(bytes.NewReader(th.codestream(iccProfile, true)), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if expected, actual := meta.ColorModelCMYK, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		checkICCProfile(t, md)
+	})
+
+	t.Run("reads codestream split across container boxes", func(t *testing.T) {
+		th := testHeader{width: 3000, height: 2000, bitDepth: 12, writeColour: wantICC(colourSpaceRGB)}
+		codestream := th.codestream(iccProfile, true)
+
+		data := bytes.Join([][]byte{
+			containerSignature[:],
+			makeBox("ftyp", []byte("jxl "), []byte{0, 0, 0, 0}, []byte("jxl ")),
+			makeBox("jxlp", []byte{0, 0, 0, 0}, codestream[:7]),
+			makeBox("Exif", []byte{0, 0, 0, 0}, []byte("exif data")),
+			makeBox("jxlp", []byte{0x80, 0, 0, 1}, codestream[7:]),
+		}, nil)
+
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if md.PixelWidth != 3000 || md.PixelHeight != 2000 {
+			t.Errorf("Expected dimensions 3000x2000 but got %dx%d", md.PixelWidth, md.PixelHeight)
+		}
+		if expected, actual := uint32(12), md.BitsPerComponent; expected != actual {
+			t.Errorf("Expected %d bits per component but got %d", expected, actual)
+		}
+		checkICCProfile(t, md)
+	})
+
+	t.Run("derives width from aspect ratio", func(t *testing.T) {
+		bw := &bitWriter{}
+		bw.writeBits(0x0AFF, 16)
+		bw.writeBool(true)
+		bw.writeBits(144/8-1, 5)
+		bw.writeBits(5, 3)
+		bw.writeBool(true)
+		bw.writeBool(true)
+
+		md, err := extractMetadata(bytes.NewReader(bw.data), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		if md.PixelWidth != 256 || md.PixelHeight != 144 {
+			t.Errorf("Expected dimensions 256x144 but got %dx%d", md.PixelWidth, md.PixelHeight)
+		}
+		if expected := (meta.CICP{ColorPrimaries: 1, TransferCharacteristics: 13, FullRange: true}); md.CICP == nil || *md.CICP != expected {
+			t.Errorf("Expected default sRGB CICP %+v but got %+v", expected, md.CICP)
+		}
+	})
+
+	t.Run("returns error for invalid signature", func(t *testing.T) {
+		_, err := extractMetadata(bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xE0}), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for truncated header", func(t *testing.T) {
+		th := testHeader{width: 1920, height: 1080, bitDepth: 10, writeColour: enumeratedRGB}
+		_, err := extractMetadata(bytes.NewReader(th.codestream(nil, false)[:6]), meta.DefaultLimits)
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
+}
+
+func TestUnpredictICC(t *testing.T) {
+
+	t.Run("expands abbreviated tag table and typed content", func(t *testing.T) {
+		profileSize := iccHeaderSize + 4 + 3*12 + 20
+		header := make([]byte, iccHeaderSize)
+		header[3] = byte(profileSize)
+		copy(header[4:], "appl")
+		copy(header[40:], "MSFT")
+
+		data := make([]byte, iccHeaderSize)
+		for i := range data {
+			data[i] = header[i] - predictedICCHeaderByte(header, profileSize, i)
+		}
+		data = append(data, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}...)
+
+		commands := []byte{4, 2 | 128, 12, 0, 10}
+		enc := appendVarInt(appendVarInt(nil, profileSize), len(commands))
+		enc = append(enc, commands...)
+		enc = append(enc, data...)
+
+		result, err := unpredictICC(enc)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+
+		expectedTags := []byte{
+			0, 0, 0, 3,
+			'r', 'T', 'R', 'C', 0, 0, 0, 164, 0, 0, 0, 12,
+			'g', 'T', 'R', 'C', 0, 0, 0, 164, 0, 0, 0, 12,
+			'b', 'T', 'R', 'C', 0, 0, 0, 164, 0, 0, 0, 12,
+			'X', 'Y', 'Z', ' ', 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12,
+		}
+		if expected, actual := header, result[:iccHeaderSize]; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected header %v but got %v", expected, actual)
+		}
+		if expected, actual := expectedTags, result[iccHeaderSize:]; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	})
+}
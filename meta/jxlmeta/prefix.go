@@ -0,0 +1,215 @@
+package jxlmeta
+
+import (
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+const maxPrefixCodeLength = 15
+
+// codeLengthCodeOrder is the order in which the code lengths of the code
+// length alphabet are stored.
+var codeLengthCodeOrder = [18]int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// codeLengthCodeLengths is the fixed prefix code used to read the code lengths
+// of the code length alphabet.
+var codeLengthCodeLengths = newPrefixCode([]uint8{2, 4, 3, 2, 2, 4})
+
+// prefixCode is a canonical prefix (Huffman) code, decoded one bit at a time.
+type prefixCode struct {
+	counts  [maxPrefixCodeLength + 1]uint16
+	symbols []uint16
+
+	// single is the only symbol of a code which has just one symbol, which
+	// is decoded without consuming any bits, or -1.
+	single int
+}
+
+func newPrefixCode(lengths []uint8) *prefixCode {
+	pc := &prefixCode{single: -1}
+
+	nonZero := 0
+	for symbol, length := range lengths {
+		if length != 0 {
+			pc.counts[length]++
+			pc.single = symbol
+			nonZero++
+		}
+	}
+	if nonZero != 1 {
+		pc.single = -1
+	}
+
+	for length := 1; length <= maxPrefixCodeLength; length++ {
+		for symbol, l := range lengths {
+			if int(l) == length {
+				pc.symbols = append(pc.symbols, uint16(symbol))
+			}
+		}
+	}
+
+	return pc
+}
+
+func (pc *prefixCode) readSymbol(br *bitReader) uint32 {
+	if pc.single >= 0 {
+		return uint32(pc.single)
+	}
+
+	code, first, index := 0, 0, 0
+	for length := 1; length <= maxPrefixCodeLength; length++ {
+		code |= int(br.readBits(1))
+		count := int(pc.counts[length])
+		if code-first < count {
+			return uint32(pc.symbols[index+code-first])
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+
+	if br.err == nil {
+		br.err = binary.Errorf(meta.ErrMalformed, "invalid prefix code")
+	}
+	return 0
+}
+
+func readPrefixCode(br *bitReader, alphabetSize int) (*prefixCode, error) {
+	if alphabetSize == 1 {
+		return newPrefixCode([]uint8{1}), nil
+	}
+
+	skip := int(br.readBits(2))
+	if skip == 1 {
+		return readSimplePrefixCode(br, alphabetSize)
+	}
+
+	var codeLengthLengths [18]uint8
+	space := 32
+	numCodes := 0
+	for i := skip; i < len(codeLengthCodeOrder) && space > 0; i++ {
+		v := uint8(codeLengthCodeLengths.readSymbol(br))
+		codeLengthLengths[codeLengthCodeOrder[i]] = v
+		if v != 0 {
+			space -= 32 >> v
+			numCodes++
+		}
+	}
+	if br.err != nil {
+		return nil, br.err
+	}
+	if numCodes != 1 && space != 0 {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid prefix code length code")
+	}
+
+	codeLengthCode := newPrefixCode(codeLengthLengths[:])
+	lengths := make([]uint8, alphabetSize)
+
+	prevLength := uint8(8)
+	repeat := 0
+	repeatLength := uint8(0)
+	space = 1 << maxPrefixCodeLength
+
+	for symbol := 0; symbol < alphabetSize && space > 0; {
+		length := uint8(codeLengthCode.readSymbol(br))
+		if br.err != nil {
+			return nil, br.err
+		}
+
+		if length < 16 {
+			repeat = 0
+			lengths[symbol] = length
+			symbol++
+			if length != 0 {
+				prevLength = length
+				space -= (1 << maxPrefixCodeLength) >> length
+			}
+			continue
+		}
+
+		extraBits := uint(length - 14)
+		newLength := uint8(0)
+		if length == 16 {
+			newLength = prevLength
+		}
+		if repeatLength != newLength {
+			repeat = 0
+			repeatLength = newLength
+		}
+
+		oldRepeat := repeat
+		if repeat > 0 {
+			repeat = (repeat - 2) << extraBits
+		}
+		repeat += int(br.readBits(extraBits)) + 3
+		delta := repeat - oldRepeat
+		if symbol+delta > alphabetSize {
+			return nil, binary.Errorf(meta.ErrMalformed, "invalid prefix code lengths")
+		}
+
+		for i := 0; i < delta; i++ {
+			lengths[symbol+i] = repeatLength
+		}
+		symbol += delta
+		if repeatLength != 0 {
+			space -= delta << (maxPrefixCodeLength - repeatLength)
+		}
+	}
+
+	if br.err != nil {
+		return nil, br.err
+	}
+	if space != 0 {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid prefix code lengths")
+	}
+
+	return newPrefixCode(lengths), nil
+}
+
+func readSimplePrefixCode(br *bitReader, alphabetSize int) (*prefixCode, error) {
+	maxBits := uint(0)
+	for 1<<maxBits < alphabetSize {
+		maxBits++
+	}
+
+	numSymbols := int(br.readBits(2)) + 1
+	symbols := make([]int, numSymbols)
+	for i := range symbols {
+		symbols[i] = int(br.readBits(maxBits))
+		if symbols[i] >= alphabetSize {
+			return nil, binary.Errorf(meta.ErrMalformed, "invalid prefix code symbol %d", symbols[i])
+		}
+		for j := 0; j < i; j++ {
+			if symbols[j] == symbols[i] {
+				return nil, binary.Errorf(meta.ErrMalformed, "duplicate prefix code symbol %d", symbols[i])
+			}
+		}
+	}
+
+	var symbolLengths []uint8
+	switch numSymbols {
+	case 1:
+		symbolLengths = []uint8{0}
+	case 2:
+		symbolLengths = []uint8{1, 1}
+	case 3:
+		symbolLengths = []uint8{1, 2, 2}
+	case 4:
+		if br.readBool() {
+			symbolLengths = []uint8{1, 2, 3, 3}
+		} else {
+			symbolLengths = []uint8{2, 2, 2, 2}
+		}
+	}
+
+	if numSymbols == 1 {
+		return &prefixCode{single: symbols[0]}, br.err
+	}
+
+	lengths := make([]uint8, alphabetSize)
+	for i, s := range symbols {
+		lengths[s] = symbolLengths[i]
+	}
+
+	return newPrefixCode(lengths), br.err
+}
@@ -0,0 +1,88 @@
+package meta
+
+import (
+	"io"
+
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// LimitError is returned when extracting metadata would exceed one of the
+// configured Limits. The Limit field names the limit which was exceeded, eg
+// "MaxICCProfileSize".
+type LimitError = binary.LimitError
+
+// Limits bounds the resources used when extracting metadata, so that images
+// from untrusted sources can be handled safely. A zero value for any limit
+// means that it is not enforced.
+type Limits struct {
+	// MaxICCProfileSize is the maximum size in bytes of an embedded ICC
+	// profile. Larger profiles are reported as an ICC profile error.
+	MaxICCProfileSize int64
+
+	// MaxICCTagCount is the maximum number of tags an embedded ICC profile may
+	// have when it is parsed by Data.ICCProfile.
+	MaxICCTagCount int
+
+	// MaxDecompressedSize is the maximum size in bytes of any compressed
+	// metadata, such as a PNG ICC profile or XMP packet, once decompressed.
+	MaxDecompressedSize int64
+
+	// MaxBytesScanned is the maximum number of bytes of the image stream which
+	// will be read or skipped before giving up. Because the data read by Load
+	// functions is buffered, this also bounds their memory use.
+	MaxBytesScanned int64
+
+	// MaxSegments is the maximum number of segments, chunks, boxes or blocks of
+	// the image stream which will be read before giving up.
+	MaxSegments int
+}
+
+// DefaultLimits are the limits used by loaders when none are specified.
+var DefaultLimits = Limits{
+	MaxICCProfileSize:   32 << 20,
+	MaxICCTagCount:      1024,
+	MaxDecompressedSize: 32 << 20,
+	MaxBytesScanned:     256 << 20,
+	MaxSegments:         1 << 16,
+}
+
+// CheckICCProfileSize returns a LimitError if an ICC profile of the specified
+// size would exceed MaxICCProfileSize.
+func (l Limits) CheckICCProfileSize(size int64) error {
+	if l.MaxICCProfileSize > 0 && size > l.MaxICCProfileSize {
+		return &LimitError{Limit: "MaxICCProfileSize", Value: l.MaxICCProfileSize}
+	}
+	return nil
+}
+
+// CheckSegmentCount returns a LimitError if the specified number of segments
+// would exceed MaxSegments.
+func (l Limits) CheckSegmentCount(count int) error {
+	if l.MaxSegments > 0 && count > l.MaxSegments {
+		return &LimitError{Limit: "MaxSegments", Value: int64(l.MaxSegments)}
+	}
+	return nil
+}
+
+// DecompressedReader returns a reader for the decompressed data produced by r,
+// which fails with a LimitError if MaxDecompressedSize is exceeded.
+func (l Limits) DecompressedReader(r io.Reader) io.Reader {
+	return binary.NewLimitedReader(r, l.MaxDecompressedSize, "MaxDecompressedSize")
+}
+
+// ScanReader returns a reader for an image stream which fails with a
+// LimitError if MaxBytesScanned is exceeded.
+func (l Limits) ScanReader(r io.Reader) *binary.LimitedReader {
+	return binary.NewLimitedReader(r, l.MaxBytesScanned, "MaxBytesScanned")
+}
+
+// ScanSeeker returns a reader for an image stream which supports seeking,
+// which fails with a LimitError if MaxBytesScanned is exceeded.
+func (l Limits) ScanSeeker(rs io.ReadSeeker) (*binary.SeekReader, error) {
+	sr, err := binary.NewSeekReader(rs)
+	if err != nil {
+		return nil, err
+	}
+	sr.SetLimit(l.MaxBytesScanned, "MaxBytesScanned")
+	return sr, nil
+}
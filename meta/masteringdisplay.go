@@ -0,0 +1,16 @@
+package meta
+
+import "github.com/mandykoh/prism/ciexyy"
+
+// MasteringDisplay describes the colour volume of the display on which an
+// image was mastered, as used for HDR content.
+type MasteringDisplay struct {
+	Primaries  Primaries
+	WhitePoint ciexyy.Color
+
+	// MaxLuminance is the maximum luminance of the display in cd/m².
+	MaxLuminance float64
+
+	// MinLuminance is the minimum luminance of the display in cd/m².
+	MinLuminance float64
+}
@@ -1,6 +1,16 @@
 package pngmeta
 
+var chunkTypeacTL = [4]byte{'a', 'c', 'T', 'L'}
+var chunkTypecHRM = [4]byte{'c', 'H', 'R', 'M'}
+var chunkTypecICP = [4]byte{'c', 'I', 'C', 'P'}
+var chunkTypecLLi = [4]byte{'c', 'L', 'L', 'i'}
+var chunkTypeeXIf = [4]byte{'e', 'X', 'I', 'f'}
+var chunkTypegAMA = [4]byte{'g', 'A', 'M', 'A'}
 var chunkTypeiCCP = [4]byte{'i', 'C', 'C', 'P'}
 var chunkTypeIDAT = [4]byte{'I', 'D', 'A', 'T'}
 var chunkTypeIEND = [4]byte{'I', 'E', 'N', 'D'}
 var chunkTypeIHDR = [4]byte{'I', 'H', 'D', 'R'}
+var chunkTypeiTXt = [4]byte{'i', 'T', 'X', 't'}
+var chunkTypemDCv = [4]byte{'m', 'D', 'C', 'v'}
+var chunkTypesRGB = [4]byte{'s', 'R', 'G', 'B'}
+var chunkTypetRNS = [4]byte{'t', 'R', 'N', 'S'}
@@ -0,0 +1,126 @@
+package pngmeta
+
+import (
+	encbinary "encoding/binary"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/icc"
+)
+
+var srgbPrimaries = meta.Primaries{
+	Red:   ciexyy.Color{X: 0.64, Y: 0.33, YY: 1},
+	Green: ciexyy.Color{X: 0.3, Y: 0.6, YY: 1},
+	Blue:  ciexyy.Color{X: 0.15, Y: 0.06, YY: 1},
+}
+
+// colourChunks holds the colour information from the chunks preceding the
+// image data, before precedence rules have been applied.
+type colourChunks struct {
+	cicp            *meta.CICP
+	renderingIntent *icc.RenderingIntent
+	gamma           float64
+	whitePoint      *ciexyy.Color
+	primaries       *meta.Primaries
+}
+
+// parse interprets the data of a colour-related chunk. Chunks with invalid
+// lengths or values are ignored.
+func (cc *colourChunks) parse(chunkType [4]byte, data []byte, md *meta.Data) {
+	be := encbinary.BigEndian
+
+	switch chunkType {
+
+	case chunkTypecICP:
+		if len(data) == 4 && cc.cicp == nil {
+			cc.cicp = &meta.CICP{
+				ColorPrimaries:          uint16(data[0]),
+				TransferCharacteristics: uint16(data[1]),
+				MatrixCoefficients:      uint16(data[2]),
+				FullRange:               data[3] != 0,
+			}
+		}
+
+	case chunkTypesRGB:
+		if len(data) == 1 && data[0] <= 3 && cc.renderingIntent == nil {
+			intent := icc.RenderingIntent(data[0])
+			cc.renderingIntent = &intent
+		}
+
+	case chunkTypegAMA:
+		if len(data) == 4 && cc.gamma == 0 {
+			if v := be.Uint32(data); v != 0 {
+				cc.gamma = 100000 / float64(v)
+			}
+		}
+
+	case chunkTypecHRM:
+		if len(data) == 32 && cc.primaries == nil {
+			xy := func(i int) ciexyy.Color {
+				return ciexyy.Color{
+					X:  float32(be.Uint32(data[i*8:])) / 100000,
+					Y:  float32(be.Uint32(data[i*8+4:])) / 100000,
+					YY: 1,
+				}
+			}
+			whitePoint := xy(0)
+			cc.whitePoint = &whitePoint
+			cc.primaries = &meta.Primaries{Red: xy(1), Green: xy(2), Blue: xy(3)}
+		}
+
+	case chunkTypemDCv:
+		if len(data) == 24 && md.MasteringDisplay == nil {
+			xy := func(i int) ciexyy.Color {
+				return ciexyy.Color{
+					X:  float32(be.Uint16(data[i*4:])) * 0.00002,
+					Y:  float32(be.Uint16(data[i*4+2:])) * 0.00002,
+					YY: 1,
+				}
+			}
+			md.MasteringDisplay = &meta.MasteringDisplay{
+				Primaries:    meta.Primaries{Red: xy(0), Green: xy(1), Blue: xy(2)},
+				WhitePoint:   xy(3),
+				MaxLuminance: float64(be.Uint32(data[16:])) * 0.0001,
+				MinLuminance: float64(be.Uint32(data[20:])) * 0.0001,
+			}
+		}
+
+	case chunkTypecLLi:
+		if len(data) == 8 && md.ContentLightLevel == nil {
+			md.ContentLightLevel = &meta.ContentLightLevel{
+				MaxCLL:  float64(be.Uint32(data[0:])) * 0.0001,
+				MaxFALL: float64(be.Uint32(data[4:])) * 0.0001,
+			}
+		}
+	}
+}
+
+// apply populates the metadata with the colour information which takes
+// precedence, being the first of cICP, iCCP, sRGB, or cHRM and gAMA.
+//
+// An ICC profile is kept alongside cICP, as the profile may still be useful to
+// applications which don't support CICP; md.CICP takes precedence over it.
+// Colour information from the remaining chunks with lower precedence is
+// discarded.
+func (cc *colourChunks) apply(md *meta.Data) {
+	md.CICP = cc.cicp
+	if cc.cicp != nil {
+		return
+	}
+
+	if iccData, err := md.ICCProfileData(); iccData != nil || err != nil {
+		return
+	}
+
+	if cc.renderingIntent != nil {
+		primaries := srgbPrimaries
+		whitePoint := ciexyy.D65
+		md.Primaries = &primaries
+		md.WhitePoint = &whitePoint
+		md.RenderingIntent = cc.renderingIntent
+		return
+	}
+
+	md.Primaries = cc.primaries
+	md.WhitePoint = cc.whitePoint
+	md.Gamma = cc.gamma
+}
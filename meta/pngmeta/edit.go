@@ -0,0 +1,161 @@
+package pngmeta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"hash/crc32"
+	"io"
+)
+
+const maxKeywordLength = 79
+
+// EditProfile copies a PNG image stream from r to w, changing its colour
+// profile as specified by edit. All other chunks are copied byte-for-byte, and
+// the image data is not re-encoded.
+//
+// Any existing iCCP, sRGB and cICP chunks are removed (a cICP chunk would
+// otherwise take precedence over the new profile), and the new iCCP or sRGB
+// chunk is written immediately after the image header.
+func EditProfile(w io.Writer, r io.Reader, edit meta.ProfileEdit) error {
+	if err := edit.Validate(); err != nil {
+		return err
+	}
+
+	var newChunk []byte
+	switch edit.Action {
+	case meta.ProfileActionReplace:
+		data, err := iccProfileChunkData(edit.Name(), edit.ICCProfile)
+		if err != nil {
+			return err
+		}
+		newChunk = encodeChunk(chunkTypeiCCP, data)
+	case meta.ProfileActionSRGB:
+		newChunk = encodeChunk(chunkTypesRGB, []byte{byte(edit.RenderingIntent)})
+	}
+
+	var sig [8]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return binary.Errorf(meta.ErrTruncated, "unexpected EOF reading PNG header")
+	}
+	if sig != pngSignature {
+		return binary.Errorf(meta.ErrFormatMismatch, "invalid PNG signature")
+	}
+	if _, err := w.Write(sig[:]); err != nil {
+		return err
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return binary.Truncated(err)
+		}
+		length := int64(header[0])<<24 | int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		var chunkType [4]byte
+		copy(chunkType[:], header[4:])
+
+		switch chunkType {
+
+		case chunkTypeiCCP, chunkTypesRGB, chunkTypecICP:
+			// Skip chunk data and CRC
+			if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+				return binary.Truncated(err)
+			}
+			continue
+
+		case chunkTypeIDAT:
+			// Everything from the image data onwards is copied unchanged
+			if _, err := w.Write(header[:]); err != nil {
+				return err
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, length+4); err != nil {
+			return binary.Truncated(err)
+		}
+
+		if chunkType == chunkTypeIHDR && newChunk != nil {
+			if _, err := w.Write(newChunk); err != nil {
+				return err
+			}
+		}
+		if chunkType == chunkTypeIEND {
+			return nil
+		}
+	}
+}
+
+// iccProfileChunkData returns the data of an iCCP chunk containing the
+// specified profile.
+func iccProfileChunkData(name string, profile []byte) ([]byte, error) {
+	data := &bytes.Buffer{}
+	data.Write(profileKeyword(name))
+	data.WriteByte(0x00) // Null terminator
+	data.WriteByte(0x00) // Compression method
+
+	zWriter := zlib.NewWriter(data)
+	if _, err := zWriter.Write(profile); err != nil {
+		return nil, err
+	}
+	if err := zWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
+
+// profileKeyword returns the specified profile name as a valid PNG keyword,
+// consisting of at most 79 printable Latin-1 characters without leading,
+// trailing or consecutive spaces. Other characters are dropped, and the
+// default profile name is used if none remain.
+func profileKeyword(name string) []byte {
+	keyword := make([]byte, 0, maxKeywordLength)
+
+	for _, r := range name {
+		if len(keyword) == maxKeywordLength {
+			break
+		}
+
+		switch {
+		case r == ' ':
+			if len(keyword) == 0 || keyword[len(keyword)-1] == ' ' {
+				continue
+			}
+		case r > ' ' && r <= '~', r >= 0xA1 && r <= 0xFF:
+		default:
+			continue
+		}
+		keyword = append(keyword, byte(r))
+	}
+
+	keyword = bytes.TrimRight(keyword, " ")
+	if len(keyword) == 0 {
+		return []byte(meta.ProfileEdit{}.Name())
+	}
+	return keyword
+}
+
+// encodeChunk returns a complete chunk with the specified type and data,
+// including its CRC.
+func encodeChunk(chunkType [4]byte, data []byte) []byte {
+	chunk := &bytes.Buffer{}
+	_ = binary.WriteU32Big(chunk, uint32(len(data)))
+	chunk.Write(chunkType[:])
+	chunk.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(chunkType[:])
+	crc.Write(data)
+	_ = binary.WriteU32Big(chunk, crc.Sum32())
+
+	return chunk.Bytes()
+}
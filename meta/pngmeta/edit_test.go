@@ -0,0 +1,170 @@
+package pngmeta
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/icc"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestEditProfile(t *testing.T) {
+
+	textChunk := append([]byte("Comment\x00"), []byte("Hello")...)
+
+	buildPNG := func(colourChunks ...[]byte) []byte {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, 2, 0, 0, 0})
+		for _, c := range colourChunks {
+			data.Write(c)
+		}
+		writeChunk(data, [4]byte{'t', 'E', 'X', 't'}, textChunk)
+		writeChunk(data, chunkTypeIDAT, bytes.Repeat([]byte{1}, 100))
+		writeChunk(data, chunkTypeIEND, nil)
+		return data.Bytes()
+	}
+
+	oldProfileChunk := func() []byte {
+		data, err := iccProfileChunkData("Old", []byte("old profile"))
+		if err != nil {
+			panic(err)
+		}
+		return encodeChunk(chunkTypeiCCP, data)
+	}
+
+	edit := func(t *testing.T, input []byte, e meta.ProfileEdit) []byte {
+		output := &bytes.Buffer{}
+		if err := EditProfile(output, bytes.NewReader(input), e); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return output.Bytes()
+	}
+
+	t.Run("replaces an existing profile", func(t *testing.T) {
+		newProfile := []byte("new profile data")
+
+		output := edit(t, buildPNG(oldProfileChunk()), meta.ProfileEdit{
+			Action:      meta.ProfileActionReplace,
+			ICCProfile:  newProfile,
+			ProfileName: "New",
+		})
+
+		md, err := extractMetadata(bytes.NewReader(output), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if actual, _ := md.ICCProfileData(); !bytes.Equal(newProfile, actual) {
+			t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+		}
+
+		expectedChunk, _ := iccProfileChunkData("New", newProfile)
+		if !bytes.Contains(output, encodeChunk(chunkTypeiCCP, expectedChunk)) {
+			t.Errorf("Expected output to contain an iCCP chunk named 'New'")
+		}
+		if bytes.Contains(output, []byte("Old\x00")) {
+			t.Errorf("Expected old profile to be removed")
+		}
+	})
+
+	t.Run("embeds a profile where there was none", func(t *testing.T) {
+		input := buildPNG()
+		newProfile := []byte("new profile data")
+
+		output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+		md, err := extractMetadata(bytes.NewReader(output), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if actual, _ := md.ICCProfileData(); !bytes.Equal(newProfile, actual) {
+			t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+		}
+
+		// The new chunk immediately follows the IHDR chunk
+		ihdrEnd := len(pngSignature) + 8 + 13 + 4
+		chunkData, _ := iccProfileChunkData("ICC Profile", newProfile)
+		newChunk := encodeChunk(chunkTypeiCCP, chunkData)
+		if expected, actual := append(append(append([]byte{}, input[:ihdrEnd]...), newChunk...), input[ihdrEnd:]...), output; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected other chunks to be unchanged")
+		}
+	})
+
+	t.Run("writes profile names as valid keywords", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			expected string
+		}{
+			{"Display P3", "Display P3"},
+			{"  Caf\u00e9  au   lait \u65e5\u672c", "Caf\xe9 au lait"},
+			{"Tab\tand\x00null", "Tabandnull"},
+			{"\u65e5\u672c", "ICC Profile"},
+			{strings.Repeat("\u00e9", 100), strings.Repeat("\xe9", 79)},
+		}
+
+		for _, c := range cases {
+			if expected, actual := []byte(c.expected), profileKeyword(c.name); !bytes.Equal(expected, actual) {
+				t.Errorf("Expected keyword %q for %q but got %q", expected, c.name, actual)
+			}
+		}
+	})
+
+	t.Run("removes a profile leaving other chunks unchanged", func(t *testing.T) {
+		output := edit(t, buildPNG(oldProfileChunk()), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if expected, actual := buildPNG(), output; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected output %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("replaces a profile with an sRGB chunk", func(t *testing.T) {
+		output := edit(t, buildPNG(oldProfileChunk()), meta.ProfileEdit{
+			Action:          meta.ProfileActionSRGB,
+			RenderingIntent: icc.SaturationRenderingIntent,
+		})
+
+		md, err := extractMetadata(bytes.NewReader(output), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if actual, _ := md.ICCProfileData(); actual != nil {
+			t.Errorf("Expected no profile data but got %v", actual)
+		}
+		if md.RenderingIntent == nil {
+			t.Fatalf("Expected a rendering intent but got none")
+		}
+		if expected, actual := icc.SaturationRenderingIntent, *md.RenderingIntent; expected != actual {
+			t.Errorf("Expected rendering intent %v but got %v", expected, actual)
+		}
+		if expected, actual := buildPNG(encodeChunk(chunkTypesRGB, []byte{byte(icc.SaturationRenderingIntent)})), output; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected output %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("writes chunks with valid CRCs", func(t *testing.T) {
+		chunk := encodeChunk(chunkTypesRGB, []byte{0})
+
+		crc := crc32.ChecksumIEEE(chunk[4 : len(chunk)-4])
+		if expected, actual := []byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}, chunk[len(chunk)-4:]; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected CRC %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("returns error for non-PNG input", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader([]byte("NOT A PNG SIGNATURE")), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error when replacing without a profile", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader(buildPNG()), meta.ProfileEdit{Action: meta.ProfileActionReplace})
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		}
+	})
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"compress/zlib"
+	encbinary "encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/mandykoh/prism/meta"
 	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/exif"
 	"io"
 	"strings"
 )
@@ -17,6 +19,12 @@ var Format = meta.ImageFormat("PNG")
 
 var pngSignature = [8]byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}
 
+const maxColourChunkLength = 64
+const maxEXIFChunkLength = 65535
+const maxXMPLength = 16 << 20
+
+var xmpKeyword = []byte("XML:com.adobe.xmp\x00")
+
 // Load loads the metadata for a PNG image stream.
 //
 // Only as much of the stream is consumed as necessary to extract the metadata;
@@ -28,55 +36,117 @@ var pngSignature = [8]byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}
 // An error is returned if basic metadata could not be extracted. The returned
 // stream still provides the full image data.
 func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a PNG image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
 	rewindBuffer := &bytes.Buffer{}
-	tee := io.TeeReader(r, rewindBuffer)
-	md, err = extractMetadata(bufio.NewReader(tee))
-	return md, io.MultiReader(rewindBuffer, r), err
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(bufio.NewReader(lr), limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a PNG image stream which supports seeking.
+//
+// Reading begins at the current position of the stream, and chunks which don't
+// contain metadata are skipped by seeking past them. This also allows EXIF and
+// XMP metadata stored after the image data to be found. The stream is returned
+// to its original position afterwards, so that the full image can then be
+// loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
 }
 
-func extractMetadata(r binary.Reader) (md *meta.Data, err error) {
+// LoadSeekerWithLimits loads the metadata for a PNG image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a PNG image of the specified size in
+// bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a PNG image of the specified
+// size in bytes as per LoadReaderAt, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
+}
+
+func extractMetadata(input binary.Reader, limits meta.Limits) (md *meta.Data, err error) {
 	metadataExtracted := false
 	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
 
 	defer func() {
 		if r := recover(); r != nil {
 			if !metadataExtracted {
 				md = nil
 			}
-			err = fmt.Errorf("panic while extracting image metadata: %v", r)
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
 		}
+		err = binary.Truncated(err)
 	}()
 
-	allMetadataExtracted := func() bool {
-		iccData, iccErr := md.ICCProfileData()
-		return metadataExtracted && (iccData != nil || iccErr != nil)
-	}
+	colour := colourChunks{}
+	_, seekable := input.(binary.Skipper)
+	r := binary.NewOffsetReader(input)
+	md.FrameCount = 1
+	md.LoopCount = 1
 
 	pngSig := [8]byte{}
-	bytesRead, err := r.Read(pngSig[:])
-	if err != nil {
-		return nil, err
-	}
-	if bytesRead != len(pngSig) {
-		return nil, fmt.Errorf("unexpected EOF reading PNG header")
+	if _, err := io.ReadFull(r, pngSig[:]); err != nil {
+		return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading PNG header")
 	}
 	if pngSig != pngSignature {
-		return nil, fmt.Errorf("invalid PNG signature")
+		return nil, binary.Errorf(meta.ErrFormatMismatch, "invalid PNG signature")
 	}
 
+	chunkCount := 0
+
+	endOfStream := false
+
 parseChunks:
 	for {
+		chunkOffset := r.Offset()
 		ch, err := readChunkHeader(r)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
+				endOfStream = true
 				break
 			}
 			return nil, err
 		}
 
+		chunkCount++
+		if err := limits.CheckSegmentCount(chunkCount); err != nil {
+			return nil, err
+		}
+
 		switch ch.ChunkType {
 
 		case chunkTypeIHDR:
+			if ch.Length < 13 {
+				return nil, binary.Malformed("IHDR", chunkOffset, "invalid chunk length %d", ch.Length)
+			}
+
 			md.PixelWidth, err = binary.ReadU32Big(r)
 			if err != nil {
 				return nil, err
@@ -93,24 +163,35 @@ parseChunks:
 			}
 			md.BitsPerComponent = uint32(bitDepth)
 
-			// Skip remainder of header
-			for i := uint32(0); i < ch.Length-9; i++ {
-				_, err := r.ReadByte()
-				if err != nil {
-					return nil, err
-				}
+			var fields [4]byte
+			if _, err := io.ReadFull(r, fields[:]); err != nil {
+				return nil, err
 			}
+			setColourType(md, fields[0])
+			md.Interlaced = fields[3] != 0
 
-			// Skip chunk CRC
-			_, err = binary.ReadU32Big(r)
-			if err != nil {
+			// Skip remainder of header and chunk CRC
+			if err := binary.Skip(r, int64(ch.Length)-13+4); err != nil {
 				return nil, err
 			}
 
 			metadataExtracted = true
 
-			if allMetadataExtracted() {
-				break parseChunks
+		case chunkTypeacTL:
+			data, err := readChunkData(r, ch, 8)
+			if err != nil {
+				return nil, err
+			}
+			if len(data) == 8 {
+				md.Animated = true
+				md.FrameCount = encbinary.BigEndian.Uint32(data)
+				md.LoopCount = encbinary.BigEndian.Uint32(data[4:])
+			}
+
+		case chunkTypetRNS:
+			md.HasAlpha = true
+			if err := skipChunk(r, ch); err != nil {
+				return nil, err
 			}
 
 		case chunkTypeiCCP:
@@ -127,7 +208,7 @@ parseChunks:
 				profileName.WriteByte(b)
 			}
 			if profileName.Len() > 79 {
-				return nil, fmt.Errorf("null terminator not found reading ICC profile name")
+				return nil, binary.Malformed("iCCP", chunkOffset, "null terminator not found reading ICC profile name")
 			}
 
 			compressionMethod, err := r.ReadByte()
@@ -135,21 +216,29 @@ parseChunks:
 				return nil, err
 			}
 			if compressionMethod != 0x00 {
-				return nil, fmt.Errorf("unknown compression method (%d)", compressionMethod)
+				return nil, binary.Errorf(meta.ErrUnsupported, "unknown compression method (%d)", compressionMethod)
 			}
 
 			offset := uint32(profileName.Len() + 2)
 			if offset >= ch.Length {
-				return nil, fmt.Errorf("invalid ICC profile chunk length")
+				return nil, binary.Malformed("iCCP", chunkOffset, "invalid ICC profile chunk length")
 			}
 
-			chunkData := make([]byte, ch.Length-offset)
-			bytesRead, err := r.Read(chunkData)
-			if err != nil {
-				return nil, err
+			// Profiles which are too large are skipped along with the CRC
+			if err := limits.CheckICCProfileSize(int64(ch.Length - offset)); err != nil {
+				md.SetICCProfileError(err)
+				if err := binary.Skip(r, int64(ch.Length-offset)+4); err != nil {
+					return nil, err
+				}
+				break
 			}
-			if bytesRead != len(chunkData) {
-				return nil, fmt.Errorf("unexpected EOF reading ICC profile chunk")
+
+			chunkData := &bytes.Buffer{}
+			if _, err := io.CopyN(chunkData, r, int64(ch.Length-offset)); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading ICC profile chunk")
+				}
+				return nil, err
 			}
 
 			// Skip chunk CRC
@@ -159,47 +248,236 @@ parseChunks:
 			}
 
 			// Decompress ICC profile data
-			zReader, err := zlib.NewReader(bytes.NewReader(chunkData))
+			zReader, err := zlib.NewReader(chunkData)
 			if err != nil {
-				md.SetICCProfileError(err)
+				md.SetICCProfileError(binary.Malformed("iCCP", chunkOffset, "%w", err))
 				break
 			}
 			profileData := &bytes.Buffer{}
-			_, err = io.Copy(profileData, zReader)
+			_, err = io.Copy(profileData, limits.DecompressedReader(zReader))
 			_ = zReader.Close()
+			if err == nil {
+				err = limits.CheckICCProfileSize(int64(profileData.Len()))
+			}
+			var limitErr *meta.LimitError
 			if err == nil {
 				md.SetICCProfileData(profileData.Bytes())
-
-				if allMetadataExtracted() {
-					break parseChunks
-				}
-			} else {
+			} else if errors.As(err, &limitErr) {
 				md.SetICCProfileError(err)
+			} else {
+				md.SetICCProfileError(binary.Malformed("iCCP", chunkOffset, "%w", err))
 			}
 
-		case chunkTypeIDAT, chunkTypeIEND:
-			break parseChunks
+		case chunkTypecHRM, chunkTypecICP, chunkTypecLLi, chunkTypegAMA, chunkTypemDCv, chunkTypesRGB:
+			data, err := readChunkData(r, ch, maxColourChunkLength)
+			if err != nil {
+				return nil, err
+			}
+			colour.parse(ch.ChunkType, data, md)
 
-		default:
-			// Skip chunk data bytes
-			for i := uint32(0); i < ch.Length; i++ {
-				_, err := r.ReadByte()
-				if err != nil {
-					return nil, err
-				}
+		case chunkTypeeXIf:
+			if err := readEXIFChunk(r, ch, md); err != nil {
+				return nil, err
 			}
 
-			// Skip chunk CRC
-			_, err := binary.ReadU32Big(r)
-			if err != nil {
+		case chunkTypeiTXt:
+			if err := readTextChunk(r, ch, md, limits); err != nil {
+				return nil, err
+			}
+
+		case chunkTypeIDAT:
+			// EXIF and XMP metadata may follow the image data, but finding it
+			// is only worthwhile if the image data can be seeked past
+			if seekable && (md.EXIF == nil || md.XMPData() == nil) {
+				readTrailingMetadata(r, ch, md, limits, chunkCount)
+			}
+			break parseChunks
+
+		case chunkTypeIEND:
+			break parseChunks
+
+		default:
+			if err := skipChunk(r, ch); err != nil {
 				return nil, err
 			}
 		}
 	}
 
 	if !metadataExtracted {
-		return nil, fmt.Errorf("no metadata found")
+		if endOfStream {
+			return nil, binary.Errorf(meta.ErrTruncated, "no metadata found")
+		}
+		return nil, binary.Errorf(meta.ErrMalformed, "no metadata found")
 	}
 
+	colour.apply(md)
+
 	return md, nil
 }
+
+// readTrailingMetadata reads any EXIF and XMP metadata not already found from
+// the chunks following the start of the image data, until the end of the
+// image. Errors are ignored, as the basic metadata has already been extracted.
+func readTrailingMetadata(r binary.Reader, ch chunkHeader, md *meta.Data, limits meta.Limits, chunkCount int) {
+	for {
+		switch ch.ChunkType {
+
+		case chunkTypeeXIf:
+			if err := readEXIFChunk(r, ch, md); err != nil {
+				return
+			}
+
+		case chunkTypeiTXt:
+			if err := readTextChunk(r, ch, md, limits); err != nil {
+				return
+			}
+
+		case chunkTypeIEND:
+			return
+
+		default:
+			if err := skipChunk(r, ch); err != nil {
+				return
+			}
+		}
+
+		var err error
+		ch, err = readChunkHeader(r)
+		if err != nil {
+			return
+		}
+
+		chunkCount++
+		if limits.CheckSegmentCount(chunkCount) != nil {
+			return
+		}
+	}
+}
+
+// readEXIFChunk reads an eXIf chunk, keeping the EXIF data if none has already
+// been found.
+func readEXIFChunk(r binary.Reader, ch chunkHeader, md *meta.Data) error {
+	data, err := readChunkData(r, ch, maxEXIFChunkLength)
+	if err != nil {
+		return err
+	}
+	if exifData, err := exif.Parse(data); err == nil && md.EXIF == nil {
+		md.EXIF = exifData
+	}
+	return nil
+}
+
+// readTextChunk reads an iTXt chunk, keeping the XMP packet it contains (if
+// any) if none has already been found.
+func readTextChunk(r binary.Reader, ch chunkHeader, md *meta.Data, limits meta.Limits) error {
+	data, err := readChunkData(r, ch, maxXMPLength)
+	if err != nil {
+		return err
+	}
+	if md.XMPData() == nil && bytes.HasPrefix(data, xmpKeyword) {
+		if xmpData, err := readXMP(data[len(xmpKeyword):], limits); err == nil {
+			md.SetXMPData(xmpData)
+		}
+	}
+	return nil
+}
+
+// setColourType populates the colour model, channel count, and alpha presence
+// from the colour type specified in the image header.
+func setColourType(md *meta.Data, colourType byte) {
+	switch colourType {
+	case 0:
+		md.ColorModel = meta.ColorModelGreyscale
+		md.ChannelCount = 1
+	case 2:
+		md.ColorModel = meta.ColorModelRGB
+		md.ChannelCount = 3
+	case 3:
+		md.ColorModel = meta.ColorModelPalette
+		md.ChannelCount = 1
+	case 4:
+		md.ColorModel = meta.ColorModelGreyscale
+		md.ChannelCount = 2
+		md.HasAlpha = true
+	case 6:
+		md.ColorModel = meta.ColorModelRGB
+		md.ChannelCount = 4
+		md.HasAlpha = true
+	}
+}
+
+// skipChunk skips the data and CRC of a chunk.
+func skipChunk(r binary.Reader, ch chunkHeader) error {
+	return binary.Skip(r, int64(ch.Length)+4)
+}
+
+// readChunkData reads the data and CRC of a small chunk. The data of chunks
+// which are larger than maxLength is skipped and nil is returned.
+//
+// The data is read incrementally rather than allocated up front, so that a
+// chunk length which is much larger than the remaining stream can't cause a
+// large allocation; memory use is instead bounded by the data actually read.
+func readChunkData(r binary.Reader, ch chunkHeader, maxLength uint32) ([]byte, error) {
+	var data []byte
+	if ch.Length <= maxLength {
+		buf := &bytes.Buffer{}
+		if _, err := io.CopyN(buf, r, int64(ch.Length)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF reading %s chunk", string(ch.ChunkType[:]))
+			}
+			return nil, err
+		}
+		data = buf.Bytes()
+	} else if err := binary.Skip(r, int64(ch.Length)); err != nil {
+		return nil, err
+	}
+
+	// Skip chunk CRC
+	if _, err := binary.ReadU32Big(r); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// readXMP extracts the text of an iTXt chunk containing an XMP packet,
+// following the chunk's keyword.
+func readXMP(data []byte, limits meta.Limits) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("invalid iTXt chunk")
+	}
+	compressed := data[0] != 0
+	compressionMethod := data[1]
+	data = data[2:]
+
+	// Skip language tag and translated keyword
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, fmt.Errorf("null terminator not found reading iTXt chunk")
+		}
+		data = data[end+1:]
+	}
+
+	if !compressed {
+		return data, nil
+	}
+	if compressionMethod != 0x00 {
+		return nil, fmt.Errorf("unknown compression method (%d)", compressionMethod)
+	}
+
+	zReader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zReader.Close()
+
+	xmpData := &bytes.Buffer{}
+	if _, err := io.Copy(xmpData, io.LimitReader(limits.DecompressedReader(zReader), maxXMPLength+1)); err != nil {
+		return nil, err
+	}
+	if xmpData.Len() > maxXMPLength {
+		return nil, fmt.Errorf("XMP data too large")
+	}
+	return xmpData.Bytes(), nil
+}
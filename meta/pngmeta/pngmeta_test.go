@@ -3,7 +3,15 @@ package pngmeta
 import (
 	"bytes"
 	"compress/zlib"
+	encbinary "encoding/binary"
+	"errors"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta"
 	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/icc"
+	"io"
+	"math"
+	"runtime"
 	"testing"
 )
 
@@ -42,12 +50,14 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte("NOT A PNG SIGNATURE"))
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected error but succeeded")
 		} else if expected, actual := "invalid PNG signature", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		} else if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
 		}
 	})
 
@@ -55,12 +65,14 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write(pngSignature[:])
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected error but succeeded")
 		} else if expected, actual := "no metadata found", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		} else if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncation error but got %v", err)
 		}
 	})
 
@@ -75,7 +87,7 @@ func TestExtractMetadata(t *testing.T) {
 		dummyCRC := uint32(0)
 		_ = binary.WriteU32Big(data, dummyCRC)
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -119,7 +131,7 @@ func TestExtractMetadata(t *testing.T) {
 		invalidICCProfileData := []byte("NOT COMPRESSED ICC PROFLE DATA")
 		writeICCProfileChunk(data, invalidICCProfileData)
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -134,10 +146,15 @@ func TestExtractMetadata(t *testing.T) {
 				t.Errorf("Expected no ICC profile but got one")
 			}
 
+			var malformedErr *meta.MalformedError
 			if iccErr == nil {
 				t.Errorf("Expected ICC profile error but got none")
-			} else if expected, actual := "zlib: invalid header", iccErr.Error(); expected != actual {
+			} else if expected, actual := "malformed iCCP at offset 33: zlib: invalid header", iccErr.Error(); expected != actual {
 				t.Errorf("Expected ICC profile error '%s' but got '%s'", expected, actual)
+			} else if !errors.As(iccErr, &malformedErr) {
+				t.Errorf("Expected malformed chunk error but got %v", iccErr)
+			} else if expected, actual := int64(33), malformedErr.Offset; expected != actual {
+				t.Errorf("Expected malformed chunk at offset %d but got %d", expected, actual)
 			}
 
 			if expected, actual := uint32(15), md.PixelWidth; expected != actual {
@@ -166,7 +183,7 @@ func TestExtractMetadata(t *testing.T) {
 		iccProfileData := []byte{1, 2, 3, 4}
 		writeICCProfileChunk(data, compressICCProfileData(iccProfileData))
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -206,7 +223,7 @@ func TestExtractMetadata(t *testing.T) {
 		}
 	})
 
-	t.Run("stops reading after all interesting metadata has been found", func(t *testing.T) {
+	t.Run("reads colour chunks following the ICC profile and stops at IDAT chunk header", func(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write(pngSignature[:])
 
@@ -220,25 +237,34 @@ func TestExtractMetadata(t *testing.T) {
 		iccProfileData := []byte{1, 2, 3, 4}
 		writeICCProfileChunk(data, compressICCProfileData(iccProfileData))
 
+		_ = binary.WriteU32Big(data, 8)
+		data.Write(chunkTypecLLi[:])
+		_ = binary.WriteU32Big(data, 10000000)
+		_ = binary.WriteU32Big(data, 4000000)
+		_ = binary.WriteU32Big(data, dummyCRC)
+
 		_ = binary.WriteU32Big(data, 4)
 		data.Write(chunkTypeIDAT[:])
 		imageData := []byte{5, 6, 7, 8}
 		data.Write(imageData)
 		_ = binary.WriteU32Big(data, dummyCRC)
 
-		_, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
 		}
 
-		ch, err := readChunkHeader(data)
-		if err != nil {
-			t.Fatalf("Expected chunk data to follow metadata but got error: %v", err)
-		} else {
-			if expected, actual := (chunkHeader{4, chunkTypeIDAT}), ch; expected != actual {
-				t.Fatalf("Expected chunk %v but got %v", expected, actual)
-			}
+		if expected := (meta.ContentLightLevel{MaxCLL: 1000, MaxFALL: 400}); md.ContentLightLevel == nil || *md.ContentLightLevel != expected {
+			t.Errorf("Expected content light level %+v but got %+v", expected, md.ContentLightLevel)
+		}
+
+		b := [4]byte{}
+		if _, err := io.ReadFull(data, b[:]); err != nil {
+			t.Fatalf("Expected IDAT data to follow metadata but got error: %v", err)
+		}
+		if expected, actual := [4]byte{5, 6, 7, 8}, b; expected != actual {
+			t.Errorf("Expected bytes %v to be available after IDAT header but got %v", expected, actual)
 		}
 	})
 
@@ -258,7 +284,7 @@ func TestExtractMetadata(t *testing.T) {
 		imageData := []byte{1, 2, 3, 4}
 		data.Write(imageData)
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -277,3 +303,633 @@ func TestExtractMetadata(t *testing.T) {
 		}
 	})
 }
+
+func writeChunk(dst *bytes.Buffer, chunkType [4]byte, data []byte) {
+	_ = binary.WriteU32Big(dst, uint32(len(data)))
+	dst.Write(chunkType[:])
+	dst.Write(data)
+	_ = binary.WriteU32Big(dst, 0)
+}
+
+func TestColourChunkPrecedence(t *testing.T) {
+
+	buildPNG := func(chunks ...func(*bytes.Buffer)) *bytes.Buffer {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 16, 2, 0, 0, 0})
+		for _, c := range chunks {
+			c(data)
+		}
+		writeChunk(data, chunkTypeIDAT, []byte{1, 2, 3, 4})
+		return data
+	}
+
+	cICP := func(data *bytes.Buffer) { writeChunk(data, chunkTypecICP, []byte{9, 16, 0, 1}) }
+	sRGB := func(data *bytes.Buffer) { writeChunk(data, chunkTypesRGB, []byte{3}) }
+	gAMA := func(data *bytes.Buffer) { writeChunk(data, chunkTypegAMA, []byte{0, 0, 0xB1, 0x8F}) }
+	cHRM := func(data *bytes.Buffer) {
+		writeChunk(data, chunkTypecHRM, []byte{
+			0, 0, 0x7A, 0x26, 0, 0, 0x80, 0x84,
+			0, 0, 0xFA, 0x00, 0, 0, 0x80, 0xE8,
+			0, 0, 0x75, 0x30, 0, 0, 0xEA, 0x60,
+			0, 0, 0x3A, 0x98, 0, 0, 0x17, 0x70,
+		})
+	}
+	iCCP := func(data *bytes.Buffer) {
+		compressed := &bytes.Buffer{}
+		zWriter := zlib.NewWriter(compressed)
+		_, _ = zWriter.Write([]byte{1, 2, 3, 4})
+		_ = zWriter.Close()
+		writeChunk(data, chunkTypeiCCP, append([]byte("Profile\x00\x00"), compressed.Bytes()...))
+	}
+
+	t.Run("cICP takes precedence over all other colour chunks but the ICC profile is kept", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(gAMA, cHRM, sRGB, iCCP, cICP), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if expected := (meta.CICP{ColorPrimaries: 9, TransferCharacteristics: 16, FullRange: true}); md.CICP == nil || *md.CICP != expected {
+			t.Errorf("Expected CICP %+v but got %+v", expected, md.CICP)
+		}
+		if iccData, _ := md.ICCProfileData(); !bytes.Equal([]byte{1, 2, 3, 4}, iccData) {
+			t.Errorf("Expected ICC profile data to be kept but got %v", iccData)
+		}
+		if md.RenderingIntent != nil || md.Primaries != nil || md.Gamma != 0 {
+			t.Errorf("Expected sRGB, cHRM and gAMA chunks to be superseded")
+		}
+	})
+
+	t.Run("iCCP takes precedence over sRGB, cHRM and gAMA", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(iCCP, sRGB, gAMA, cHRM), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if iccData, _ := md.ICCProfileData(); !bytes.Equal([]byte{1, 2, 3, 4}, iccData) {
+			t.Errorf("Expected ICC profile data but got %v", iccData)
+		}
+		if md.CICP != nil || md.RenderingIntent != nil || md.Primaries != nil || md.Gamma != 0 {
+			t.Errorf("Expected sRGB, cHRM and gAMA chunks to be superseded")
+		}
+	})
+
+	t.Run("sRGB takes precedence over cHRM and gAMA", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(gAMA, cHRM, sRGB), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if md.RenderingIntent == nil || *md.RenderingIntent != icc.AbsoluteColorimetricRenderingIntent {
+			t.Errorf("Expected absolute colorimetric rendering intent but got %v", md.RenderingIntent)
+		}
+		if md.Primaries == nil || *md.Primaries != srgbPrimaries {
+			t.Errorf("Expected sRGB primaries but got %+v", md.Primaries)
+		}
+		if md.WhitePoint == nil || *md.WhitePoint != ciexyy.D65 {
+			t.Errorf("Expected D65 white point but got %+v", md.WhitePoint)
+		}
+		if md.Gamma != 0 {
+			t.Errorf("Expected gAMA chunk to be superseded but got gamma %v", md.Gamma)
+		}
+		if md.CICP != nil {
+			t.Errorf("Expected no CICP but got %+v", md.CICP)
+		}
+	})
+
+	t.Run("cHRM and gAMA are used in the absence of other colour chunks", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(gAMA, cHRM), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if expected, actual := 100000.0/45455, md.Gamma; expected != actual {
+			t.Errorf("Expected gamma %v but got %v", expected, actual)
+		}
+		if md.WhitePoint == nil || md.WhitePoint.X != 0.3127 || md.WhitePoint.Y != 0.329 {
+			t.Errorf("Expected D65 white point but got %+v", md.WhitePoint)
+		}
+		if md.Primaries == nil || md.Primaries.Red.X != 0.64 || md.Primaries.Green.Y != 0.6 || md.Primaries.Blue.Y != 0.06 {
+			t.Errorf("Expected sRGB primaries but got %+v", md.Primaries)
+		}
+		if md.CICP != nil || md.RenderingIntent != nil {
+			t.Errorf("Expected no CICP or rendering intent")
+		}
+	})
+
+	t.Run("EXIF data is reported independently of colour chunks", func(t *testing.T) {
+		eXIf := func(data *bytes.Buffer) {
+			writeChunk(data, chunkTypeeXIf, []byte{
+				'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+				0x00, 0x01,
+				0x87, 0x69, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x1A,
+				0x00, 0x00, 0x00, 0x00,
+				0x00, 0x02,
+				0xA0, 0x01, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0xFF, 0xFF, 0x00, 0x00,
+				0xA0, 0x05, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x38,
+				0x00, 0x00, 0x00, 0x00,
+				0x00, 0x01,
+				0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x04, 'R', '0', '3', 0x00,
+				0x00, 0x00, 0x00, 0x00,
+			})
+		}
+
+		md, err := extractMetadata(buildPNG(sRGB, eXIf), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if md.RenderingIntent == nil {
+			t.Errorf("Expected rendering intent from sRGB chunk but got none")
+		}
+
+		if md.EXIF == nil {
+			t.Errorf("Expected EXIF data but got none")
+		} else if !md.EXIF.IsAdobeRGB() {
+			t.Errorf("Expected EXIF data to indicate Adobe RGB but got %+v", *md.EXIF)
+		}
+	})
+
+	t.Run("XMP data is extracted from iTXt chunk", func(t *testing.T) {
+		xmpData := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:ColorSpace="1"/></rdf:RDF>`)
+
+		compressed := &bytes.Buffer{}
+		zWriter := zlib.NewWriter(compressed)
+		_, _ = zWriter.Write(xmpData)
+		_ = zWriter.Close()
+
+		for _, c := range []struct {
+			name string
+			data []byte
+		}{
+			{"uncompressed", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), xmpData...)},
+			{"compressed", append([]byte("XML:com.adobe.xmp\x00\x01\x00en\x00\x00"), compressed.Bytes()...)},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				iTXt := func(data *bytes.Buffer) {
+					writeChunk(data, chunkTypeiTXt, []byte("Comment\x00\x00\x00\x00\x00Not XMP"))
+					writeChunk(data, chunkTypeiTXt, c.data)
+				}
+
+				md, err := extractMetadata(buildPNG(iTXt), meta.DefaultLimits)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+
+				if expected, actual := xmpData, md.XMPData(); !bytes.Equal(expected, actual) {
+					t.Errorf("Expected XMP data %s but got %s", expected, actual)
+				}
+			})
+		}
+	})
+
+	t.Run("mastering display and content light level are always reported", func(t *testing.T) {
+		mDCv := func(data *bytes.Buffer) {
+			writeChunk(data, chunkTypemDCv, []byte{
+				0x8A, 0x48, 0x39, 0x08,
+				0x21, 0x34, 0x9B, 0xAA,
+				0x19, 0x96, 0x08, 0xFC,
+				0x3D, 0x13, 0x40, 0x42,
+				0x00, 0x98, 0x96, 0x80,
+				0x00, 0x00, 0x00, 0x32,
+			})
+		}
+
+		md, err := extractMetadata(buildPNG(mDCv, cICP), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if md.MasteringDisplay == nil {
+			t.Fatalf("Expected mastering display but got none")
+		}
+		if expected, actual := 1000.0, md.MasteringDisplay.MaxLuminance; math.Abs(expected-actual) > 1e-9 {
+			t.Errorf("Expected maximum luminance %v but got %v", expected, actual)
+		}
+		if expected, actual := 0.005, md.MasteringDisplay.MinLuminance; math.Abs(expected-actual) > 1e-9 {
+			t.Errorf("Expected minimum luminance %v but got %v", expected, actual)
+		}
+		if expected, actual := float32(0.708), md.MasteringDisplay.Primaries.Red.X; math.Abs(float64(expected-actual)) > 1e-5 {
+			t.Errorf("Expected red x of %v but got %v", expected, actual)
+		}
+		if expected, actual := float32(0.329), md.MasteringDisplay.WhitePoint.Y; math.Abs(float64(expected-actual)) > 1e-5 {
+			t.Errorf("Expected white point y of %v but got %v", expected, actual)
+		}
+	})
+}
+
+func TestImageProperties(t *testing.T) {
+
+	buildPNG := func(colourType, interlace byte, chunks ...[]byte) *bytes.Buffer {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, colourType, 0, 0, interlace})
+		for i := 0; i+1 < len(chunks); i += 2 {
+			var chunkType [4]byte
+			copy(chunkType[:], chunks[i])
+			writeChunk(data, chunkType, chunks[i+1])
+		}
+		writeChunk(data, chunkTypeIDAT, []byte{1, 2, 3, 4})
+		return data
+	}
+
+	t.Run("colour model and channels are determined by colour type", func(t *testing.T) {
+		cases := []struct {
+			colourType byte
+			colorModel meta.ColorModel
+			channels   uint32
+			hasAlpha   bool
+		}{
+			{0, meta.ColorModelGreyscale, 1, false},
+			{2, meta.ColorModelRGB, 3, false},
+			{3, meta.ColorModelPalette, 1, false},
+			{4, meta.ColorModelGreyscale, 2, true},
+			{6, meta.ColorModelRGB, 4, true},
+		}
+
+		for _, c := range cases {
+			md, err := extractMetadata(buildPNG(c.colourType, 0), meta.DefaultLimits)
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			if expected, actual := c.colorModel, md.ColorModel; expected != actual {
+				t.Errorf("Expected colour type %d to have colour model %v but got %v", c.colourType, expected, actual)
+			}
+			if expected, actual := c.channels, md.ChannelCount; expected != actual {
+				t.Errorf("Expected colour type %d to have %d channels but got %d", c.colourType, expected, actual)
+			}
+			if expected, actual := c.hasAlpha, md.HasAlpha; expected != actual {
+				t.Errorf("Expected colour type %d to have alpha %v but got %v", c.colourType, expected, actual)
+			}
+			if md.Interlaced {
+				t.Errorf("Expected image not to be interlaced")
+			}
+		}
+	})
+
+	t.Run("interlacing is reported", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(2, 1), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if !md.Interlaced {
+			t.Errorf("Expected image to be interlaced")
+		}
+	})
+
+	t.Run("transparency chunk indicates alpha", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(3, 0, []byte("tRNS"), []byte{0, 255}), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+	})
+
+	t.Run("animation control chunk indicates animation", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(6, 0, []byte("acTL"), []byte{0, 0, 0, 5, 0, 0, 0, 2}), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if !md.Animated {
+			t.Errorf("Expected image to be animated")
+		}
+		if expected, actual := uint32(5), md.FrameCount; expected != actual {
+			t.Errorf("Expected %d frames but got %d", expected, actual)
+		}
+		if expected, actual := uint32(2), md.LoopCount; expected != actual {
+			t.Errorf("Expected loop count %d but got %d", expected, actual)
+		}
+	})
+
+	t.Run("still image has a single frame", func(t *testing.T) {
+		md, err := extractMetadata(buildPNG(2, 0), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if md.Animated {
+			t.Errorf("Expected image not to be animated")
+		}
+		if expected, actual := uint32(1), md.FrameCount; expected != actual {
+			t.Errorf("Expected %d frames but got %d", expected, actual)
+		}
+	})
+}
+
+func TestLoadSeeker(t *testing.T) {
+
+	xmpData := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/>`)
+
+	buildPNG := func() []byte {
+		data := &bytes.Buffer{}
+		data.Write([]byte("padding"))
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, 2, 0, 0, 0})
+		writeChunk(data, chunkTypeIDAT, bytes.Repeat([]byte{1}, 10000))
+		writeChunk(data, chunkTypeIDAT, bytes.Repeat([]byte{2}, 10000))
+		writeChunk(data, chunkTypeiTXt, append(append([]byte{}, xmpKeyword...), append([]byte{0, 0, 0, 0}, xmpData...)...))
+		writeChunk(data, chunkTypeIEND, nil)
+		return data.Bytes()
+	}
+
+	t.Run("finds XMP data following the image data and restores stream position", func(t *testing.T) {
+		r := bytes.NewReader(buildPNG())
+		if _, err := r.Seek(7, io.SeekStart); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		md, err := LoadSeeker(r)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := uint32(16), md.PixelWidth; expected != actual {
+			t.Errorf("Expected image width of %d but got %d", expected, actual)
+		}
+		if expected, actual := xmpData, md.XMPData(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected XMP data %s but got %s", expected, actual)
+		}
+
+		if pos, _ := r.Seek(0, io.SeekCurrent); pos != 7 {
+			t.Errorf("Expected stream to be returned to position %d but was at %d", 7, pos)
+		}
+	})
+
+	t.Run("reads from a ReaderAt", func(t *testing.T) {
+		data := buildPNG()[7:]
+
+		md, err := LoadReaderAt(bytes.NewReader(data), int64(len(data)))
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := xmpData, md.XMPData(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected XMP data %s but got %s", expected, actual)
+		}
+	})
+
+	t.Run("doesn't read past the image data when not seeking", func(t *testing.T) {
+		md, _, err := Load(bytes.NewReader(buildPNG()[7:]))
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if actual := md.XMPData(); actual != nil {
+			t.Errorf("Expected no XMP data but got %s", actual)
+		}
+	})
+}
+
+func TestLimits(t *testing.T) {
+
+	compress := func(data []byte) []byte {
+		buf := &bytes.Buffer{}
+		w := zlib.NewWriter(buf)
+		_, _ = w.Write(data)
+		_ = w.Close()
+		return buf.Bytes()
+	}
+
+	buildPNG := func(chunks ...func(*bytes.Buffer)) []byte {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, 2, 0, 0, 0})
+		for _, c := range chunks {
+			c(data)
+		}
+		writeChunk(data, chunkTypeIDAT, bytes.Repeat([]byte{1}, 1000))
+		writeChunk(data, chunkTypeIEND, nil)
+		return data.Bytes()
+	}
+
+	iccChunk := func(compressedData []byte) func(*bytes.Buffer) {
+		return func(b *bytes.Buffer) {
+			writeChunk(b, chunkTypeiCCP, append([]byte("Profile\x00\x00"), compressedData...))
+		}
+	}
+
+	expectICCLimitError := func(t *testing.T, md *meta.Data, limit string) {
+		t.Helper()
+
+		iccData, iccErr := md.ICCProfileData()
+		if iccData != nil {
+			t.Errorf("Expected no ICC profile but got one")
+		}
+		var limitErr *meta.LimitError
+		if !errors.As(iccErr, &limitErr) {
+			t.Fatalf("Expected limit error but got %v", iccErr)
+		}
+		if expected, actual := limit, limitErr.Limit; expected != actual {
+			t.Errorf("Expected limit '%s' to be exceeded but got '%s'", expected, actual)
+		}
+	}
+
+	t.Run("oversized ICC profile is reported as an ICC profile error", func(t *testing.T) {
+		profile := compress(bytes.Repeat([]byte{1}, 5000))
+		limits := meta.Limits{MaxICCProfileSize: 1000}
+
+		md, _, err := LoadWithLimits(bytes.NewReader(buildPNG(iccChunk(profile))), limits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		expectICCLimitError(t, md, "MaxICCProfileSize")
+	})
+
+	t.Run("ICC profile decompressing beyond the limit is reported as an ICC profile error", func(t *testing.T) {
+		profile := compress(make([]byte, 1<<20))
+		limits := meta.Limits{MaxDecompressedSize: 1024}
+
+		md, _, err := LoadWithLimits(bytes.NewReader(buildPNG(iccChunk(profile))), limits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		expectICCLimitError(t, md, "MaxDecompressedSize")
+	})
+
+	t.Run("ICC profile with too many tags is reported when parsed", func(t *testing.T) {
+		profile := make([]byte, 128)
+		copy(profile[36:], "acsp")
+		profile = append(profile, 0, 0, 0, 2)
+		for i := 0; i < 2; i++ {
+			profile = append(profile, 't', 'e', 's', byte('0'+i), 0, 0, 0, 156, 0, 0, 0, 4)
+		}
+		profile = append(profile, 1, 2, 3, 4)
+		encbinary.BigEndian.PutUint32(profile, uint32(len(profile)))
+		limits := meta.Limits{MaxICCTagCount: 1}
+
+		md, _, err := LoadWithLimits(bytes.NewReader(buildPNG(iccChunk(compress(profile)))), limits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		_, err = md.ICCProfile()
+
+		var limitErr *meta.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("Expected limit error but got %v", err)
+		}
+		if expected, actual := "MaxTagCount", limitErr.Limit; expected != actual {
+			t.Errorf("Expected limit '%s' to be exceeded but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("returns error when too many chunks are read", func(t *testing.T) {
+		var chunks []func(*bytes.Buffer)
+		for i := 0; i < 10; i++ {
+			chunks = append(chunks, func(b *bytes.Buffer) {
+				writeChunk(b, [4]byte{'t', 'E', 'X', 't'}, []byte("Comment\x00text"))
+			})
+		}
+		limits := meta.Limits{MaxSegments: 5}
+
+		_, _, err := LoadWithLimits(bytes.NewReader(buildPNG(chunks...)), limits)
+
+		var limitErr *meta.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("Expected limit error but got %v", err)
+		}
+		if expected, actual := "MaxSegments", limitErr.Limit; expected != actual {
+			t.Errorf("Expected limit '%s' to be exceeded but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("returns error when too much of the stream is scanned", func(t *testing.T) {
+		data := buildPNG(func(b *bytes.Buffer) {
+			writeChunk(b, [4]byte{'z', 'Z', 'Z', 'z'}, make([]byte, 1000))
+		})
+		limits := meta.Limits{MaxBytesScanned: 500}
+
+		_, _, err := LoadWithLimits(bytes.NewReader(data), limits)
+		var limitErr *meta.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected limit error from LoadWithLimits but got %v", err)
+		}
+
+		_, err = LoadSeekerWithLimits(bytes.NewReader(data), limits)
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected limit error from LoadSeekerWithLimits but got %v", err)
+		}
+
+		_, err = LoadReaderAtWithLimits(bytes.NewReader(data), int64(len(data)), limits)
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected limit error from LoadReaderAtWithLimits but got %v", err)
+		}
+	})
+
+	t.Run("doesn't allocate the full length of a text chunk which extends past the end of the stream", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, 2, 0, 0, 0})
+		data.Write([]byte{0x00, 0xFF, 0xFF, 0xFF})
+		data.Write(chunkTypeiTXt[:])
+		data.WriteString("XML:com.adobe.xmp")
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := extractMetadata(bytes.NewReader(data.Bytes()), meta.DefaultLimits)
+		runtime.ReadMemStats(&after)
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("Expected less than 1MB to be allocated but got %d bytes", allocated)
+		}
+	})
+
+	t.Run("doesn't allocate the full length of an ICC profile chunk which extends past the end of the stream", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, 2, 0, 0, 0})
+		data.Write([]byte{0x01, 0x00, 0x00, 0x00})
+		data.Write(chunkTypeiCCP[:])
+		data.WriteString("ICC profile\x00\x00")
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := extractMetadata(bytes.NewReader(data.Bytes()), meta.DefaultLimits)
+		runtime.ReadMemStats(&after)
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("Expected less than 1MB to be allocated but got %d bytes", allocated)
+		}
+	})
+
+	t.Run("succeeds when within limits", func(t *testing.T) {
+		limits := meta.Limits{MaxICCProfileSize: 100, MaxDecompressedSize: 100, MaxBytesScanned: 2000, MaxSegments: 5}
+
+		md, _, err := LoadWithLimits(bytes.NewReader(buildPNG()), limits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := uint32(16), md.PixelWidth; expected != actual {
+			t.Errorf("Expected image width of %d but got %d", expected, actual)
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+
+	header := func() *bytes.Buffer {
+		data := &bytes.Buffer{}
+		data.Write(pngSignature[:])
+		writeChunk(data, chunkTypeIHDR, []byte{0, 0, 0, 16, 0, 0, 0, 16, 8, 2, 0, 0, 0})
+		return data
+	}
+
+	t.Run("truncated chunk is reported as truncated data", func(t *testing.T) {
+		data := header()
+		_ = binary.WriteU32Big(data, 100)
+		data.Write(chunkTypeeXIf[:])
+		data.Write([]byte{1, 2, 3})
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncation error but got %v", err)
+		}
+	})
+
+	t.Run("unknown ICC profile compression method is reported as unsupported", func(t *testing.T) {
+		data := header()
+		writeChunk(data, chunkTypeiCCP, []byte("Profile\x00\x01data"))
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		if !errors.Is(err, meta.ErrUnsupported) {
+			t.Errorf("Expected unsupported feature error but got %v", err)
+		}
+	})
+
+	t.Run("malformed chunk is reported with its offset", func(t *testing.T) {
+		data := header()
+		writeChunk(data, chunkTypeiCCP, []byte("Profile\x00\x00"))
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		var malformedErr *meta.MalformedError
+		if !errors.As(err, &malformedErr) {
+			t.Fatalf("Expected malformed chunk error but got %v", err)
+		}
+		if !errors.Is(err, meta.ErrMalformed) {
+			t.Errorf("Expected error to be reported as malformed data")
+		}
+		if expected, actual := "iCCP", malformedErr.Segment; expected != actual {
+			t.Errorf("Expected malformed %s chunk but got %s", expected, actual)
+		}
+		if expected, actual := int64(33), malformedErr.Offset; expected != actual {
+			t.Errorf("Expected malformed chunk at offset %d but got %d", expected, actual)
+		}
+	})
+}
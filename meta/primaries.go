@@ -0,0 +1,11 @@
+package meta
+
+import "github.com/mandykoh/prism/ciexyy"
+
+// Primaries describes the chromaticities of the red, green, and blue primaries
+// of an RGB colour space.
+type Primaries struct {
+	Red   ciexyy.Color
+	Green ciexyy.Color
+	Blue  ciexyy.Color
+}
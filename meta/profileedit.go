@@ -0,0 +1,83 @@
+package meta
+
+import (
+	"fmt"
+
+	"github.com/mandykoh/prism/meta/icc"
+)
+
+// ProfileAction is the change to make to the colour profile of an image.
+type ProfileAction int
+
+const (
+	// ProfileActionReplace embeds a new ICC profile, replacing any existing
+	// colour profile.
+	ProfileActionReplace ProfileAction = iota
+
+	// ProfileActionRemove removes any embedded ICC profile, leaving the image
+	// untagged. Other tags which describe the colour space and would take the
+	// profile's place (eg PNG sRGB and cICP chunks) are removed as well.
+	ProfileActionRemove
+
+	// ProfileActionSRGB tags the image as sRGB without an ICC profile. For
+	// formats with no other means of doing so (eg JPEG and WebP), this simply
+	// removes the ICC profile, as untagged images are treated as sRGB.
+	ProfileActionSRGB
+)
+
+func (pa ProfileAction) String() string {
+	switch pa {
+	case ProfileActionReplace:
+		return "Replace"
+	case ProfileActionRemove:
+		return "Remove"
+	case ProfileActionSRGB:
+		return "sRGB"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(pa))
+	}
+}
+
+// ProfileEdit describes a change to the colour profile of an image, as made by
+// functions such as pngmeta.EditProfile. Only the metadata of the image is
+// rewritten; the encoded image data is copied unchanged.
+type ProfileEdit struct {
+	Action ProfileAction
+
+	// ICCProfile is the profile to embed when the action is
+	// ProfileActionReplace.
+	ICCProfile []byte
+
+	// ProfileName is the name recorded for the embedded profile by formats
+	// which require one (eg PNG). If empty, "ICC Profile" is used. PNG only
+	// allows names of up to 79 printable Latin-1 characters, so other
+	// characters are dropped.
+	ProfileName string
+
+	// RenderingIntent is recorded along with an sRGB tag by formats which
+	// support it (eg PNG).
+	RenderingIntent icc.RenderingIntent
+}
+
+// Name returns the name to record for the embedded profile.
+func (pe ProfileEdit) Name() string {
+	if pe.ProfileName == "" {
+		return "ICC Profile"
+	}
+	return pe.ProfileName
+}
+
+// Validate returns an error if the edit can't be made, eg because no profile
+// was given to replace the existing one with.
+func (pe ProfileEdit) Validate() error {
+	switch pe.Action {
+	case ProfileActionReplace:
+		if len(pe.ICCProfile) == 0 {
+			return fmt.Errorf("no ICC profile given to replace with")
+		}
+	case ProfileActionRemove, ProfileActionSRGB:
+	default:
+		return fmt.Errorf("unknown profile action %v", pe.Action)
+	}
+	return nil
+}
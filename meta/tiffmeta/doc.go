@@ -0,0 +1,2 @@
+// Package tiffmeta provides support for working with embedded TIFF metadata.
+package tiffmeta
@@ -0,0 +1,191 @@
+package tiffmeta
+
+import (
+	"bytes"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// EditProfile copies a TIFF or BigTIFF image stream from r to w, changing the
+// colour profile of its first image as specified by edit. The image data is
+// not re-encoded.
+//
+// Because TIFF data is addressed by absolute offsets, the original stream is
+// copied unchanged apart from the first IFD, so that all other offsets remain
+// valid. Removing a profile rewrites that IFD in place without the ICC profile
+// tag. Otherwise, any new profile data is appended, followed by a new copy of
+// the IFD with the updated tag, and the header is pointed at the new copy.
+// The original profile is dropped if it's the last data in the stream, and is
+// otherwise left in place but no longer referenced. This requires reading the
+// entire stream into memory.
+//
+// As TIFF has no other means of tagging an image as sRGB,
+// meta.ProfileActionSRGB simply removes the profile.
+func EditProfile(w io.Writer, r io.Reader, edit meta.ProfileEdit) error {
+	if err := edit.Validate(); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	tr := &tiffReader{r: bytes.NewReader(data)}
+
+	ifdOffset, err := tr.readHeader()
+	if err != nil {
+		return err
+	}
+	entries, err := tr.readIFD(ifdOffset)
+	if err != nil {
+		return err
+	}
+
+	countSize, entrySize, valueSize := tr.ifdSizes()
+	ifdEnd := ifdOffset + countSize + uint64(len(entries))*entrySize
+
+	nextIFDOffset, err := tr.bytesAt(ifdEnd, valueSize)
+	if err != nil {
+		return err
+	}
+	ifdEnd += valueSize
+
+	headerSize := 8
+	if tr.bigTIFF {
+		headerSize = 16
+	}
+
+	newEntries := make([]ifdEntry, 0, len(entries)+1)
+	dataLength := uint64(len(data))
+	for _, e := range entries {
+		if e.tag != tagICCProfile {
+			newEntries = append(newEntries, e)
+			continue
+		}
+
+		// The old profile can be dropped if nothing follows it but padding
+		length := e.fieldType.size() * e.count
+		if length <= valueSize {
+			continue
+		}
+		offset := tr.offsetValue(e.value)
+		end := offset + length
+		if offset >= ifdEnd && offset >= uint64(headerSize) && end > offset && (end == dataLength || end%2 != 0 && end+1 == dataLength) {
+			dataLength = offset
+		}
+	}
+
+	// Nothing needs to change if there's no profile to remove
+	if len(newEntries) == len(entries) && edit.Action != meta.ProfileActionReplace {
+		_, err := w.Write(data)
+		return err
+	}
+
+	if edit.Action != meta.ProfileActionReplace {
+		ifd := tr.encodeIFD(newEntries, nextIFDOffset)
+		copy(data[ifdOffset:ifdEnd], append(ifd, make([]byte, ifdEnd-ifdOffset-uint64(len(ifd)))...))
+
+		_, err := w.Write(data[:dataLength])
+		return err
+	}
+	data = data[:dataLength]
+
+	// IFDs and the values they reference must begin on a word boundary
+	appended := &bytes.Buffer{}
+	pad := func() {
+		if (len(data)+appended.Len())%2 != 0 {
+			appended.WriteByte(0)
+		}
+	}
+	pad()
+
+	value := make([]byte, valueSize)
+	if uint64(len(edit.ICCProfile)) <= valueSize {
+		copy(value, edit.ICCProfile)
+	} else {
+		tr.putOffset(value, uint64(len(data)+appended.Len()))
+		appended.Write(edit.ICCProfile)
+		pad()
+	}
+
+	newEntries = append(newEntries, ifdEntry{
+		tag:       tagICCProfile,
+		fieldType: fieldTypeUndefined,
+		count:     uint64(len(edit.ICCProfile)),
+		value:     value,
+	})
+	sort.SliceStable(newEntries, func(i, j int) bool {
+		return newEntries[i].tag < newEntries[j].tag
+	})
+
+	newIFDOffset := uint64(len(data) + appended.Len())
+	appended.Write(tr.encodeIFD(newEntries, nextIFDOffset))
+
+	if !tr.bigTIFF && uint64(len(data)+appended.Len()) > math.MaxUint32 {
+		return binary.Errorf(meta.ErrUnsupported, "edited image is too large for a classic TIFF file")
+	}
+
+	// Point the header at the new IFD
+	header := append([]byte(nil), data[:headerSize]...)
+	tr.putOffset(header[headerSize-int(valueSize):], newIFDOffset)
+
+	for _, b := range [][]byte{header, data[headerSize:], appended.Bytes()} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeIFD returns the encoded form of an IFD with the specified entries,
+// followed by the offset of the next IFD.
+func (tr *tiffReader) encodeIFD(entries []ifdEntry, nextIFDOffset []byte) []byte {
+	countSize, entrySize, _ := tr.ifdSizes()
+	ifd := &bytes.Buffer{}
+
+	count := make([]byte, countSize)
+	if tr.bigTIFF {
+		tr.order.PutUint64(count, uint64(len(entries)))
+	} else {
+		tr.order.PutUint16(count, uint16(len(entries)))
+	}
+	ifd.Write(count)
+
+	for _, e := range entries {
+		entry := make([]byte, entrySize)
+		tr.order.PutUint16(entry, uint16(e.tag))
+		tr.order.PutUint16(entry[2:], uint16(e.fieldType))
+		if tr.bigTIFF {
+			tr.order.PutUint64(entry[4:], e.count)
+			copy(entry[12:], e.value)
+		} else {
+			tr.order.PutUint32(entry[4:], uint32(e.count))
+			copy(entry[8:], e.value)
+		}
+		ifd.Write(entry)
+	}
+	ifd.Write(nextIFDOffset)
+
+	return ifd.Bytes()
+}
+
+// offsetValue returns an offset stored in the size appropriate to the stream.
+func (tr *tiffReader) offsetValue(b []byte) uint64 {
+	if tr.bigTIFF {
+		return tr.order.Uint64(b)
+	}
+	return uint64(tr.order.Uint32(b))
+}
+
+// putOffset stores an offset in the size appropriate to the stream.
+func (tr *tiffReader) putOffset(b []byte, offset uint64) {
+	if tr.bigTIFF {
+		tr.order.PutUint64(b, offset)
+	} else {
+		tr.order.PutUint32(b, uint32(offset))
+	}
+}
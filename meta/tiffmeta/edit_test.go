@@ -0,0 +1,206 @@
+package tiffmeta
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"golang.org/x/image/tiff"
+	"image"
+	"os"
+	"testing"
+)
+
+func TestEditProfile(t *testing.T) {
+
+	edit := func(t *testing.T, input []byte, e meta.ProfileEdit) []byte {
+		output := &bytes.Buffer{}
+		if err := EditProfile(output, bytes.NewReader(input), e); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return output.Bytes()
+	}
+
+	load := func(t *testing.T, data []byte) *meta.Data {
+		md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return md
+	}
+
+	variants := []struct {
+		name    string
+		order   encbinary.ByteOrder
+		bigTIFF bool
+	}{
+		{"little endian TIFF", encbinary.LittleEndian, false},
+		{"big endian TIFF", encbinary.BigEndian, false},
+		{"little endian BigTIFF", encbinary.LittleEndian, true},
+		{"big endian BigTIFF", encbinary.BigEndian, true},
+	}
+
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+
+			buildImage := func(iccProfile []byte) []byte {
+				entries := []testEntry{
+					{tagImageWidth, fieldTypeLong, 1, longValue(v.order, 1200)},
+					{tagImageLength, fieldTypeShort, 1, shortValues(v.order, 800)},
+					{tagBitsPerSample, fieldTypeShort, 3, shortValues(v.order, 8, 8, 8)},
+					{tagPhotometricInterpretation, fieldTypeShort, 1, shortValues(v.order, uint16(PhotometricRGB))},
+				}
+				if iccProfile != nil {
+					entries = append(entries, testEntry{tagICCProfile, fieldTypeUndefined, uint64(len(iccProfile)), iccProfile})
+				}
+				return buildTIFF(v.order, v.bigTIFF, entries)
+			}
+
+			t.Run("replaces an existing profile", func(t *testing.T) {
+				oldProfile := []byte("old profile data")
+				input := buildImage(oldProfile)
+				newProfile := []byte("new, longer profile data")
+
+				output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+				headerSize := 8
+				if v.bigTIFF {
+					headerSize = 16
+				}
+				dataLength := len(input) - len(oldProfile)
+				if !bytes.Equal(input[headerSize:dataLength], output[headerSize:dataLength]) {
+					t.Errorf("Expected data following the header to be unchanged")
+				}
+				if bytes.Contains(output, oldProfile) {
+					t.Errorf("Expected old profile to be removed")
+				}
+
+				md := load(t, output)
+				if actual, _ := md.ICCProfileData(); !bytes.Equal(newProfile, actual) {
+					t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+				}
+				if expected, actual := uint32(1200), md.PixelWidth; expected != actual {
+					t.Errorf("Expected image width of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(8), md.BitsPerComponent; expected != actual {
+					t.Errorf("Expected image bits per component of %d but got %d", expected, actual)
+				}
+			})
+
+			t.Run("embeds a profile where there was none", func(t *testing.T) {
+				for _, newProfile := range [][]byte{[]byte("abc"), []byte("new profile data")} {
+					output := edit(t, buildImage(nil), meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+					if actual, _ := load(t, output).ICCProfileData(); !bytes.Equal(newProfile, actual) {
+						t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+					}
+				}
+			})
+
+			t.Run("removes the profile", func(t *testing.T) {
+				for _, action := range []meta.ProfileAction{meta.ProfileActionRemove, meta.ProfileActionSRGB} {
+					t.Run(action.String(), func(t *testing.T) {
+						input := buildImage([]byte("old profile data"))
+
+						output := edit(t, input, meta.ProfileEdit{Action: action})
+
+						if len(output) >= len(input) {
+							t.Errorf("Expected output to be smaller than %d bytes but was %d", len(input), len(output))
+						}
+
+						md := load(t, output)
+						if actual, err := md.ICCProfileData(); actual != nil || err != nil {
+							t.Errorf("Expected no profile but got %s (error %v)", actual, err)
+						}
+						if expected, actual := uint32(800), md.PixelHeight; expected != actual {
+							t.Errorf("Expected image height of %d but got %d", expected, actual)
+						}
+					})
+				}
+			})
+
+			t.Run("keeps an old profile which is followed by other data", func(t *testing.T) {
+				input := buildTIFF(v.order, v.bigTIFF, []testEntry{
+					{tagImageWidth, fieldTypeLong, 1, longValue(v.order, 1200)},
+					{tagImageLength, fieldTypeShort, 1, shortValues(v.order, 800)},
+					{tagICCProfile, fieldTypeUndefined, 16, []byte("old profile data")},
+					{tag(0xC000), fieldTypeUndefined, 16, []byte("other value data")},
+				})
+
+				output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+				if expected, actual := len(input), len(output); expected != actual {
+					t.Errorf("Expected output of %d bytes but got %d", expected, actual)
+				}
+				if !bytes.HasSuffix(output, []byte("old profile dataother value data")) {
+					t.Errorf("Expected value data to be unchanged")
+				}
+				if actual, err := load(t, output).ICCProfileData(); actual != nil || err != nil {
+					t.Errorf("Expected no profile but got %s (error %v)", actual, err)
+				}
+			})
+
+			t.Run("copies images without a profile to remove unchanged", func(t *testing.T) {
+				input := buildImage(nil)
+
+				output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+				if !bytes.Equal(input, output) {
+					t.Errorf("Expected output %v but got %v", input, output)
+				}
+			})
+		})
+	}
+
+	t.Run("preserves the pixels of a real image", func(t *testing.T) {
+		input, err := os.ReadFile("../../test-images/pizza-rgb8-prophotorgb.tiff")
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		expected, err := tiff.Decode(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		for _, e := range []meta.ProfileEdit{
+			{Action: meta.ProfileActionReplace, ICCProfile: []byte("new profile data")},
+			{Action: meta.ProfileActionRemove},
+		} {
+			t.Run(e.Action.String(), func(t *testing.T) {
+				output := edit(t, input, e)
+
+				if actual, _ := load(t, output).ICCProfileData(); !bytes.Equal(e.ICCProfile, actual) {
+					t.Errorf("Expected profile data %s but got %s", e.ICCProfile, actual)
+				}
+
+				actual, err := tiff.Decode(bytes.NewReader(output))
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if !bytes.Equal(expected.(*image.RGBA).Pix, actual.(*image.RGBA).Pix) {
+					t.Errorf("Expected pixels to be unchanged")
+				}
+			})
+		}
+	})
+
+	t.Run("returns error for invalid signature", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader([]byte("XX*\x00\x08\x00\x00\x00")), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+
+	t.Run("returns error for truncated IFD", func(t *testing.T) {
+		input := buildTIFF(encbinary.LittleEndian, false, []testEntry{
+			{tagImageWidth, fieldTypeShort, 1, shortValues(encbinary.LittleEndian, 64)},
+		})
+
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader(input[:12]), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package tiffmeta
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/meta"
+)
+
+// PhotometricInterpretation is the value of the TIFF PhotometricInterpretation
+// tag, describing the colour model of the image data.
+type PhotometricInterpretation uint16
+
+const (
+	PhotometricWhiteIsZero PhotometricInterpretation = 0
+	PhotometricBlackIsZero PhotometricInterpretation = 1
+	PhotometricRGB         PhotometricInterpretation = 2
+	PhotometricPalette     PhotometricInterpretation = 3
+	PhotometricMask        PhotometricInterpretation = 4
+	PhotometricSeparated   PhotometricInterpretation = 5
+	PhotometricYCbCr       PhotometricInterpretation = 6
+	PhotometricCIELab      PhotometricInterpretation = 8
+	PhotometricICCLab      PhotometricInterpretation = 9
+	PhotometricITULab      PhotometricInterpretation = 10
+)
+
+// ColorModel returns the colour model corresponding to this photometric
+// interpretation. Separated images are assumed to be CMYK.
+func (pi PhotometricInterpretation) ColorModel() meta.ColorModel {
+	switch pi {
+	case PhotometricWhiteIsZero, PhotometricBlackIsZero, PhotometricMask:
+		return meta.ColorModelGreyscale
+	case PhotometricRGB:
+		return meta.ColorModelRGB
+	case PhotometricPalette:
+		return meta.ColorModelPalette
+	case PhotometricSeparated:
+		return meta.ColorModelCMYK
+	case PhotometricYCbCr:
+		return meta.ColorModelYCbCr
+	case PhotometricCIELab, PhotometricICCLab, PhotometricITULab:
+		return meta.ColorModelLab
+	default:
+		return meta.ColorModelUnknown
+	}
+}
+
+func (pi PhotometricInterpretation) String() string {
+	switch pi {
+	case PhotometricWhiteIsZero:
+		return "WhiteIsZero"
+	case PhotometricBlackIsZero:
+		return "BlackIsZero"
+	case PhotometricRGB:
+		return "RGB"
+	case PhotometricPalette:
+		return "Palette"
+	case PhotometricMask:
+		return "Transparency mask"
+	case PhotometricSeparated:
+		return "Separated"
+	case PhotometricYCbCr:
+		return "YCbCr"
+	case PhotometricCIELab:
+		return "CIELab"
+	case PhotometricICCLab:
+		return "ICCLab"
+	case PhotometricITULab:
+		return "ITULab"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint16(pi))
+	}
+}
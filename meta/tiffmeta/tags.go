@@ -0,0 +1,50 @@
+package tiffmeta
+
+type tag uint16
+
+const (
+	tagImageWidth                tag = 256
+	tagImageLength               tag = 257
+	tagBitsPerSample             tag = 258
+	tagPhotometricInterpretation tag = 262
+	tagSamplesPerPixel           tag = 277
+	tagICCProfile                tag = 34675
+)
+
+type fieldType uint16
+
+const (
+	fieldTypeByte      fieldType = 1
+	fieldTypeASCII     fieldType = 2
+	fieldTypeShort     fieldType = 3
+	fieldTypeLong      fieldType = 4
+	fieldTypeRational  fieldType = 5
+	fieldTypeSByte     fieldType = 6
+	fieldTypeUndefined fieldType = 7
+	fieldTypeSShort    fieldType = 8
+	fieldTypeSLong     fieldType = 9
+	fieldTypeSRational fieldType = 10
+	fieldTypeFloat     fieldType = 11
+	fieldTypeDouble    fieldType = 12
+	fieldTypeIFD       fieldType = 13
+	fieldTypeLong8     fieldType = 16
+	fieldTypeSLong8    fieldType = 17
+	fieldTypeIFD8      fieldType = 18
+)
+
+// size returns the size in bytes of a single value of this type, or zero if
+// the type is unknown.
+func (ft fieldType) size() uint64 {
+	switch ft {
+	case fieldTypeByte, fieldTypeASCII, fieldTypeSByte, fieldTypeUndefined:
+		return 1
+	case fieldTypeShort, fieldTypeSShort:
+		return 2
+	case fieldTypeLong, fieldTypeSLong, fieldTypeFloat, fieldTypeIFD:
+		return 4
+	case fieldTypeRational, fieldTypeSRational, fieldTypeDouble, fieldTypeLong8, fieldTypeSLong8, fieldTypeIFD8:
+		return 8
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,453 @@
+package tiffmeta
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/exif"
+	"io"
+	"math"
+)
+
+// Format specifies the image format handled by this package
+var Format = meta.ImageFormat("TIFF")
+
+var (
+	littleEndianSignature = [2]byte{'I', 'I'}
+	bigEndianSignature    = [2]byte{'M', 'M'}
+)
+
+// Values of the tags other than the ICC profile are small, so larger values are
+// rejected to avoid allocating memory for malformed data
+const maxValueLength = 1024
+
+const (
+	classicTIFFVersion = 42
+	bigTIFFVersion     = 43
+)
+
+// Load loads the metadata for a TIFF or BigTIFF image stream.
+//
+// Only as much of the stream is consumed as necessary to extract the metadata;
+// the returned stream contains a buffered copy of the consumed data such that
+// reading from it will produce the same results as fully reading the input
+// stream. This provides a convenient way to load the full image after loading
+// the metadata.
+//
+// Because TIFF metadata may be stored anywhere in the file, this may require
+// reading up to the entire stream.
+//
+// An error is returned if basic metadata could not be extracted. The returned
+// stream still provides the full image data.
+func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a TIFF or BigTIFF image stream as per
+// Load, subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
+	// The data buffered for random access also serves to rewind the stream.
+	// It's captured before the scan limit is applied, so that any data read
+	// while detecting that the limit was exceeded is kept.
+	rewindBuffer := &bytes.Buffer{}
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(&streamReaderAt{r: lr, data: rewindBuffer}, limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a TIFF or BigTIFF image stream which
+// supports seeking.
+//
+// Reading begins at the current position of the stream, and only the parts of
+// the stream containing metadata are read, by seeking directly to them. The
+// stream is returned to its original position afterwards, so that the full
+// image can then be loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a TIFF or BigTIFF image stream
+// which supports seeking as per LoadSeeker, subject to the specified limits
+// rather than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err = extractMetadata(io.NewSectionReader(seekerReaderAt{r}, start, end-start), limits)
+
+	if _, restoreErr := r.Seek(start, io.SeekStart); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a TIFF or BigTIFF image of the specified
+// size in bytes. Only the parts of the image containing metadata are read.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a TIFF or BigTIFF image of the
+// specified size in bytes as per LoadReaderAt, subject to the specified limits
+// rather than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return extractMetadata(io.NewSectionReader(r, 0, size), limits)
+}
+
+func extractMetadata(r io.ReaderAt, limits meta.Limits) (md *meta.Data, err error) {
+	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
+
+	defer func() {
+		if r := recover(); r != nil {
+			md = nil
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
+		}
+		err = binary.Truncated(err)
+	}()
+
+	tr := &tiffReader{r: r, limits: limits}
+
+	ifdOffset, err := tr.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := tr.readIFD(ifdOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	hasWidth := false
+	hasHeight := false
+	photometric := PhotometricInterpretation(0xFFFF)
+	md.ChannelCount = 1
+
+	for _, e := range entries {
+		switch e.tag {
+
+		case tagImageWidth:
+			v, err := tr.uintValue(e)
+			if err != nil {
+				return nil, err
+			}
+			md.PixelWidth = uint32(v)
+			hasWidth = true
+
+		case tagImageLength:
+			v, err := tr.uintValue(e)
+			if err != nil {
+				return nil, err
+			}
+			md.PixelHeight = uint32(v)
+			hasHeight = true
+
+		case tagBitsPerSample:
+			v, err := tr.uintValue(e)
+			if err != nil {
+				return nil, err
+			}
+			md.BitsPerComponent = uint32(v)
+
+		case tagSamplesPerPixel:
+			v, err := tr.uintValue(e)
+			if err != nil {
+				return nil, err
+			}
+			md.ChannelCount = uint32(v)
+
+		case tagPhotometricInterpretation:
+			v, err := tr.uintValue(e)
+			if err != nil {
+				return nil, err
+			}
+			photometric = PhotometricInterpretation(v)
+		}
+	}
+
+	if !hasWidth || !hasHeight {
+		return nil, binary.Errorf(meta.ErrMalformed, "no metadata found")
+	}
+
+	if md.BitsPerComponent == 0 {
+		md.BitsPerComponent = 1
+	}
+
+	md.ColorModel = photometric.ColorModel()
+
+	// Read the ICC profile last, as it's typically stored after the smaller
+	// tag values
+	for _, e := range entries {
+		if e.tag == tagICCProfile {
+			data, err := tr.valueBytes(e, math.MaxInt64)
+			if err != nil {
+				md.SetICCProfileError(err)
+			} else {
+				md.SetICCProfileData(append([]byte(nil), data...))
+			}
+			break
+		}
+	}
+
+	if exifData, err := exif.Read(tr); err == nil && *exifData != (exif.Data{}) {
+		md.EXIF = exifData
+	}
+
+	return md, nil
+}
+
+type ifdEntry struct {
+	tag       tag
+	fieldType fieldType
+	count     uint64
+	value     []byte
+}
+
+type tiffReader struct {
+	r       io.ReaderAt
+	order   encbinary.ByteOrder
+	bigTIFF bool
+	limits  meta.Limits
+	scanned int64
+}
+
+// readHeader reads the TIFF or BigTIFF header, determining the byte order and
+// offset size of the stream, and returns the offset of the first IFD.
+func (tr *tiffReader) readHeader() (ifdOffset uint64, err error) {
+	header, err := tr.bytesAt(0, 8)
+	if err != nil {
+		return 0, err
+	}
+
+	switch [2]byte{header[0], header[1]} {
+	case littleEndianSignature:
+		tr.order = encbinary.LittleEndian
+	case bigEndianSignature:
+		tr.order = encbinary.BigEndian
+	default:
+		return 0, binary.Errorf(meta.ErrFormatMismatch, "invalid TIFF signature")
+	}
+
+	switch tr.order.Uint16(header[2:]) {
+
+	case classicTIFFVersion:
+		return uint64(tr.order.Uint32(header[4:])), nil
+
+	case bigTIFFVersion:
+		tr.bigTIFF = true
+
+		if tr.order.Uint16(header[4:]) != 8 || tr.order.Uint16(header[6:]) != 0 {
+			return 0, binary.Errorf(meta.ErrUnsupported, "unsupported BigTIFF offset size")
+		}
+
+		offset, err := tr.bytesAt(8, 8)
+		if err != nil {
+			return 0, err
+		}
+		return tr.order.Uint64(offset), nil
+
+	default:
+		return 0, binary.Errorf(meta.ErrFormatMismatch, "invalid TIFF signature")
+	}
+}
+
+// bytesAt returns the specified range of bytes from the stream.
+func (tr *tiffReader) bytesAt(offset, length uint64) ([]byte, error) {
+	end := offset + length
+	if end < offset || end > math.MaxInt64 {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid offset %d", offset)
+	}
+
+	tr.scanned += int64(length)
+	if max := tr.limits.MaxBytesScanned; max > 0 && tr.scanned > max {
+		return nil, &meta.LimitError{Limit: "MaxBytesScanned", Value: max}
+	}
+
+	data := make([]byte, length)
+	n, err := tr.r.ReadAt(data, int64(offset))
+	if uint64(n) < length {
+		if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, binary.Errorf(meta.ErrTruncated, "unexpected EOF")
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (tr *tiffReader) ReadAt(p []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, binary.Errorf(meta.ErrMalformed, "invalid offset %d", offset)
+	}
+
+	data, err := tr.bytesAt(uint64(offset), uint64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+
+	return copy(p, data), nil
+}
+
+// ifdSizes returns the sizes in bytes of the entry count, each entry, and each
+// value or offset (including the offset of the next IFD) within an IFD.
+func (tr *tiffReader) ifdSizes() (countSize, entrySize, valueSize uint64) {
+	if tr.bigTIFF {
+		return 8, 20, 8
+	}
+	return 2, 12, 4
+}
+
+func (tr *tiffReader) readIFD(offset uint64) ([]ifdEntry, error) {
+	countSize, entrySize, valueSize := tr.ifdSizes()
+
+	countBytes, err := tr.bytesAt(offset, countSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var count uint64
+	if tr.bigTIFF {
+		count = tr.order.Uint64(countBytes)
+	} else {
+		count = uint64(tr.order.Uint16(countBytes))
+	}
+
+	if count > 0xFFFF {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid IFD entry count %d", count)
+	}
+
+	data, err := tr.bytesAt(offset+countSize, count*entrySize)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ifdEntry, count)
+	for i := range entries {
+		e := data[uint64(i)*entrySize:]
+		entries[i].tag = tag(tr.order.Uint16(e))
+		entries[i].fieldType = fieldType(tr.order.Uint16(e[2:]))
+		if tr.bigTIFF {
+			entries[i].count = tr.order.Uint64(e[4:])
+			entries[i].value = e[12 : 12+valueSize]
+		} else {
+			entries[i].count = uint64(tr.order.Uint32(e[4:]))
+			entries[i].value = e[8 : 8+valueSize]
+		}
+	}
+
+	return entries, nil
+}
+
+// uintValue returns the first value of an integer field.
+func (tr *tiffReader) uintValue(e ifdEntry) (uint64, error) {
+	if e.count == 0 {
+		return 0, binary.Errorf(meta.ErrMalformed, "missing value for tag %d", e.tag)
+	}
+
+	v, err := tr.valueBytes(e, maxValueLength)
+	if err != nil {
+		return 0, err
+	}
+
+	switch e.fieldType {
+	case fieldTypeByte:
+		return uint64(v[0]), nil
+	case fieldTypeShort:
+		return uint64(tr.order.Uint16(v)), nil
+	case fieldTypeLong:
+		return uint64(tr.order.Uint32(v)), nil
+	case fieldTypeLong8:
+		return tr.order.Uint64(v), nil
+	default:
+		return 0, binary.Errorf(meta.ErrMalformed, "unexpected field type %d for tag %d", e.fieldType, e.tag)
+	}
+}
+
+// valueBytes returns the raw bytes of all the values of a field, which must not
+// be longer than maxLength.
+func (tr *tiffReader) valueBytes(e ifdEntry, maxLength uint64) ([]byte, error) {
+	size := e.fieldType.size()
+	if size == 0 {
+		return nil, binary.Errorf(meta.ErrMalformed, "unknown field type %d for tag %d", e.fieldType, e.tag)
+	}
+
+	length := size * e.count
+	if e.count != 0 && length/e.count != size {
+		return nil, binary.Errorf(meta.ErrMalformed, "invalid value count for tag %d", e.tag)
+	}
+	if e.tag == tagICCProfile {
+		if err := tr.limits.CheckICCProfileSize(int64(length)); err != nil {
+			return nil, err
+		}
+	}
+	if length > maxLength {
+		return nil, binary.Errorf(meta.ErrUnsupported, "value too large for tag %d", e.tag)
+	}
+
+	// Values which fit within the entry are stored inline; otherwise the entry
+	// contains their offset
+	if length <= uint64(len(e.value)) {
+		return e.value[:length], nil
+	}
+
+	var offset uint64
+	if tr.bigTIFF {
+		offset = tr.order.Uint64(e.value)
+	} else {
+		offset = uint64(tr.order.Uint32(e.value))
+	}
+
+	return tr.bytesAt(offset, length)
+}
+
+// streamReaderAt implements io.ReaderAt for a stream, reading further into the
+// stream as necessary. Everything read from r must also be written to data (eg
+// by an io.TeeReader), which buffers the contents of the stream.
+type streamReaderAt struct {
+	r    io.Reader
+	data *bytes.Buffer
+}
+
+func (sr *streamReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	end := offset + int64(len(p))
+
+	if available := int64(sr.data.Len()); end > available {
+		if _, err := io.CopyN(io.Discard, sr.r, end-available); err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+
+	if offset >= int64(sr.data.Len()) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, sr.data.Bytes()[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// seekerReaderAt implements io.ReaderAt for a stream which supports seeking.
+type seekerReaderAt struct {
+	r io.ReadSeeker
+}
+
+func (sr seekerReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	if _, err := sr.r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(sr.r, p)
+}
@@ -0,0 +1,124 @@
+package tiffmeta_test
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/tiffmeta"
+	"golang.org/x/image/tiff"
+)
+
+func printICCProfile(md *meta.Data) {
+	profile, err := md.ICCProfile()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("ProfileSize: %d\n", profile.Header.ProfileSize)
+	fmt.Printf("PreferredCMM: %v\n", profile.Header.PreferredCMM)
+	fmt.Printf("ProfileVersion: %s\n", profile.Header.Version)
+	fmt.Printf("DeviceClass: %s\n", profile.Header.DeviceClass)
+	fmt.Printf("DataColorSpace: %s\n", profile.Header.DataColorSpace)
+	fmt.Printf("PCS: %s\n", profile.Header.ProfileConnectionSpace)
+	fmt.Printf("CreatedAt: %v\n", profile.Header.CreatedAt)
+	fmt.Printf("PrimaryPlatform: %v\n", profile.Header.PrimaryPlatform)
+	fmt.Printf("Embedded: %v\n", profile.Header.Embedded)
+	fmt.Printf("DependsOnEmbeddedData: %v\n", profile.Header.DependsOnEmbeddedData)
+	fmt.Printf("DeviceManufacturer: %s\n", profile.Header.DeviceManufacturer)
+	fmt.Printf("DeviceModel: %s\n", profile.Header.DeviceModel)
+	fmt.Printf("DeviceAttributes: %064b\n", profile.Header.DeviceAttributes)
+	fmt.Printf("RenderingIntent: %v\n", profile.Header.RenderingIntent)
+	fmt.Printf("PCSIlluminant: %v\n", profile.Header.PCSIlluminant)
+	fmt.Printf("ProfileCreator: %v\n", profile.Header.ProfileCreator)
+	fmt.Printf("ProfileID: %0x\n", profile.Header.ProfileID)
+
+	if desc, err := profile.Description(); err != nil {
+		panic(err)
+	} else {
+		fmt.Printf("Description: %s\n", desc)
+	}
+}
+
+func printMetadata(md *meta.Data, img image.Image) {
+	fmt.Printf("Format: %s\n", md.Format)
+	fmt.Printf("BitsPerComponent: %d\n", md.BitsPerComponent)
+	fmt.Printf("PixelHeight: %d\n", md.PixelHeight)
+	fmt.Printf("PixelWidth: %d\n", md.PixelWidth)
+	fmt.Printf("ColorModel: %s\n", md.ColorModel)
+	fmt.Printf("ChannelCount: %d\n", md.ChannelCount)
+
+	fmt.Printf("Actual image height: %d\n", img.Bounds().Dy())
+	fmt.Printf("Actual image width: %d\n", img.Bounds().Dx())
+}
+
+func ExampleLoad_basicTIFFMetadata() {
+	inFile, err := os.Open("../../test-images/pizza-rgb8-prophotorgb.tiff")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, imgStream, err := tiffmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	img, err := tiff.Decode(imgStream)
+	if err != nil {
+		panic(err)
+	}
+
+	printMetadata(md, img)
+
+	// Output:
+	// Format: TIFF
+	// BitsPerComponent: 8
+	// PixelHeight: 64
+	// PixelWidth: 64
+	// ColorModel: RGB
+	// ChannelCount: 3
+	// Actual image height: 64
+	// Actual image width: 64
+}
+
+func ExampleLoad_embeddedICCv2() {
+	inFile, err := os.Open("../../test-images/pizza-rgb8-prophotorgb.tiff")
+	if err != nil {
+		panic(err)
+	}
+	defer inFile.Close()
+
+	md, imgStream, err := tiffmeta.Load(inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = tiff.Decode(imgStream)
+	if err != nil {
+		panic(err)
+	}
+
+	printICCProfile(md)
+
+	// Output:
+	// ProfileSize: 940
+	// PreferredCMM: 'lcms'
+	// ProfileVersion: 2.1.0
+	// DeviceClass: Display
+	// DataColorSpace: RGB
+	// PCS: XYZ
+	// CreatedAt: 1998-12-01 18:58:21 +0000 UTC
+	// PrimaryPlatform: Apple Computer, Inc.
+	// Embedded: false
+	// DependsOnEmbeddedData: false
+	// DeviceManufacturer: 'KODA'
+	// DeviceModel: 'ROMM'
+	// DeviceAttributes: 0000000000000000000000000000000000000000000000000000000000000000
+	// RenderingIntent: Perceptual
+	// PCSIlluminant: [63190 65536 54061]
+	// ProfileCreator: 'lcms'
+	// ProfileID: 00000000000000000000000000000000
+	// Description: ProPhoto RGB
+}
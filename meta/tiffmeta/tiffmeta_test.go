@@ -0,0 +1,294 @@
+package tiffmeta
+
+import (
+	"bytes"
+	encbinary "encoding/binary"
+	"errors"
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/exif"
+	"io"
+	"testing"
+)
+
+type testEntry struct {
+	tag       tag
+	fieldType fieldType
+	count     uint64
+	value     []byte
+}
+
+// buildTIFF constructs a TIFF stream with a single IFD containing the specified
+// entries, with any values too large to be stored inline placed after the IFD.
+func buildTIFF(order encbinary.ByteOrder, bigTIFF bool, entries []testEntry) []byte {
+	buf := &bytes.Buffer{}
+
+	if order == encbinary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+
+	countSize, entrySize, valueSize := 2, 12, 4
+	headerSize := 8
+	if bigTIFF {
+		countSize, entrySize, valueSize = 8, 20, 8
+		headerSize = 16
+		encbinary.Write(buf, order, uint16(43))
+		encbinary.Write(buf, order, uint16(8))
+		encbinary.Write(buf, order, uint16(0))
+		encbinary.Write(buf, order, uint64(headerSize))
+		encbinary.Write(buf, order, uint64(len(entries)))
+	} else {
+		encbinary.Write(buf, order, uint16(42))
+		encbinary.Write(buf, order, uint32(headerSize))
+		encbinary.Write(buf, order, uint16(len(entries)))
+	}
+
+	extraOffset := headerSize + countSize + len(entries)*entrySize + valueSize
+	extra := &bytes.Buffer{}
+
+	for _, e := range entries {
+		encbinary.Write(buf, order, uint16(e.tag))
+		encbinary.Write(buf, order, uint16(e.fieldType))
+
+		if bigTIFF {
+			encbinary.Write(buf, order, e.count)
+		} else {
+			encbinary.Write(buf, order, uint32(e.count))
+		}
+
+		value := make([]byte, valueSize)
+		if len(e.value) <= valueSize {
+			copy(value, e.value)
+		} else if bigTIFF {
+			order.PutUint64(value, uint64(extraOffset+extra.Len()))
+			extra.Write(e.value)
+		} else {
+			order.PutUint32(value, uint32(extraOffset+extra.Len()))
+			extra.Write(e.value)
+		}
+		buf.Write(value)
+	}
+
+	buf.Write(make([]byte, valueSize))
+	buf.Write(extra.Bytes())
+
+	return buf.Bytes()
+}
+
+func shortValues(order encbinary.ByteOrder, values ...uint16) []byte {
+	b := make([]byte, len(values)*2)
+	for i, v := range values {
+		order.PutUint16(b[i*2:], v)
+	}
+	return b
+}
+
+func longValue(order encbinary.ByteOrder, v uint32) []byte {
+	b := make([]byte, 4)
+	order.PutUint32(b, v)
+	return b
+}
+
+func TestExtractMetadata(t *testing.T) {
+
+	iccProfileData := []byte("not really an ICC profile, but long enough")
+
+	variants := []struct {
+		name    string
+		order   encbinary.ByteOrder
+		bigTIFF bool
+	}{
+		{"little endian TIFF", encbinary.LittleEndian, false},
+		{"big endian TIFF", encbinary.BigEndian, false},
+		{"little endian BigTIFF", encbinary.LittleEndian, true},
+		{"big endian BigTIFF", encbinary.BigEndian, true},
+	}
+
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+
+			t.Run("returns all metadata", func(t *testing.T) {
+				data := buildTIFF(v.order, v.bigTIFF, []testEntry{
+					{tagImageWidth, fieldTypeLong, 1, longValue(v.order, 1200)},
+					{tagImageLength, fieldTypeShort, 1, shortValues(v.order, 800)},
+					{tagBitsPerSample, fieldTypeShort, 4, shortValues(v.order, 16, 16, 16, 16)},
+					{tagPhotometricInterpretation, fieldTypeShort, 1, shortValues(v.order, uint16(PhotometricSeparated))},
+					{tagSamplesPerPixel, fieldTypeShort, 1, shortValues(v.order, 4)},
+					{tagICCProfile, fieldTypeUndefined, uint64(len(iccProfileData)), iccProfileData},
+				})
+
+				md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if expected, actual := uint32(1200), md.PixelWidth; expected != actual {
+					t.Errorf("Expected image width of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(800), md.PixelHeight; expected != actual {
+					t.Errorf("Expected image height of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(16), md.BitsPerComponent; expected != actual {
+					t.Errorf("Expected image bits per component of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(4), md.ChannelCount; expected != actual {
+					t.Errorf("Expected channel count of %d but got %d", expected, actual)
+				}
+				if expected, actual := meta.ColorModelCMYK, md.ColorModel; expected != actual {
+					t.Errorf("Expected colour model %v but got %v", expected, actual)
+				}
+
+				iccData, iccErr := md.ICCProfileData()
+				if iccErr != nil {
+					t.Errorf("Expected ICC profile data but got error: %v", iccErr)
+				} else if !bytes.Equal(iccProfileData, iccData) {
+					t.Errorf("Expected ICC profile data %v but got %v", iccProfileData, iccData)
+				}
+			})
+
+			t.Run("returns metadata without ICC profile if the tag is not present", func(t *testing.T) {
+				data := buildTIFF(v.order, v.bigTIFF, []testEntry{
+					{tagImageWidth, fieldTypeShort, 1, shortValues(v.order, 64)},
+					{tagImageLength, fieldTypeShort, 1, shortValues(v.order, 32)},
+					{tagPhotometricInterpretation, fieldTypeShort, 1, shortValues(v.order, uint16(PhotometricBlackIsZero))},
+				})
+
+				md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if expected, actual := uint32(1), md.BitsPerComponent; expected != actual {
+					t.Errorf("Expected default bits per component of %d but got %d", expected, actual)
+				}
+				if expected, actual := uint32(1), md.ChannelCount; expected != actual {
+					t.Errorf("Expected default channel count of %d but got %d", expected, actual)
+				}
+				if expected, actual := meta.ColorModelGreyscale, md.ColorModel; expected != actual {
+					t.Errorf("Expected colour model %v but got %v", expected, actual)
+				}
+
+				iccData, iccErr := md.ICCProfileData()
+				if iccErr != nil {
+					t.Errorf("Expected no ICC profile error but got: %v", iccErr)
+				}
+				if iccData != nil {
+					t.Errorf("Expected no ICC profile but got one")
+				}
+
+				if md.EXIF != nil {
+					t.Errorf("Expected no EXIF data but got %+v", *md.EXIF)
+				}
+			})
+
+			t.Run("returns EXIF tags from the IFD", func(t *testing.T) {
+				tagOrientation := tag(0x0112)
+
+				data := buildTIFF(v.order, v.bigTIFF, []testEntry{
+					{tagImageWidth, fieldTypeShort, 1, shortValues(v.order, 64)},
+					{tagImageLength, fieldTypeShort, 1, shortValues(v.order, 32)},
+					{tagOrientation, fieldTypeShort, 1, shortValues(v.order, uint16(exif.OrientationBottomRight))},
+				})
+
+				md, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if md.EXIF == nil {
+					t.Fatalf("Expected EXIF data but got none")
+				}
+				if expected, actual := exif.OrientationBottomRight, md.EXIF.Orientation; expected != actual {
+					t.Errorf("Expected orientation %v but got %v", expected, actual)
+				}
+			})
+		})
+	}
+
+	t.Run("returns error with invalid signature", func(t *testing.T) {
+		_, err := extractMetadata(bytes.NewReader([]byte("NOT A TIFF FILE")), meta.DefaultLimits)
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "invalid TIFF signature", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("returns error if image dimensions are not found", func(t *testing.T) {
+		data := buildTIFF(encbinary.LittleEndian, false, []testEntry{
+			{tagImageWidth, fieldTypeShort, 1, shortValues(encbinary.LittleEndian, 64)},
+		})
+
+		_, err := extractMetadata(bytes.NewReader(data), meta.DefaultLimits)
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "no metadata found", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("returns error if the IFD is truncated", func(t *testing.T) {
+		data := buildTIFF(encbinary.LittleEndian, false, []testEntry{
+			{tagImageWidth, fieldTypeShort, 1, shortValues(encbinary.LittleEndian, 64)},
+			{tagImageLength, fieldTypeShort, 1, shortValues(encbinary.LittleEndian, 64)},
+		})
+
+		_, err := extractMetadata(bytes.NewReader(data[:20]), meta.DefaultLimits)
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		} else if expected, actual := "unexpected EOF", err.Error(); expected != actual {
+			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+
+	t.Run("returns a stream providing the full image data", func(t *testing.T) {
+		data := buildTIFF(encbinary.BigEndian, false, []testEntry{
+			{tagImageWidth, fieldTypeShort, 1, shortValues(encbinary.BigEndian, 64)},
+			{tagImageLength, fieldTypeShort, 1, shortValues(encbinary.BigEndian, 32)},
+		})
+		data = append(data, bytes.Repeat([]byte("image data"), 100)...)
+
+		md, imgStream, err := Load(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := uint32(64), md.PixelWidth; expected != actual {
+			t.Errorf("Expected image width of %d but got %d", expected, actual)
+		}
+
+		actual, err := io.ReadAll(imgStream)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if !bytes.Equal(data, actual) {
+			t.Errorf("Expected stream to provide the full image data")
+		}
+	})
+	t.Run("returns a stream providing the full image data when a limit is exceeded", func(t *testing.T) {
+		data := make([]byte, 208)
+		copy(data, "II*\x00")
+		encbinary.LittleEndian.PutUint32(data[4:], 100)
+
+		_, imgStream, err := LoadWithLimits(bytes.NewReader(data), meta.Limits{MaxBytesScanned: 50})
+
+		var limitErr *meta.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected limit error but got %v", err)
+		}
+
+		actual, err := io.ReadAll(imgStream)
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if !bytes.Equal(data, actual) {
+			t.Errorf("Expected stream to provide all %d bytes of the image but got %d", len(data), len(actual))
+		}
+	})
+}
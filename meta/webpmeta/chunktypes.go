@@ -7,4 +7,9 @@ var (
 	chunkTypeVP8L = [4]byte{'V', 'P', '8', 'L'}
 	chunkTypeVP8X = [4]byte{'V', 'P', '8', 'X'}
 	chunkTypeICCP = [4]byte{'I', 'C', 'C', 'P'}
+	chunkTypeALPH = [4]byte{'A', 'L', 'P', 'H'}
+	chunkTypeANIM = [4]byte{'A', 'N', 'I', 'M'}
+	chunkTypeANMF = [4]byte{'A', 'N', 'M', 'F'}
+	chunkTypeEXIF = [4]byte{'E', 'X', 'I', 'F'}
+	chunkTypeXMP  = [4]byte{'X', 'M', 'P', ' '}
 )
@@ -0,0 +1,206 @@
+package webpmeta
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+)
+
+// Flags in the first byte of the VP8X chunk
+const (
+	vp8xFlagICCProfile = 0x20
+	vp8xFlagAlpha      = 0x10
+)
+
+const vp8xChunkLength = 10
+
+// EditProfile copies a WebP image stream from r to w, changing its colour
+// profile as specified by edit. All other chunks are copied byte-for-byte, and
+// the image data is not re-encoded.
+//
+// Embedding a profile in a simple (VP8 or VP8L) image converts it to the
+// extended format by adding a VP8X chunk. As WebP has no other means of
+// tagging an image as sRGB, meta.ProfileActionSRGB simply removes the profile.
+func EditProfile(w io.Writer, r io.Reader, edit meta.ProfileEdit) error {
+	if err := edit.Validate(); err != nil {
+		return err
+	}
+
+	var newChunk []byte
+	if edit.Action == meta.ProfileActionReplace {
+		if uint64(len(edit.ICCProfile)) > math.MaxUint32-8 {
+			return binary.Errorf(meta.ErrUnsupported, "ICC profile of %d bytes is too large to embed in a WebP image", len(edit.ICCProfile))
+		}
+		newChunk = encodeChunk(chunkTypeICCP, edit.ICCProfile)
+	}
+
+	br := binary.NewOffsetReader(bufio.NewReader(r))
+
+	riffLength, err := verifySignature(br)
+	if err != nil {
+		return binary.Truncated(err)
+	}
+
+	ch, err := readChunkHeader(br)
+	if err != nil {
+		return binary.Truncated(err)
+	}
+
+	switch ch.ChunkType {
+	case chunkTypeVP8X:
+		return editExtendedProfile(w, br, riffLength, ch, newChunk)
+	case chunkTypeVP8, chunkTypeVP8L:
+		return editSimpleProfile(w, br, riffLength, ch, newChunk)
+	default:
+		return binary.Errorf(meta.ErrUnsupported, "unknown WebP format")
+	}
+}
+
+func editExtendedProfile(w io.Writer, r *binary.OffsetReader, riffLength uint32, vp8x chunkHeader, newChunk []byte) error {
+	if vp8x.Length < vp8xChunkLength {
+		return binary.Malformed("VP8X", formatChunkOffset, "invalid chunk length %d", vp8x.Length)
+	}
+	vp8xData, err := readChunkData(r, vp8x, vp8x.Length)
+	if err != nil {
+		return binary.Truncated(err)
+	}
+	if vp8x.Length&1 != 0 {
+		if err := skip(r, 1); err != nil {
+			return binary.Truncated(err)
+		}
+	}
+
+	// An existing profile must immediately follow the VP8X chunk
+	next, err := readChunkHeader(r)
+	if err != nil && err != io.EOF {
+		return binary.Truncated(err)
+	}
+	hasNext := err == nil
+
+	newLength := int64(riffLength) + int64(len(newChunk))
+	if hasNext && next.ChunkType == chunkTypeICCP {
+		paddedLength := int64(next.Length) + int64(next.Length&1)
+		if err := binary.Skip(r, paddedLength); err != nil {
+			return binary.Truncated(err)
+		}
+		newLength -= 8 + paddedLength
+		hasNext = false
+	}
+	if newLength < 0 || newLength > math.MaxUint32 {
+		return binary.Malformed("RIFF", 0, "invalid RIFF length %d", riffLength)
+	}
+
+	if newChunk != nil {
+		vp8xData[0] |= vp8xFlagICCProfile
+	} else {
+		vp8xData[0] &^= vp8xFlagICCProfile
+	}
+
+	out := &bytes.Buffer{}
+	writeHeader(out, uint32(newLength))
+	out.Write(encodeChunk(chunkTypeVP8X, vp8xData))
+	out.Write(newChunk)
+	if hasNext {
+		writeChunkHeader(out, next)
+	}
+	if _, err := out.WriteTo(w); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func editSimpleProfile(w io.Writer, r *binary.OffsetReader, riffLength uint32, bitstream chunkHeader, newChunk []byte) error {
+	out := &bytes.Buffer{}
+
+	// Without a profile to add, the image is copied unchanged
+	if newChunk == nil {
+		writeHeader(out, riffLength)
+		writeChunkHeader(out, bitstream)
+		if _, err := out.WriteTo(w); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	var head []byte
+	var width, height uint32
+	var flags byte = vp8xFlagICCProfile
+
+	if bitstream.ChunkType == chunkTypeVP8 {
+		head = make([]byte, 10)
+		if _, err := io.ReadFull(r, head); err != nil {
+			return binary.Truncated(err)
+		}
+		var err error
+		if width, height, err = vp8Dimensions(head); err != nil {
+			return err
+		}
+	} else {
+		head = make([]byte, 5)
+		if _, err := io.ReadFull(r, head); err != nil {
+			return binary.Truncated(err)
+		}
+		var alphaIsUsed bool
+		var err error
+		if width, height, alphaIsUsed, err = vp8lDimensions(head); err != nil {
+			return err
+		}
+		if alphaIsUsed {
+			flags |= vp8xFlagAlpha
+		}
+	}
+
+	newLength := uint64(riffLength) + 8 + vp8xChunkLength + uint64(len(newChunk))
+	if newLength > math.MaxUint32 {
+		return binary.Errorf(meta.ErrUnsupported, "image is too large to embed an ICC profile")
+	}
+
+	vp8xData := []byte{
+		flags, 0, 0, 0,
+		byte(width - 1), byte((width - 1) >> 8), byte((width - 1) >> 16),
+		byte(height - 1), byte((height - 1) >> 8), byte((height - 1) >> 16),
+	}
+
+	writeHeader(out, uint32(newLength))
+	out.Write(encodeChunk(chunkTypeVP8X, vp8xData))
+	out.Write(newChunk)
+	writeChunkHeader(out, bitstream)
+	out.Write(head)
+	if _, err := out.WriteTo(w); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// writeHeader writes the RIFF header of a WebP image with the specified RIFF
+// length.
+func writeHeader(w *bytes.Buffer, riffLength uint32) {
+	writeChunkHeader(w, chunkHeader{ChunkType: chunkTypeRIFF, Length: riffLength})
+	w.Write(webpSignature[:])
+}
+
+func writeChunkHeader(w *bytes.Buffer, ch chunkHeader) {
+	w.Write(ch.ChunkType[:])
+	_ = binary.WriteU32Little(w, ch.Length)
+}
+
+// encodeChunk returns a complete chunk with the specified type and data,
+// including any padding.
+func encodeChunk(chunkType [4]byte, data []byte) []byte {
+	chunk := &bytes.Buffer{}
+	writeChunkHeader(chunk, chunkHeader{ChunkType: chunkType, Length: uint32(len(data))})
+	chunk.Write(data)
+	if len(data)&1 != 0 {
+		chunk.WriteByte(0)
+	}
+	return chunk.Bytes()
+}
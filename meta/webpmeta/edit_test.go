@@ -0,0 +1,122 @@
+package webpmeta
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/mandykoh/prism/meta"
+	"github.com/mandykoh/prism/meta/binary"
+	"golang.org/x/image/webp"
+)
+
+func TestEditProfile(t *testing.T) {
+
+	readImage := func(name string) []byte {
+		data, err := os.ReadFile("../../test-images/" + name)
+		if err != nil {
+			panic(err)
+		}
+		return data
+	}
+
+	edit := func(t *testing.T, input []byte, e meta.ProfileEdit) []byte {
+		t.Helper()
+
+		output := &bytes.Buffer{}
+		if err := EditProfile(output, bytes.NewReader(input), e); err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		return output.Bytes()
+	}
+
+	assertRIFFLength := func(t *testing.T, data []byte) {
+		t.Helper()
+
+		riffLength, _ := binary.ReadU32Little(bytes.NewReader(data[4:8]))
+		if expected, actual := uint32(len(data)-8), riffLength; expected != actual {
+			t.Errorf("Expected RIFF length %d but got %d", expected, actual)
+		}
+	}
+
+	newProfile := []byte("new profile data!")
+
+	for _, name := range []string{"checkerboard-srgb-vp8.webp", "checkerboard-srgb-vp8l.webp", "pizza-rgb8-displayp3-vp8x.webp"} {
+		t.Run(name, func(t *testing.T) {
+			input := readImage(name)
+
+			original, err := extractMetadata(bytes.NewReader(input), meta.DefaultLimits)
+			if err != nil {
+				t.Fatalf("Expected success but got error: %v", err)
+			}
+
+			t.Run("embeds a new profile", func(t *testing.T) {
+				output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+
+				assertRIFFLength(t, output)
+
+				md, err := extractMetadata(bytes.NewReader(output), meta.DefaultLimits)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if actual, _ := md.ICCProfileData(); !bytes.Equal(newProfile, actual) {
+					t.Errorf("Expected profile data %s but got %s", newProfile, actual)
+				}
+				if expected, actual := original.PixelWidth, md.PixelWidth; expected != actual {
+					t.Errorf("Expected width %d but got %d", expected, actual)
+				}
+				if expected, actual := original.PixelHeight, md.PixelHeight; expected != actual {
+					t.Errorf("Expected height %d but got %d", expected, actual)
+				}
+				if expected, actual := original.HasAlpha, md.HasAlpha; expected != actual {
+					t.Errorf("Expected alpha %v but got %v", expected, actual)
+				}
+
+				img, err := webp.Decode(bytes.NewReader(output))
+				if err != nil {
+					t.Fatalf("Expected image to decode but got error: %v", err)
+				}
+				if expected, actual := int(original.PixelWidth), img.Bounds().Dx(); expected != actual {
+					t.Errorf("Expected decoded width %d but got %d", expected, actual)
+				}
+			})
+
+			t.Run("removes any profile", func(t *testing.T) {
+				output := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+				assertRIFFLength(t, output)
+
+				md, err := extractMetadata(bytes.NewReader(output), meta.DefaultLimits)
+				if err != nil {
+					t.Fatalf("Expected success but got error: %v", err)
+				}
+				if actual, _ := md.ICCProfileData(); actual != nil {
+					t.Errorf("Expected no profile data but got %d bytes", len(actual))
+				}
+				if expected, actual := original.PixelWidth, md.PixelWidth; expected != actual {
+					t.Errorf("Expected width %d but got %d", expected, actual)
+				}
+			})
+
+			t.Run("preserves image data", func(t *testing.T) {
+				withProfile := edit(t, input, meta.ProfileEdit{Action: meta.ProfileActionReplace, ICCProfile: newProfile})
+				output := edit(t, withProfile, meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+				// Everything after the VP8X chunk and profile is unchanged
+				tail := input[len(input)-len(input)/2:]
+				if !bytes.HasSuffix(output, tail) {
+					t.Errorf("Expected image data to be unchanged")
+				}
+			})
+		})
+	}
+
+	t.Run("returns error for non-WebP input", func(t *testing.T) {
+		err := EditProfile(&bytes.Buffer{}, bytes.NewReader([]byte("RIFF....NOTP")), meta.ProfileEdit{Action: meta.ProfileActionRemove})
+
+		if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
+		}
+	})
+}
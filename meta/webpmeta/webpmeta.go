@@ -4,11 +4,11 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
 
 	"github.com/mandykoh/prism/meta"
 	"github.com/mandykoh/prism/meta/binary"
+	"github.com/mandykoh/prism/meta/exif"
 )
 
 // Format specifies the image format handled by this package
@@ -28,6 +28,12 @@ const (
 // Bits per component is fixed in WebP
 const bitsPerComponent = 8
 
+// The chunk determining the format always follows the RIFF header
+const formatChunkOffset = 12
+
+const maxEXIFChunkLength = 65535
+const maxXMPChunkLength = 16 << 20
+
 // Load loads the metadata for a WebP image stream.
 //
 // Only as much of the stream is consumed as necessary to extract the metadata;
@@ -39,115 +45,186 @@ const bitsPerComponent = 8
 // An error is returned if basic metadata could not be extracted. The returned
 // stream still provides the full image data.
 func Load(r io.Reader) (md *meta.Data, imgStream io.Reader, err error) {
+	return LoadWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadWithLimits loads the metadata for a WebP image stream as per Load,
+// subject to the specified limits rather than meta.DefaultLimits.
+func LoadWithLimits(r io.Reader, limits meta.Limits) (md *meta.Data, imgStream io.Reader, err error) {
 	rewindBuffer := &bytes.Buffer{}
-	tee := io.TeeReader(r, rewindBuffer)
-	md, err = extractMetadata(bufio.NewReader(tee))
-	return md, io.MultiReader(rewindBuffer, r), err
+	lr := limits.ScanReader(io.TeeReader(r, rewindBuffer))
+	md, err = extractMetadata(bufio.NewReader(lr), limits)
+	return md, io.MultiReader(rewindBuffer, r), lr.Cause(err)
+}
+
+// LoadSeeker loads the metadata for a WebP image stream which supports seeking.
+//
+// Reading begins at the current position of the stream, and chunks which don't
+// contain metadata (including any image data preceding EXIF and XMP metadata)
+// are skipped by seeking past them. The stream is returned to its original
+// position afterwards, so that the full image can then be loaded from it.
+//
+// An error is returned if basic metadata could not be extracted.
+func LoadSeeker(r io.ReadSeeker) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(r, meta.DefaultLimits)
+}
+
+// LoadSeekerWithLimits loads the metadata for a WebP image stream which
+// supports seeking as per LoadSeeker, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadSeekerWithLimits(r io.ReadSeeker, limits meta.Limits) (md *meta.Data, err error) {
+	sr, err := limits.ScanSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	md, err = extractMetadata(sr, limits)
+	err = sr.Cause(err)
+	if restoreErr := sr.Restore(); restoreErr != nil && err == nil {
+		return nil, restoreErr
+	}
+	return md, err
+}
+
+// LoadReaderAt loads the metadata for a WebP image of the specified size in
+// bytes, as per LoadSeeker.
+func LoadReaderAt(r io.ReaderAt, size int64) (md *meta.Data, err error) {
+	return LoadReaderAtWithLimits(r, size, meta.DefaultLimits)
+}
+
+// LoadReaderAtWithLimits loads the metadata for a WebP image of the specified
+// size in bytes as per LoadReaderAt, subject to the specified limits rather
+// than meta.DefaultLimits.
+func LoadReaderAtWithLimits(r io.ReaderAt, size int64, limits meta.Limits) (md *meta.Data, err error) {
+	return LoadSeekerWithLimits(io.NewSectionReader(r, 0, size), limits)
 }
 
-func extractMetadata(r binary.Reader) (md *meta.Data, err error) {
+func extractMetadata(input binary.Reader, limits meta.Limits) (md *meta.Data, err error) {
 	md = &meta.Data{Format: Format}
+	md.SetLimits(limits)
+	r := binary.NewOffsetReader(input)
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("panic while extracting image metadata: %v", r)
+			err = binary.Errorf(meta.ErrMalformed, "panic while extracting image metadata: %v", r)
 		}
+		err = binary.Truncated(err)
 	}()
 
-	if err := verifySignature(r); err != nil {
+	if _, err := verifySignature(r); err != nil {
 		return nil, err
 	}
 	format, chunkLen, err := readWebPFormat(r)
 	if err != nil {
 		return nil, err
 	}
-	err = parseFormat(r, md, format, chunkLen)
+	err = parseFormat(r, md, format, chunkLen, limits)
 	if err != nil {
 		return nil, err
 	}
 	return md, nil
 }
 
-func parseFormat(r binary.Reader, md *meta.Data, format webpFormat, chunkLen uint32) error {
+func parseFormat(r *binary.OffsetReader, md *meta.Data, format webpFormat, chunkLen uint32, limits meta.Limits) error {
 	switch format {
 	case webpFormatExtended:
-		return parseWebpExtended(r, md, chunkLen)
+		return parseWebpExtended(r, md, chunkLen, limits)
 	case webpFormatSimple:
 		return parseWebpSimple(r, md, chunkLen)
 	case webpFormatLossless:
 		return parseWebpLossless(r, md, chunkLen)
 	default:
-		return errors.New("unknown WebP format")
+		return binary.Errorf(meta.ErrUnsupported, "unknown WebP format")
 	}
 }
 
 func parseWebpSimple(r binary.Reader, md *meta.Data, chunkLen uint32) error {
-	if err := skip(r, 3); err != nil {
-		return err
-	}
-	var b [7]byte
+	var b [10]byte
 	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return err
 	}
-	if b[0] != 0x9d || b[1] != 0x01 || b[2] != 0x2a {
-		return errors.New("corrupted WebP VP8 frame")
+	width, height, err := vp8Dimensions(b[:])
+	if err != nil {
+		return err
 	}
-	md.PixelWidth = uint32(b[4]&((1<<6)-1))<<8 | uint32(b[3])
-	md.PixelWidth = uint32(b[6]&((1<<6)-1))<<8 | uint32(b[5])
+	md.PixelWidth = width
+	md.PixelHeight = height
 	md.BitsPerComponent = bitsPerComponent
+	md.ColorModel = meta.ColorModelYCbCr
+	md.ChannelCount = 3
+	md.FrameCount = 1
+	md.LoopCount = 1
 	return nil
 }
 
 func parseWebpLossless(r binary.Reader, md *meta.Data, chunkLen uint32) error {
-	sig, err := r.ReadByte()
-	if err != nil {
-		return err
-	}
-	if sig != 0x2f {
-		return errors.New("corrupted lossless WebP")
-	}
-	// Next 28 bits are width-1 and height-1.
-	b0, err := r.ReadByte()
-	if err != nil {
+	var b [5]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return err
 	}
-	b1, err := r.ReadByte()
+	width, height, alphaIsUsed, err := vp8lDimensions(b[:])
 	if err != nil {
 		return err
 	}
-	b2, err := r.ReadByte()
-	if err != nil {
-		return err
+	md.PixelWidth = width
+	md.PixelHeight = height
+	md.BitsPerComponent = bitsPerComponent
+	md.ColorModel = meta.ColorModelRGB
+	md.ChannelCount = 3
+	md.HasAlpha = alphaIsUsed
+	if alphaIsUsed {
+		md.ChannelCount = 4
 	}
-	b3, err := r.ReadByte()
-	if err != nil {
-		return err
+	md.FrameCount = 1
+	md.LoopCount = 1
+	return nil
+}
+
+// vp8Dimensions returns the image dimensions from the first 10 bytes of a VP8
+// bitstream, being the frame tag and key frame header.
+func vp8Dimensions(b []byte) (width, height uint32, err error) {
+	if b[3] != 0x9d || b[4] != 0x01 || b[5] != 0x2a {
+		return 0, 0, binary.Malformed("VP8", formatChunkOffset, "corrupted WebP VP8 frame")
 	}
+	width = uint32(b[7]&((1<<6)-1))<<8 | uint32(b[6])
+	height = uint32(b[9]&((1<<6)-1))<<8 | uint32(b[8])
+	return width, height, nil
+}
 
-	w := uint32(b0)
-	w |= uint32(b1&((1<<6)-1)) << 8
+// vp8lDimensions returns the image dimensions and alpha hint from the first 5
+// bytes of a VP8L bitstream.
+func vp8lDimensions(b []byte) (width, height uint32, alphaIsUsed bool, err error) {
+	if b[0] != 0x2f {
+		return 0, 0, false, binary.Malformed("VP8L", formatChunkOffset, "corrupted lossless WebP")
+	}
+	// Next 28 bits are width-1 and height-1.
+	w := uint32(b[1])
+	w |= uint32(b[2]&((1<<6)-1)) << 8
 	w &= 0x3FFF
 
-	h := uint32((b1 >> 6) & ((1 << 2) - 1))
-	h |= uint32(b2) << 2
-	h |= uint32(b3&((1<<4)-1)) << 10
+	h := uint32((b[2] >> 6) & ((1 << 2) - 1))
+	h |= uint32(b[3]) << 2
+	h |= uint32(b[4]&((1<<4)-1)) << 10
 	h &= 0x3FFF
 
-	md.PixelWidth = w + 1
-	md.PixelHeight = h + 1
-	md.BitsPerComponent = bitsPerComponent
-	return nil
+	// Next bit is the alpha hint.
+	alphaIsUsed = b[4]&(1<<4) != 0
+
+	return w + 1, h + 1, alphaIsUsed, nil
 }
 
-func parseWebpExtended(r binary.Reader, md *meta.Data, chunkLen uint32) error {
+func parseWebpExtended(r *binary.OffsetReader, md *meta.Data, chunkLen uint32, limits meta.Limits) error {
 	if chunkLen != 10 {
-		return fmt.Errorf("unexpected VP8X chunk length: %d", chunkLen)
+		return binary.Malformed("VP8X", formatChunkOffset, "unexpected VP8X chunk length: %d", chunkLen)
 	}
 	flags, err := r.ReadByte()
 	if err != nil {
 		return err
 	}
 	hasProfile := flags&(1<<5) != 0
+	hasEXIF := flags&(1<<3) != 0
+	hasXMP := flags&(1<<2) != 0
+	md.HasAlpha = flags&(1<<4) != 0
+	md.Animated = flags&(1<<1) != 0
 	// Next 3 bytes are reserved, skip them.
 	for i := 0; i < 3; i++ {
 		if _, err = r.ReadByte(); err != nil {
@@ -167,57 +244,233 @@ func parseWebpExtended(r binary.Reader, md *meta.Data, chunkLen uint32) error {
 	md.PixelHeight = h + 1
 	md.BitsPerComponent = bitsPerComponent
 
-	if hasProfile {
-		data, err := readICCP(r, chunkLen)
+	if !md.Animated {
+		md.FrameCount = 1
+		md.LoopCount = 1
+	}
+
+	// Reading continues until the image data has been found, along with any
+	// EXIF and XMP chunks (which typically follow the image data) and all the
+	// frames of an animation. This may require reading up to the entire
+	// stream. Errors reading beyond the ICC profile are ignored, as the basic
+	// metadata has already been extracted.
+	imageFound := false
+	firstChunk := true
+	padding := false
+	chunkCount := 1
+
+readChunks:
+	for !imageFound || hasEXIF || hasXMP || md.Animated {
+		if padding {
+			if _, err := r.ReadByte(); err != nil {
+				break
+			}
+		}
+
+		chunkOffset := r.Offset()
+		ch, err := readChunkHeader(r)
 		if err != nil {
-			md.SetICCProfileError(err)
-		} else {
-			md.SetICCProfileData(data)
+			if hasProfile && firstChunk {
+				md.SetICCProfileError(binary.Truncated(err))
+			}
+			break
+		}
+		padding = ch.Length%2 != 0
+
+		chunkCount++
+		if limits.CheckSegmentCount(chunkCount) != nil {
+			break
+		}
+
+		// ICCP _must_ be the first chunk following VP8X.
+		if hasProfile && firstChunk && ch.ChunkType != chunkTypeICCP {
+			md.SetICCProfileError(binary.Malformed(string(ch.ChunkType[:]), chunkOffset, "no expected ICCP chunk"))
+		}
+		firstChunk = false
+
+		switch ch.ChunkType {
+
+		case chunkTypeICCP:
+			if !hasProfile {
+				break
+			}
+			if err := limits.CheckICCProfileSize(int64(ch.Length)); err != nil {
+				md.SetICCProfileError(err)
+				break
+			}
+			data := &bytes.Buffer{}
+			if _, err := io.CopyN(data, r, int64(ch.Length)); err != nil {
+				md.SetICCProfileError(binary.Truncated(err))
+				break readChunks
+			}
+			md.SetICCProfileData(data.Bytes())
+			continue
+
+		case chunkTypeVP8, chunkTypeVP8L:
+			imageFound = true
+			setBitstreamColorModel(md, ch.ChunkType)
+
+			// Avoid reading the image data unless there is more to find
+			if !hasEXIF && !hasXMP && !md.Animated {
+				break readChunks
+			}
+
+		case chunkTypeALPH:
+			md.HasAlpha = true
+
+		case chunkTypeANIM:
+			data, err := readChunkData(r, ch, 6)
+			if err != nil {
+				break readChunks
+			}
+			if len(data) == 6 {
+				md.LoopCount = uint32(data[4]) | uint32(data[5])<<8
+			}
+			continue
+
+		case chunkTypeANMF:
+			md.FrameCount++
+			if md.FrameCount == 1 {
+				consumed, err := readFrameColorModel(r, md, ch.Length)
+				if err != nil {
+					break readChunks
+				}
+				ch.Length -= consumed
+			}
+
+		case chunkTypeEXIF:
+			if !hasEXIF {
+				break
+			}
+			hasEXIF = false
+			data, err := readChunkData(r, ch, maxEXIFChunkLength)
+			if err != nil {
+				break readChunks
+			}
+			if exifData, err := exif.Parse(data); err == nil {
+				md.EXIF = exifData
+			}
+			continue
+
+		case chunkTypeXMP:
+			if !hasXMP {
+				break
+			}
+			hasXMP = false
+			data, err := readChunkData(r, ch, maxXMPChunkLength)
+			if err != nil {
+				break readChunks
+			}
+			md.SetXMPData(data)
+			continue
+		}
+
+		if err := binary.Skip(r, int64(ch.Length)); err != nil {
+			break
+		}
+	}
+
+	if md.ColorModel != meta.ColorModelUnknown {
+		md.ChannelCount = 3
+		if md.HasAlpha {
+			md.ChannelCount = 4
 		}
 	}
 
 	return nil
 }
 
-func readICCP(r binary.Reader, chunkLen uint32) ([]byte, error) {
-	// Skip to the end of the chunk.
-	if err := skip(r, chunkLen-10); err != nil {
-		return nil, err
+// readFrameColorModel reads the header of an animation frame and the headers
+// of the chunks it contains up to the frame's image data, from which the
+// colour model is determined. The number of bytes of the frame read is
+// returned.
+func readFrameColorModel(r binary.Reader, md *meta.Data, frameLen uint32) (uint32, error) {
+	const frameHeaderLen = 16
+
+	if frameLen < frameHeaderLen {
+		return 0, errors.New("invalid ANMF chunk length")
+	}
+	if err := skip(r, frameHeaderLen); err != nil {
+		return 0, err
 	}
+	consumed := uint32(frameHeaderLen)
 
-	// ICCP _must_ be the next chunk.
-	ch, err := readChunkHeader(r)
-	if err != nil {
-		return nil, err
+	for consumed+8 <= frameLen {
+		ch, err := readChunkHeader(r)
+		if err != nil {
+			return consumed, err
+		}
+		consumed += 8
+
+		if ch.ChunkType == chunkTypeVP8 || ch.ChunkType == chunkTypeVP8L {
+			setBitstreamColorModel(md, ch.ChunkType)
+			return consumed, nil
+		}
+
+		length := ch.Length + ch.Length%2
+		if length > frameLen-consumed {
+			return consumed, errors.New("invalid ANMF frame data")
+		}
+		if err := binary.Skip(r, int64(length)); err != nil {
+			return consumed, err
+		}
+		consumed += length
 	}
-	if ch.ChunkType != chunkTypeICCP {
-		return nil, errors.New("no expected ICCP chunk")
+
+	return consumed, nil
+}
+
+// setBitstreamColorModel sets the colour model according to the type of the
+// chunk containing the image bitstream. Lossy images are encoded as YCbCr and
+// lossless images as RGB.
+func setBitstreamColorModel(md *meta.Data, chunkType [4]byte) {
+	if chunkType == chunkTypeVP8L {
+		md.ColorModel = meta.ColorModelRGB
+	} else {
+		md.ColorModel = meta.ColorModelYCbCr
 	}
+}
 
-	// Extract ICCP.
-	data := make([]byte, ch.Length)
-	if _, err := io.ReadFull(r, data); err != nil {
+// readChunkData reads the data of a chunk, excluding any padding byte. The
+// data of chunks which are larger than maxLength is skipped and nil is
+// returned.
+//
+// The data is read incrementally rather than allocated up front, so that a
+// chunk length which is much larger than the remaining stream can't cause a
+// large allocation.
+func readChunkData(r binary.Reader, ch chunkHeader, maxLength uint32) ([]byte, error) {
+	if ch.Length > maxLength {
+		return nil, binary.Skip(r, int64(ch.Length))
+	}
+
+	data := &bytes.Buffer{}
+	if _, err := io.CopyN(data, r, int64(ch.Length)); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
-	return data, nil
+	return data.Bytes(), nil
 }
 
-func verifySignature(r binary.Reader) error {
+// verifySignature reads the RIFF header of a WebP image, returning the length
+// recorded in it.
+func verifySignature(r binary.Reader) (riffLength uint32, err error) {
 	ch, err := readChunkHeader(r)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if ch.ChunkType != chunkTypeRIFF {
-		return errors.New("missing RIFF header")
+		return 0, binary.Errorf(meta.ErrFormatMismatch, "missing RIFF header")
 	}
 	var fourcc [4]byte
 	if _, err := io.ReadFull(r, fourcc[:]); err != nil {
-		return err
+		return 0, err
 	}
 	if fourcc != webpSignature {
-		return errors.New("not a WEBP file")
+		return 0, binary.Errorf(meta.ErrFormatMismatch, "not a WEBP file")
 	}
-	return nil
+	return ch.Length, nil
 }
 
 func readWebPFormat(r binary.Reader) (format webpFormat, length uint32, err error) {
@@ -233,7 +486,7 @@ func readWebPFormat(r binary.Reader) (format webpFormat, length uint32, err erro
 	case chunkTypeVP8X:
 		return webpFormatExtended, ch.Length, nil
 	default:
-		return 0, 0, fmt.Errorf("unexpected WEBP format: %s", string(ch.ChunkType[:]))
+		return 0, 0, binary.Errorf(meta.ErrUnsupported, "unexpected WEBP format: %s", string(ch.ChunkType[:]))
 	}
 }
 
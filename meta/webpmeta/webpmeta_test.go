@@ -2,8 +2,11 @@ package webpmeta
 
 import (
 	"bytes"
+	"errors"
+	"runtime"
 	"testing"
 
+	"github.com/mandykoh/prism/meta"
 	"github.com/mandykoh/prism/meta/binary"
 )
 
@@ -12,12 +15,14 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte("NOT A RIFF SIGNATURE"))
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected error but succeeded")
 		} else if expected, actual := "missing RIFF header", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		} else if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
 		}
 	})
 
@@ -25,12 +30,14 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte("RIFF....NOTP"))
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected error but succeeded")
 		} else if expected, actual := "not a WEBP file", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		} else if !errors.Is(err, meta.ErrFormatMismatch) {
+			t.Errorf("Expected format mismatch error but got %v", err)
 		}
 	})
 
@@ -38,12 +45,32 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte("RIFF\x28\x51\x04\x00WEBPVP8"))
 
-		_, err := extractMetadata(data)
+		_, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err == nil {
 			t.Errorf("Expected error but succeeded")
 		} else if expected, actual := "unexpected EOF reading chunk type", err.Error(); expected != actual {
 			t.Errorf("Expected error '%s' but got '%s'", expected, actual)
+		} else if !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncation error but got %v", err)
+		}
+	})
+
+	t.Run("returns error with offset for malformed extended header", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x08\x00\x00\x00\x14\x00\x00\x00\xaf\x04\x00\xaf"))
+
+		_, err := extractMetadata(data, meta.DefaultLimits)
+
+		var malformedErr *meta.MalformedError
+		if !errors.As(err, &malformedErr) {
+			t.Fatalf("Expected malformed chunk error but got %v", err)
+		}
+		if expected, actual := "VP8X", malformedErr.Segment; expected != actual {
+			t.Errorf("Expected malformed %s chunk but got %s", expected, actual)
+		}
+		if expected, actual := int64(12), malformedErr.Offset; expected != actual {
+			t.Errorf("Expected malformed chunk at offset %d but got %d", expected, actual)
 		}
 	})
 
@@ -51,7 +78,7 @@ func TestExtractMetadata(t *testing.T) {
 		data := &bytes.Buffer{}
 		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00\x14\x00\x00\x00\xaf\x04\x00\xaf\x04\x00"))
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -89,7 +116,7 @@ func TestExtractMetadata(t *testing.T) {
 		binary.WriteU32Little(data, uint32(len(iccProfileData)))
 		data.Write(iccProfileData)
 
-		md, err := extractMetadata(data)
+		md, err := extractMetadata(data, meta.DefaultLimits)
 
 		if err != nil {
 			t.Fatalf("Expected success but got error: %v", err)
@@ -128,4 +155,230 @@ func TestExtractMetadata(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("returns EXIF data following the image data", func(t *testing.T) {
+		exifData := []byte{
+			'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+			0x00, 0x01,
+			0x87, 0x69, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x1A,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x02,
+			0xA0, 0x01, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0xFF, 0xFF, 0x00, 0x00,
+			0xA0, 0x05, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x38,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x01,
+			0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x04, 'R', '0', '3', 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		}
+
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00\x08\x00\x00\x00\xaf\x04\x00\xaf\x04\x00"))
+		data.Write(chunkTypeVP8[:])
+		binary.WriteU32Little(data, 3)
+		data.Write([]byte{1, 2, 3, 0})
+		data.Write(chunkTypeEXIF[:])
+		binary.WriteU32Little(data, uint32(len(exifData)))
+		data.Write(exifData)
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+
+		if md.EXIF == nil {
+			t.Errorf("Expected EXIF data but got none")
+		} else if !md.EXIF.IsAdobeRGB() {
+			t.Errorf("Expected EXIF data to indicate Adobe RGB but got %+v", *md.EXIF)
+		}
+	})
+
+	t.Run("returns XMP data following the EXIF data", func(t *testing.T) {
+		exifData := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00\x00")
+		xmpData := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/>`)
+
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00\x0c\x00\x00\x00\xaf\x04\x00\xaf\x04\x00"))
+		data.Write(chunkTypeVP8[:])
+		binary.WriteU32Little(data, 3)
+		data.Write([]byte{1, 2, 3, 0})
+		data.Write(chunkTypeEXIF[:])
+		binary.WriteU32Little(data, uint32(len(exifData)))
+		data.Write(exifData)
+		data.Write([]byte{0})
+		data.Write(chunkTypeXMP[:])
+		binary.WriteU32Little(data, uint32(len(xmpData)))
+		data.Write(xmpData)
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if md.EXIF == nil {
+			t.Errorf("Expected EXIF data but got none")
+		}
+		if expected, actual := xmpData, md.XMPData(); !bytes.Equal(expected, actual) {
+			t.Errorf("Expected XMP data %s but got %s", expected, actual)
+		}
+	})
+
+	t.Run("returns alpha and colour model for lossless image", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\x00\x00\x00\x00WEBPVP8L\x05\x00\x00\x00\x2f\x09\x40\x03\x10"))
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := uint32(10), md.PixelWidth; expected != actual {
+			t.Errorf("Expected image width of %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(14), md.PixelHeight; expected != actual {
+			t.Errorf("Expected image height of %d but got %d", expected, actual)
+		}
+		if expected, actual := meta.ColorModelRGB, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+		if expected, actual := uint32(4), md.ChannelCount; expected != actual {
+			t.Errorf("Expected channel count of %d but got %d", expected, actual)
+		}
+		if md.Animated {
+			t.Errorf("Expected image not to be animated")
+		}
+	})
+
+	t.Run("returns colour model of extended image from image chunk", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00\x10\x00\x00\x00\xaf\x04\x00\xaf\x04\x00"))
+		data.Write(chunkTypeALPH[:])
+		binary.WriteU32Little(data, 3)
+		data.Write([]byte{1, 2, 3, 0})
+		data.Write(chunkTypeVP8[:])
+		binary.WriteU32Little(data, 3)
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := meta.ColorModelYCbCr, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		if !md.HasAlpha {
+			t.Errorf("Expected image to have alpha")
+		}
+		if expected, actual := uint32(4), md.ChannelCount; expected != actual {
+			t.Errorf("Expected channel count of %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(1), md.FrameCount; expected != actual {
+			t.Errorf("Expected frame count of %d but got %d", expected, actual)
+		}
+	})
+
+	t.Run("returns animation frame count and loop count", func(t *testing.T) {
+		frame := func(chunkType [4]byte) []byte {
+			f := &bytes.Buffer{}
+			f.Write(make([]byte, 16))
+			f.Write(chunkTypeALPH[:])
+			binary.WriteU32Little(f, 1)
+			f.Write([]byte{0, 0})
+			f.Write(chunkType[:])
+			binary.WriteU32Little(f, 2)
+			f.Write([]byte{0, 0})
+			return f.Bytes()
+		}
+
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00\x02\x00\x00\x00\xaf\x04\x00\xaf\x04\x00"))
+		data.Write(chunkTypeANIM[:])
+		binary.WriteU32Little(data, 6)
+		data.Write([]byte{0, 0, 0, 0, 3, 0})
+		for _, ct := range [][4]byte{chunkTypeVP8L, chunkTypeVP8, chunkTypeVP8} {
+			f := frame(ct)
+			data.Write(chunkTypeANMF[:])
+			binary.WriteU32Little(data, uint32(len(f)))
+			data.Write(f)
+		}
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if !md.Animated {
+			t.Errorf("Expected image to be animated")
+		}
+		if expected, actual := uint32(3), md.FrameCount; expected != actual {
+			t.Errorf("Expected frame count of %d but got %d", expected, actual)
+		}
+		if expected, actual := uint32(3), md.LoopCount; expected != actual {
+			t.Errorf("Expected loop count of %d but got %d", expected, actual)
+		}
+		if expected, actual := meta.ColorModelRGB, md.ColorModel; expected != actual {
+			t.Errorf("Expected colour model %v but got %v", expected, actual)
+		}
+		if md.HasAlpha {
+			t.Errorf("Expected image not to have alpha")
+		}
+		if expected, actual := uint32(3), md.ChannelCount; expected != actual {
+			t.Errorf("Expected channel count of %d but got %d", expected, actual)
+		}
+	})
+
+	t.Run("doesn't allocate the full length of chunks which extend past the end of the stream", func(t *testing.T) {
+		for _, c := range []struct {
+			name      string
+			flags     byte
+			chunkType [4]byte
+			length    uint32
+		}{
+			{"ICC profile", 0x20, chunkTypeICCP, 16 << 20},
+			{"XMP", 0x04, chunkTypeXMP, maxXMPChunkLength},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				data := &bytes.Buffer{}
+				data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00"))
+				data.Write([]byte{c.flags, 0x00, 0x00, 0x00, 0xaf, 0x04, 0x00, 0xaf, 0x04, 0x00})
+				if c.chunkType != chunkTypeICCP {
+					data.Write(chunkTypeVP8L[:])
+					binary.WriteU32Little(data, 5)
+					data.Write([]byte{0x2f, 0xaf, 0x44, 0xb1, 0x04, 0x00})
+				}
+				data.Write(c.chunkType[:])
+				binary.WriteU32Little(data, c.length)
+				data.WriteString("data")
+
+				var before, after runtime.MemStats
+				runtime.ReadMemStats(&before)
+				_, _ = extractMetadata(data, meta.DefaultLimits)
+				runtime.ReadMemStats(&after)
+
+				if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+					t.Errorf("Expected less than 1MB to be allocated but got %d bytes", allocated)
+				}
+			})
+		}
+	})
+
+	t.Run("returns truncated error for ICC profile chunk which extends past the end of the stream", func(t *testing.T) {
+		data := &bytes.Buffer{}
+		data.Write([]byte("RIFF\xc0Z\x04\x00WEBPVP8X\x0a\x00\x00\x00\x20\x00\x00\x00\xaf\x04\x00\xaf\x04\x00"))
+		data.Write(chunkTypeICCP[:])
+		binary.WriteU32Little(data, 16<<20)
+		data.WriteString("data")
+
+		md, err := extractMetadata(data, meta.DefaultLimits)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if _, err := md.ICCProfileData(); !errors.Is(err, meta.ErrTruncated) {
+			t.Errorf("Expected truncated error but got %v", err)
+		}
+	})
 }
@@ -0,0 +1,3 @@
+// Package xmp provides support for working with the colour-related properties
+// of embedded XMP metadata.
+package xmp
@@ -0,0 +1,16 @@
+package xmp
+
+// GainMap holds the parameters of an HDR gain map, as described by Adobe's
+// hdrgm XMP namespace. Per-channel values are in red, green, blue order; where
+// a single value is specified, it applies to all channels.
+type GainMap struct {
+	Version            string
+	GainMapMin         [3]float64
+	GainMapMax         [3]float64
+	Gamma              [3]float64
+	OffsetSDR          [3]float64
+	OffsetHDR          [3]float64
+	HDRCapacityMin     float64
+	HDRCapacityMax     float64
+	BaseRenditionIsHDR bool
+}
@@ -0,0 +1,10 @@
+package xmp
+
+const (
+	namespaceEXIF      = "http://ns.adobe.com/exif/1.0/"
+	namespaceHDRGM     = "http://ns.adobe.com/hdr-gain-map/1.0/"
+	namespacePhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	namespaceRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	namespaceTIFF      = "http://ns.adobe.com/tiff/1.0/"
+	namespaceXMPNote   = "http://ns.adobe.com/xmp/note/"
+)
@@ -0,0 +1,288 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/mandykoh/prism/ciexyy"
+	"github.com/mandykoh/prism/meta/exif"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Properties holds the colour-related properties extracted from XMP metadata.
+// Properties which were not present are left with their zero values.
+type Properties struct {
+	// ICCProfileName is the name of the image's ICC profile, from the
+	// photoshop:ICCProfile property.
+	ICCProfileName string
+
+	// ColorSpace is the value of the exif:ColorSpace property.
+	ColorSpace exif.ColorSpace
+
+	// Orientation is the value of the tiff:Orientation property.
+	Orientation exif.Orientation
+
+	// BitsPerSample is the value of the tiff:BitsPerSample property.
+	BitsPerSample []uint32
+
+	// PhotometricInterpretation is the value of the
+	// tiff:PhotometricInterpretation property.
+	PhotometricInterpretation uint16
+
+	// WhitePoint is the value of the tiff:WhitePoint property, or nil if not
+	// specified.
+	WhitePoint *ciexyy.Color
+
+	// PrimaryChromaticities is the value of the tiff:PrimaryChromaticities
+	// property in red, green, blue order, or nil if not specified.
+	PrimaryChromaticities *[3]ciexyy.Color
+
+	// GainMap holds the properties from the hdrgm namespace, or nil if the
+	// image does not describe an HDR gain map.
+	GainMap *GainMap
+
+	// HasExtendedXMP is the GUID of the extended XMP packet accompanying this
+	// one, from the xmpNote:HasExtendedXMP property.
+	HasExtendedXMP string
+}
+
+// Parse extracts the colour-related properties from one or more XMP packets,
+// such as the standard and extended packets of a JPEG image. Where a property
+// appears in more than one packet, the value from the last takes precedence.
+//
+// Invalid property values are ignored, but an error is returned if a packet
+// is not well-formed XML.
+func Parse(packets ...[]byte) (*Properties, error) {
+	values := make(map[xml.Name][]string)
+
+	for _, data := range packets {
+		packetValues, err := readValues(data)
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range packetValues {
+			values[name] = v
+		}
+	}
+
+	p := &Properties{}
+
+	p.ICCProfileName = firstValue(values, namespacePhotoshop, "ICCProfile")
+	p.HasExtendedXMP = firstValue(values, namespaceXMPNote, "HasExtendedXMP")
+
+	if v, err := strconv.ParseUint(firstValue(values, namespaceEXIF, "ColorSpace"), 10, 16); err == nil {
+		p.ColorSpace = exif.ColorSpace(v)
+	}
+
+	if v, err := strconv.ParseUint(firstValue(values, namespaceTIFF, "Orientation"), 10, 16); err == nil {
+		p.Orientation = exif.Orientation(v)
+	}
+
+	if v, err := strconv.ParseUint(firstValue(values, namespaceTIFF, "PhotometricInterpretation"), 10, 16); err == nil {
+		p.PhotometricInterpretation = uint16(v)
+	}
+
+	for _, s := range values[xml.Name{Space: namespaceTIFF, Local: "BitsPerSample"}] {
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			p.BitsPerSample = nil
+			break
+		}
+		p.BitsPerSample = append(p.BitsPerSample, uint32(v))
+	}
+
+	if v, ok := rationalValues(values, namespaceTIFF, "WhitePoint", 2); ok {
+		p.WhitePoint = &ciexyy.Color{X: float32(v[0]), Y: float32(v[1]), YY: 1}
+	}
+
+	if v, ok := rationalValues(values, namespaceTIFF, "PrimaryChromaticities", 6); ok {
+		p.PrimaryChromaticities = &[3]ciexyy.Color{
+			{X: float32(v[0]), Y: float32(v[1]), YY: 1},
+			{X: float32(v[2]), Y: float32(v[3]), YY: 1},
+			{X: float32(v[4]), Y: float32(v[5]), YY: 1},
+		}
+	}
+
+	p.GainMap = readGainMap(values)
+
+	return p, nil
+}
+
+// readGainMap returns the gain map properties, applying the defaults specified
+// for the hdrgm namespace, or nil if no gain map is described.
+func readGainMap(values map[xml.Name][]string) *GainMap {
+	version := firstValue(values, namespaceHDRGM, "Version")
+	gainMapMax, ok := channelValues(values, "GainMapMax")
+	if version == "" || !ok {
+		return nil
+	}
+
+	gm := &GainMap{
+		Version:    version,
+		GainMapMax: gainMapMax,
+		Gamma:      [3]float64{1, 1, 1},
+		OffsetSDR:  [3]float64{1.0 / 64, 1.0 / 64, 1.0 / 64},
+		OffsetHDR:  [3]float64{1.0 / 64, 1.0 / 64, 1.0 / 64},
+	}
+
+	if v, ok := channelValues(values, "GainMapMin"); ok {
+		gm.GainMapMin = v
+	}
+	if v, ok := channelValues(values, "Gamma"); ok {
+		gm.Gamma = v
+	}
+	if v, ok := channelValues(values, "OffsetSDR"); ok {
+		gm.OffsetSDR = v
+	}
+	if v, ok := channelValues(values, "OffsetHDR"); ok {
+		gm.OffsetHDR = v
+	}
+
+	if v, err := strconv.ParseFloat(firstValue(values, namespaceHDRGM, "HDRCapacityMin"), 64); err == nil {
+		gm.HDRCapacityMin = v
+	}
+
+	gm.HDRCapacityMax = gm.GainMapMax[0]
+	for _, v := range gm.GainMapMax[1:] {
+		if v > gm.HDRCapacityMax {
+			gm.HDRCapacityMax = v
+		}
+	}
+	if v, err := strconv.ParseFloat(firstValue(values, namespaceHDRGM, "HDRCapacityMax"), 64); err == nil {
+		gm.HDRCapacityMax = v
+	}
+
+	gm.BaseRenditionIsHDR = strings.EqualFold(firstValue(values, namespaceHDRGM, "BaseRenditionIsHDR"), "True")
+
+	return gm
+}
+
+// readValues reads the values of the simple and array-valued properties of
+// each rdf:Description in an XMP packet. Properties may be expressed either as
+// attributes or as child elements of the description.
+func readValues(data []byte) (map[xml.Name][]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	values := make(map[xml.Name][]string)
+
+	var stack []xml.Name
+	text := strings.Builder{}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid XMP packet: %v", err)
+		}
+
+		switch t := token.(type) {
+
+		case xml.StartElement:
+			if isDescription(t.Name) {
+				for _, attr := range t.Attr {
+					if attr.Name.Space != namespaceRDF && attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" {
+						values[attr.Name] = []string{attr.Value}
+					}
+				}
+			}
+
+			stack = append(stack, t.Name)
+			text.Reset()
+
+		case xml.CharData:
+			text.Write(t)
+
+		case xml.EndElement:
+			n := len(stack)
+
+			switch {
+
+			// Item of an rdf:Seq, rdf:Bag or rdf:Alt array
+			case t.Name == (xml.Name{Space: namespaceRDF, Local: "li"}) && n >= 4 && isDescription(stack[n-4]):
+				property := stack[n-3]
+				values[property] = append(values[property], strings.TrimSpace(text.String()))
+
+			// Simple property expressed as an element
+			case t.Name.Space != namespaceRDF && n >= 2 && isDescription(stack[n-2]):
+				if _, ok := values[t.Name]; !ok {
+					values[t.Name] = []string{strings.TrimSpace(text.String())}
+				}
+			}
+
+			stack = stack[:n-1]
+		}
+	}
+}
+
+func isDescription(name xml.Name) bool {
+	return name == xml.Name{Space: namespaceRDF, Local: "Description"}
+}
+
+func firstValue(values map[xml.Name][]string, namespace, local string) string {
+	v := values[xml.Name{Space: namespace, Local: local}]
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+// channelValues returns a per-channel hdrgm property, which may be specified
+// either as a single value or as a sequence of three.
+func channelValues(values map[xml.Name][]string, local string) (result [3]float64, ok bool) {
+	v := values[xml.Name{Space: namespaceHDRGM, Local: local}]
+
+	switch len(v) {
+	case 1:
+		f, err := strconv.ParseFloat(v[0], 64)
+		if err != nil {
+			return result, false
+		}
+		return [3]float64{f, f, f}, true
+
+	case 3:
+		for i := range result {
+			f, err := strconv.ParseFloat(v[i], 64)
+			if err != nil {
+				return result, false
+			}
+			result[i] = f
+		}
+		return result, true
+
+	default:
+		return result, false
+	}
+}
+
+// rationalValues returns the values of an array property consisting of the
+// specified number of rationals in "numerator/denominator" form.
+func rationalValues(values map[xml.Name][]string, namespace, local string, count int) ([]float64, bool) {
+	v := values[xml.Name{Space: namespace, Local: local}]
+	if len(v) != count {
+		return nil, false
+	}
+
+	result := make([]float64, count)
+	for i, s := range v {
+		parts := strings.SplitN(s, "/", 2)
+
+		numerator, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, false
+		}
+
+		denominator := 1.0
+		if len(parts) == 2 {
+			denominator, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil || denominator == 0 {
+				return nil, false
+			}
+		}
+
+		result[i] = numerator / denominator
+	}
+
+	return result, true
+}
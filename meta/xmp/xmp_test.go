@@ -0,0 +1,207 @@
+package xmp
+
+import (
+	"github.com/mandykoh/prism/meta/exif"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	t.Run("extracts properties expressed as attributes", func(t *testing.T) {
+		packet := []byte(`<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:tiff="http://ns.adobe.com/tiff/1.0/"
+   photoshop:ICCProfile="Adobe RGB (1998)"
+   exif:ColorSpace="65535"
+   tiff:Orientation="6"
+   tiff:PhotometricInterpretation="2"/>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+
+		p, err := Parse(packet)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := "Adobe RGB (1998)", p.ICCProfileName; expected != actual {
+			t.Errorf("Expected ICC profile name '%s' but got '%s'", expected, actual)
+		}
+		if expected, actual := exif.ColorSpaceUncalibrated, p.ColorSpace; expected != actual {
+			t.Errorf("Expected colour space %v but got %v", expected, actual)
+		}
+		if expected, actual := exif.OrientationRightTop, p.Orientation; expected != actual {
+			t.Errorf("Expected orientation %v but got %v", expected, actual)
+		}
+		if expected, actual := uint16(2), p.PhotometricInterpretation; expected != actual {
+			t.Errorf("Expected photometric interpretation %d but got %d", expected, actual)
+		}
+		if p.GainMap != nil {
+			t.Errorf("Expected no gain map but got %+v", *p.GainMap)
+		}
+	})
+
+	t.Run("extracts properties expressed as elements and arrays", func(t *testing.T) {
+		packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:t="http://ns.adobe.com/tiff/1.0/" xmlns:e="http://ns.adobe.com/exif/1.0/">
+   <e:ColorSpace>1</e:ColorSpace>
+   <t:BitsPerSample>
+    <rdf:Seq>
+     <rdf:li>16</rdf:li>
+     <rdf:li>16</rdf:li>
+     <rdf:li>16</rdf:li>
+    </rdf:Seq>
+   </t:BitsPerSample>
+   <t:WhitePoint>
+    <rdf:Seq>
+     <rdf:li>3127/10000</rdf:li>
+     <rdf:li>329/1000</rdf:li>
+    </rdf:Seq>
+   </t:WhitePoint>
+   <t:PrimaryChromaticities>
+    <rdf:Seq>
+     <rdf:li>64/100</rdf:li>
+     <rdf:li>33/100</rdf:li>
+     <rdf:li>30/100</rdf:li>
+     <rdf:li>60/100</rdf:li>
+     <rdf:li>15/100</rdf:li>
+     <rdf:li>6/100</rdf:li>
+    </rdf:Seq>
+   </t:PrimaryChromaticities>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`)
+
+		p, err := Parse(packet)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := exif.ColorSpaceSRGB, p.ColorSpace; expected != actual {
+			t.Errorf("Expected colour space %v but got %v", expected, actual)
+		}
+		if expected, actual := 3, len(p.BitsPerSample); expected != actual {
+			t.Errorf("Expected %d bits per sample values but got %d", expected, actual)
+		} else if expected, actual := uint32(16), p.BitsPerSample[2]; expected != actual {
+			t.Errorf("Expected %d bits per sample but got %d", expected, actual)
+		}
+		if p.WhitePoint == nil {
+			t.Errorf("Expected a white point but got none")
+		} else if p.WhitePoint.X != 0.3127 || p.WhitePoint.Y != 0.329 {
+			t.Errorf("Expected D65 white point but got %+v", *p.WhitePoint)
+		}
+		if p.PrimaryChromaticities == nil {
+			t.Errorf("Expected primary chromaticities but got none")
+		} else if expected, actual := float32(0.6), p.PrimaryChromaticities[1].Y; expected != actual {
+			t.Errorf("Expected green y of %v but got %v", expected, actual)
+		}
+	})
+
+	t.Run("extracts gain map properties with defaults", func(t *testing.T) {
+		packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:hdrgm="http://ns.adobe.com/hdr-gain-map/1.0/"
+   hdrgm:Version="1.0"
+   hdrgm:HDRCapacityMin="0.5">
+   <hdrgm:GainMapMax>
+    <rdf:Seq>
+     <rdf:li>2.5</rdf:li>
+     <rdf:li>3</rdf:li>
+     <rdf:li>2</rdf:li>
+    </rdf:Seq>
+   </hdrgm:GainMapMax>
+   <hdrgm:Gamma>2</hdrgm:Gamma>
+   <hdrgm:BaseRenditionIsHDR>True</hdrgm:BaseRenditionIsHDR>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`)
+
+		p, err := Parse(packet)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if p.GainMap == nil {
+			t.Fatalf("Expected a gain map but got none")
+		}
+
+		gm := *p.GainMap
+		if expected, actual := "1.0", gm.Version; expected != actual {
+			t.Errorf("Expected version '%s' but got '%s'", expected, actual)
+		}
+		if expected, actual := [3]float64{2.5, 3, 2}, gm.GainMapMax; expected != actual {
+			t.Errorf("Expected maximum gain %v but got %v", expected, actual)
+		}
+		if expected, actual := [3]float64{}, gm.GainMapMin; expected != actual {
+			t.Errorf("Expected minimum gain %v but got %v", expected, actual)
+		}
+		if expected, actual := [3]float64{2, 2, 2}, gm.Gamma; expected != actual {
+			t.Errorf("Expected gamma %v but got %v", expected, actual)
+		}
+		if expected, actual := [3]float64{1.0 / 64, 1.0 / 64, 1.0 / 64}, gm.OffsetSDR; expected != actual {
+			t.Errorf("Expected SDR offset %v but got %v", expected, actual)
+		}
+		if expected, actual := 0.5, gm.HDRCapacityMin; expected != actual {
+			t.Errorf("Expected minimum HDR capacity %v but got %v", expected, actual)
+		}
+		if expected, actual := 3.0, gm.HDRCapacityMax; expected != actual {
+			t.Errorf("Expected maximum HDR capacity %v but got %v", expected, actual)
+		}
+		if !gm.BaseRenditionIsHDR {
+			t.Errorf("Expected base rendition to be HDR")
+		}
+	})
+
+	t.Run("gives precedence to properties from later packets", func(t *testing.T) {
+		standard := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ <rdf:Description xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/" xmlns:xmpNote="http://ns.adobe.com/xmp/note/"
+  photoshop:ICCProfile="sRGB IEC61966-2.1"
+  xmpNote:HasExtendedXMP="0123456789ABCDEF0123456789ABCDEF"/>
+</rdf:RDF>`)
+		extended := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ <rdf:Description xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">
+  <photoshop:ICCProfile>Display P3</photoshop:ICCProfile>
+ </rdf:Description>
+</rdf:RDF>`)
+
+		p, err := Parse(standard, extended)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if expected, actual := "Display P3", p.ICCProfileName; expected != actual {
+			t.Errorf("Expected ICC profile name '%s' but got '%s'", expected, actual)
+		}
+		if expected, actual := "0123456789ABCDEF0123456789ABCDEF", p.HasExtendedXMP; expected != actual {
+			t.Errorf("Expected extended XMP GUID '%s' but got '%s'", expected, actual)
+		}
+	})
+
+	t.Run("ignores properties from other namespaces", func(t *testing.T) {
+		packet := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ <rdf:Description xmlns:photoshop="http://example.com/not-photoshop/" photoshop:ICCProfile="Bogus"/>
+</rdf:RDF>`)
+
+		p, err := Parse(packet)
+
+		if err != nil {
+			t.Fatalf("Expected success but got error: %v", err)
+		}
+		if actual := p.ICCProfileName; actual != "" {
+			t.Errorf("Expected no ICC profile name but got '%s'", actual)
+		}
+	})
+
+	t.Run("returns error for malformed packet", func(t *testing.T) {
+		_, err := Parse([]byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description>`))
+
+		if err == nil {
+			t.Errorf("Expected error but succeeded")
+		}
+	})
+}
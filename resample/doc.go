@@ -0,0 +1,3 @@
+// Package resample provides support for resizing images correctly, by
+// performing the resampling on linear, alpha premultiplied colour.
+package resample
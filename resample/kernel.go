@@ -0,0 +1,118 @@
+package resample
+
+import "math"
+
+// Kernel represents a separable resampling filter.
+type Kernel struct {
+	// Support is the radius of the kernel; At is zero for values outside
+	// -Support–Support.
+	Support float64
+
+	// At returns the weight of the kernel at the specified distance from its
+	// centre, in units of source pixels.
+	At func(t float64) float64
+}
+
+// Box is a kernel which averages all source pixels covered by each
+// destination pixel. When enlarging, this is equivalent to nearest neighbour
+// sampling.
+var Box = &Kernel{
+	Support: 0.5,
+	At: func(t float64) float64 {
+		if t >= -0.5 && t < 0.5 {
+			return 1
+		}
+		return 0
+	},
+}
+
+// BiLinear is the tent kernel, which produces smooth but slightly soft
+// results.
+var BiLinear = &Kernel{
+	Support: 1,
+	At: func(t float64) float64 {
+		if t = math.Abs(t); t < 1 {
+			return 1 - t
+		}
+		return 0
+	},
+}
+
+// CatmullRom is the Catmull-Rom cubic kernel, which produces sharper results
+// than BiLinear at the cost of slight ringing around edges.
+var CatmullRom = &Kernel{
+	Support: 2,
+	At: func(t float64) float64 {
+		t = math.Abs(t)
+		if t < 1 {
+			return (1.5*t-2.5)*t*t + 1
+		}
+		if t < 2 {
+			return ((-0.5*t+2.5)*t-4)*t + 2
+		}
+		return 0
+	},
+}
+
+// Lanczos3 is the three-lobed Lanczos windowed sinc kernel, which produces the
+// sharpest results at the cost of the most ringing around edges.
+var Lanczos3 = &Kernel{
+	Support: 3,
+	At: func(t float64) float64 {
+		t = math.Abs(t)
+		if t == 0 {
+			return 1
+		}
+		if t < 3 {
+			pt := math.Pi * t
+			return 3 * math.Sin(pt) * math.Sin(pt/3) / (pt * pt)
+		}
+		return 0
+	},
+}
+
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// contributions returns the weighted source pixels contributing to each pixel
+// when resampling a row or column of srcSize pixels into dstSize pixels.
+func (k *Kernel) contributions(dstSize, srcSize int) []contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	filterScale := math.Max(scale, 1)
+	support := k.Support * filterScale
+
+	result := make([]contribution, dstSize)
+
+	for i := range result {
+		centre := (float64(i) + 0.5) * scale
+
+		start := int(math.Floor(centre - support))
+		if start < 0 {
+			start = 0
+		}
+		end := int(math.Ceil(centre + support))
+		if end > srcSize {
+			end = srcSize
+		}
+
+		weights := make([]float32, 0, end-start)
+		sum := 0.0
+		for j := start; j < end; j++ {
+			w := k.At((float64(j) + 0.5 - centre) / filterScale)
+			weights = append(weights, float32(w))
+			sum += w
+		}
+
+		if sum != 0 {
+			for j := range weights {
+				weights[j] = float32(float64(weights[j]) / sum)
+			}
+		}
+
+		result[i] = contribution{start: start, weights: weights}
+	}
+
+	return result
+}
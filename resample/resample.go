@@ -0,0 +1,121 @@
+package resample
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism/colorspace"
+	"github.com/mandykoh/prism/linear"
+	"image"
+	"image/draw"
+)
+
+// Resize resamples an encoded image to the size of another. The resampling is
+// performed on linear, alpha premultiplied colour, so that (for example)
+// averaging a checkerboard of black and white pixels produces the correct mid
+// tone, and transparent pixels don’t darken the edges of opaque ones.
+//
+// Straight alpha images (image.NRGBA and image.NRGBA64) are read and written
+// as such. Colour values of other images are assumed to be associated with
+// alpha in linear light (see linear.LinearAlphaAssociation).
+//
+// src is the encoded image to be resampled, in its entirety.
+//
+// dst is the image to write the result to, filling its entire bounds.
+//
+// space is the colour space in which both src and dst are encoded.
+//
+// kernel is the resampling filter to use, eg Box or Lanczos3.
+//
+// parallelism specifies the degree of parallel processing; a value of 4
+// indicates that processing will be spread across four threads.
+func Resize(dst draw.Image, src image.Image, space colorspace.RGB, kernel *Kernel, parallelism int) {
+	srcBounds := src.Bounds()
+	dstBounds := dst.Bounds()
+
+	srcWidth, srcHeight := srcBounds.Dx(), srcBounds.Dy()
+	dstWidth, dstHeight := dstBounds.Dx(), dstBounds.Dy()
+	if srcWidth <= 0 || srcHeight <= 0 || dstWidth <= 0 || dstHeight <= 0 {
+		return
+	}
+
+	columns := kernel.contributions(dstWidth, srcWidth)
+	rows := kernel.contributions(dstHeight, srcHeight)
+
+	readPixel := space.LinearPixelReader(src)
+	writePixel := space.EncodedPixelWriter(dst)
+
+	// Horizontal pass, from the source image into an intermediate buffer of
+	// destination width and source height
+	tmp := make([]float32, dstWidth*srcHeight*4)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		srcRow := make([]float32, srcWidth*4)
+
+		for i := workerNum; i < srcHeight; i += workerCount {
+			for j := 0; j < srcWidth; j++ {
+				c, alpha := readPixel(srcBounds.Min.X+j, srcBounds.Min.Y+i)
+				srcRow[j*4] = c.R * alpha
+				srcRow[j*4+1] = c.G * alpha
+				srcRow[j*4+2] = c.B * alpha
+				srcRow[j*4+3] = alpha
+			}
+
+			tmpRow := tmp[i*dstWidth*4 : (i+1)*dstWidth*4]
+			for j, c := range columns {
+				var sum [4]float32
+				for k, w := range c.weights {
+					offset := (c.start + k) * 4
+					sum[0] += srcRow[offset] * w
+					sum[1] += srcRow[offset+1] * w
+					sum[2] += srcRow[offset+2] * w
+					sum[3] += srcRow[offset+3] * w
+				}
+				copy(tmpRow[j*4:j*4+4], sum[:])
+			}
+		}
+	})
+
+	// Vertical pass, from the intermediate buffer into the destination image
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < dstHeight; i += workerCount {
+			r := rows[i]
+
+			for j := 0; j < dstWidth; j++ {
+				var sum [4]float32
+				for k, w := range r.weights {
+					offset := ((r.start+k)*dstWidth + j) * 4
+					sum[0] += tmp[offset] * w
+					sum[1] += tmp[offset+1] * w
+					sum[2] += tmp[offset+2] * w
+					sum[3] += tmp[offset+3] * w
+				}
+				c, alpha := unpremultiply(sum)
+				writePixel(dstBounds.Min.X+j, dstBounds.Min.Y+i, c, alpha)
+			}
+		}
+	})
+}
+
+// unpremultiply clamps a linear, alpha premultiplied colour value (which may
+// have overshot due to negative kernel lobes) and returns its straight colour
+// and alpha.
+func unpremultiply(c [4]float32) (col linear.RGB, alpha float32) {
+	alpha = c[3]
+	if alpha <= 0 {
+		return linear.RGB{}, 0
+	}
+	if alpha > 1 {
+		alpha = 1
+	}
+
+	clamp := func(v float32) float32 {
+		if v < 0 {
+			return 0
+		}
+		if v > alpha {
+			return 1
+		}
+		return v / alpha
+	}
+
+	return linear.RGB{R: clamp(c[0]), G: clamp(c[1]), B: clamp(c[2])}, alpha
+}
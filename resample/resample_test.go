@@ -0,0 +1,87 @@
+package resample
+
+import (
+	"github.com/mandykoh/prism/colorspace"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestKernel(t *testing.T) {
+
+	t.Run("contributions()", func(t *testing.T) {
+
+		t.Run("produces normalised weights", func(t *testing.T) {
+			for _, k := range []*Kernel{Box, BiLinear, CatmullRom, Lanczos3} {
+				for _, sizes := range [][2]int{{10, 37}, {37, 10}, {16, 16}} {
+					for i, c := range k.contributions(sizes[0], sizes[1]) {
+						sum := float32(0)
+						for _, w := range c.weights {
+							sum += w
+						}
+						if math.Abs(float64(sum)-1) > 0.0001 {
+							t.Errorf("Expected weights for pixel %d scaling %d to %d to sum to 1 but got %v", i, sizes[1], sizes[0], sum)
+						}
+					}
+				}
+			}
+		})
+	})
+}
+
+func TestResize(t *testing.T) {
+
+	t.Run("averages in linear light", func(t *testing.T) {
+		src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+		src.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		src.SetNRGBA(1, 0, color.NRGBA{A: 255})
+		src.SetNRGBA(0, 1, color.NRGBA{A: 255})
+		src.SetNRGBA(1, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		for _, k := range []*Kernel{Box, BiLinear} {
+			dst := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+			Resize(dst, src, colorspace.SRGB, k, 2)
+
+			if expected, actual := (color.NRGBA{R: 188, G: 188, B: 188, A: 255}), dst.NRGBAAt(0, 0); expected != actual {
+				t.Errorf("Expected %+v but got %+v", expected, actual)
+			}
+		}
+	})
+
+	t.Run("doesn't darken colours next to transparent pixels", func(t *testing.T) {
+		src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		src.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 128, A: 255})
+		src.SetNRGBA(1, 0, color.NRGBA{})
+
+		dst := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		Resize(dst, src, colorspace.SRGB, Box, 2)
+
+		if expected, actual := (color.NRGBA{R: 255, G: 128, A: 128}), dst.NRGBAAt(0, 0); expected != actual {
+			t.Errorf("Expected %+v but got %+v", expected, actual)
+		}
+	})
+
+	t.Run("preserves flat colour with all kernels", func(t *testing.T) {
+		src := image.NewRGBA64(image.Rect(0, 0, 13, 7))
+		for i := 0; i < 7; i++ {
+			for j := 0; j < 13; j++ {
+				src.SetRGBA64(j, i, color.RGBA64{R: 40000, G: 20000, B: 10000, A: 65535})
+			}
+		}
+
+		for _, k := range []*Kernel{Box, BiLinear, CatmullRom, Lanczos3} {
+			dst := image.NewRGBA64(image.Rect(5, 5, 31, 9))
+			Resize(dst, src, colorspace.DisplayP3, k, 3)
+
+			for i := dst.Rect.Min.Y; i < dst.Rect.Max.Y; i++ {
+				for j := dst.Rect.Min.X; j < dst.Rect.Max.X; j++ {
+					c := dst.RGBA64At(j, i)
+					if math.Abs(float64(c.R)-40000) > 2 || math.Abs(float64(c.G)-20000) > 2 || math.Abs(float64(c.B)-10000) > 2 || c.A != 65535 {
+						t.Fatalf("Expected colour to be preserved at %d,%d but got %+v", j, i, c)
+					}
+				}
+			}
+		}
+	})
+}
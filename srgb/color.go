@@ -27,6 +27,15 @@ func (c Color) ToRGBA(alpha float32) color.RGBA {
 	return c.RGB.ToEncodedRGBA(alpha, To8Bit)
 }
 
+// ToRGBAAssociated returns an encoded 8-bit RGBA representation of this colour
+// suitable for use with instances of image.RGBA, with the colour values
+// associated with alpha using the specified convention.
+//
+// alpha is the normalised alpha value and will be clipped to 0.0–1.0.
+func (c Color) ToRGBAAssociated(alpha float32, assoc linear.AlphaAssociation) color.RGBA {
+	return c.RGB.ToEncodedRGBAAssociated(alpha, assoc, To8Bit)
+}
+
 // ToRGBA64 returns an encoded 16-bit RGBA representation of this colour
 // suitable for use with instances of image.RGBA64.
 //
@@ -35,6 +44,15 @@ func (c Color) ToRGBA64(alpha float32) color.RGBA64 {
 	return c.RGB.ToEncodedRGBA64(alpha, To16Bit)
 }
 
+// ToRGBA64Associated returns an encoded 16-bit RGBA representation of this
+// colour suitable for use with instances of image.RGBA64, with the colour
+// values associated with alpha using the specified convention.
+//
+// alpha is the normalised alpha value and will be clipped to 0.0–1.0.
+func (c Color) ToRGBA64Associated(alpha float32, assoc linear.AlphaAssociation) color.RGBA64 {
+	return c.RGB.ToEncodedRGBA64Associated(alpha, assoc, To16Bit)
+}
+
 // ToXYZ returns a CIE XYZ representation of this colour.
 func (c Color) ToXYZ() ciexyz.Color {
 	return ciexyz.Color{
@@ -52,6 +70,15 @@ func ColorFromEncodedColor(c color.Color) (col Color, alpha float32) {
 	return Color{rgb}, a
 }
 
+// ColorFromEncodedColorAssociated creates a Color instance from an sRGB encoded
+// color.Color value whose colour values are associated with alpha using the
+// specified convention. The alpha value is returned as a normalised value
+// between 0.0–1.0.
+func ColorFromEncodedColorAssociated(c color.Color, assoc linear.AlphaAssociation) (col Color, alpha float32) {
+	rgb, a := linear.RGBFromEncodedAssociated(c, assoc, From16Bit)
+	return Color{rgb}, a
+}
+
 // ColorFromLinear creates a Color instance from a linear normalised RGB
 // triplet.
 func ColorFromLinear(r, g, b float32) Color {
@@ -98,6 +125,17 @@ func ColorFromRGBA(c color.RGBA) (col Color, alpha float32) {
 		alpha
 }
 
+// ColorFromRGBAAssociated creates a Color instance by interpreting an 8-bit
+// RGBA colour as sRGB encoded, with the colour values associated with alpha
+// using the specified convention. The alpha value is returned as a normalised
+// value between 0.0–1.0.
+func ColorFromRGBAAssociated(c color.RGBA, assoc linear.AlphaAssociation) (col Color, alpha float32) {
+	if assoc == linear.LinearAlphaAssociation {
+		return ColorFromRGBA(c)
+	}
+	return ColorFromEncodedColorAssociated(c, assoc)
+}
+
 // ColorFromXYZ creates an sRGB Color instance from a CIE XYZ colour.
 func ColorFromXYZ(c ciexyz.Color) Color {
 	return ColorFromLinear(
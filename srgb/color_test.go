@@ -1,6 +1,7 @@
 package srgb
 
 import (
+	"github.com/mandykoh/prism/linear"
 	"image/color"
 	"math"
 	"testing"
@@ -92,6 +93,64 @@ func TestColor(t *testing.T) {
 				c := ColorFromLinear(a, a, a)
 				actual := c.ToRGBA(a)
 
+				if expected != actual {
+					t.Errorf("Expected normalised %+v with alpha %v to map to %+v but was %+v", c, a, expected, actual)
+				}
+			}
+		})
+	})
+	t.Run("ColorFromRGBAAssociated()", func(t *testing.T) {
+
+		t.Run("matches ColorFromRGBA() for linear association", func(t *testing.T) {
+			for i := 0; i < 256; i++ {
+				rgba := color.RGBA{R: uint8(i / 2), G: uint8(i / 3), B: uint8(i), A: uint8(i)}
+
+				expected, expectedAlpha := ColorFromRGBA(rgba)
+				actual, actualAlpha := ColorFromRGBAAssociated(rgba, linear.LinearAlphaAssociation)
+
+				if expected != actual || expectedAlpha != actualAlpha {
+					t.Errorf("Expected %+v to map to %+v but was %+v", rgba, expected, actual)
+				}
+			}
+		})
+
+		t.Run("matches the equivalent NRGBA colour for encoded association", func(t *testing.T) {
+			for i := 1; i < 256; i++ {
+				nrgba := color.NRGBA{R: 255, G: 128, B: 0, A: uint8(i)}
+				rgba := color.RGBAModel.Convert(nrgba).(color.RGBA)
+
+				expected, expectedAlpha := ColorFromNRGBA(nrgba)
+				actual, actualAlpha := ColorFromRGBAAssociated(rgba, linear.EncodedAlphaAssociation)
+
+				if math.Abs(float64(expected.R-actual.R)) > 0.0001 || math.Abs(float64(expected.B-actual.B)) > 0.0001 {
+					t.Errorf("Expected %+v to map to %+v but was %+v", rgba, expected, actual)
+				}
+				if i >= 64 && math.Abs(float64(expected.G-actual.G)) > 0.01 {
+					t.Errorf("Expected %+v to map to %+v but was %+v", rgba, expected, actual)
+				}
+				if math.Abs(float64(expectedAlpha)-float64(actualAlpha)) > 0.0001 {
+					t.Errorf("Expected alpha %d to map to %v but was %v", rgba.A, expectedAlpha, actualAlpha)
+				}
+			}
+		})
+	})
+
+	t.Run("ToRGBAAssociated()", func(t *testing.T) {
+
+		t.Run("premultiplies encoded values for encoded association", func(t *testing.T) {
+			for i := 0; i < 256; i++ {
+				c, a := ColorFromNRGBA(color.NRGBA{R: 255, G: 128, B: 0, A: uint8(i)})
+
+				nrgba := c.ToNRGBA(a)
+				expected := color.RGBA{
+					R: uint8((uint32(nrgba.R)*uint32(nrgba.A) + 127) / 255),
+					G: uint8((uint32(nrgba.G)*uint32(nrgba.A) + 127) / 255),
+					B: uint8((uint32(nrgba.B)*uint32(nrgba.A) + 127) / 255),
+					A: nrgba.A,
+				}
+
+				actual := c.ToRGBAAssociated(a, linear.EncodedAlphaAssociation)
+
 				if expected != actual {
 					t.Errorf("Expected normalised %+v with alpha %v to map to %+v but was %+v", c, a, expected, actual)
 				}